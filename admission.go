@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+// Below are the allowed values for the admission setting of the Config
+const hostAdmission = "host"
+const lockFreeAdmission = "lockFree"
+const ticketAdmission = "ticket"
+
+// Admission lets the philosophers of a table start eating without asking the Host, which is the lock-free admission :
+// a philosopher claims each of his chopsticks and a place among the philosophers allowed to eat with compare-and-swap
+// operations on the state words shared with the Host, and only asks the Host when one of them is taken. The Host claims
+// the chopsticks the same way, so that both paths never give a chopstick twice.
+// The philosopher who ate without the Host gives back his chopsticks himself once his meal is over.
+// A nil Admission means that the philosophers always ask the Host.
+type Admission struct {
+	table    *Table
+	stopped  atomic.Bool
+	accepted atomic.Int64
+}
+
+// NewAdmission creates the lock-free admission of a table
+func NewAdmission(table *Table) *Admission {
+	return &Admission{table: table}
+}
+
+// TryEat claims the chopsticks of the philosopher and a place at the table, it returns false when one of them
+// is taken or when the dinner is stopped, the philosopher then has to ask the Host
+func (admission *Admission) TryEat(philosopher *Philosopher) (Grant, bool) {
+	if admission == nil || admission.stopped.Load() {
+		return Grant{}, false
+	}
+	grant, claimed := admission.claim(philosopher)
+	if claimed {
+		admission.accepted.Add(1)
+		philosopher.emit(eventAccepted, "")
+	}
+	return grant, claimed
+}
+
+// claim claims the chopsticks of the philosopher and a place at the table, without telling anyone
+func (admission *Admission) claim(philosopher *Philosopher) (Grant, bool) {
+	var table = admission.table
+	if !table.reserveEater() {
+		return Grant{}, false
+	}
+	var crossings = table.seating.crossings[philosopher.id]
+	for i, crossing := range crossings {
+		if !table.claims[crossing.chopStick.id].CompareAndSwap(false, true) {
+			unclaim(crossings[:i], table.claims)
+			table.eaters.Add(-1)
+			return Grant{}, false
+		}
+	}
+	return Grant{allowed: true, chopSticks: table.seating.utensils[philosopher.id]}, true
+}
+
+// Release gives back the chopsticks and the place at the table of a philosopher who ate without the Host
+func (admission *Admission) Release(philosopher *Philosopher) {
+	unclaim(admission.table.seating.crossings[philosopher.id], admission.table.claims)
+	admission.table.eaters.Add(-1)
+}
+
+// Stop makes the philosophers ask the Host from now on, so that the Host can send them away
+func (admission *Admission) Stop() {
+	if admission == nil {
+		return
+	}
+	admission.stopped.Store(true)
+}
+
+// Accepted returns how many philosophers started eating without asking the Host
+func (admission *Admission) Accepted() int {
+	if admission == nil {
+		return 0
+	}
+	return int(admission.accepted.Load())
+}
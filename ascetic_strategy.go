@@ -0,0 +1,42 @@
+//go:build ascetic
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// This file is an example of a strategy compiled in from outside the simulation, it only uses RegisterStrategy
+// and the Strategy interface. It is built with the ascetic tag :
+//
+//	go run -tags ascetic . -config examples/ascetic.json
+
+const asceticFast = 250 * time.Millisecond // how long an ascetic philosopher fasts after each meal
+
+func init() {
+	RegisterStrategy("ascetic", func(config Config, table int) Strategy {
+		return &AsceticStrategy{lastMeal: make(map[int]time.Time)}
+	})
+}
+
+// AsceticStrategy makes each philosopher fast for a while after each meal, whatever the utensils left free
+type AsceticStrategy struct {
+	lastMeal map[int]time.Time // when each philosopher released his utensils for the last time
+}
+
+// Admit refuses the philosophers who have not fasted long enough since their last meal
+func (strategy *AsceticStrategy) Admit(request StrategyRequest) (bool, string) {
+	if last, found := strategy.lastMeal[request.Seat]; found && request.Now.Sub(last) < asceticFast {
+		return false, fmt.Sprintf("%s fasts for another %v", request.Name, (asceticFast - request.Now.Sub(last)).Round(time.Millisecond))
+	}
+	return true, ""
+}
+
+// Served does nothing, the fast starts once the meal is over
+func (strategy *AsceticStrategy) Served(int, time.Time) {}
+
+// Released starts the fast of the philosopher
+func (strategy *AsceticStrategy) Released(seat int, now time.Time) {
+	strategy.lastMeal[seat] = now
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Below are the allowed values for the backoff setting of the Config
+const thinkBackoff = "think"               // the rejected philosopher thinks again as long as after a meal, the default
+const fixedBackoff = "fixed"               // he waits backoffBase before each retry
+const exponentialBackoff = "exponential"   // he waits a random duration up to backoffBase doubled at each retry
+const decorrelatedBackoff = "decorrelated" // he waits a random duration between backoffBase and 3 times his last wait
+const immediateBackoff = "immediate"       // he asks again at once, going back to the queue of the Host
+
+const defaultBackoffBase = 50 * time.Millisecond // the first wait of the backoffs which need one
+const defaultBackoffMax = time.Second            // the longest wait of the exponential and decorrelated backoffs
+
+// Backoff tells how long a philosopher whose request to eat was turned down, by the Host or by its rate limiter,
+// waits before asking again. The philosopher counts his retries for the current meal, starting at 1 after the first
+// rejection and starting afresh once he eats, and remembers his last wait, so that a Backoff holds no state of its own
+// and is shared by the philosophers of a table. The waits are drawn from the Random of the philosopher, or of his
+// worker in the worker pool execution, so that a seed gives the same waits on every run.
+type Backoff interface {
+	// Delay returns how long the philosopher waits before his retry, previous being his last wait for this meal
+	Delay(philosopher *Philosopher, random *Random, retry int, previous time.Duration) time.Duration
+}
+
+// NewBackoff creates the Backoff of the philosophers of a validated configuration, its durations at the speed of the dinner
+func NewBackoff(config Config) Backoff {
+	var base, ceiling = config.Scale(time.Duration(config.BackoffBase)), config.Scale(time.Duration(config.BackoffMax))
+	switch config.Backoff {
+	case fixedBackoff:
+		return FixedBackoff{delay: base}
+	case exponentialBackoff:
+		return ExponentialBackoff{base: base, ceiling: ceiling}
+	case decorrelatedBackoff:
+		return DecorrelatedBackoff{base: base, ceiling: ceiling}
+	case immediateBackoff:
+		return ImmediateBackoff{}
+	}
+	return ThinkBackoff{}
+}
+
+// validBackoff checks that the backoff is one of the known ones
+func validBackoff(name string) error {
+	switch name {
+	case thinkBackoff, fixedBackoff, exponentialBackoff, decorrelatedBackoff, immediateBackoff:
+		return nil
+	}
+	return fmt.Errorf("config: unknown backoff %q, expected %q, %q, %q, %q or %q", name, thinkBackoff, fixedBackoff,
+		exponentialBackoff, decorrelatedBackoff, immediateBackoff)
+}
+
+// ThinkBackoff lets the philosopher think again before he retries, as long as he thinks after a meal
+type ThinkBackoff struct{}
+
+// Delay draws a thinking time of the philosopher
+func (ThinkBackoff) Delay(philosopher *Philosopher, random *Random, _ int, _ time.Duration) time.Duration {
+	return philosopher.thinkingTime(random)
+}
+
+// FixedBackoff makes the philosopher wait the same delay before each retry
+type FixedBackoff struct {
+	delay time.Duration
+}
+
+// Delay returns the fixed delay
+func (backoff FixedBackoff) Delay(*Philosopher, *Random, int, time.Duration) time.Duration {
+	return backoff.delay
+}
+
+// ExponentialBackoff makes the philosopher wait a random duration up to base before his first retry, the bound
+// doubling at each retry until it reaches ceiling ("full jitter"), so that the philosophers rejected together
+// do not all ask again at the same time
+type ExponentialBackoff struct {
+	base    time.Duration
+	ceiling time.Duration
+}
+
+// Delay draws the wait of the retry
+func (backoff ExponentialBackoff) Delay(_ *Philosopher, random *Random, retry int, _ time.Duration) time.Duration {
+	var bound = backoff.base
+	for i := 1; i < retry && bound < backoff.ceiling; i++ {
+		bound *= 2
+	}
+	bound = min(bound, backoff.ceiling)
+	return time.Duration(random.Int63n(int64(bound) + 1))
+}
+
+// DecorrelatedBackoff makes the philosopher wait a random duration between base and 3 times his last wait, at most
+// ceiling ("decorrelated jitter"), the waits growing with the retries without depending on their number
+type DecorrelatedBackoff struct {
+	base    time.Duration
+	ceiling time.Duration
+}
+
+// Delay draws the wait of the retry from the previous one
+func (backoff DecorrelatedBackoff) Delay(_ *Philosopher, random *Random, _ int, previous time.Duration) time.Duration {
+	var bound = max(3*previous, backoff.base)
+	return min(backoff.ceiling, backoff.base+time.Duration(random.Int63n(int64(bound-backoff.base)+1)))
+}
+
+// ImmediateBackoff makes the philosopher ask again at once
+type ImmediateBackoff struct{}
+
+// Delay returns no wait
+func (ImmediateBackoff) Delay(*Philosopher, *Random, int, time.Duration) time.Duration { return 0 }
+
+// Retry is what a philosopher remembers of the rejections of his current meal, how many times he was turned down
+// and how long he waited before his last retry, along with the hint of an overloaded Host telling when to retry,
+// which he follows instead of his backoff
+type Retry struct {
+	count int
+	last  time.Duration
+	after time.Duration
+}
+
+// Rejected counts a rejection of the current meal
+func (retry *Retry) Rejected() {
+	retry.count++
+}
+
+// Reset forgets the rejections, the philosopher being allowed to eat
+func (retry *Retry) Reset() {
+	*retry = Retry{}
+}
+
+// nextWait returns how long the philosopher waits before asking to eat, thinking before his first request for a
+// meal and backing off after a rejection
+func (philosopher *Philosopher) nextWait(random *Random, retry *Retry) time.Duration {
+	if retry.after > 0 {
+		retry.last, retry.after = retry.after, 0
+		return retry.last
+	}
+	if retry.count == 0 || philosopher.backoff == nil {
+		return philosopher.thinkingTime(random)
+	}
+	retry.last = philosopher.backoff.Delay(philosopher, random, retry.count, retry.last)
+	return retry.last
+}
+
+// Retries tells how often the philosophers asked again after being turned down, with the backoff of the dinner :
+// - the name of the backoff
+// - how many retries there were, and how many meals were eaten
+// - the most retries of a single meal, and the name of the philosopher who made them
+type Retries struct {
+	Backoff string `json:"backoff"`
+	Retries int    `json:"retries"`
+	Meals   int    `json:"meals"`
+	Most    int    `json:"most"`
+	Name    string `json:"name"`
+}
+
+// NewRetries computes the Retries of the dinner from the reports of its philosophers
+func NewRetries(backoff string, reports []PhilosopherReport) Retries {
+	var retries = Retries{Backoff: backoff}
+	for _, report := range reports {
+		retries.Retries += report.Rejected + report.Throttled
+		retries.Meals += report.Meals
+		if report.MostRetries > retries.Most {
+			retries.Most, retries.Name = report.MostRetries, report.Name
+		}
+	}
+	return retries
+}
+
+// String gives a one line summary of the Retries
+func (retries Retries) String() string {
+	var summary = fmt.Sprintf("%s backoff, %d retries, %.2f per meal", retries.Backoff, retries.Retries,
+		float64(retries.Retries)/float64(max(retries.Meals, 1)))
+	if retries.Most > 0 {
+		summary += fmt.Sprintf(", at most %d before a meal (%s)", retries.Most, retries.Name)
+	}
+	return summary
+}
@@ -0,0 +1,160 @@
+//go:build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bench is the bench command, it measures how many requests to eat the Hosts of a large table decide per second,
+// how long a philosopher waits for the decision and how many allocations it takes, depending on the number of shards
+// and on the admission.
+// The philosophers are replaced by drivers asking to eat as fast as possible, each of them going round a contiguous
+// range of seats and finishing a meal as soon as he comes back to its seat, and the events are dropped so that only
+// the admission is measured.
+// The decisions of the warm-up and of the cool-down, before and after the measured duration, are left out of the
+// rate and of the latencies, the summary of the Hosts telling all of them.
+func bench(arguments []string) error {
+	var flags = flag.NewFlagSet("bench", flag.ExitOnError)
+	var philosophers = flags.Int("philosophers", 50000, "number of philosophers around the table")
+	var topology = flags.String("topology", "", "seating of the philosophers, ring:N, grid:WxH or torus:WxH (a ring by default)")
+	var shardCounts = flags.String("shards", "1,2,4,8", "numbers of shards to compare, separated by commas")
+	var admissions = flags.String("admission", hostAdmission, "admissions to compare, separated by commas (host, lockFree, ticket)")
+	var drivers = flags.Int("drivers", 4*runtime.GOMAXPROCS(0), "number of goroutines asking to eat")
+	var duration = flags.Duration("duration", 2*time.Second, "how long each number of shards is measured")
+	var warmup = flags.Duration("warmup", 0, "how long the table is driven before it is measured")
+	var cooldown = flags.Duration("cooldown", 0, "how long the table is driven after it is measured")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s bench [-philosophers 50000] [-shards 1,2,4,8] [-admission host,lockFree] [-duration 2s] [-warmup 1s] [-cooldown 1s]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	var overrides Config
+	if *topology != "" {
+		parsed, err := ParseTopology(*topology)
+		if err != nil {
+			return err
+		}
+		overrides.Topology = parsed
+	}
+
+	fmt.Printf("%d philosophers, %d drivers, %d cores\n", *philosophers, *drivers, runtime.GOMAXPROCS(0))
+	var baseline float64
+	for _, admission := range strings.Split(*admissions, ",") {
+		for _, field := range strings.Split(*shardCounts, ",") {
+			shards, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return fmt.Errorf("bench: %q is not a number of shards", field)
+			}
+			var description = fmt.Sprintf(`{"philosophers": %d, "shards": %d, "admission": %q}`, *philosophers, shards, strings.TrimSpace(admission))
+			config, err := ParseConfig([]byte(description), overrides)
+			if err != nil {
+				return err
+			}
+
+			var table = NewTable(0, config, nil, nil)
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			var latencies = benchTable(table, *drivers, *warmup, *duration, *cooldown)
+			runtime.ReadMemStats(&after)
+			var rate = float64(len(latencies)) / duration.Seconds()
+			if baseline == 0 {
+				baseline = rate
+			}
+			var total time.Duration
+			for _, latency := range latencies {
+				total += latency
+			}
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			var mean, p99 time.Duration
+			var allocations float64
+			if len(latencies) > 0 {
+				mean = total / time.Duration(len(latencies))
+				p99 = latencies[len(latencies)*99/100]
+				allocations = float64(after.Mallocs-before.Mallocs) / float64(len(latencies))
+			}
+			fmt.Printf("%-8s %3d shards : %10.0f decisions/s (x%.2f), latency mean %v p99 %v, %.2f allocations per decision, %s\n",
+				config.Admission, shards, rate, rate/baseline, mean, p99, allocations, table.stats)
+		}
+	}
+	return nil
+}
+
+// benchTable drives the table during the warm-up, the given duration and the cool-down, and returns how long each
+// request to eat sent during the duration waited for its decision
+func benchTable(table *Table, drivers int, warmup, duration, cooldown time.Duration) []time.Duration {
+	table.startHosts()
+	var from = time.Now().Add(warmup)
+	var to = from.Add(duration)
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	var seats = len(table.philosophers)
+	drivers = min(drivers, seats)
+	var latencies = make([][]time.Duration, drivers)
+	for driver := 0; driver < drivers; driver++ {
+		wg.Add(1)
+		go func(driver, first, last int) {
+			defer wg.Done()
+			// what each seat of the driver is doing : not eating, eating allowed by the Host or eating without the Host
+			var eating = make([]int, last-first)
+			const byHost, byAdmission = 1, 2
+			var finish = func(seat int) {
+				var philosopher = table.philosophers[seat]
+				if eating[seat-first] == byAdmission {
+					philosopher.admission.Release(philosopher)
+				} else {
+					table.requests(seat).Tell(philosopher.release(false))
+				}
+				eating[seat-first] = 0
+			}
+			for seat := first; !stop.Load(); seat++ {
+				if seat == last {
+					seat = first
+				}
+				if eating[seat-first] != 0 {
+					finish(seat)
+					continue
+				}
+				var philosopher = table.philosophers[seat]
+				var start = time.Now()
+				if _, admitted := philosopher.admission.TryEat(philosopher); admitted {
+					eating[seat-first] = byAdmission
+				} else {
+					if table.shardOf(seat).host.Ask(philosopher.askToEat(start)).allowed {
+						eating[seat-first] = byHost
+					}
+				}
+				if !start.Before(from) && start.Before(to) {
+					latencies[driver] = append(latencies[driver], time.Since(start))
+				}
+			}
+			for seat := first; seat < last; seat++ {
+				if eating[seat-first] != 0 {
+					finish(seat)
+				}
+			}
+		}(driver, driver*seats/drivers, (driver+1)*seats/drivers)
+	}
+
+	time.Sleep(warmup + duration + cooldown)
+	stop.Store(true)
+	wg.Wait()
+	table.Close()
+
+	var all []time.Duration
+	for _, driverLatencies := range latencies {
+		all = append(all, driverLatencies...)
+	}
+	return all
+}
@@ -0,0 +1,301 @@
+//go:build !js
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const bundleVersion = 1                // the revision of the format of the bundles
+const bundleManifest = "manifest.json" // the file of a bundle telling what it holds and what its dinner gives
+const bundleConfig = "config.json"     // the file of a bundle holding the configuration of its dinner
+const bundleScenario = "scenario/"     // the directory of a bundle holding its scenario, such as the statement of a puzzle
+
+// Bundle is a dinner packaged to be shared and replayed : its configuration, whose seed makes the dinner the same
+// wherever it is run by the discrete engine, a scenario going along with it, such as the statement of a puzzle or a
+// script driving the dinner, and its BundleManifest telling the checksums of the files and of the outcome expected
+type Bundle struct {
+	Manifest BundleManifest
+	Config   []byte
+	Scenario []byte
+}
+
+// BundleManifest tells what a Bundle holds : the revision of its format, the seed of its dinner, the name of its
+// scenario file, empty without scenario, the SHA-256 of its files and the BundleOutcome its dinner is expected to give
+type BundleManifest struct {
+	Version  int           `json:"version"`
+	Seed     int64         `json:"seed"`
+	Scenario string        `json:"scenario,omitempty"`
+	Config   string        `json:"configSha256"`
+	Script   string        `json:"scenarioSha256,omitempty"`
+	Expected BundleOutcome `json:"expected"`
+}
+
+// BundleOutcome is what the dinner of a Bundle gives : the events emitted, the meals eaten, the requests accepted
+// and rejected, the starved philosophers and how long the dinner lasted in simulated time, along with the SHA-256 of
+// the sequence of its events and the one of its Result, so that any difference in the decisions is caught
+type BundleOutcome struct {
+	Events   int           `json:"events"`
+	Meals    int           `json:"meals"`
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Starved  []string      `json:"starved,omitempty"`
+	Makespan time.Duration `json:"makespan"`
+	Trace    string        `json:"eventsSha256"`
+	Result   string        `json:"resultSha256"`
+}
+
+// checksum returns the SHA-256 of the data in hexadecimal
+func checksum(data []byte) string {
+	var sum = sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RunBundle runs the dinner of a configuration with the discrete engine, quietly, and returns its BundleOutcome
+func RunBundle(config Config) BundleOutcome {
+	var outcome BundleOutcome
+	var trace = sha256.New()
+	var simulation = NewSimulation(config)
+	var recorder = NewRecorder(false)
+	simulation.Events().SetQuiet()
+	simulation.Events().Handle(recorder.Record)
+	simulation.Events().Handle(func(event Event) {
+		// only what the seed decides is summed, the wall clock and the ids of the run being left out
+		fmt.Fprintf(trace, "%d %d %d %s %s %d\n", event.Elapsed, event.Table, event.Philosopher, event.Name, event.Kind, event.Meal)
+		outcome.Events++
+		outcome.Makespan = event.Elapsed
+	})
+	var result = simulation.Run()
+
+	var summary = sha256.New()
+	var stats = statsOf(result)
+	outcome.Accepted, outcome.Rejected, outcome.Starved = stats.accepted+stats.admitted, rejectedOf(stats), result.Starved()
+	var causes []string
+	for cause := range stats.rejected {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+	for _, cause := range causes {
+		fmt.Fprintf(summary, "rejected %s %d\n", cause, stats.rejected[cause])
+	}
+	for _, report := range recorder.Reports() {
+		outcome.Meals += report.Meals
+		fmt.Fprintf(summary, "%d %s %d %d %d %d %t\n", report.Table, report.Name, report.Meals, report.Accepted, report.Rejected,
+			report.Paused, report.Starved)
+	}
+	outcome.Trace, outcome.Result = hex.EncodeToString(trace.Sum(nil)), hex.EncodeToString(summary.Sum(nil))
+	return outcome
+}
+
+// NewBundle packages the dinner of a validated configuration along with the scenario named name, run with the
+// discrete engine to record the outcome expected
+func NewBundle(config Config, name string, scenario []byte) (Bundle, error) {
+	config.Engine = discreteEngine
+	if err := config.Validate(); err != nil {
+		return Bundle{}, fmt.Errorf("bundle: the dinner is replayed by the discrete engine: %v", err)
+	}
+	data, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return Bundle{}, fmt.Errorf("bundle: %v", err)
+	}
+	var bundle = Bundle{Config: append(data, '\n'), Scenario: scenario,
+		Manifest: BundleManifest{Version: bundleVersion, Seed: config.Seed}}
+	if name != "" {
+		bundle.Manifest.Scenario, bundle.Manifest.Script = filepath.Base(name), checksum(scenario)
+	}
+	bundle.Manifest.Config = checksum(bundle.Config)
+	bundle.Manifest.Expected = RunBundle(config)
+	return bundle, nil
+}
+
+// WriteBundle writes the Bundle as a gzipped tar archive : its manifest, its configuration and its scenario
+func WriteBundle(w io.Writer, bundle Bundle) error {
+	manifest, err := json.MarshalIndent(bundle.Manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	var zipper = gzip.NewWriter(w)
+	var archive = tar.NewWriter(zipper)
+	var files = [][2]string{{bundleManifest, string(append(manifest, '\n'))}, {bundleConfig, string(bundle.Config)}}
+	if bundle.Manifest.Scenario != "" {
+		files = append(files, [2]string{bundleScenario + bundle.Manifest.Scenario, string(bundle.Scenario)})
+	}
+	for _, file := range files {
+		var header = &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), ModTime: time.Unix(0, 0)}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(archive, file[1]); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return zipper.Close()
+}
+
+// ReadBundle reads a Bundle written by WriteBundle, checking that its files are the ones its manifest tells
+func ReadBundle(r io.Reader) (Bundle, error) {
+	var bundle Bundle
+	zipped, err := gzip.NewReader(r)
+	if err != nil {
+		return bundle, fmt.Errorf("bundle: %v", err)
+	}
+	var files = make(map[string][]byte)
+	var archive = tar.NewReader(zipped)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return bundle, fmt.Errorf("bundle: %v", err)
+		}
+		var data bytes.Buffer
+		if _, err := io.Copy(&data, archive); err != nil {
+			return bundle, fmt.Errorf("bundle: %v", err)
+		}
+		files[header.Name] = data.Bytes()
+	}
+	if err := json.Unmarshal(files[bundleManifest], &bundle.Manifest); err != nil {
+		return bundle, fmt.Errorf("bundle: invalid %s: %v", bundleManifest, err)
+	}
+	if bundle.Manifest.Version > bundleVersion {
+		return bundle, fmt.Errorf("bundle: revision %d of the bundles is not known, at most %d", bundle.Manifest.Version, bundleVersion)
+	}
+	bundle.Config = files[bundleConfig]
+	if checksum(bundle.Config) != bundle.Manifest.Config {
+		return bundle, fmt.Errorf("bundle: %s does not match its checksum", bundleConfig)
+	}
+	if bundle.Manifest.Scenario != "" {
+		bundle.Scenario = files[bundleScenario+bundle.Manifest.Scenario]
+		if checksum(bundle.Scenario) != bundle.Manifest.Script {
+			return bundle, fmt.Errorf("bundle: %s%s does not match its checksum", bundleScenario, bundle.Manifest.Scenario)
+		}
+	}
+	return bundle, nil
+}
+
+// Verify runs the dinner of the Bundle again and returns the differences between its outcome and the expected one,
+// none when the dinner is reproduced
+func (bundle Bundle) Verify(output io.Writer) ([]string, error) {
+	config, err := ParseConfig(bundle.Config, Config{})
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %v", err)
+	}
+	if config.Seed != bundle.Manifest.Seed {
+		return nil, fmt.Errorf("bundle: the configuration has the seed %d, the manifest %d", config.Seed, bundle.Manifest.Seed)
+	}
+	var expected, got = bundle.Manifest.Expected, RunBundle(config)
+	var differences []string
+	var writer = tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "\texpected\tgot")
+	var row = func(label string, expected, got any) {
+		var mark = ""
+		if fmt.Sprint(expected) != fmt.Sprint(got) {
+			differences = append(differences, fmt.Sprintf("%s : %v expected, got %v", label, expected, got))
+			mark = "\t*"
+		}
+		fmt.Fprintf(writer, "%s\t%v\t%v%s\n", label, expected, got, mark)
+	}
+	row("events", expected.Events, got.Events)
+	row("meals", expected.Meals, got.Meals)
+	row("requests accepted", expected.Accepted, got.Accepted)
+	row("requests rejected", expected.Rejected, got.Rejected)
+	row("starved", strings.Join(expected.Starved, ", "), strings.Join(got.Starved, ", "))
+	row("makespan", expected.Makespan, got.Makespan)
+	row("events sha256", expected.Trace[:12], got.Trace[:12])
+	row("result sha256", expected.Result[:12], got.Result[:12])
+	writer.Flush()
+	return differences, nil
+}
+
+// bundle is the bundle command, it packages the dinner of the config file, with its seed and a scenario, into an
+// archive recording the outcome of the dinner, which the verify command runs again anywhere to confirm that the
+// dinner is reproduced, so that concurrency puzzles can be shared along with their expected outcome
+func bundle(arguments []string) error {
+	var flags = flag.NewFlagSet("bundle", flag.ExitOnError)
+	var configFile = flags.String("config", "", "JSON file describing the dinner, see the -config flag of the program")
+	var scenarioFile = flags.String("scenario", "", "file going along with the dinner, such as the statement of the puzzle or a script driving it")
+	var seed = flags.Int64("seed", 0, "seed of the dinner, the one of the config file or a random one by default")
+	var output = flags.String("o", "bundle.tar.gz", "archive where the bundle is written")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s bundle [-config dinner.json] [-scenario puzzle.md] [-seed 42] [-o puzzle.tar.gz]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	config, err := LoadConfig(*configFile, Config{})
+	if err != nil {
+		return err
+	}
+	if *seed != 0 {
+		config.Seed = *seed
+	}
+	var scenario []byte
+	if *scenarioFile != "" {
+		if scenario, err = os.ReadFile(*scenarioFile); err != nil {
+			return fmt.Errorf("bundle: %v", err)
+		}
+	}
+	packaged, err := NewBundle(config, *scenarioFile, scenario)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("bundle: %v", err)
+	}
+	if err := WriteBundle(file, packaged); err != nil {
+		file.Close()
+		return fmt.Errorf("bundle: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("bundle: %v", err)
+	}
+	var expected = packaged.Manifest.Expected
+	fmt.Printf("Bundle saved in %s : seed %d, %d events, %d meals in %v\n", *output, config.Seed, expected.Events, expected.Meals,
+		expected.Makespan.Round(time.Millisecond))
+	return nil
+}
+
+// verifyBundle is the verify command for a bundle, it runs its dinner again and fails when the outcome differs
+func verifyBundle(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("verify: %v", err)
+	}
+	defer file.Close()
+	packaged, err := ReadBundle(file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Verifying the bundle %s with the seed %d\n", path, packaged.Manifest.Seed)
+	if packaged.Manifest.Scenario != "" {
+		fmt.Printf("Scenario : %s\n", packaged.Manifest.Scenario)
+	}
+	differences, err := packaged.Verify(os.Stdout)
+	if err != nil {
+		return err
+	}
+	if len(differences) > 0 {
+		return fmt.Errorf("verify: the dinner of the bundle is not reproduced :\n  %s", strings.Join(differences, "\n  "))
+	}
+	fmt.Println("The dinner of the bundle is reproduced")
+	return nil
+}
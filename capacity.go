@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultCapacityWait = 100 * time.Millisecond // the 95th percentile of the waits the CapacityController aims at, by default
+const defaultCapacityRejection = 0.2               // the share of requests rejected by the capacity the CapacityController aims at, by default
+
+// CapacityController adjusts how many philosophers the Hosts allow to eat at the same time while the dinner takes
+// place, as an autoscaler adjusts the capacity of a service : every capacityInterval, it looks at the requests the
+// Hosts decided and the meals started since its last look, and
+// - allows one more philosopher to eat when the share of the requests rejected because all the allowed philosophers
+// were eating is above capacityRejection, or when the 95th percentile of the waits is above capacityWait while some
+// requests were rejected so, up to capacityMax
+// - allows one less when both are below half their target, down to capacityMin
+// - leaves the capacity as it is otherwise, or when nothing was decided, such as while the dinner is paused
+// The requests turned down for their utensils do not count, since a larger capacity would not let them through.
+// Each change is told by a capacityChanged event for each table, whose meal is the new capacity.
+type CapacityController struct {
+	simulation  *Simulation
+	interval    time.Duration
+	wait        time.Duration
+	rejection   float64
+	min         int
+	max         int
+	mutex       sync.Mutex
+	current     int
+	waits       []time.Duration
+	hungrySince map[string]time.Time
+	decided     int // the requests decided by the Hosts at the last look
+	full        int // the requests rejected by the capacity at the last look
+}
+
+// NewCapacityController creates the CapacityController of a Simulation, nil unless its configuration enables it
+func NewCapacityController(config Config, simulation *Simulation) *CapacityController {
+	if config.CapacityInterval == 0 {
+		return nil
+	}
+	return &CapacityController{simulation: simulation, interval: config.Scale(time.Duration(config.CapacityInterval)),
+		wait: config.Scale(time.Duration(config.CapacityWait)), rejection: config.CapacityRejection, min: config.CapacityMin,
+		max: config.CapacityMax, current: min(max(config.MaxEaters, config.CapacityMin), config.CapacityMax),
+		hungrySince: make(map[string]time.Time)}
+}
+
+// Handle records the waits of the meals started, it is meant to be an EventBus handler
+func (controller *CapacityController) Handle(event Event) {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+	switch event.Kind {
+	case eventRejected, eventThrottled:
+		if _, hungry := controller.hungrySince[event.Name]; !hungry {
+			controller.hungrySince[event.Name] = event.Time
+		}
+	case eventStarted:
+		var wait = time.Duration(0)
+		if since, hungry := controller.hungrySince[event.Name]; hungry {
+			wait = event.Time.Sub(since)
+			delete(controller.hungrySince, event.Name)
+		}
+		controller.waits = append(controller.waits, wait)
+	}
+}
+
+// Run adjusts the capacity every interval until done is closed
+func (controller *CapacityController) Run(done <-chan struct{}) {
+	var ticker = time.NewTicker(controller.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			controller.adjust()
+		}
+	}
+}
+
+// adjust looks at what happened since the last adjustment and changes the capacity when it is needed, the requests
+// being counted from the HostState the Hosts publish
+func (controller *CapacityController) adjust() {
+	var decided, full = 0, 0
+	for _, host := range controller.simulation.State().Hosts {
+		decided += host.Accepted + host.Rejected
+		full += host.Causes[causeMaxEaters]
+	}
+	controller.mutex.Lock()
+	decided, controller.decided = decided-controller.decided, decided
+	full, controller.full = full-controller.full, full
+	var waits = controller.waits
+	controller.waits = nil
+	var current = controller.current
+	controller.mutex.Unlock()
+	if decided == 0 || controller.simulation.Paused() {
+		return
+	}
+
+	var rejection = float64(full) / float64(decided)
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	var p95 = time.Duration(0)
+	if len(waits) > 0 {
+		p95 = waits[min(len(waits)-1, len(waits)*95/100)]
+	}
+	var next = current
+	switch {
+	case (rejection > controller.rejection || p95 > controller.wait && full > 0) && current < controller.max:
+		next = current + 1
+	case p95 < controller.wait/2 && rejection < controller.rejection/2 && current > controller.min:
+		next = current - 1
+	}
+	if next == current {
+		return
+	}
+	if err := controller.simulation.SetMaxEaters(next); err != nil {
+		return
+	}
+	controller.mutex.Lock()
+	controller.current = next
+	controller.mutex.Unlock()
+	var detail = fmt.Sprintf("from %d to %d, wait p95 %v, %.0f%% of %d requests rejected by the capacity", current, next,
+		p95.Round(time.Millisecond), 100*rejection, decided)
+	for _, table := range controller.simulation.tables {
+		controller.simulation.events.Emit(Event{Table: table.id, Kind: eventCapacityChanged, Meal: next, Detail: detail})
+	}
+}
+
+// validateCapacity checks the settings of the CapacityController, which changes the capacity of a Simulation while
+// its dinner takes place in real time
+func (config Config) validateCapacity() error {
+	if config.CapacityInterval == 0 {
+		return nil
+	}
+	if config.CapacityInterval < 0 || config.CapacityWait < 0 {
+		return fmt.Errorf("config: capacityInterval and capacityWait cannot be negative, got %v and %v", time.Duration(config.CapacityInterval),
+			time.Duration(config.CapacityWait))
+	}
+	if config.CapacityRejection <= 0 || config.CapacityRejection > 1 {
+		return fmt.Errorf("config: capacityRejection must be above 0 and at most 1, got %g", config.CapacityRejection)
+	}
+	if config.CapacityMin < 1 || config.CapacityMax < config.CapacityMin {
+		return fmt.Errorf("config: the capacity must stay between at least 1 and capacityMax, got %d to %d", config.CapacityMin, config.CapacityMax)
+	}
+	if config.Engine == discreteEngine {
+		return fmt.Errorf("config: the capacity controller looks at the dinner in real time, it does not work with the discrete engine")
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MealProgress is sent by the philosophers, or by whatever runs them, to the Completion of the dinner :
+// - mealCompleted once a philosopher has finished a meal and told the Host about it
+// - mealsGivenUp when a philosopher leaves the table before eating all his meals, because he starved,
+// the dinner is stopped or the Host is gone
+// - guestSeated when the Reception seats a guest, meals tells how many meals he will eat
+// - receptionClosed when the Reception of a table seats no more guests and the last of them has left
+type MealProgress struct {
+	command string
+	table   int
+	seat    int
+	meals   int
+}
+
+// Below are the allowed command for the MealProgress struct
+const mealCompleted = "mealCompleted"
+const mealsGivenUp = "mealsGivenUp"
+const guestSeated = "guestSeated"
+const receptionClosed = "receptionClosed"
+
+// Below are the criteria ending the dinner, the until setting of the configuration
+const untilAllMeals = "allMeals"   // all the meals are eaten, the default
+const untilDuration = "duration"   // the dinner lasted untilDuration, or all the meals are eaten
+const untilFailure = "failure"     // a philosopher starved or failed, or the Host refused a request breaking the protocol
+const untilMealCount = "mealCount" // untilMeals meals are eaten, all the tables included, or all the meals are eaten
+
+// Completion decides when the dinner is over, it owns the progress channel the meals are told through and counts
+// the meals each seat has left to eat : the dinner is over once no seat has a meal left and the Receptions of the
+// tables in the open mode are closed, so that meals of guests not yet arrived cannot be missed.
+// The until setting of the configuration may end the dinner earlier, once it lasted untilDuration, at the first
+// failure or once untilMeals meals are eaten : Over is then closed, telling the Reason, and whoever runs the tables
+// stops them, the philosophers finishing the meal they are eating and giving up the other ones, so that Done is
+// closed once they all left.
+// A philosopher tells the Host about his meal before telling the Completion, the tables are closed once the
+// Completion is done so that the Hosts have handled every meal.
+// A nil Completion counts nothing, for a philosopher joining a table served by another process.
+type Completion struct {
+	progress  chan MealProgress
+	remaining [][]int
+	left      int
+	open      int
+	until     string
+	duration  time.Duration
+	target    int
+	eaten     int
+	end       sync.Once
+	reason    string // written before over is closed
+	over      chan struct{}
+	done      chan struct{}
+}
+
+// NewCompletion creates the Completion of the given tables from the meals their philosophers have left to eat,
+// a restored dinner only having the meals left, the tables in the open mode waiting for their Reception instead,
+// the dinner ending as the configuration of the tables tells
+func NewCompletion(tables []*Table) *Completion {
+	var completion = &Completion{remaining: make([][]int, len(tables)), over: make(chan struct{}), done: make(chan struct{})}
+	if len(tables) > 0 {
+		var config = tables[0].config
+		completion.until, completion.duration, completion.target = config.Until, time.Duration(config.UntilDuration), config.UntilMeals
+	}
+	var seats = 0
+	for index, table := range tables {
+		completion.remaining[index] = make([]int, len(table.philosophers))
+		seats += len(table.philosophers)
+		if table.reception != nil {
+			completion.open++
+			continue
+		}
+		for seat, philosopher := range table.philosophers {
+			completion.remaining[index][seat] = philosopher.meals - philosopher.countEating
+			completion.left += completion.remaining[index][seat]
+		}
+	}
+	completion.progress = make(chan MealProgress, seats)
+	return completion
+}
+
+// Run counts the meals told through the progress channel until the Completion is closed, Done is closed as soon
+// as the dinner is over, Over as soon as it should end
+func (completion *Completion) Run() {
+	if completion.until == untilDuration {
+		var timer = time.AfterFunc(completion.duration, func() {
+			completion.stop(fmt.Sprintf("the dinner lasted %v", completion.duration))
+		})
+		defer timer.Stop()
+	}
+	completion.check()
+	for progress := range completion.progress {
+		var remaining = &completion.remaining[progress.table][progress.seat]
+		switch progress.command {
+		case mealCompleted:
+			if *remaining > 0 {
+				*remaining--
+				completion.left--
+			}
+			completion.eaten++
+			if completion.until == untilMealCount && completion.eaten >= completion.target {
+				completion.stop(fmt.Sprintf("%d meals eaten", completion.eaten))
+			}
+		case mealsGivenUp:
+			completion.left -= *remaining
+			*remaining = 0
+		case guestSeated:
+			completion.left += progress.meals - *remaining
+			*remaining = progress.meals
+		case receptionClosed:
+			completion.open--
+		}
+		completion.check()
+	}
+}
+
+// check closes Done once no meal is left to eat, it is only called by Run
+func (completion *Completion) check() {
+	select {
+	case <-completion.done:
+	default:
+		if completion.left == 0 && completion.open == 0 {
+			close(completion.done)
+		}
+	}
+}
+
+// Handle ends the dinner at the first failure with the failure criterion : a philosopher starving or failing, or
+// the Host refusing a request breaking the protocol, it is meant to be an EventBus handler
+func (completion *Completion) Handle(event Event) {
+	if completion.until != untilFailure {
+		return
+	}
+	switch event.Kind {
+	case eventStarved:
+		completion.stop(fmt.Sprintf("%s starved", event.Name))
+	case eventPhilosopherFailed:
+		completion.stop(fmt.Sprintf("%s failed", event.Name))
+	case eventError:
+		completion.stop(fmt.Sprintf("the Host refused a request breaking the protocol, %s", event.Detail))
+	}
+}
+
+// stop closes Over for the given reason, the first time it is called
+func (completion *Completion) stop(reason string) {
+	completion.end.Do(func() {
+		completion.reason = reason
+		close(completion.over)
+	})
+}
+
+// Over is closed once the dinner should end before all the meals are eaten, the tables being stopped
+func (completion *Completion) Over() <-chan struct{} {
+	return completion.over
+}
+
+// Reason tells why the dinner ended before all the meals were eaten, empty when it did not
+func (completion *Completion) Reason() string {
+	select {
+	case <-completion.over:
+		return completion.reason
+	default:
+		return ""
+	}
+}
+
+// Complete tells that the philosopher of the seat has finished a meal
+func (completion *Completion) Complete(table, seat int) {
+	if completion == nil {
+		return
+	}
+	completion.progress <- MealProgress{command: mealCompleted, table: table, seat: seat}
+}
+
+// GiveUp tells that the philosopher of the seat left the table without eating his remaining meals
+func (completion *Completion) GiveUp(table, seat int) {
+	if completion == nil {
+		return
+	}
+	completion.progress <- MealProgress{command: mealsGivenUp, table: table, seat: seat}
+}
+
+// Seat tells that a guest who will eat the given meals sits on the seat, it must be called before he asks to eat
+func (completion *Completion) Seat(table, seat, meals int) {
+	completion.progress <- MealProgress{command: guestSeated, table: table, seat: seat, meals: meals}
+}
+
+// CloseReception tells that the Reception of the table seats no more guests
+func (completion *Completion) CloseReception(table int) {
+	completion.progress <- MealProgress{command: receptionClosed, table: table}
+}
+
+// Done is closed once the dinner is over
+func (completion *Completion) Done() <-chan struct{} {
+	return completion.done
+}
+
+// Close stops the Completion, it must only be called once the dinner is over and the tables are closed
+func (completion *Completion) Close() {
+	close(completion.progress)
+}
+
+// validateCompletion checks the criterion ending the dinner : its duration is measured in real time, which the
+// discrete engine does not wait for, and the meals to eat must be counted
+func (config Config) validateCompletion() error {
+	switch config.Until {
+	case untilAllMeals, untilFailure:
+	case untilDuration:
+		if config.UntilDuration <= 0 {
+			return fmt.Errorf("config: the %s criterion needs a positive untilDuration, got %v", untilDuration, time.Duration(config.UntilDuration))
+		}
+		if config.Engine == discreteEngine {
+			return fmt.Errorf("config: the %s criterion measures the dinner in real time, it does not work with the discrete engine", untilDuration)
+		}
+	case untilMealCount:
+		if config.UntilMeals < 1 {
+			return fmt.Errorf("config: the %s criterion needs untilMeals to be at least 1, got %d", untilMealCount, config.UntilMeals)
+		}
+	default:
+		return fmt.Errorf("config: unknown completion criterion %q, expected %q, %q, %q or %q", config.Until, untilAllMeals, untilDuration,
+			untilFailure, untilMealCount)
+	}
+	return nil
+}
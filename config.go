@@ -0,0 +1,581 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultPhilosophers = 5 // There are five philosophers around the table
+const defaultTimeToEat = 3    // philosophers eat 3 times
+const defaultGuests = 20      // guests arriving at a table in the open mode
+const defaultHungerRate = 10  // energy lost per second while hungry
+const defaultEatingRate = 50  // energy regained per second while eating
+const defaultFeedbackSize = 1 // room for one answer of the Host in the feedback channel of a philosopher
+const defaultWorkers = 16     // workers running the philosophers in the worker pool execution
+const defaultMissedBeats = 3  // heartbeats a philosopher can miss before he is unresponsive
+
+// Config holds the settings of the dinner, it can be loaded from a JSON file :
+// - philosophers is the number of philosophers around the table
+// - meals is how many times each philosopher has to eat, in the open mode it is the maximum number of meals of a guest
+// - maxEaters is how many philosophers the Host allows to eat at the same time
+// - topology is the adjacency list telling which philosophers share a chopstick (a ring when omitted),
+// or a description such as "grid:4x3" (see ParseTopology)
+// - tables is how many identical tables dine at the same time, each with its own Host
+// - potCapacity is how many philosophers, all tables included, the shared rice pot can serve at the same time (no pot when 0)
+// - dishCapacity is how many philosophers of a table can serve themselves at the same time from its central dish (no dish when 0)
+// - utensils is either "chopsticks" (the default) or "forksAndSpoons", where philosophers need one fork and one spoon
+// - napkins is the size of a third pool of utensils, the napkins spread around the table, a philosopher needing one of
+// them along with his chopsticks, or his fork and spoon, to eat (no napkins when 0)
+// - forks and spoons are the sizes of the two pools spread around the table in the forks and spoons variant, one of each per pair of neighbors by default
+// - arrivalRate enables the open mode when not 0, guests arrive at this rate (per second) following a Poisson process,
+// wait for a free seat, eat between 1 and meals times and leave
+// - guests is how many guests arrive at each table in the open mode
+// - energy enables the health model when not 0, it is the energy of a philosopher when he is full, it decays by hungerRate
+// per second while he is hungry and is replenished by eatingRate per second while he eats, a philosopher starves when it reaches 0
+// - softDeadline and hardDeadline enable the deadline mode, each meal should start within these durations (such as "500ms")
+// from the moment the philosopher gets hungry, the soft deadline defaults to the hard one
+// - preemptAfter enables preemption, a philosopher hungry for longer than this duration can ask the philosophers
+// of lower priority in his way to pause
+// - priorities gives the priority of each philosopher, 0 for the philosophers not listed
+// - seats overrides the meals, the meal and thinking durations, the priority and the tenant of some seats (see SeatSettings)
+// - tenantQuotas gives how many philosophers of each tenant the Host allows to eat at the same time, the tenants
+// without quota being only measured (see Tenants)
+// - priorityInheritance makes a philosopher holding the utensil of a philosopher of higher priority inherit his
+// priority until he is served, so that the philosophers of medium priority cannot keep him waiting (see Inversions)
+// - slos are the service level objectives of the dinner, such as {"objective": 0.95, "threshold": "500ms"} for 95% of
+// the meals starting within 500ms of the request, the summary telling whether each of them is met (see SLO)
+// - agingRate makes the priority of a hungry philosopher grow by this much per second of hunger (0 by default, no aging),
+// so that the philosophers of low priority end up outranking the others instead of being preempted forever
+// - requestRate limits the requests to eat of each philosopher to this many per second when not 0, with a token bucket
+// of requestBurst tokens (1 by default), the Host throttling the requests beyond it (see RateLimiter)
+// - rateLimiter is either "host" (the default), where only the Host enforces the request rate, or "philosophers"
+// where the philosophers also wait for a token before asking the Host
+// - acquisition is either "sequential" (the default), where a philosopher locks his utensils one after the other, or
+// "atomic" where the PairAllocator of the table gives them to him all together, so that he never holds one while
+// waiting for another
+// - chopstickWait makes the philosophers tell with a chopstickWait event each time they wait this long or longer
+// to lock the utensils of a meal they are allowed to eat, the summary telling how long they waited (see UtensilWaits)
+// - confirmTimeout enables the two-phase grants when not 0 : a grant only reserves the utensils, the philosopher
+// confirming to the Host that he took them, or giving the grant back when one of them is still held this long after
+// it (see Reservations)
+// - check enables the check mode, where the Ownership of each table follows who holds each utensil and reports
+// their misuses as protocol violations
+// - explain makes the Host narrate each of its decisions about a request to eat, who holds the utensils, why it turned
+// the philosopher down and what would have happened without it, for lectures (see Explanation)
+// - backoff tells how long a philosopher turned down waits before asking again : "think" (the default) where he thinks
+// again as after a meal, "fixed" where he waits backoffBase, "exponential" where he waits a random duration up to
+// backoffBase doubled at each retry, "decorrelated" where he waits between backoffBase and 3 times his last wait, both
+// at most backoffMax, or "immediate" where he asks again at once (see Backoff)
+// - backoffBase and backoffMax are the first and the longest waits of the backoff (such as "50ms" and "1s" by default)
+// - speed scales the time of the dinner, 1 by default : the philosophers think and eat speed times faster, and the
+// deadlines, preemptAfter, the rates of the guests, of the energy, of the aging and of the requests and the simulated
+// network are scaled alike, so that the same dinner takes place faster (such as 10) or slower (such as 0.1)
+// - seed makes the random draws of the dinner (thinking times, meal durations, arrivals of the guests) depend on it only,
+// a seed is picked when it is 0
+// - network simulates the latency, losses and partitions of the network in the distributed mode (see Network)
+// - shards splits each table into this many arcs of contiguous seats, each with its own Host, so that tables of
+// tens of thousands of philosophers are not limited by a single Host (1 by default)
+// - admission is either "host" (the default), where the philosophers ask the Host each time they want to eat,
+// "lockFree" where they claim their chopsticks by themselves and only ask the Host when one of them is taken,
+// or "ticket" where the Host serves them in the order they got hungry (see Tickets)
+// - strategy is the name of the Strategy the Host asks before letting a philosopher eat, "greedy" (the default)
+// letting him eat as soon as the rules of the table allow it, "plan" making him eat in the order of the schedule
+// computed offline (see Plan), the other ones being registered with RegisterStrategy
+// - middlewares are the names of the Middlewares wrapping the Strategy of each Host, the first one being the outermost,
+// such as "chaos", the other ones being registered with RegisterMiddleware
+// - chaosRate is the share of the requests, between 0 and 1, the chaos middleware turns down at random
+// - capacityInterval enables the CapacityController when not 0, which adjusts maxEaters this often (such as "500ms"),
+// between capacityMin (1 by default) and capacityMax (the number of philosophers by default), aiming at a 95th
+// percentile of the waits below capacityWait ("100ms" by default) and a share of rejected requests below
+// capacityRejection (0.2 by default) of the requests rejected because all the allowed philosophers eat
+// - requestChannelSize is how many requests the channel of each Host holds before the philosophers block (0 by default,
+// a philosopher then waits until the Host receives his request)
+// - feedbackChannelSize is how many answers of the Host the feedback channel of each philosopher holds (1 by default)
+// - heartbeatInterval enables the heartbeats when not 0, each philosopher tells that he is alive this often (such as "100ms")
+// and the philosophers silent for longer than livenessTimeout, 3 heartbeat intervals by default, are reported (see Liveness)
+// - backpressureThreshold enables the backpressure events when not 0, a philosopher whose request waited longer than
+// this duration (such as "5ms") before the Host received it is reported
+// - shedDepth enables the load shedding when not 0 : once the mailbox of a Host holds this many messages, the
+// requests to eat are turned down at once with a hint telling when to retry, instead of piling up (see Overload),
+// it cannot exceed requestChannelSize
+// - execution is either "goroutines" (the default), where each philosopher has his own goroutine, or "workerPool"
+// where the philosophers are state machines run by a fixed number of workers (see WorkerPool)
+// - workers is the number of workers of each table in the worker pool execution (16 by default)
+// - engine is either "concurrent" (the default), where the philosophers think and eat in real time, or "discrete"
+// where the DiscreteEngine simulates the dinner without waiting
+// - until tells when the dinner ends : "allMeals" (the default) once all the meals are eaten, "duration" once it
+// lasted untilDuration (such as "30s") in real time, "failure" at the first starvation, failure of a philosopher or
+// request breaking the protocol, or "mealCount" once untilMeals meals are eaten, all the tables included, the dinner
+// ending anyway once all the meals are eaten (see Completion)
+// - names gives the name of each seat, or is "philosophers" for Plato, Aristotle, Kant..., the seats not named
+// being known by their number
+// - messages is a text/template writing the line of each event on the console instead of the default sentence,
+// such as "{{.Name}} is eating meal {{.Number}}/{{.Meals}}" (see MessageData), an empty line leaving the event out
+type Config struct {
+	Philosophers          int            `json:"philosophers"`
+	Meals                 int            `json:"meals"`
+	MaxEaters             int            `json:"maxEaters"`
+	Topology              Topology       `json:"topology"`
+	Tables                int            `json:"tables"`
+	PotCapacity           int            `json:"potCapacity"`
+	DishCapacity          int            `json:"dishCapacity"`
+	Utensils              string         `json:"utensils"`
+	Forks                 int            `json:"forks"`
+	Spoons                int            `json:"spoons"`
+	Napkins               int            `json:"napkins"`
+	ArrivalRate           float64        `json:"arrivalRate"`
+	Guests                int            `json:"guests"`
+	Energy                float64        `json:"energy"`
+	HungerRate            float64        `json:"hungerRate"`
+	EatingRate            float64        `json:"eatingRate"`
+	SoftDeadline          Duration       `json:"softDeadline"`
+	HardDeadline          Duration       `json:"hardDeadline"`
+	PreemptAfter          Duration       `json:"preemptAfter"`
+	Priorities            []int          `json:"priorities"`
+	Seats                 []SeatSettings `json:"seats"`
+	TenantQuotas          map[string]int `json:"tenantQuotas"`
+	PriorityInheritance   bool           `json:"priorityInheritance"`
+	SLOs                  []SLO          `json:"slos"`
+	AgingRate             float64        `json:"agingRate"`
+	RequestRate           float64        `json:"requestRate"`
+	RequestBurst          int            `json:"requestBurst"`
+	RateLimiter           string         `json:"rateLimiter"`
+	Acquisition           string         `json:"acquisition"`
+	ChopstickWait         Duration       `json:"chopstickWait"`
+	ConfirmTimeout        Duration       `json:"confirmTimeout"`
+	Check                 bool           `json:"check"`
+	Explain               bool           `json:"explain"`
+	Backoff               string         `json:"backoff"`
+	BackoffBase           Duration       `json:"backoffBase"`
+	BackoffMax            Duration       `json:"backoffMax"`
+	Speed                 float64        `json:"speed"`
+	Seed                  int64          `json:"seed"`
+	Network               *Network       `json:"network"`
+	Shards                int            `json:"shards"`
+	Admission             string         `json:"admission"`
+	Strategy              string         `json:"strategy"`
+	Middlewares           []string       `json:"middlewares"`
+	ChaosRate             float64        `json:"chaosRate"`
+	CapacityInterval      Duration       `json:"capacityInterval"`
+	CapacityWait          Duration       `json:"capacityWait"`
+	CapacityRejection     float64        `json:"capacityRejection"`
+	CapacityMin           int            `json:"capacityMin"`
+	CapacityMax           int            `json:"capacityMax"`
+	RequestChannelSize    int            `json:"requestChannelSize"`
+	FeedbackChannelSize   int            `json:"feedbackChannelSize"`
+	HeartbeatInterval     Duration       `json:"heartbeatInterval"`
+	LivenessTimeout       Duration       `json:"livenessTimeout"`
+	BackpressureThreshold Duration       `json:"backpressureThreshold"`
+	ShedDepth             int            `json:"shedDepth"`
+	Execution             string         `json:"execution"`
+	Workers               int            `json:"workers"`
+	Engine                string         `json:"engine"`
+	Until                 string         `json:"until"`
+	UntilDuration         Duration       `json:"untilDuration"`
+	UntilMeals            int            `json:"untilMeals"`
+	Names                 Names          `json:"names"`
+	Messages              string         `json:"messages"`
+}
+
+// Priority returns the priority of the given philosopher, the one of the settings of his seat when they tell it
+func (config Config) Priority(philosopher int) int {
+	if priority := config.Seat(philosopher).Priority; priority != nil {
+		return *priority
+	}
+	if philosopher < len(config.Priorities) {
+		return config.Priorities[philosopher]
+	}
+	return 0
+}
+
+// AgedPriority returns the effective priority of a philosopher of the given priority who has been hungry for the given duration
+func (config Config) AgedPriority(priority int, hungry time.Duration) float64 {
+	return float64(priority) + config.AgingRate*config.Speed*hungry.Seconds()
+}
+
+// Scale returns the duration of the dinner at its speed
+func (config Config) Scale(duration time.Duration) time.Duration {
+	return time.Duration(float64(duration) / config.Speed)
+}
+
+// ParseSpeed parses a speed such as "10x" or "0.1x", the x being optional
+func ParseSpeed(text string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(text, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("config: the speed should be a positive factor such as 10x or 0.1x, got %q", text)
+	}
+	return speed, nil
+}
+
+// Duration is a time.Duration written as a string such as "1.5s" or "300ms" in the config file
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+// UnmarshalJSON parses a duration string
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration should be a string such as \"300ms\": %v", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+// LoadConfig reads the configuration from the given JSON file, an empty path gives the default configuration
+// The settings given on the command line, which are the non zero fields of overrides, replace the ones of the file.
+// Missing settings are then filled with their default value, and the resulting configuration is validated
+func LoadConfig(path string, overrides Config) (Config, error) {
+	if path == "" {
+		return ParseConfig(nil, overrides)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: %v", err)
+	}
+	config, err := ParseConfig(data, overrides)
+	if err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig is LoadConfig for a configuration already read, empty data gives the default configuration
+func ParseConfig(data []byte, overrides Config) (Config, error) {
+	var config Config
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("config: %v", err)
+		}
+	}
+
+	config.merge(overrides)
+	config.setDefaults()
+
+	return config, config.Validate()
+}
+
+// merge replaces the settings of the configuration by the non zero ones of overrides
+// A topology given on the command line also sets the number of philosophers, and the settings of the seats given on
+// the command line are merged over the ones of the file
+func (config *Config) merge(overrides Config) {
+	config.mergeSeats(overrides.Seats)
+	if overrides.Speed != 0 {
+		config.Speed = overrides.Speed
+	}
+	if overrides.Topology != nil {
+		config.Topology = overrides.Topology
+		config.Philosophers = len(overrides.Topology)
+	}
+}
+
+// setDefaults fills the settings left empty
+func (config *Config) setDefaults() {
+	if config.Philosophers == 0 {
+		if config.Topology != nil {
+			config.Philosophers = len(config.Topology)
+		} else {
+			config.Philosophers = defaultPhilosophers
+		}
+	}
+	if config.Meals == 0 {
+		config.Meals = defaultTimeToEat
+	}
+	if config.Tables == 0 {
+		config.Tables = 1
+	}
+	if config.Shards == 0 {
+		config.Shards = 1
+	}
+	if config.Admission == "" {
+		config.Admission = hostAdmission
+	}
+	if config.Strategy == "" {
+		config.Strategy = greedyStrategy
+	}
+	if config.FeedbackChannelSize == 0 {
+		config.FeedbackChannelSize = defaultFeedbackSize
+	}
+	if config.Execution == "" {
+		config.Execution = goroutinesExecution
+	}
+	if config.Until == "" {
+		config.Until = untilAllMeals
+	}
+	if config.Engine == "" {
+		config.Engine = concurrentEngine
+	}
+	if config.Execution == workerPoolExecution && config.Workers == 0 {
+		config.Workers = defaultWorkers
+	}
+	if config.Topology == nil {
+		config.Topology = RingTopology(config.Philosophers)
+	}
+	if config.ArrivalRate > 0 && config.Guests == 0 {
+		config.Guests = defaultGuests
+	}
+	if config.Energy > 0 && config.HungerRate == 0 {
+		config.HungerRate = defaultHungerRate
+	}
+	if config.Energy > 0 && config.EatingRate == 0 {
+		config.EatingRate = defaultEatingRate
+	}
+	if config.RequestRate > 0 && config.RequestBurst == 0 {
+		config.RequestBurst = 1
+	}
+	if config.RateLimiter == "" {
+		config.RateLimiter = hostRateLimiter
+	}
+	if config.Acquisition == "" {
+		config.Acquisition = sequentialAcquisition
+	}
+	if config.Backoff == "" {
+		config.Backoff = thinkBackoff
+	}
+	if config.BackoffBase == 0 {
+		config.BackoffBase = Duration(defaultBackoffBase)
+	}
+	if config.BackoffMax == 0 {
+		config.BackoffMax = Duration(max(defaultBackoffMax, time.Duration(config.BackoffBase)))
+	}
+	if config.HeartbeatInterval > 0 && config.LivenessTimeout == 0 {
+		config.LivenessTimeout = defaultMissedBeats * config.HeartbeatInterval
+	}
+	if config.SoftDeadline == 0 {
+		config.SoftDeadline = config.HardDeadline
+	}
+	for i := range config.SLOs {
+		if config.SLOs[i].Name == "" {
+			config.SLOs[i].Name = sloName(config.SLOs[i])
+		}
+		if config.SLOs[i].Window == 0 {
+			config.SLOs[i].Window = Duration(defaultSLOWindow)
+		}
+	}
+	if config.CapacityInterval > 0 && config.CapacityWait == 0 {
+		config.CapacityWait = Duration(defaultCapacityWait)
+	}
+	if config.CapacityInterval > 0 && config.CapacityRejection == 0 {
+		config.CapacityRejection = defaultCapacityRejection
+	}
+	if config.CapacityInterval > 0 && config.CapacityMin == 0 {
+		config.CapacityMin = 1
+	}
+	if config.CapacityInterval > 0 && config.CapacityMax == 0 {
+		config.CapacityMax = config.Philosophers
+	}
+	if config.Utensils == "" {
+		config.Utensils = chopSticksVariant
+	}
+	if config.Forks == 0 {
+		config.Forks = len(config.Topology.ChopSticks())
+	}
+	if config.Spoons == 0 {
+		config.Spoons = len(config.Topology.ChopSticks())
+	}
+	if config.Speed == 0 {
+		config.Speed = 1
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if config.MaxEaters == 0 {
+		config.MaxEaters = config.Philosophers / 2
+		if config.MaxEaters == 0 {
+			config.MaxEaters = 1
+		}
+	}
+}
+
+// Validate checks that the configuration describes a dinner that can take place
+func (config Config) Validate() error {
+	if config.Philosophers < 1 {
+		return fmt.Errorf("config: at least one philosopher is needed, got %d", config.Philosophers)
+	}
+	if config.Meals < 1 {
+		return fmt.Errorf("config: philosophers must eat at least once, got %d meals", config.Meals)
+	}
+	if config.MaxEaters < 1 {
+		return fmt.Errorf("config: the Host must allow at least one philosopher to eat, got %d", config.MaxEaters)
+	}
+	if config.Tables < 1 {
+		return fmt.Errorf("config: at least one table is needed, got %d", config.Tables)
+	}
+	if config.PotCapacity < 0 {
+		return fmt.Errorf("config: the rice pot capacity cannot be negative, got %d", config.PotCapacity)
+	}
+	if config.ArrivalRate < 0 || config.Guests < 0 {
+		return fmt.Errorf("config: the arrival rate and the number of guests cannot be negative, got %g and %d", config.ArrivalRate, config.Guests)
+	}
+	if config.Energy < 0 || config.HungerRate < 0 || config.EatingRate < 0 {
+		return fmt.Errorf("config: the energy settings cannot be negative, got energy %g, hungerRate %g and eatingRate %g", config.Energy, config.HungerRate, config.EatingRate)
+	}
+	if config.SoftDeadline < 0 || config.HardDeadline < 0 {
+		return fmt.Errorf("config: deadlines cannot be negative, got %v and %v", time.Duration(config.SoftDeadline), time.Duration(config.HardDeadline))
+	}
+	if config.HardDeadline > 0 && config.SoftDeadline > config.HardDeadline {
+		return fmt.Errorf("config: the soft deadline %v cannot be after the hard deadline %v", time.Duration(config.SoftDeadline), time.Duration(config.HardDeadline))
+	}
+	if config.PreemptAfter < 0 {
+		return fmt.Errorf("config: preemptAfter cannot be negative, got %v", time.Duration(config.PreemptAfter))
+	}
+	if config.AgingRate < 0 {
+		return fmt.Errorf("config: agingRate cannot be negative, got %g", config.AgingRate)
+	}
+	if config.RequestRate < 0 || config.RequestBurst < 0 {
+		return fmt.Errorf("config: requestRate and requestBurst cannot be negative, got %g and %d", config.RequestRate, config.RequestBurst)
+	}
+	if config.RateLimiter != hostRateLimiter && config.RateLimiter != philosophersRateLimiter {
+		return fmt.Errorf("config: unknown rate limiter %q, expected %q or %q", config.RateLimiter, hostRateLimiter, philosophersRateLimiter)
+	}
+	if config.Acquisition != sequentialAcquisition && config.Acquisition != atomicAcquisition {
+		return fmt.Errorf("config: unknown acquisition %q, expected %q or %q", config.Acquisition, sequentialAcquisition, atomicAcquisition)
+	}
+	if config.ChopstickWait < 0 {
+		return fmt.Errorf("config: chopstickWait cannot be negative, got %v", time.Duration(config.ChopstickWait))
+	}
+	if config.ConfirmTimeout < 0 {
+		return fmt.Errorf("config: confirmTimeout cannot be negative, got %v", time.Duration(config.ConfirmTimeout))
+	}
+	if config.ConfirmTimeout > 0 && (config.Execution != goroutinesExecution || config.Engine != concurrentEngine || config.Admission == lockFreeAdmission) {
+		return fmt.Errorf("config: the two-phase grants are confirmed by the goroutines of the philosophers asking the Host, they do not work with the worker pool, the discrete engine nor the lock-free admission")
+	}
+	if err := validBackoff(config.Backoff); err != nil {
+		return err
+	}
+	if config.BackoffBase < 0 || config.BackoffMax < config.BackoffBase {
+		return fmt.Errorf("config: backoffBase cannot be negative nor longer than backoffMax, got %v and %v", time.Duration(config.BackoffBase), time.Duration(config.BackoffMax))
+	}
+	if config.Speed <= 0 {
+		return fmt.Errorf("config: the speed must be positive, got %g", config.Speed)
+	}
+	if len(config.Priorities) > config.Philosophers {
+		return fmt.Errorf("config: %d priorities given for %d philosophers", len(config.Priorities), config.Philosophers)
+	}
+	if err := config.validateSeats(); err != nil {
+		return err
+	}
+	if config.DishCapacity < 0 {
+		return fmt.Errorf("config: the central dish capacity cannot be negative, got %d", config.DishCapacity)
+	}
+	if len(config.Topology) != config.Philosophers {
+		return fmt.Errorf("config: topology describes %d philosophers, expected %d", len(config.Topology), config.Philosophers)
+	}
+	if err := config.Topology.Validate(); err != nil {
+		return err
+	}
+	if config.Forks < 0 || config.Spoons < 0 {
+		return fmt.Errorf("config: the forks and spoons pools cannot be negative, got %d forks and %d spoons", config.Forks, config.Spoons)
+	}
+	if config.Napkins < 0 {
+		return fmt.Errorf("config: the napkins pool cannot be negative, got %d napkins", config.Napkins)
+	}
+	if config.Network != nil {
+		if err := config.Network.Validate(config.Philosophers); err != nil {
+			return err
+		}
+	}
+	if config.Shards < 1 || config.Shards > config.Philosophers {
+		return fmt.Errorf("config: shards must be between 1 and the number of philosophers, got %d", config.Shards)
+	}
+	if config.Admission != hostAdmission && config.Admission != lockFreeAdmission && config.Admission != ticketAdmission {
+		return fmt.Errorf("config: unknown admission %q, expected %q, %q or %q", config.Admission, hostAdmission, lockFreeAdmission, ticketAdmission)
+	}
+	if config.Admission == ticketAdmission && config.Shards > 1 {
+		return fmt.Errorf("config: the ticket admission orders the requests of a single Host, it does not work with shards")
+	}
+	if err := validStrategy(config.Strategy); err != nil {
+		return err
+	}
+	if config.Strategy == planStrategy {
+		if err := config.validatePlan(); err != nil {
+			return err
+		}
+	}
+	for _, name := range config.Middlewares {
+		if err := validMiddleware(name); err != nil {
+			return err
+		}
+	}
+	if err := config.validateTenants(); err != nil {
+		return err
+	}
+	if err := config.validateCapacity(); err != nil {
+		return err
+	}
+	if err := config.validateSLOs(); err != nil {
+		return err
+	}
+	if config.ChaosRate < 0 || config.ChaosRate > 1 {
+		return fmt.Errorf("config: chaosRate must be between 0 and 1, got %g", config.ChaosRate)
+	}
+	if (config.Shards > 1 || config.Admission == lockFreeAdmission) && (config.Utensils != chopSticksVariant || config.Napkins > 0 || config.DishCapacity > 0 ||
+		config.PotCapacity > 0 || config.ArrivalRate > 0 || config.HardDeadline > 0 || config.SoftDeadline > 0 || config.PreemptAfter > 0) {
+		return fmt.Errorf("config: shards and the lock-free admission only work with chopsticks, without napkins, dish, rice pot, open mode, deadlines nor preemption")
+	}
+	if config.RequestChannelSize < 0 || config.FeedbackChannelSize < 1 {
+		return fmt.Errorf("config: the request channels cannot hold a negative number of requests, and the feedback channels must hold at least one answer, got %d and %d",
+			config.RequestChannelSize, config.FeedbackChannelSize)
+	}
+	if config.BackpressureThreshold < 0 {
+		return fmt.Errorf("config: backpressureThreshold cannot be negative, got %v", time.Duration(config.BackpressureThreshold))
+	}
+	if config.ShedDepth < 0 || config.ShedDepth > config.RequestChannelSize {
+		return fmt.Errorf("config: shedDepth must be between 0 and requestChannelSize %d, got %d", config.RequestChannelSize, config.ShedDepth)
+	}
+	if config.ShedDepth > 0 && (config.Execution != goroutinesExecution || config.Engine != concurrentEngine) {
+		return fmt.Errorf("config: the load shedding needs the goroutines execution and the concurrent engine, whose philosophers send their requests themselves")
+	}
+	if config.HeartbeatInterval < 0 || config.LivenessTimeout < 0 {
+		return fmt.Errorf("config: heartbeatInterval and livenessTimeout cannot be negative, got %v and %v", time.Duration(config.HeartbeatInterval), time.Duration(config.LivenessTimeout))
+	}
+	if config.HeartbeatInterval > 0 && config.LivenessTimeout < config.HeartbeatInterval {
+		return fmt.Errorf("config: the liveness timeout %v cannot be shorter than the heartbeat interval %v", time.Duration(config.LivenessTimeout), time.Duration(config.HeartbeatInterval))
+	}
+	if config.Execution != goroutinesExecution && config.Execution != workerPoolExecution {
+		return fmt.Errorf("config: unknown execution %q, expected %q or %q", config.Execution, goroutinesExecution, workerPoolExecution)
+	}
+	if config.Execution == workerPoolExecution && config.Workers < 1 {
+		return fmt.Errorf("config: the worker pool needs at least one worker, got %d", config.Workers)
+	}
+	if config.Execution == workerPoolExecution && (config.PreemptAfter > 0 || config.ArrivalRate > 0) {
+		return fmt.Errorf("config: the worker pool does not work with preemption nor the open mode")
+	}
+	if config.Engine != concurrentEngine && config.Engine != discreteEngine {
+		return fmt.Errorf("config: unknown engine %q, expected %q or %q", config.Engine, concurrentEngine, discreteEngine)
+	}
+	if config.Engine == discreteEngine && (config.ArrivalRate > 0 || config.Execution == workerPoolExecution) {
+		return fmt.Errorf("config: the discrete engine does not simulate the open mode, nor runs the philosophers on a worker pool")
+	}
+	if err := config.validateCompletion(); err != nil {
+		return err
+	}
+	if config.RequestRate > 0 && config.RateLimiter == philosophersRateLimiter && (config.Execution != goroutinesExecution || config.Engine != concurrentEngine) {
+		return fmt.Errorf("config: the philosophers only pace themselves in their goroutines, not in the worker pool nor the discrete engine")
+	}
+	if config.HeartbeatInterval > 0 && (config.Execution != goroutinesExecution || config.Engine != concurrentEngine) {
+		return fmt.Errorf("config: the heartbeats come from the goroutines of the philosophers, they do not work with the worker pool nor the discrete engine")
+	}
+	if err := config.Names.Validate(config.Philosophers); err != nil {
+		return err
+	}
+	if _, err := template.New("messages").Parse(config.Messages); err != nil {
+		return fmt.Errorf("config: invalid messages template: %v", err)
+	}
+	_, _, err := layUtensils(config)
+	return err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Below are the verbosities of the console, -quiet printing no event at all
+const lifecycleVerbosity = 0 // the philosophers starting, pausing and finishing their meals, the guests seated and the starvations
+const decisionsVerbosity = 1 // -v, also the decisions of the Hosts, the preemptions, the rice served and the backpressure
+const queuesVerbosity = 2    // -vv, also the requests waiting for the Host when it decided
+
+// Messages writes the line of each event told at the verbosity, from the messages template of the Config
+// Nil Messages tell the lifecycle events with the default sentences
+type Messages struct {
+	template  *template.Template
+	meals     []int
+	verbosity int
+}
+
+// MessageData is what the messages template is executed with :
+// - the fields of the event, such as {{.Name}}, {{.Kind}} or {{.Detail}}
+// - the default sentence telling the event, {{.Message}}, empty for the events which are not told
+// - the number of the meal starting at 1, {{.Number}}, and the number of meals of the philosopher, {{.Meals}}, the
+// meals of his seat when its settings tell them
+type MessageData struct {
+	Event
+	Message string
+	Number  int
+	Meals   int
+}
+
+// NewMessages creates the Messages of a validated configuration telling the events at the given verbosity
+func NewMessages(config Config, verbosity int) *Messages {
+	var messages = &Messages{meals: config.SeatMeals(), verbosity: verbosity}
+	if config.Messages != "" {
+		messages.template = template.Must(template.New("messages").Parse(config.Messages))
+	}
+	return messages
+}
+
+// Told tells whether the event is told at the verbosity of the Messages
+func (messages *Messages) Told(event Event) bool {
+	var verbosity = lifecycleVerbosity
+	if messages != nil {
+		verbosity = messages.verbosity
+	}
+	switch event.Kind {
+	case eventAccepted, eventRejected, eventThrottled, eventPreempted, eventRiceServed, eventBackpressure:
+		return verbosity >= decisionsVerbosity
+	}
+	return true
+}
+
+// Format returns the line telling the event, the default sentence when the template fails, which tells the ticket
+// of the philosopher with the ticket admission and the requests waiting for the Host at the highest verbosity
+func (messages *Messages) Format(event Event) string {
+	var message = eventMessage(event)
+	if messages != nil && messages.verbosity >= decisionsVerbosity && message != "" && event.Ticket > 0 {
+		message = fmt.Sprintf("%s [ticket %d]", message, event.Ticket)
+	}
+	if messages != nil && messages.verbosity >= queuesVerbosity && message != "" && event.Queue > 0 {
+		message = fmt.Sprintf("%s [%d requests waiting]", message, event.Queue)
+	}
+	if messages == nil || messages.template == nil {
+		return message
+	}
+	var meals = 0
+	if event.Philosopher >= 0 && event.Philosopher < len(messages.meals) {
+		meals = messages.meals[event.Philosopher]
+	}
+	var line strings.Builder
+	if err := messages.template.Execute(&line, MessageData{Event: event, Message: message, Number: event.Meal + 1, Meals: meals}); err != nil {
+		return message
+	}
+	return line.String()
+}
+
+// LogEvents returns an EventBus handler logging each event told by messages with the line they write, along with
+// the fields of the event, the starvations, the backpressure and the liveness incidents being warnings and the protocol
+// violations and the failures of the philosophers errors
+func LogEvents(logger Logger, messages *Messages) func(Event) {
+	return func(event Event) {
+		if !messages.Told(event) {
+			return
+		}
+		var message = messages.Format(event)
+		if message == "" {
+			return
+		}
+		var level = LevelInfo
+		if event.Kind == eventStarved || event.Kind == eventBackpressure || event.Kind == eventUnresponsive || event.Kind == eventInversion {
+			level = LevelWarn
+		} else if event.Kind == eventError || event.Kind == eventPhilosopherFailed {
+			level = LevelError
+		}
+		logger.Log(level, message, "run", event.Run, "kind", string(event.Kind), "table", event.Table, "philosopher", event.Name,
+			"meal", event.Meal, "detail", event.Detail, "queue", event.Queue, "ticket", event.Ticket)
+	}
+}
+
+// eventMessage tells the event in a sentence, empty for the events which are not told
+func eventMessage(event Event) string {
+	switch event.Kind {
+	case eventAccepted:
+		if event.Detail != "" {
+			return fmt.Sprintf("Host accepts request to eat from %s with %s", event.Name, event.Detail)
+		}
+		return fmt.Sprintf("Host accepts request to eat from %s", event.Name)
+	case eventRejected:
+		return fmt.Sprintf("Host rejects request to eat from %s, reason %s", event.Name, event.Detail)
+	case eventThrottled:
+		return fmt.Sprintf("Request to eat from %s throttled, %s", event.Name, event.Detail)
+	case eventPreempted:
+		return fmt.Sprintf("Host asks %s to pause for %s", event.Name, event.Detail)
+	case eventStarted:
+		return fmt.Sprintf("starting  eating %s (%d)", event.Name, event.Meal)
+	case eventFinished:
+		if event.Detail != "" {
+			return fmt.Sprintf("finishing eating %s (%d), %s", event.Name, event.Meal, event.Detail)
+		}
+		return fmt.Sprintf("finishing eating %s (%d)", event.Name, event.Meal)
+	case eventPaused:
+		return fmt.Sprintf("pausing   eating %s (%d)", event.Name, event.Meal)
+	case eventStarved:
+		return fmt.Sprintf("Philosopher %s starved %s", event.Name, event.Detail)
+	case eventSeated:
+		return fmt.Sprintf("Reception seats guest %s for %d meals", event.Name, event.Meal)
+	case eventBackpressure:
+		return fmt.Sprintf("Backpressure, the request of %s waited %s to reach the Host", event.Name, event.Detail)
+	case eventRiceServed:
+		return fmt.Sprintf("Kitchen serves rice to table %d (%s)", event.Table, event.Detail)
+	case eventError:
+		return fmt.Sprintf("Host error, %s", event.Detail)
+	case eventUnresponsive:
+		return fmt.Sprintf("Liveness, %s is unresponsive, %s", event.Name, event.Detail)
+	case eventResponsive:
+		return fmt.Sprintf("Liveness, %s is responsive again %s", event.Name, event.Detail)
+	case eventExplained:
+		return fmt.Sprintf("Explanation, %s", event.Detail)
+	case eventCapacityChanged:
+		return fmt.Sprintf("Capacity of table %d changed %s", event.Table, event.Detail)
+	case eventInversion:
+		return fmt.Sprintf("Priority inversion, %s", event.Detail)
+	case eventChopstickWait:
+		return fmt.Sprintf("%s waited %s, still held when he was allowed to eat", event.Name, event.Detail)
+	case eventAborted:
+		return fmt.Sprintf("%s gave his grant back, %s", event.Name, event.Detail)
+	case eventPhilosopherFailed:
+		return fmt.Sprintf("Philosopher %s failed, %s", event.Name, event.Detail)
+	}
+	return ""
+}
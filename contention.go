@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Contention is how contended a utensil of a table was during the dinner :
+// - the requests to eat denied because a neighbor held it
+// - how many times it was locked, how many of them had to wait for it, along with the total and the longest wait
+// - the total and the longest time it was held
+type Contention struct {
+	Table   int           `json:"table"`
+	ID      int           `json:"id"`
+	Kind    UtensilKind   `json:"kind"`
+	Denied  int64         `json:"denied"`
+	Locks   int64         `json:"locks"`
+	Waits   int64         `json:"waits"`
+	Waiting time.Duration `json:"waiting"`
+	MaxWait time.Duration `json:"maxWait"`
+	Holding time.Duration `json:"holding"`
+	MaxHold time.Duration `json:"maxHold"`
+}
+
+// Contention returns how contended each utensil of the table was
+func (table *Table) Contention() []Contention {
+	var contentions []Contention
+	for _, utensil := range table.utensils {
+		contentions = append(contentions, Contention{
+			Table:   table.id,
+			ID:      utensil.id,
+			Kind:    utensil.kind,
+			Denied:  utensil.denied.Load(),
+			Locks:   utensil.locks.Load(),
+			Waits:   utensil.waits.Load(),
+			Waiting: time.Duration(utensil.waiting.Load()),
+			MaxWait: time.Duration(utensil.maxWait.Load()),
+			Holding: time.Duration(utensil.holding.Load()),
+			MaxHold: time.Duration(utensil.maxHold.Load())})
+	}
+	return contentions
+}
+
+// MostContended returns the utensils of all the tables, the most contended first : the ones denying the most requests,
+// then the ones waited for the longest, then the ones held the longest
+func (result Result) MostContended() []Contention {
+	var contentions []Contention
+	for _, table := range result.Tables {
+		contentions = append(contentions, table.Contention()...)
+	}
+	sort.SliceStable(contentions, func(i, j int) bool {
+		var a, b = contentions[i], contentions[j]
+		if a.Denied != b.Denied {
+			return a.Denied > b.Denied
+		}
+		if a.Waiting != b.Waiting {
+			return a.Waiting > b.Waiting
+		}
+		return a.Holding > b.Holding
+	})
+	return contentions
+}
+
+// WriteContention writes the top most contended utensils of the dinner, after a line comparing the least and the most
+// contended ones so that an asymmetry stands out
+func WriteContention(w io.Writer, result Result, top int) {
+	var contentions = result.MostContended()
+	if len(contentions) == 0 {
+		return
+	}
+	var denied int64
+	for _, contention := range contentions {
+		denied += contention.Denied
+	}
+	var least, most = contentions[len(contentions)-1], contentions[0]
+	fmt.Fprintf(w, "Contention : %d utensils, %d requests denied by a held utensil, from %d to %d per utensil (mean %.1f)\n",
+		len(contentions), denied, least.Denied, most.Denied, float64(denied)/float64(len(contentions)))
+
+	var writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tUTENSIL\tDENIED\tLOCKS\tWAITS\tMEAN WAIT\tMAX WAIT\tMEAN HOLD\tMAX HOLD")
+	for _, contention := range contentions[:min(top, len(contentions))] {
+		fmt.Fprintf(writer, "%d\t%s %d\t%d\t%d\t%d\t%v\t%v\t%v\t%v\n", contention.Table, contention.Kind, contention.ID, contention.Denied,
+			contention.Locks, contention.Waits, meanDuration(contention.Waiting, contention.Waits), contention.MaxWait.Round(time.Microsecond),
+			meanDuration(contention.Holding, contention.Locks), contention.MaxHold.Round(time.Microsecond))
+	}
+	writer.Flush()
+}
+
+// meanDuration divides a total duration by a count, rounded to the microsecond
+func meanDuration(total time.Duration, count int64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return (total / time.Duration(count)).Round(time.Microsecond)
+}
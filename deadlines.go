@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Deadlines turns the dinner into a real-time scheduling problem : each meal must start within the soft deadline,
+// and should never start after the hard deadline, both counted from the moment the philosopher gets hungry.
+// It is owned by the Host which uses it to track the philosophers waiting to eat :
+// - a philosopher whose soft deadline has passed becomes urgent
+// - the Host gives way to the urgent philosophers, earliest deadline first, by rejecting the requests which
+// would take their utensils or the last place at the table
+// A nil Deadlines means that the deadline mode is disabled.
+type Deadlines struct {
+	soft    time.Duration
+	hard    time.Duration
+	waiting map[int]Waiter
+	misses  map[string]*DeadlineMisses
+}
+
+// Waiter is a philosopher waiting to eat, along with the moment he got hungry
+type Waiter struct {
+	philosopher *Philosopher
+	hungrySince time.Time
+}
+
+// DeadlineMisses counts the meals of a philosopher and how many of them missed their deadlines
+type DeadlineMisses struct {
+	meals int
+	soft  int
+	hard  int
+}
+
+// NewDeadlines creates the deadline tracker of a Host, it returns nil when the configuration sets no deadline
+func NewDeadlines(config Config) *Deadlines {
+	if config.SoftDeadline == 0 && config.HardDeadline == 0 {
+		return nil
+	}
+	return &Deadlines{
+		soft:    config.Scale(time.Duration(config.SoftDeadline)),
+		hard:    config.Scale(time.Duration(config.HardDeadline)),
+		waiting: make(map[int]Waiter),
+		misses:  make(map[string]*DeadlineMisses)}
+}
+
+// Waiting records that the philosopher, hungry since the given time, wants to eat
+func (deadlines *Deadlines) Waiting(philosopher *Philosopher, hungrySince time.Time) {
+	if deadlines == nil {
+		return
+	}
+	if _, ok := deadlines.waiting[philosopher.id]; !ok {
+		deadlines.waiting[philosopher.id] = Waiter{philosopher: philosopher, hungrySince: hungrySince}
+	}
+}
+
+// GiveWay tells if the philosopher has to be rejected in favor of an urgent philosopher whose deadline comes first,
+// either because they need the same utensils or because lastPlace tells that only one more philosopher can eat
+// It returns the name of the urgent philosopher
+func (deadlines *Deadlines) GiveWay(philosopher *Philosopher, now time.Time, lastPlace bool) (string, bool) {
+	if deadlines == nil {
+		return "", false
+	}
+
+	var asking = deadlines.waiting[philosopher.id]
+	var urgent []Waiter
+	for id, waiter := range deadlines.waiting {
+		if id != philosopher.id && !now.Before(waiter.hungrySince.Add(deadlines.soft)) && waiter.hungrySince.Before(asking.hungrySince) {
+			urgent = append(urgent, waiter)
+		}
+	}
+	sort.Slice(urgent, func(i, j int) bool { return urgent[i].hungrySince.Before(urgent[j].hungrySince) })
+
+	for _, waiter := range urgent {
+		if lastPlace || shareUtensils(waiter.philosopher.needs, philosopher.needs) {
+			return waiter.philosopher.name, true
+		}
+	}
+	return "", false
+}
+
+// Served records that the philosopher, hungry since the given time, starts eating, and counts the deadlines he missed
+func (deadlines *Deadlines) Served(philosopher *Philosopher, hungrySince time.Time, now time.Time) {
+	if deadlines == nil {
+		return
+	}
+	var waiter, ok = deadlines.waiting[philosopher.id]
+	if !ok {
+		waiter = Waiter{philosopher: philosopher, hungrySince: hungrySince}
+	}
+	delete(deadlines.waiting, philosopher.id)
+
+	var misses = deadlines.misses[philosopher.name]
+	if misses == nil {
+		misses = &DeadlineMisses{}
+		deadlines.misses[philosopher.name] = misses
+	}
+	misses.meals++
+	var waited = now.Sub(waiter.hungrySince)
+	if deadlines.hard > 0 && waited > deadlines.hard {
+		misses.hard++
+	} else if deadlines.soft > 0 && waited > deadlines.soft {
+		misses.soft++
+	}
+}
+
+// Left forgets a philosopher who left the table without eating
+func (deadlines *Deadlines) Left(philosopher *Philosopher) {
+	if deadlines == nil {
+		return
+	}
+	delete(deadlines.waiting, philosopher.id)
+}
+
+// String reports the deadline misses per philosopher
+func (deadlines *Deadlines) String() string {
+	var names []string
+	for name := range deadlines.misses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines = []string{fmt.Sprintf("soft %v, hard %v", deadlines.soft, deadlines.hard)}
+	for _, name := range names {
+		var misses = deadlines.misses[name]
+		lines = append(lines, fmt.Sprintf("  %s : %d soft and %d hard misses over %d meals", name, misses.soft, misses.hard, misses.meals))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shareUtensils tells if two philosophers may need the same utensil
+func shareUtensils(a, b []Need) bool {
+	for _, needA := range a {
+		for _, needB := range b {
+			for _, candidate := range needA.candidates {
+				if containsChopStick(needB.candidates, candidate) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
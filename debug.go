@@ -0,0 +1,321 @@
+//go:build !js
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const debugListed = 5 // events listed before and after the current one by the list command
+
+// Debugger steps forward and backward through the recorded trace of a dinner, it has :
+// - the events of the trace, and for each of them how many events of the same kind his philosopher had in a row
+// - the index of the current event, -1 before the first one, the state of the tables being the one after it
+// - the breakpoints, where continuing forward or backward stops
+type Debugger struct {
+	events      []Event
+	streaks     []int
+	current     int
+	breakpoints []Breakpoint
+	writer      io.Writer
+}
+
+// Breakpoint stops the Debugger on the event where a philosopher, or any of them for *, had count events of
+// the kind in a row, such as "3 rejected 2" for philosopher 3 being rejected twice in a row
+type Breakpoint struct {
+	philosopher string
+	kind        EventKind
+	count       int
+}
+
+// debugHelp tells the commands of the Debugger
+const debugHelp = `Commands :
+  next [n], n        step forward one event, or n events
+  back [n], b        step backward one event, or n events
+  goto <index>, g    go to the event of the given index, -1 being before the first event
+  continue, c        go forward to the next event matching a breakpoint
+  reverse, rc        go backward to the previous event matching a breakpoint
+  break <philosopher|*> <kind> [count]
+                     stop where the philosopher, or anyone, had count (1 by default) events of the kind in a row
+  breakpoints, bl    list the breakpoints
+  delete <number>    delete a breakpoint
+  event, p           print the current event along with its whole detail
+  list, l            list the events around the current one
+  state, s           print the state of the tables after the current event
+  help, h            print this help
+  quit, q            leave the debugger`
+
+// debug is the debug command, it loads the trace of a run saved in a Store with -store-events, or exported by
+// -export, and runs a Debugger reading its commands from the standard input
+func debug(arguments []string) error {
+	var flags = flag.NewFlagSet("debug", flag.ExitOnError)
+	var path = flags.String("store", "runs.db", "SQLite database where the run was saved along with its events")
+	var eventsFile = flags.String("events", "", "events.csv, events.json or events.pb file written by -export, instead of a run of the -store")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s debug [-store runs.db] <run id>\n       %s debug -events events.csv\n", os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output(), debugHelp)
+	}
+	flags.Parse(arguments)
+
+	var events []Event
+	var err error
+	if *eventsFile != "" {
+		if flags.NArg() > 0 {
+			return fmt.Errorf("debug: -events cannot be combined with a run id")
+		}
+		events, err = ReadEvents(*eventsFile)
+	} else {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		var id int64
+		if id, err = strconv.ParseInt(flags.Arg(0), 10, 64); err != nil {
+			return fmt.Errorf("debug: %q is not a run id", flags.Arg(0))
+		}
+		if _, err := os.Stat(*path); err != nil {
+			return fmt.Errorf("debug: %v", err)
+		}
+		var store *Store
+		if store, err = OpenStore(*path); err == nil {
+			events, err = store.Events(id)
+		}
+		if err == nil && len(events) == 0 {
+			err = fmt.Errorf("debug: run %d has no event in %s, save it with -store-events", id, *path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var debugger = NewDebugger(events, os.Stdout)
+	fmt.Printf("%d events, type help for the commands\n", len(events))
+	return debugger.Run(os.Stdin)
+}
+
+// NewDebugger creates a Debugger before the first of the events, writing to the given writer
+func NewDebugger(events []Event, writer io.Writer) *Debugger {
+	var debugger = &Debugger{events: events, streaks: make([]int, len(events)), current: -1, writer: writer}
+	var last = make(map[string]int) // the index of the last event of each philosopher
+	for i, event := range events {
+		debugger.streaks[i] = 1
+		if previous, found := last[event.Name]; found && events[previous].Kind == event.Kind {
+			debugger.streaks[i] = debugger.streaks[previous] + 1
+		}
+		last[event.Name] = i
+	}
+	return debugger
+}
+
+// Run executes the commands read from the input until quit or the end of the input, an unknown command
+// or a wrong argument being reported without stopping the Debugger
+func (debugger *Debugger) Run(input io.Reader) error {
+	var scanner = bufio.NewScanner(input)
+	for {
+		fmt.Fprint(debugger.writer, "(debug) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(debugger.writer)
+			return scanner.Err()
+		}
+		var fields = strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "q" {
+			return nil
+		}
+		if err := debugger.Execute(fields[0], fields[1:]); err != nil {
+			fmt.Fprintln(debugger.writer, err)
+		}
+	}
+}
+
+// Execute executes a command with its arguments
+func (debugger *Debugger) Execute(command string, arguments []string) error {
+	var count = func() (int, error) {
+		if len(arguments) == 0 {
+			return 1, nil
+		}
+		return strconv.Atoi(arguments[0])
+	}
+	switch command {
+	case "next", "n":
+		steps, err := count()
+		if err != nil {
+			return err
+		}
+		debugger.Goto(debugger.current + steps)
+	case "back", "b":
+		steps, err := count()
+		if err != nil {
+			return err
+		}
+		debugger.Goto(debugger.current - steps)
+	case "goto", "g":
+		if len(arguments) != 1 {
+			return fmt.Errorf("goto expects the index of an event")
+		}
+		index, err := strconv.Atoi(arguments[0])
+		if err != nil {
+			return err
+		}
+		debugger.Goto(index)
+	case "continue", "c":
+		debugger.Continue(1)
+	case "reverse", "rc":
+		debugger.Continue(-1)
+	case "break":
+		if len(arguments) < 2 || len(arguments) > 3 {
+			return fmt.Errorf("break expects a philosopher or *, a kind of event and optionally a count")
+		}
+		var kind, found = findEventKind(arguments[1])
+		var breakpoint = Breakpoint{philosopher: arguments[0], kind: kind, count: 1}
+		if !found {
+			return fmt.Errorf("unknown kind of event %q, expected one of %v", arguments[1], eventKinds)
+		}
+		if len(arguments) == 3 {
+			var err error
+			if breakpoint.count, err = strconv.Atoi(arguments[2]); err != nil || breakpoint.count < 1 {
+				return fmt.Errorf("the count of a breakpoint must be a positive number, got %q", arguments[2])
+			}
+		}
+		debugger.breakpoints = append(debugger.breakpoints, breakpoint)
+		fmt.Fprintf(debugger.writer, "Breakpoint %d : %s\n", len(debugger.breakpoints), breakpoint)
+	case "breakpoints", "bl":
+		for i, breakpoint := range debugger.breakpoints {
+			fmt.Fprintf(debugger.writer, "Breakpoint %d : %s\n", i+1, breakpoint)
+		}
+	case "delete":
+		if len(arguments) != 1 {
+			return fmt.Errorf("delete expects the number of a breakpoint")
+		}
+		number, err := strconv.Atoi(arguments[0])
+		if err != nil || number < 1 || number > len(debugger.breakpoints) {
+			return fmt.Errorf("no breakpoint %s", arguments[0])
+		}
+		debugger.breakpoints = append(debugger.breakpoints[:number-1], debugger.breakpoints[number:]...)
+	case "event", "p":
+		if debugger.current < 0 {
+			return fmt.Errorf("before the first event")
+		}
+		var event = debugger.events[debugger.current]
+		fmt.Fprintf(debugger.writer, "%s\n%s\n", debugger.line(debugger.current), event.Detail)
+	case "list", "l":
+		for i := max(debugger.current-debugListed, 0); i <= min(debugger.current+debugListed, len(debugger.events)-1); i++ {
+			var marker = "  "
+			if i == debugger.current {
+				marker = "=>"
+			}
+			fmt.Fprintf(debugger.writer, "%s %s\n", marker, debugger.line(i))
+		}
+	case "state", "s":
+		debugger.printState()
+	case "help", "h":
+		fmt.Fprintln(debugger.writer, debugHelp)
+	default:
+		return fmt.Errorf("unknown command %q, type help for the commands", command)
+	}
+	return nil
+}
+
+// Goto makes the event of the given index the current one, within the trace, and prints it
+func (debugger *Debugger) Goto(index int) {
+	debugger.current = min(max(index, -1), len(debugger.events)-1)
+	debugger.printCurrent()
+}
+
+// Continue goes forward, or backward when direction is -1, to the next event matching a breakpoint,
+// or to the end of the trace
+func (debugger *Debugger) Continue(direction int) {
+	for i := debugger.current + direction; i >= 0 && i < len(debugger.events); i += direction {
+		for number, breakpoint := range debugger.breakpoints {
+			if breakpoint.Matches(debugger.events[i], debugger.streaks[i]) {
+				debugger.current = i
+				fmt.Fprintf(debugger.writer, "Stopped at breakpoint %d : %s\n", number+1, breakpoint)
+				debugger.printCurrent()
+				return
+			}
+		}
+	}
+	if direction > 0 {
+		debugger.Goto(len(debugger.events) - 1)
+	} else {
+		debugger.Goto(-1)
+	}
+}
+
+// Matches tells if the breakpoint stops on the event, streak being how many events of its kind the philosopher had in a row
+func (breakpoint Breakpoint) Matches(event Event, streak int) bool {
+	return (breakpoint.philosopher == "*" || breakpoint.philosopher == event.Name) && breakpoint.kind == event.Kind && streak >= breakpoint.count
+}
+
+// String tells the condition of the breakpoint
+func (breakpoint Breakpoint) String() string {
+	var who = "philosopher " + breakpoint.philosopher
+	if breakpoint.philosopher == "*" {
+		who = "any philosopher"
+	}
+	if breakpoint.count == 1 {
+		return fmt.Sprintf("%s %s", who, breakpoint.kind)
+	}
+	return fmt.Sprintf("%s %s %d times in a row", who, breakpoint.kind, breakpoint.count)
+}
+
+// printCurrent prints the current event, or tells that the Debugger is at an end of the trace
+func (debugger *Debugger) printCurrent() {
+	if debugger.current < 0 {
+		fmt.Fprintln(debugger.writer, "Before the first event")
+		return
+	}
+	fmt.Fprintln(debugger.writer, debugger.line(debugger.current))
+	if debugger.current == len(debugger.events)-1 {
+		fmt.Fprintln(debugger.writer, "End of the trace")
+	}
+}
+
+// line tells the event of the given index on a line : its index, its time since the first event, the table
+// when there are several, and the sentence of the console, the first line of its detail otherwise
+func (debugger *Debugger) line(index int) string {
+	var event = debugger.events[index]
+	var message, _, _ = strings.Cut(eventMessage(event), "\n")
+	if message == "" {
+		message, _, _ = strings.Cut(fmt.Sprintf("%s %s %s", event.Name, event.Kind, event.Detail), "\n")
+	}
+	return fmt.Sprintf("#%d  +%v  %s", index, event.Time.Sub(debugger.events[0].Time), message)
+}
+
+// printState prints what each philosopher is doing after the current event, the state being rebuilt from the
+// start of the trace so that going backward costs the same as going forward
+func (debugger *Debugger) printState() {
+	var tracker = NewStateTracker(nil)
+	var reports = NewRecorder(false)
+	for _, event := range debugger.events[:debugger.current+1] {
+		tracker.Track(event)
+		reports.Record(event)
+	}
+	var rejected = make(map[string]int)
+	for _, report := range reports.Reports() {
+		rejected[report.Name] = report.Rejected
+	}
+
+	var state = tracker.State()
+	fmt.Fprintf(debugger.writer, "After event #%d, %d philosophers seen, %d backpressure events\n", debugger.current, len(state.Philosophers), state.Backpressure)
+	var writer = tabwriter.NewWriter(debugger.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tPHILOSOPHER\tNAME\tPHASE\tMEALS\tREJECTED")
+	for _, philosopher := range state.Philosophers {
+		fmt.Fprintf(writer, "%d\t%d\t%s\t%s\t%d\t%d\n", philosopher.Table, philosopher.Philosopher, philosopher.Name,
+			philosopher.Phase, philosopher.MealsEaten, rejected[philosopher.Name])
+	}
+	writer.Flush()
+	for _, violation := range state.Violations {
+		fmt.Fprintf(debugger.writer, "Phases : %s\n", violation)
+	}
+}
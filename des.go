@@ -0,0 +1,321 @@
+package main
+
+import (
+	"container/heap"
+	"sync/atomic"
+	"time"
+)
+
+// Below are the allowed values for the engine setting of the Config
+const concurrentEngine = "concurrent"
+const discreteEngine = "discrete"
+
+// Clock is the time of a dinner simulated by the discrete-event engine, it only moves from one occurrence to the next
+// A nil Clock is the real time
+type Clock struct {
+	nanos atomic.Int64
+}
+
+// NewClock creates a Clock telling the given time
+func NewClock(start time.Time) *Clock {
+	var clock = &Clock{}
+	clock.Set(start)
+	return clock
+}
+
+// Now returns the time of the dinner
+func (clock *Clock) Now() time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return time.Unix(0, clock.nanos.Load())
+}
+
+// Set moves the Clock to the given time
+func (clock *Clock) Set(now time.Time) {
+	clock.nanos.Store(now.UnixNano())
+}
+
+// DiscreteEngine simulates the philosophers of a dinner without goroutines nor sleeps : it keeps an agenda of
+// the moments a philosopher stops thinking or finishes his meal, and jumps from one to the next, moving the Clock
+// of the dinner. The Hosts, the Kitchen and the EventBus are the same as in the concurrent engine and read the time
+// from the Clock, and each philosopher draws his thinking times and meal durations from his own Random, so a
+// dinner gives the same statistics with both engines, the discrete one taking a few milliseconds instead of seconds.
+// The requests reach the Hosts at once, the engine waiting for each answer before going on, and the open mode
+// is not simulated since the guests arrive in real time.
+// Between two occurrences the engine takes the snapshots asked for, and it starts a dinner restored from a Snapshot
+// where the snapshot was taken instead of from the beginning.
+type DiscreteEngine struct {
+	clock     *Clock
+	start     time.Time
+	events    *EventBus
+	tables    []*Table
+	diners    [][]Diner
+	eating    []*SeatSet
+	gone      map[*Philosopher]PhilosopherState // the philosophers who left the table before eating all their meals
+	agenda    Agenda
+	seq       uint64
+	snapshots chan func()
+	restored  *Snapshot
+	done      chan struct{}
+}
+
+// Occurrence is the moment the philosopher of a seat stops thinking or finishes his meal, relatively to the start
+// of the dinner, seq orders the occurrences happening at the same time in the order they were scheduled
+// An occurrence is cancelled when the diner has been given another timer since, such as a meal being paused
+type Occurrence struct {
+	at    time.Duration
+	seq   uint64
+	table int
+	seat  int
+	timer int
+}
+
+// Agenda is the min-heap of the occurrences to come, the next one first
+type Agenda []Occurrence
+
+func (agenda Agenda) Len() int { return len(agenda) }
+func (agenda Agenda) Less(i, j int) bool {
+	if agenda[i].at != agenda[j].at {
+		return agenda[i].at < agenda[j].at
+	}
+	return agenda[i].seq < agenda[j].seq
+}
+func (agenda Agenda) Swap(i, j int) { agenda[i], agenda[j] = agenda[j], agenda[i] }
+func (agenda *Agenda) Push(x any)   { *agenda = append(*agenda, x.(Occurrence)) }
+func (agenda *Agenda) Pop() any {
+	var old = *agenda
+	var occurrence = old[len(old)-1]
+	*agenda = old[:len(old)-1]
+	return occurrence
+}
+
+// NewDiscreteEngine creates the engine simulating the philosophers of the tables, and gives its Clock to the tables
+// and to the EventBus before their Hosts start
+func NewDiscreteEngine(tables []*Table, events *EventBus) *DiscreteEngine {
+	var engine = &DiscreteEngine{
+		clock:     NewClock(time.Now()),
+		events:    events,
+		tables:    tables,
+		gone:      make(map[*Philosopher]PhilosopherState),
+		snapshots: make(chan func(), 1),
+		done:      make(chan struct{})}
+	for _, table := range tables {
+		table.clock = engine.clock
+		for _, utensil := range table.utensils {
+			utensil.clock = engine.clock
+		}
+		engine.diners = append(engine.diners, make([]Diner, len(table.philosophers)))
+		engine.eating = append(engine.eating, NewSeatSet(len(table.philosophers)))
+	}
+	events.SetClock(engine.clock)
+	return engine
+}
+
+// Start simulates the dinner in the background, each meal eaten is told to the Completion
+func (engine *DiscreteEngine) Start(completion *Completion) {
+	go engine.run(completion)
+}
+
+// run goes through the agenda until every philosopher has eaten all his meals or left the table
+func (engine *DiscreteEngine) run(completion *Completion) {
+	defer close(engine.done)
+	engine.start = engine.clock.Now()
+	if engine.restored != nil {
+		engine.restore(engine.restored)
+		engine.events.SetStart(engine.start)
+	} else {
+		for table := range engine.tables {
+			for seat := range engine.diners[table] {
+				engine.diners[table][seat].hungrySince = engine.start
+				engine.think(table, seat, 0)
+			}
+		}
+	}
+
+	for engine.agenda.Len() > 0 {
+		select {
+		case capture := <-engine.snapshots:
+			capture()
+		default:
+		}
+		var occurrence = heap.Pop(&engine.agenda).(Occurrence)
+		var diner = &engine.diners[occurrence.table][occurrence.seat]
+		if occurrence.timer != diner.timer {
+			continue
+		}
+		engine.clock.Set(engine.start.Add(occurrence.at))
+		if diner.eating {
+			engine.finish(occurrence.table, occurrence.seat, occurrence.at, completion)
+		} else {
+			engine.ask(occurrence.table, occurrence.seat, occurrence.at, completion)
+		}
+	}
+}
+
+// schedule gives the diner of the seat a new timer, cancelling the previous one
+func (engine *DiscreteEngine) schedule(table, seat int, at time.Duration) {
+	var diner = &engine.diners[table][seat]
+	diner.timer++
+	engine.seq++
+	heap.Push(&engine.agenda, Occurrence{at: at, seq: engine.seq, table: table, seat: seat, timer: diner.timer})
+}
+
+// think lets the philosopher of the seat think for a while before he asks to eat, or back off once turned down
+func (engine *DiscreteEngine) think(table, seat int, now time.Duration) {
+	var philosopher = engine.tables[table].philosophers[seat]
+	engine.schedule(table, seat, now+philosopher.nextWait(philosopher.random, &engine.diners[table][seat].retry))
+}
+
+// askToEat builds the request of the philosopher to eat, which reaches the Host at once
+func (engine *DiscreteEngine) askToEat(philosopher *Philosopher, hungrySince time.Time) GrantRequest {
+	var request = philosopher.askToEat(hungrySince)
+	request.sent = time.Time{}
+	return request
+}
+
+// releaseMessage builds the message of the philosopher leaving his utensils, which reaches the Host at once
+func (engine *DiscreteEngine) releaseMessage(philosopher *Philosopher, paused bool) Release {
+	var release = philosopher.release(paused)
+	release.sent = time.Time{}
+	return release
+}
+
+// ask makes the philosopher of the seat ask to eat, the way eat does, and starts his meal when he is allowed to
+func (engine *DiscreteEngine) ask(index, seat int, now time.Duration, completion *Completion) {
+	var table = engine.tables[index]
+	var philosopher = table.philosophers[seat]
+	var diner = &engine.diners[index][seat]
+
+	grant, admitted := philosopher.admission.TryEat(philosopher)
+	if !admitted {
+		table.requests(seat).Tell(engine.askToEat(philosopher, diner.hungrySince))
+		grant = <-philosopher.feedbackChannel
+		for grant.preempt {
+			grant = <-philosopher.feedbackChannel
+		}
+		engine.interrupt(index, now, completion)
+	}
+	if grant.shutdown {
+		// the dinner is stopped, the remaining meals cannot be eaten
+		engine.leave(philosopher, PhaseLeft, completion)
+		return
+	}
+	if starving, since := philosopher.energy.Starved(engine.clock.Now()); starving && !grant.allowed {
+		var starve = philosopher.starve(since)
+		starve.sent = time.Time{}
+		table.requests(seat).Tell(starve)
+		engine.leave(philosopher, PhaseStarved, completion)
+		return
+	}
+	if !grant.allowed {
+		diner.retry.Rejected()
+		engine.think(index, seat, now)
+		return
+	}
+	diner.retry.Reset()
+
+	philosopher.takeUtensils(grant.chopSticks)
+	if diner.mealLeft == 0 {
+		diner.mealLeft = philosopher.mealTime(philosopher.random)
+	}
+	diner.chopSticks = grant.chopSticks
+	diner.admitted = admitted
+	diner.eating = true
+	diner.mealStart = engine.clock.Now()
+	engine.eating[index].Add(seat)
+	philosopher.emit(eventStarted, "")
+	engine.schedule(index, seat, now+diner.mealLeft)
+}
+
+// interrupt pauses the meals of the philosophers the Host asked to pause while deciding, and ends the meals it
+// revoked once the dinner is stopped
+func (engine *DiscreteEngine) interrupt(index int, now time.Duration, completion *Completion) {
+	if engine.tables[index].config.PreemptAfter == 0 && !engine.tables[index].stopped.Load() {
+		return
+	}
+	var seats = append([]int{}, engine.eating[index].Members()...)
+	for _, seat := range seats {
+		select {
+		case grant := <-engine.tables[index].philosophers[seat].feedbackChannel:
+			if grant.shutdown {
+				engine.revoke(index, seat, completion)
+			} else if grant.preempt {
+				engine.pause(index, seat, now)
+			}
+		default:
+		}
+	}
+}
+
+// pause ends the meal of the philosopher of the seat before it is over, he asks to eat the rest of it after thinking
+func (engine *DiscreteEngine) pause(index, seat int, now time.Duration) {
+	var table = engine.tables[index]
+	var philosopher = table.philosophers[seat]
+	var diner = &engine.diners[index][seat]
+
+	var at = engine.clock.Now()
+	diner.mealLeft -= at.Sub(diner.mealStart)
+	philosopher.emit(eventPaused, "")
+	engine.release(index, seat)
+	table.requests(seat).Tell(engine.releaseMessage(philosopher, true))
+	engine.think(index, seat, now)
+}
+
+// revoke ends the meal of the philosopher of the seat because the dinner is stopped, he leaves the table
+func (engine *DiscreteEngine) revoke(index, seat int, completion *Completion) {
+	var table = engine.tables[index]
+	var philosopher = table.philosophers[seat]
+	var diner = &engine.diners[index][seat]
+
+	diner.mealLeft -= engine.clock.Now().Sub(diner.mealStart)
+	diner.timer++ // cancels the end of his meal
+	philosopher.emit(eventPaused, revokedMeal)
+	engine.release(index, seat)
+	table.requests(seat).Tell(engine.releaseMessage(philosopher, true))
+	engine.leave(philosopher, PhaseLeft, completion)
+}
+
+// release lets the philosopher of the seat leave his utensils, whether he finished or paused his meal
+func (engine *DiscreteEngine) release(index, seat int) {
+	var philosopher = engine.tables[index].philosophers[seat]
+	var diner = &engine.diners[index][seat]
+	philosopher.energy.Eat(diner.mealStart, engine.clock.Now())
+	philosopher.leaveUtensils(diner.chopSticks)
+	diner.chopSticks = nil
+	diner.eating = false
+	engine.eating[index].Remove(seat)
+}
+
+// finish ends the meal of the philosopher of the seat, and lets him think before his next meal if he has some left
+func (engine *DiscreteEngine) finish(index, seat int, now time.Duration, completion *Completion) {
+	var table = engine.tables[index]
+	var philosopher = table.philosophers[seat]
+	var diner = &engine.diners[index][seat]
+
+	philosopher.emit(eventFinished, "")
+	engine.release(index, seat)
+	philosopher.countEating++
+	diner.hungrySince = engine.clock.Now()
+	diner.mealLeft = 0
+
+	// the Host is told before the Completion, the table is closed once all the meals are completed
+	if diner.admitted {
+		philosopher.admission.Release(philosopher)
+	} else {
+		table.requests(seat).Tell(engine.releaseMessage(philosopher, false))
+	}
+	completion.Complete(index, seat)
+
+	if philosopher.countEating < philosopher.meals {
+		engine.think(index, seat, now)
+	}
+}
+
+// leave gives up the remaining meals of the philosopher, who starved or left the table
+func (engine *DiscreteEngine) leave(philosopher *Philosopher, phase Phase, completion *Completion) {
+	engine.gone[philosopher] = PhilosopherState{Phase: phase, MealsEaten: philosopher.countEating}
+	// a snapshot tells that he has nothing left to eat
+	philosopher.countEating = philosopher.meals
+	completion.GiveUp(philosopher.table, philosopher.id)
+}
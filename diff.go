@@ -0,0 +1,299 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// RunResult is what -result saves of a run for the diff command :
+// - what identifies the run and its configuration
+// - how long the dinner lasted, the meals eaten and the throughput in meals per second
+// - the requests to eat accepted, and the ones rejected by cause, the throttled ones included
+// - how long each meal waited before it started, from the first rejection of the philosopher, 0 when he was
+// accepted at once
+// - the Fairness of the waits of the philosophers, and the efficiency of the dinner in percent of its Optimum
+// - the starved philosophers, and the illegal transitions of the phases of the philosophers
+type RunResult struct {
+	Run        RunInfo         `json:"run"`
+	Config     Config          `json:"config"`
+	Duration   time.Duration   `json:"duration"`
+	Meals      int             `json:"meals"`
+	Throughput float64         `json:"throughput"`
+	Accepted   int             `json:"accepted"`
+	Rejected   map[string]int  `json:"rejected"`
+	Waits      []time.Duration `json:"waits"`
+	Fairness   Fairness        `json:"fairness"`
+	Efficiency float64         `json:"efficiency"`
+	Starved    []string        `json:"starved"`
+	Violations []string        `json:"violations"`
+}
+
+// NewRunResult creates the RunResult of a run from its Result, the summary of a SteadyState following the whole
+// dinner, the reports of the philosophers and the illegal transitions of its phases
+func NewRunResult(info RunInfo, config Config, result Result, summary SteadySummary, reports []PhilosopherReport, violations []string) RunResult {
+	var stats = statsOf(result)
+	return RunResult{
+		Run:        info,
+		Config:     config,
+		Duration:   summary.Duration,
+		Meals:      summary.Meals,
+		Throughput: summary.Throughput,
+		Accepted:   stats.accepted + stats.admitted,
+		Rejected:   stats.rejected,
+		Waits:      summary.Waits,
+		Fairness:   NewFairness(reports),
+		Efficiency: NewEfficiency(config, reports, summary.Duration).Percent,
+		Starved:    result.Starved(),
+		Violations: violations}
+}
+
+// SaveRunResult writes the RunResult in the file, in JSON
+func SaveRunResult(path string, result RunResult) error {
+	data, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadRunResult reads a RunResult saved by -result
+func LoadRunResult(path string) (RunResult, error) {
+	var result RunResult
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("%s: %v", path, err)
+	}
+	sort.Slice(result.Waits, func(i, j int) bool { return result.Waits[i] < result.Waits[j] })
+	return result, nil
+}
+
+// diff is the diff command, it compares two runs saved by -result and marks the differences which are statistically
+// significant, the ones whose p-value is below alpha : the throughput with a test of the rates of two Poisson
+// processes, the waits with a Mann-Whitney rank test, and the rejections and the starved philosophers with a test
+// of two proportions. The percentiles of the waits are given along, the rank test telling whether the waits of one
+// run tend to be longer than the ones of the other.
+func diff(arguments []string) error {
+	var flags = flag.NewFlagSet("diff", flag.ExitOnError)
+	var alpha = flags.Float64("alpha", 0.05, "significance level under which a difference is marked")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [-alpha 0.05] runA.json runB.json\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("diff compares two results saved by -result, got %d", flags.NArg())
+	}
+
+	a, err := LoadRunResult(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := LoadRunResult(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("A : %s (%s)\n", a.Run, flags.Arg(0))
+	fmt.Printf("B : %s (%s)\n", b.Run, flags.Arg(1))
+	if a.Run.ConfigHash != b.Run.ConfigHash {
+		fmt.Println("The runs have different configurations")
+	}
+	compareResults(os.Stdout, a, b, *alpha)
+	return nil
+}
+
+// compareResults writes the statistics of both runs side by side, with their relative difference and the p-value
+// of the test of each of them, a * marking the ones below alpha, then the violations seen in one run only
+func compareResults(output io.Writer, a, b RunResult, alpha float64) {
+	var writer = tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "\tA\tB\tdifference\tp-value\n")
+	var formattedRow = func(label string, format string, valueA, valueB float64, p float64) {
+		var difference = "="
+		if valueA == 0 && valueB != 0 {
+			difference = "from 0"
+		} else if valueA != valueB {
+			difference = fmt.Sprintf("%+.0f%%", (valueB-valueA)/math.Abs(valueA)*100)
+		}
+		var significance = ""
+		if !math.IsNaN(p) {
+			significance = fmt.Sprintf("%.3f", p)
+			if p < alpha {
+				significance += " *"
+			}
+		}
+		fmt.Fprintf(writer, "%s\t"+format+"\t"+format+"\t%s\t%s\n", label, valueA, valueB, difference, significance)
+	}
+	var row = func(label string, valueA, valueB float64, p float64) {
+		formattedRow(label, "%.4g", valueA, valueB, p)
+	}
+
+	row("duration (s)", a.Duration.Seconds(), b.Duration.Seconds(), math.NaN())
+	row("meals", float64(a.Meals), float64(b.Meals), math.NaN())
+	row("throughput (meals/s)", a.Throughput, b.Throughput, poissonRatesTest(a.Meals, a.Duration, b.Meals, b.Duration))
+	row("efficiency (%)", a.Efficiency, b.Efficiency, math.NaN())
+	row("waits (rank test)", float64(len(a.Waits)), float64(len(b.Waits)), mannWhitneyTest(a.Waits, b.Waits))
+	row("  wait mean (ms)", meanMilliseconds(a.Waits), meanMilliseconds(b.Waits), math.NaN())
+	for _, percent := range []int{50, 90, 99} {
+		var percentileA = SteadySummary{Waits: a.Waits}.Percentile(percent)
+		var percentileB = SteadySummary{Waits: b.Waits}.Percentile(percent)
+		row(fmt.Sprintf("  wait p%d (ms)", percent), milliseconds(percentileA), milliseconds(percentileB), math.NaN())
+	}
+	formattedRow("fairness (Jain index)", "%.3f", a.Fairness.Index, b.Fairness.Index, math.NaN())
+	formattedRow("latency max/min", "%.3f", a.Fairness.LatencyRatio, b.Fairness.LatencyRatio, math.NaN())
+
+	var requestsA, requestsB = a.Accepted + rejectedTotal(a), b.Accepted + rejectedTotal(b)
+	row("requests", float64(requestsA), float64(requestsB), math.NaN())
+	row("rejected", float64(rejectedTotal(a)), float64(rejectedTotal(b)),
+		proportionsTest(rejectedTotal(a), requestsA, rejectedTotal(b), requestsB))
+	var causes = make(map[string]bool)
+	for cause := range a.Rejected {
+		causes[cause] = true
+	}
+	for cause := range b.Rejected {
+		causes[cause] = true
+	}
+	var sorted []string
+	for cause := range causes {
+		sorted = append(sorted, cause)
+	}
+	sort.Strings(sorted)
+	for _, cause := range sorted {
+		row("  rejected for "+cause, float64(a.Rejected[cause]), float64(b.Rejected[cause]),
+			proportionsTest(a.Rejected[cause], requestsA, b.Rejected[cause], requestsB))
+	}
+	row("starved", float64(len(a.Starved)), float64(len(b.Starved)),
+		proportionsTest(len(a.Starved), a.Config.Tables*a.Config.Philosophers, len(b.Starved), b.Config.Tables*b.Config.Philosophers))
+	row("violations", float64(len(a.Violations)), float64(len(b.Violations)), math.NaN())
+	writer.Flush()
+
+	for _, violation := range onlyIn(a.Violations, b.Violations) {
+		fmt.Fprintf(output, "Only in A : %s\n", violation)
+	}
+	for _, violation := range onlyIn(b.Violations, a.Violations) {
+		fmt.Fprintf(output, "Only in B : %s\n", violation)
+	}
+}
+
+// rejectedTotal sums the requests of the run rejected whatever their cause
+func rejectedTotal(result RunResult) int {
+	var rejected = 0
+	for _, count := range result.Rejected {
+		rejected += count
+	}
+	return rejected
+}
+
+// onlyIn returns the lines of a which are not in b
+func onlyIn(a, b []string) []string {
+	var inB = make(map[string]bool)
+	for _, line := range b {
+		inB[line] = true
+	}
+	var only []string
+	for _, line := range a {
+		if !inB[line] {
+			only = append(only, line)
+		}
+	}
+	return only
+}
+
+// milliseconds returns the duration in milliseconds
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// meanMilliseconds returns the mean of the durations in milliseconds, 0 when there are none
+func meanMilliseconds(durations []time.Duration) float64 {
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	return milliseconds(meanDuration(total, int64(len(durations))))
+}
+
+// twoSidedP returns the two-sided p-value of a statistic following the standard normal distribution
+func twoSidedP(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// poissonRatesTest tests whether the meals of both runs were eaten at the same rate, seeing them as Poisson
+// processes, it returns NaN when a run has no duration
+func poissonRatesTest(mealsA int, durationA time.Duration, mealsB int, durationB time.Duration) float64 {
+	var secondsA, secondsB = durationA.Seconds(), durationB.Seconds()
+	if secondsA <= 0 || secondsB <= 0 {
+		return math.NaN()
+	}
+	var variance = float64(mealsA)/(secondsA*secondsA) + float64(mealsB)/(secondsB*secondsB)
+	if variance == 0 {
+		return 1
+	}
+	return twoSidedP((float64(mealsA)/secondsA - float64(mealsB)/secondsB) / math.Sqrt(variance))
+}
+
+// proportionsTest tests whether the counts out of the totals of both runs come from the same proportion, it
+// returns NaN when a run has no total
+func proportionsTest(countA, totalA, countB, totalB int) float64 {
+	if totalA == 0 || totalB == 0 {
+		return math.NaN()
+	}
+	var pooled = float64(countA+countB) / float64(totalA+totalB)
+	var variance = pooled * (1 - pooled) * (1/float64(totalA) + 1/float64(totalB))
+	if variance == 0 {
+		return 1
+	}
+	return twoSidedP((float64(countA)/float64(totalA) - float64(countB)/float64(totalB)) / math.Sqrt(variance))
+}
+
+// mannWhitneyTest tests whether the waits of one run tend to be longer than the ones of the other, with the normal
+// approximation of the Mann-Whitney U statistic corrected for the ties, which the waits of 0 make plenty of.
+// The waits must be sorted, it returns NaN when a run has none.
+func mannWhitneyTest(a, b []time.Duration) float64 {
+	var n1, n2 = float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return math.NaN()
+	}
+	// the ranks of the waits of a among all the waits, the tied waits sharing the mean of their ranks
+	var rankSum, ties = 0.0, 0.0
+	var i, j = 0, 0
+	for i < len(a) || j < len(b) {
+		var value time.Duration
+		if j >= len(b) || (i < len(a) && a[i] <= b[j]) {
+			value = a[i]
+		} else {
+			value = b[j]
+		}
+		var countA, countB = 0, 0
+		for i < len(a) && a[i] == value {
+			i++
+			countA++
+		}
+		for j < len(b) && b[j] == value {
+			j++
+			countB++
+		}
+		var tied = float64(countA + countB)
+		var rank = float64(i+j) - (tied-1)/2
+		rankSum += float64(countA) * rank
+		ties += tied*tied*tied - tied
+	}
+	var u = rankSum - n1*(n1+1)/2
+	var n = n1 + n2
+	var variance = n1 * n2 / 12 * (n + 1 - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	return twoSidedP((u - n1*n2/2) / math.Sqrt(variance))
+}
@@ -0,0 +1,39 @@
+package main
+
+// Dish is the central serving dish of a table, independently of the utensils only capacity philosophers
+// can serve themselves at the same time. It behaves like a semaphore owned by the Host of the table, so it
+// needs no locking. A nil Dish means that the table has no central dish and never limits the philosophers.
+type Dish struct {
+	capacity int
+	serving  int
+	peak     int
+}
+
+// NewDish creates a central dish where capacity philosophers can serve themselves at the same time
+func NewDish(capacity int) *Dish {
+	return &Dish{capacity: capacity}
+}
+
+// Full tells if the dish already serves as many philosophers as it can
+func (dish *Dish) Full() bool {
+	return dish != nil && dish.serving >= dish.capacity
+}
+
+// Acquire lets one more philosopher serve himself, the Host must have checked that the dish is not full
+func (dish *Dish) Acquire() {
+	if dish == nil {
+		return
+	}
+	dish.serving++
+	if dish.serving > dish.peak {
+		dish.peak = dish.serving
+	}
+}
+
+// Release is called once a philosopher who served himself has finished eating
+func (dish *Dish) Release() {
+	if dish == nil || dish.serving == 0 {
+		return
+	}
+	dish.serving--
+}
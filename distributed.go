@@ -0,0 +1,635 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const maxFrameSize = 1 << 20              // messages of the table protocol are small, anything bigger is an error
+const maxReconnects = 10                  // a remote philosopher gives up after 10 failed attempts to reach the Host
+const reconnectDelay = time.Second        // and waits 1 second between attempts
+const dialTimeout = 5 * time.Second       // to connect to the Host
+const heartbeatTimeout = 30 * time.Second // a philosopher silent for this long is considered gone
+
+// TableServer runs the Host of a table whose philosophers live in other processes, see api/philosophers/v1/table.proto
+// Each connection is relayed to the Host as if the philosopher was seated locally. When a connection is lost,
+// the utensils of the philosopher are given back to the Host and his seat is freed, so that he can join again
+// and eat the meals he has not eaten yet.
+type TableServer struct {
+	mutex      sync.Mutex
+	table      *Table
+	seats      []RemoteSeat
+	changed    chan struct{} // closed and replaced each time a philosopher eats, so that the standby Hosts are told right away
+	completion *Completion
+}
+
+// RemoteSeat tells if a philosopher is connected to his seat and how many meals he has eaten
+type RemoteSeat struct {
+	connected bool
+	eaten     int
+}
+
+// JoinMessage is the first message sent over a connection to a served table, by a philosopher or by a standby Host
+type JoinMessage struct {
+	seat    int
+	replica bool
+}
+
+// ServeTable listens on the given address and runs the Host of the table described by the configuration
+// until all the philosophers who joined from other processes have eaten all their meals
+func ServeTable(address string, config Config, events *EventBus) (Result, error) {
+	if err := checkServedConfig(config); err != nil {
+		return Result{}, err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return Result{}, err
+	}
+	defer listener.Close()
+
+	var server = NewTableServer(config, events, nil)
+	var network = NewSimulatedNetwork(config.Network.Scaled(config.Speed), 0)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				join, err := readJoin(conn)
+				switch {
+				case err != nil:
+					refuse(conn, err.Error())
+				case join.replica:
+					refuse(conn, "this Host has no replicas")
+				default:
+					if conn = network.Link(conn, join.seat); conn != nil {
+						server.serve(frameConn{conn}, join.seat)
+					}
+				}
+			}()
+		}
+	}()
+	return server.Wait(), nil
+}
+
+// checkServedConfig tells if the table described by the configuration can be served to other processes
+func checkServedConfig(config Config) error {
+	if config.Tables > 1 || config.ArrivalRate > 0 || config.Shards > 1 || config.Admission == lockFreeAdmission || config.Execution == workerPoolExecution ||
+		config.Engine == discreteEngine {
+		return fmt.Errorf("a served table cannot be combined with several tables, the open mode, shards, the lock-free admission, the worker pool nor the discrete engine")
+	}
+	return nil
+}
+
+// NewTableServer starts the Host of a served table, eaten is how many meals each philosopher has already eaten
+// when the table is taken over from another Host, it is nil for a new table
+func NewTableServer(config Config, events *EventBus, eaten []int) *TableServer {
+	var server = &TableServer{
+		table:   NewTable(0, config, nil, events),
+		seats:   make([]RemoteSeat, config.Philosophers),
+		changed: make(chan struct{})}
+	for seat := range server.seats {
+		if seat < len(eaten) {
+			server.seats[seat].eaten = min(eaten[seat], config.MealsOf(seat))
+		}
+		server.table.philosophers[seat].countEating = server.seats[seat].eaten
+	}
+	server.completion = NewCompletion([]*Table{server.table})
+	events.Handle(server.completion.Handle)
+	go server.completion.Run()
+	server.table.startHosts()
+	return server
+}
+
+// Wait waits until all the philosophers have eaten all their meals, or left once the dinner should end, then closes
+// the table
+// The Completion is left running, a connection still open may tell it about a meal
+func (server *TableServer) Wait() Result {
+	select {
+	case <-server.completion.Over():
+		server.table.Stop()
+		<-server.completion.Done()
+	case <-server.completion.Done():
+	}
+	server.table.Close()
+	return Result{Tables: []*Table{server.table}, Ended: server.completion.Reason()}
+}
+
+// Eaten returns how many meals each philosopher has eaten so far, along with a channel closed as soon as this changes
+func (server *TableServer) Eaten() ([]int, <-chan struct{}) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	var eaten = make([]int, len(server.seats))
+	for seat := range server.seats {
+		eaten[seat] = server.seats[seat].eaten
+	}
+	return eaten, server.changed
+}
+
+// tableConn is the connection of a philosopher of another process to a served table, in one of the encodings of its
+// messages : the messages of table.proto prefixed by their length (see frameConn), or the text protocol (see textConn)
+type tableConn interface {
+	// welcome answers the Join message of the philosopher
+	welcome(welcome WelcomeMessage) error
+	// request reads the next request of the philosopher of the seat, failing once he is silent for too long
+	request(seat int) (Request, error)
+	// answer sends him an answer of the Host
+	answer(grant Grant) error
+	Close() error
+}
+
+// frameConn is a connection carrying the messages of table.proto, each of them prefixed by its length
+type frameConn struct {
+	net.Conn
+}
+
+func (conn frameConn) welcome(welcome WelcomeMessage) error {
+	return writeFrame(conn, welcome.proto())
+}
+
+func (conn frameConn) request(seat int) (Request, error) {
+	conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+	frame, err := readFrame(conn)
+	if err != nil {
+		return Request{}, err
+	}
+	return decodeTableRequest(frame, seat)
+}
+
+func (conn frameConn) answer(grant Grant) error {
+	return writeFrame(conn, encodeTableResponse(grant))
+}
+
+// serve relays the requests of a remote philosopher to the Host, and the answers of the Host back to him
+func (server *TableServer) serve(conn tableConn, seat int) {
+	defer conn.Close()
+
+	var welcome, ok = server.sit(seat)
+	if ok {
+		defer server.leave(seat)
+	}
+	if err := conn.welcome(welcome); err != nil || !ok {
+		return
+	}
+
+	var relay = &remoteRelay{
+		seat:            seat,
+		feedbackChannel: server.table.philosophers[seat].feedbackChannel,
+		requestChan:     server.table.requests(seat),
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{})}
+	go relay.answer(conn)
+
+	for {
+		request, err := conn.request(seat)
+		if err != nil {
+			break
+		}
+		relay.forward(request)
+
+		switch request.command {
+		case finishedEating:
+			server.mutex.Lock()
+			server.seats[seat].eaten++
+			server.notify()
+			server.mutex.Unlock()
+			server.completion.Complete(0, seat)
+		case starved:
+			server.mutex.Lock()
+			server.seats[seat].eaten = server.table.config.MealsOf(seat)
+			server.notify()
+			server.mutex.Unlock()
+			server.completion.GiveUp(0, seat)
+		}
+	}
+
+	relay.lost()
+	// the next philosopher taking the seat gets the answers of the Host once the pending ones are received
+	<-relay.stopped
+}
+
+// notify tells whoever waits on the changed channel that a philosopher has eaten, the mutex must be held
+func (server *TableServer) notify() {
+	close(server.changed)
+	server.changed = make(chan struct{})
+}
+
+// WelcomeMessage answers the Join message of a philosopher, with the reason why he cannot sit, or with his name, the
+// meals he still has to eat and the configuration of the table
+type WelcomeMessage struct {
+	err       string
+	seat      int
+	name      string
+	mealsLeft int
+	config    []byte
+}
+
+// proto encodes the message as the Welcome message of table.proto
+func (welcome WelcomeMessage) proto() protoMessage {
+	var message protoMessage
+	message.String(1, welcome.err)
+	if welcome.err != "" {
+		return message
+	}
+	message.String(2, welcome.name)
+	message.Int(3, int64(welcome.mealsLeft))
+	message.String(4, string(welcome.config))
+	message.Uint(5, schemaVersion)
+	return message
+}
+
+// sit gives his seat to the philosopher who just connected, it returns false along with the WelcomeMessage
+// when he cannot sit or has nothing left to eat
+func (server *TableServer) sit(seat int) (WelcomeMessage, bool) {
+	var welcome = WelcomeMessage{seat: seat}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	switch {
+	case seat < 0 || seat >= len(server.seats):
+		welcome.err = fmt.Sprintf("there is no seat %d at this table", seat)
+		return welcome, false
+	case server.seats[seat].connected:
+		welcome.err = fmt.Sprintf("seat %d is already taken", seat)
+		return welcome, false
+	}
+
+	welcome.mealsLeft = server.table.config.MealsOf(seat) - server.seats[seat].eaten
+	welcome.config, _ = json.Marshal(server.table.config)
+	welcome.name = server.table.philosophers[seat].name
+	if welcome.mealsLeft == 0 {
+		return welcome, false
+	}
+	server.seats[seat].connected = true
+	return welcome, true
+}
+
+// readJoin reads the Join message a connection to a served table starts with
+func readJoin(conn net.Conn) (JoinMessage, error) {
+	var join JoinMessage
+	conn.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+	frame, err := readFrame(conn)
+	if err != nil {
+		return join, err
+	}
+	err = parseProto(frame, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			join.seat = int(int32(varint))
+		case 2:
+			join.replica = varint != 0
+		}
+		return nil
+	})
+	return join, err
+}
+
+// refuse answers a Join message with the reason why the connection cannot go on, then closes it
+func refuse(conn net.Conn, reason string) {
+	var welcome protoMessage
+	welcome.String(1, reason)
+	writeFrame(conn, welcome)
+	conn.Close()
+}
+
+// leave frees the seat of a philosopher whose connection is over
+func (server *TableServer) leave(seat int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.seats[seat].connected = false
+}
+
+// remoteRelay keeps track of what the Host granted to a remote philosopher, so that a lost connection
+// never leaves utensils held by a philosopher who is gone :
+// - pending is how many requests to eat are waiting for an answer of the Host
+// - eating tells if the Host let the philosopher eat and he has not finished nor paused yet
+// The Host answers on the feedback channel of the seat, stopped is closed once the relay does not read it anymore
+type remoteRelay struct {
+	mutex           sync.Mutex
+	seat            int
+	feedbackChannel chan Grant
+	requestChan     Mailbox
+	pending         int
+	eating          bool
+	gone            bool
+	stop            chan struct{}
+	stopped         chan struct{}
+}
+
+// forward sends a request of the remote philosopher to the Host
+func (relay *remoteRelay) forward(request Request) {
+	relay.mutex.Lock()
+	switch request.command {
+	case wantToEat:
+		relay.pending++
+	case finishedEating, pausedEating, abortedEating:
+		relay.eating = false
+	}
+	relay.mutex.Unlock()
+	relay.requestChan.Tell(request)
+}
+
+// answer writes the answers of the Host to the remote philosopher, once the connection is lost it keeps
+// receiving the answers still pending and gives back the utensils the Host may have granted meanwhile
+func (relay *remoteRelay) answer(conn tableConn) {
+	defer close(relay.stopped)
+	for {
+		select {
+		case grant := <-relay.feedbackChannel:
+			relay.mutex.Lock()
+			if !grant.preempt {
+				relay.pending--
+			}
+			if grant.allowed {
+				relay.eating = true
+			}
+			var gone = relay.gone
+			var release = gone && relay.pending == 0 && relay.eating
+			var done = gone && relay.pending == 0
+			if release {
+				relay.eating = false
+			}
+			relay.mutex.Unlock()
+
+			if !gone {
+				conn.answer(grant)
+			}
+			if release {
+				relay.requestChan.Tell(Release{philosopher: relay.seat, paused: true})
+			}
+			if done {
+				return
+			}
+		case <-relay.stop:
+			return
+		}
+	}
+}
+
+// lost is called once the connection is lost, the utensils are given back right away unless
+// an answer of the Host is still pending, in which case answer gives them back once it arrives
+func (relay *remoteRelay) lost() {
+	relay.mutex.Lock()
+	relay.gone = true
+	var release = relay.pending == 0 && relay.eating
+	var done = relay.pending == 0
+	if release {
+		relay.eating = false
+	}
+	relay.mutex.Unlock()
+
+	if release {
+		relay.requestChan.Tell(Release{philosopher: relay.seat, paused: true})
+	}
+	if done {
+		close(relay.stop)
+	}
+}
+
+// JoinTable runs a single philosopher in this process, seated at the table served by the Host at one of the given addresses
+// When the connection is lost he tries to join again, and only eats the meals he has not eaten yet. When the table
+// is served by several replicas of the Host, he tries them in turn until he finds the one leading the table.
+// His events and the connections lost are written to the logger.
+func JoinTable(addresses []string, seat int, logger Logger) error {
+	var address = 0
+	for attempt := 0; ; attempt++ {
+		finished, err := joinTableOnce(addresses[address], seat, logger)
+		if finished {
+			return nil
+		}
+		if attempt == maxReconnects*len(addresses) {
+			return fmt.Errorf("giving up after %d attempts to reach the Host: %v", attempt+1, err)
+		}
+		var lost = addresses[address]
+		address = (address + 1) % len(addresses)
+		if address == 0 {
+			logger.Log(LevelWarn, fmt.Sprintf("Lost the Host at %s (%v), joining again in %v", lost, err, reconnectDelay),
+				"address", lost, "error", err, "attempt", attempt)
+			time.Sleep(reconnectDelay)
+		} else {
+			logger.Log(LevelWarn, fmt.Sprintf("Lost the Host at %s (%v), trying %s", lost, err, addresses[address]),
+				"address", lost, "error", err, "attempt", attempt)
+		}
+	}
+}
+
+// joinTableOnce eats the meals left over a single connection, it returns true once there is nothing left to eat
+func joinTableOnce(address string, seat int, logger Logger) (bool, error) {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var join protoMessage
+	join.Int(1, int64(seat))
+	join.Uint(3, schemaVersion)
+	if err := writeFrame(conn, join); err != nil {
+		return false, err
+	}
+	frame, err := readFrame(conn)
+	if err != nil {
+		return false, err
+	}
+	var welcomeError, name, configJSON string
+	var mealsLeft int
+	err = parseProto(frame, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			welcomeError = string(bytes)
+		case 2:
+			name = string(bytes)
+		case 3:
+			mealsLeft = int(int32(varint))
+		case 4:
+			configJSON = string(bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if welcomeError != "" {
+		return false, fmt.Errorf("%s", welcomeError)
+	}
+	if mealsLeft == 0 {
+		return true, nil
+	}
+	config, err := ParseConfig([]byte(configJSON), Config{})
+	if err != nil {
+		return false, err
+	}
+
+	var events = NewEventBus()
+	events.Handle(LogEvents(logger, NewMessages(config, lifecycleVerbosity)))
+	var philosopher = Philosopher{
+		id:              seat,
+		name:            name,
+		meals:           mealsLeft,
+		thinking:        time.Duration(config.Seat(seat).Think),
+		eating:          time.Duration(config.Seat(seat).Eat),
+		speed:           config.Speed,
+		energy:          NewEnergy(config),
+		events:          events,
+		backoff:         NewBackoff(config),
+		feedbackChannel: make(chan Grant, config.FeedbackChannelSize)}
+
+	var requestChan = make(Mailbox)
+	var sessionDone = make(chan struct{})
+	var connectionLost = make(chan error, 1)
+	var written = make(chan struct{})
+	go func() {
+		for request := range requestChan {
+			writeFrame(conn, encodeTableRequest(request))
+		}
+		close(written)
+	}()
+	go func() {
+		var chopSticks = make(map[int]*ChopStick)
+		for {
+			frame, err := readFrame(conn)
+			var grant Grant
+			if err == nil {
+				grant, err = decodeTableResponse(frame, chopSticks)
+			}
+			if err != nil {
+				connectionLost <- err
+				grant = Grant{shutdown: true}
+			}
+			select {
+			case philosopher.feedbackChannel <- grant:
+			case <-sessionDone:
+				return
+			}
+			if err != nil {
+				// keep telling the philosopher that the Host is gone until he leaves
+				for {
+					select {
+					case philosopher.feedbackChannel <- grant:
+					case <-sessionDone:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	philosopher.eat(requestChan, nil)
+	close(requestChan)
+	close(sessionDone)
+	<-written
+
+	select {
+	case err := <-connectionLost:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// encodeTableRequest encodes a request of a remote philosopher as a TableRequest message
+func encodeTableRequest(request Request) protoMessage {
+	var message protoMessage
+	message.String(1, request.command)
+	message.Int(2, int64(request.meal))
+	if !request.hungrySince.IsZero() {
+		message.Int(3, request.hungrySince.UnixNano())
+	}
+	return message
+}
+
+// decodeTableRequest decodes a TableRequest message sent by the remote philosopher of the given seat
+func decodeTableRequest(frame []byte, seat int) (Request, error) {
+	var request = Request{philosopher: seat}
+	err := parseProto(frame, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			request.command = string(bytes)
+		case 2:
+			request.meal = int(int32(varint))
+		case 3:
+			request.hungrySince = time.Unix(0, int64(varint))
+		}
+		return nil
+	})
+	if err != nil {
+		return request, err
+	}
+	switch request.command {
+	case wantToEat, confirmedEating, abortedEating, finishedEating, pausedEating, starved:
+		return request, nil
+	}
+	return request, fmt.Errorf("unknown command %q", request.command)
+}
+
+// encodeTableResponse encodes an answer of the Host as a TableResponse message
+func encodeTableResponse(grant Grant) protoMessage {
+	var message protoMessage
+	message.Bool(1, grant.allowed)
+	message.Bool(2, grant.preempt)
+	var utensils []int64
+	for _, chopStick := range grant.chopSticks {
+		utensils = append(utensils, int64(chopStick.id))
+	}
+	message.Packed(3, utensils)
+	message.Int(4, int64(grant.retryAfter))
+	return message
+}
+
+// decodeTableResponse decodes a TableResponse message, the utensils of the remote Host are represented by local ChopSticks
+func decodeTableResponse(frame []byte, chopSticks map[int]*ChopStick) (Grant, error) {
+	var grant Grant
+	err := parseProto(frame, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			grant.allowed = varint != 0
+		case 2:
+			grant.preempt = varint != 0
+		case 3:
+			var ids = []uint64{varint}
+			if bytes != nil {
+				var err error
+				if ids, err = parseVarints(bytes); err != nil {
+					return err
+				}
+			}
+			for _, id := range ids {
+				if chopSticks[int(id)] == nil {
+					chopSticks[int(id)] = &ChopStick{id: int(id)}
+				}
+				grant.chopSticks = append(grant.chopSticks, chopSticks[int(id)])
+			}
+		case 4:
+			grant.retryAfter = time.Duration(varint)
+		}
+		return nil
+	})
+	return grant, err
+}
+
+// writeFrame writes a message prefixed by its length
+func writeFrame(w io.Writer, message protoMessage) error {
+	var frame = binary.BigEndian.AppendUint32(nil, uint32(len(message)))
+	_, err := w.Write(append(frame, message...))
+	return err
+}
+
+// readFrame reads a message prefixed by its length
+func readFrame(r io.Reader) ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	var length = binary.BigEndian.Uint32(prefix[:])
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("message of %d bytes is too big", length)
+	}
+	var frame = make([]byte, length)
+	_, err := io.ReadFull(r, frame)
+	return frame, err
+}
@@ -0,0 +1,39 @@
+//go:build !js && !windows && !plan9
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// dumpOnSignal writes what state returns in JSON on the standard error each time the process receives SIGUSR1, such as
+// the State of the dinner, so that a long dinner can be looked into without stopping it, the returned function
+// stops listening to the signal
+func dumpOnSignal(state func() any) func() {
+	var signals = make(chan os.Signal, 1)
+	var done = make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				data, err := json.MarshalIndent(state(), "", "  ")
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%s\n", data)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
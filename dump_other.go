@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package main
+
+// dumpOnSignal does nothing, the system has no SIGUSR1
+func dumpOnSignal(func() any) func() {
+	return func() {}
+}
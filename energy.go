@@ -0,0 +1,50 @@
+package main
+
+import "time"
+
+// Energy is the health of a philosopher, it decays while he is hungry and is replenished while he eats.
+// When it reaches zero the philosopher starves, which makes the whole dinner a failure.
+// A nil Energy means that the health model is disabled and that philosophers never starve.
+type Energy struct {
+	level      float64
+	max        float64
+	hungerRate float64
+	eatingRate float64
+	since      time.Time
+}
+
+// NewEnergy creates the Energy of a philosopher, full and hungry from now on, according to the configuration
+// It returns nil when the configuration does not enable the health model
+func NewEnergy(config Config) *Energy {
+	if config.Energy == 0 {
+		return nil
+	}
+	return &Energy{
+		level:      config.Energy,
+		max:        config.Energy,
+		hungerRate: config.HungerRate * config.Speed,
+		eatingRate: config.EatingRate * config.Speed,
+		since:      time.Now()}
+}
+
+// Starved tells if the energy has been exhausted by the hunger endured until now, and since when the philosopher is hungry
+func (energy *Energy) Starved(now time.Time) (bool, time.Time) {
+	if energy == nil {
+		return false, time.Time{}
+	}
+	return energy.level-now.Sub(energy.since).Seconds()*energy.hungerRate <= 0, energy.since
+}
+
+// Eat is called when the philosopher eats from start to end, the hunger endured until start is consumed
+// then the meal replenishes the energy, and the philosopher is hungry again from end
+func (energy *Energy) Eat(start, end time.Time) {
+	if energy == nil {
+		return
+	}
+	energy.level -= start.Sub(energy.since).Seconds() * energy.hungerRate
+	energy.level += end.Sub(start).Seconds() * energy.eatingRate
+	if energy.level > energy.max {
+		energy.level = energy.max
+	}
+	energy.since = end
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Below are the errors of the simulations, returned by the API along with what went wrong and told by the error
+// events of the Hosts, errors.Is telling them apart :
+// - ErrUnknownPhilosopher when a request or a call names a seat the table, or the Host receiving it, does not have
+// - ErrDoubleFinish when a philosopher tells the Host that he finished or paused a meal it did not grant him,
+// such as a meal whose end it already heard of, or confirmed a grant it does not wait for
+// - ErrShutdown when the dinner is over
+var ErrUnknownPhilosopher = errors.New("unknown philosopher")
+var ErrDoubleFinish = errors.New("meal not granted")
+var ErrShutdown = errors.New("the dinner is over")
+
+// emitError tells the EventBus of the table that the Host of the shard received a request breaking the protocol,
+// the Host then ignores the request
+func (table *Table) emitError(shard *Shard, request Request, err error) {
+	var name string
+	if request.philosopher >= 0 && request.philosopher < len(table.philosophers) {
+		name = table.philosophers[request.philosopher].name
+	}
+	table.events.Emit(Event{
+		Table:       table.id,
+		Philosopher: request.philosopher,
+		Name:        name,
+		Kind:        eventError,
+		Meal:        request.meal,
+		Detail:      fmt.Sprintf("%s from seat %d refused by the Host of seats %d to %d: %v", request.command, request.philosopher, shard.first, shard.last-1, err)})
+}
+
+// checkRequest tells if the Host of the shard can process the request : it must come from one of the seats of the
+// shard, a meal must have been granted to end, and reserved to be confirmed
+func checkRequest(shard *Shard, request Request, eating *SeatSet, reservations *Reservations) error {
+	if request.command == updateMaxEaters || request.command == stopDinner || request.command == queryState {
+		return nil
+	}
+	if request.philosopher < shard.first || request.philosopher >= shard.last {
+		return ErrUnknownPhilosopher
+	}
+	if (request.command == finishedEating || request.command == pausedEating) && !eating.Has(request.philosopher) {
+		return ErrDoubleFinish
+	}
+	if (request.command == confirmedEating || request.command == abortedEating) && !reservations.Pending(request.philosopher) {
+		return ErrDoubleFinish
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind tells what happened during the dinner
+type EventKind string
+
+// Below are the kinds of events emitted during the dinner
+const (
+	eventAccepted          EventKind = "accepted"          // the Host allows a philosopher to eat, detail tells the utensils in the forks and spoons variant
+	eventRejected          EventKind = "rejected"          // the Host denies a philosopher to eat, detail tells why
+	eventThrottled         EventKind = "throttled"         // a philosopher asks too often, the Host denies him to eat or he waits, detail tells how long
+	eventPreempted         EventKind = "preempted"         // the Host asks an eating philosopher to pause, detail tells for whom
+	eventStarted           EventKind = "started"           // a philosopher starts eating
+	eventFinished          EventKind = "finished"          // a philosopher finishes eating
+	eventPaused            EventKind = "paused"            // a philosopher pauses his meal
+	eventStarved           EventKind = "starved"           // a philosopher starved, detail holds the decisions of the Host since he got hungry
+	eventSeated            EventKind = "seated"            // the Reception seats a guest, meal tells how many meals he will eat
+	eventLeft              EventKind = "left"              // a guest leaves the table
+	eventRiceServed        EventKind = "riceServed"        // the Kitchen serves rice to a table, detail tells how much of the pot is used
+	eventBackpressure      EventKind = "backpressure"      // the request of a philosopher waited too long to reach the Host, detail tells how long
+	eventError             EventKind = "error"             // the Host refused a request breaking the protocol, detail tells why
+	eventUnresponsive      EventKind = "unresponsive"      // a philosopher sent no heartbeat for too long, detail tells since when
+	eventResponsive        EventKind = "responsive"        // an unresponsive philosopher beats again, detail tells how long he was silent
+	eventExplained         EventKind = "explained"         // the Host tells why it decided about a request to eat in the explain mode, detail narrates it
+	eventCapacityChanged   EventKind = "capacityChanged"   // the CapacityController changes maxEaters, meal tells the new capacity and detail why
+	eventInversion         EventKind = "inversion"         // a philosopher blocked by a holder of lower priority eats after meals of medium priority, detail tells them
+	eventChopstickWait     EventKind = "chopstickWait"     // a philosopher allowed to eat waited for a utensil still held, detail tells how long and which
+	eventAborted           EventKind = "aborted"           // a philosopher gave a two-phase grant back, detail tells which utensil was still held
+	eventPhilosopherFailed EventKind = "philosopherFailed" // the goroutine of a philosopher panicked and he left the table, detail tells the panic and the stack
+)
+
+// eventKinds are all the kinds of events
+var eventKinds = []EventKind{eventAccepted, eventRejected, eventThrottled, eventPreempted, eventStarted, eventFinished, eventPaused,
+	eventStarved, eventSeated, eventLeft, eventRiceServed, eventBackpressure, eventError, eventUnresponsive, eventResponsive,
+	eventExplained, eventCapacityChanged, eventInversion, eventChopstickWait, eventAborted, eventPhilosopherFailed}
+
+// findEventKind returns the kind of events of the given name, whatever its case
+func findEventKind(name string) (EventKind, bool) {
+	for _, kind := range eventKinds {
+		if strings.EqualFold(name, string(kind)) {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// schemaVersion is the revision of the schema of api/philosophers/v1 : its Event message, which the gRPC stream, the
+// NATS messages, the JSON lines and the trace files all follow, and the messages of the gRPC API and of the served
+// tables. A revision only adds fields, so that a reader of any revision reads the messages of any other one, ignoring
+// the fields it does not know, breaking changes going to api/philosophers/v2. The messages written before the
+// revisions were numbered are of revision 1.
+const schemaVersion = 3
+
+// globalSeq numbers the events of all the EventBuses of the process, in the order they are emitted
+var globalSeq atomic.Uint64
+
+// Event is something that happened during the dinner, for the philosopher of the given table
+// Version is the revision of the schema of the event (see schemaVersion)
+// Seq numbers the events of its EventBus, and Global the events of all the EventBuses of the process, such as the
+// ones of the simulations of a server, both in the order they were emitted
+// Time is told by the Clock of the dinner, simulated with the discrete engine, Wall by the wall clock of the process
+// emitting the event, and Elapsed is Time since the start of the dinner
+// Meal is the number of the meal concerned, starting at 0
+// Simulation is the id of the simulation in a server running several of them, empty otherwise
+// Run is the id of the run of the dinner (see RunInfo), empty for the events of a philosopher joining a served table
+// Queue is the number of requests waiting for the Host when it received the request leading to the event,
+// on the events emitted by the Host only
+type Event struct {
+	Version     int           `json:"version"`
+	Seq         uint64        `json:"seq"`
+	Global      uint64        `json:"global"`
+	Time        time.Time     `json:"time"`
+	Wall        time.Time     `json:"wall"`
+	Elapsed     time.Duration `json:"elapsed"`
+	Simulation  string        `json:"simulation,omitempty"`
+	Run         string        `json:"run,omitempty"`
+	Table       int           `json:"table"`
+	Philosopher int           `json:"philosopher"`
+	Name        string        `json:"name,omitempty"`
+	Kind        EventKind     `json:"kind"`
+	Meal        int           `json:"meal"`
+	Detail      string        `json:"detail,omitempty"`
+	Queue       int           `json:"queue,omitempty"`
+	Ticket      uint64        `json:"ticket,omitempty"`
+}
+
+// EventBus delivers the events of a simulation, in the order they are emitted, to :
+// - handlers, called synchronously so that they never miss an event (such as the console output)
+// - subscribers, who receive the events through a buffered channel and miss the events emitted while it is full,
+// so that a slow subscriber (such as a network client) never slows the dinner down
+// A quiet EventBus tells the Host and the philosophers not to format the details of the events, which then carry none
+type EventBus struct {
+	mutex       sync.Mutex
+	seq         uint64
+	handlers    []func(Event)
+	subscribers map[chan Event]bool
+	dropped     uint64
+	closed      bool
+	label       string
+	run         string
+	quiet       atomic.Bool
+	clock       *Clock
+	start       time.Time
+}
+
+// NewEventBus creates an EventBus without handlers nor subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan Event]bool), start: time.Now()}
+}
+
+// Handle adds a handler called for every event emitted from now on
+func (bus *EventBus) Handle(handler func(Event)) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.handlers = append(bus.handlers, handler)
+}
+
+// SetLabel stamps the events emitted from now on with the id of their simulation
+func (bus *EventBus) SetLabel(label string) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.label = label
+}
+
+// SetQuiet makes the events emitted from now on carry no detail, so that nothing is formatted during the dinner
+func (bus *EventBus) SetQuiet() {
+	bus.quiet.Store(true)
+}
+
+// Quiet tells if the details of the events are not wanted, which is the case of a nil EventBus dropping the events
+func (bus *EventBus) Quiet() bool {
+	return bus == nil || bus.quiet.Load()
+}
+
+// Subscribe returns a channel receiving the events emitted from now on, it is closed by Unsubscribe or Close
+func (bus *EventBus) Subscribe(size int) chan Event {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	var subscription = make(chan Event, size)
+	if bus.closed {
+		close(subscription)
+	} else {
+		bus.subscribers[subscription] = true
+	}
+	return subscription
+}
+
+// Unsubscribe stops the delivery of events to the channel and closes it
+func (bus *EventBus) Unsubscribe(subscription chan Event) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.subscribers[subscription] {
+		delete(bus.subscribers, subscription)
+		close(subscription)
+	}
+}
+
+// SetClock makes the events be timestamped by the given Clock, it must be called before any event is emitted
+func (bus *EventBus) SetClock(clock *Clock) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.clock = clock
+	bus.start = clock.Now()
+}
+
+// SetStart tells when the dinner started in the time of the Clock, the Elapsed time of the events starting from it,
+// which is when the EventBus was created or given its Clock unless a restored dinner started earlier
+func (bus *EventBus) SetStart(start time.Time) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.start = start
+}
+
+// SetRun stamps the events emitted from now on with the id of their run
+func (bus *EventBus) SetRun(run string) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.run = run
+}
+
+// Emit numbers and timestamps the event then delivers it, a nil EventBus drops the events
+func (bus *EventBus) Emit(event Event) {
+	if bus == nil {
+		return
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.seq++
+	event.Version = schemaVersion
+	event.Seq = bus.seq
+	event.Global = globalSeq.Add(1)
+	event.Wall = time.Now()
+	event.Time = event.Wall
+	if bus.clock != nil {
+		event.Time = bus.clock.Now()
+	}
+	event.Elapsed = event.Time.Sub(bus.start)
+	event.Simulation = bus.label
+	event.Run = bus.run
+
+	for _, handler := range bus.handlers {
+		handler(event)
+	}
+	for subscription := range bus.subscribers {
+		select {
+		case subscription <- event:
+		default:
+			bus.dropped++
+		}
+	}
+}
+
+// Close closes the channels of all the subscribers, it must be called once no more events can be emitted
+func (bus *EventBus) Close() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	for subscription := range bus.subscribers {
+		close(subscription)
+	}
+	bus.subscribers = make(map[chan Event]bool)
+	bus.closed = true
+}
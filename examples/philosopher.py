@@ -0,0 +1,54 @@
+#!/usr/bin/env python3
+"""A philosopher joining a served table with the text protocol, one JSON message per line.
+
+Run by the Host with -clients 'python3 examples/philosopher.py', talking over the standard input and output, or on
+its own with a host and a port, such as python3 examples/philosopher.py localhost 7001 2, to join a table served with
+-serve-text :7001.
+"""
+import json
+import os
+import random
+import socket
+import sys
+import time
+
+
+def main():
+    if len(sys.argv) > 1:
+        connection = socket.create_connection((sys.argv[1], int(sys.argv[2])))
+        seat = int(sys.argv[3])
+        reader, writer = connection.makefile("r"), connection.makefile("w")
+    else:
+        seat = int(os.environ["PHILOSOPHER_SEAT"])
+        reader, writer = sys.stdin, sys.stdout
+
+    def send(message):
+        writer.write(json.dumps(message) + "\n")
+        writer.flush()
+
+    def receive():
+        while True:
+            message = json.loads(reader.readline())
+            if message["type"] != "error":
+                return message
+            print(f"seat {seat}: {message['error']}", file=sys.stderr)
+
+    send({"type": "join", "seat": seat})
+    welcome = receive()
+    meals = welcome["meals"]
+    for _ in range(meals):
+        time.sleep(random.uniform(0, 0.3))
+        while True:
+            send({"type": "ask"})
+            answer = receive()
+            if answer["type"] == "granted":
+                break
+            if answer["type"] == "shutdown":
+                return
+            time.sleep(random.uniform(0, 0.1))
+        time.sleep(random.uniform(0.05, 0.5))
+        send({"type": "finished"})
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,307 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Below are the weights of the grades in the score of a submission, out of 100
+const safetyWeight = 40   // no philosophers eating along with a neighbor, no misuse of the utensils
+const livenessWeight = 40 // the share of the planned meals eaten
+const fairnessWeight = 20 // Jain's fairness index of the waits
+
+// ExerciseScenario is one of the dinners a submission is graded on, its configuration being given in JSON, as in
+// a config file, with a fixed seed so that every submission is graded on the same dinners
+type ExerciseScenario struct {
+	Name        string
+	Description string
+	Config      string
+}
+
+// exerciseScenarios are the dinners of the exercise mode, from the classic table to the crowded and the hungry ones
+var exerciseScenarios = []ExerciseScenario{
+	{Name: "classic", Description: "five philosophers around a table", Config: `{"philosophers": 5, "meals": 5, "seed": 1}`},
+	{Name: "crowded", Description: "seven philosophers, a single one eating at a time",
+		Config: `{"philosophers": 7, "meals": 3, "maxEaters": 1, "seed": 2}`},
+	{Name: "hungry", Description: "philosophers starving when they wait too long",
+		Config: `{"philosophers": 5, "meals": 3, "maxEaters": 2, "energy": 10, "hungerRate": 10, "eatingRate": 30, "seed": 3}`},
+	{Name: "grid", Description: "nine philosophers seated on a grid, each having up to four neighbors",
+		Config: `{"topology": "grid:3x3", "meals": 3, "seed": 4}`},
+	{Name: "forks", Description: "forks and spoons shared by the whole table",
+		Config: `{"philosophers": 6, "meals": 3, "utensils": "forksAndSpoons", "forks": 5, "spoons": 4, "seed": 5}`},
+	{Name: "napkins", Description: "six philosophers sharing two napkins along with their chopsticks",
+		Config: `{"philosophers": 6, "meals": 3, "napkins": 2, "seed": 6}`},
+}
+
+// ScenarioGrade is how a submission did on a scenario :
+// - safety is 1 when no philosopher ate along with a neighbor sharing a chopstick with him and the utensils were used
+// by the rules, 0 otherwise, the violations telling what went wrong
+// - liveness is the share of the planned meals eaten, the starved philosophers and a dinner stopped by the timeout
+// leaving meals uneaten
+// - fairness is Jain's fairness index of the mean waits of the philosophers (see Fairness)
+type ScenarioGrade struct {
+	Scenario   string   `json:"scenario"`
+	Seed       int64    `json:"seed"`
+	Safety     float64  `json:"safety"`
+	Liveness   float64  `json:"liveness"`
+	Fairness   float64  `json:"fairness"`
+	Meals      int      `json:"meals"`
+	Planned    int      `json:"planned"`
+	Starved    []string `json:"starved,omitempty"`
+	TimedOut   bool     `json:"timedOut,omitempty"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+// ExerciseReport is the machine-readable grade of a submission : the mean of each grade over the scenarios, and the
+// score out of 100 weighting them
+type ExerciseReport struct {
+	Strategy  string          `json:"strategy"`
+	Score     float64         `json:"score"`
+	Safety    float64         `json:"safety"`
+	Liveness  float64         `json:"liveness"`
+	Fairness  float64         `json:"fairness"`
+	Scenarios []ScenarioGrade `json:"scenarios"`
+}
+
+// PluginStrategy is the Strategy of a submission compiled as a Go plugin, which cannot share the types of the
+// program : its methods only take standard types. The plugin exports a NewStrategy function creating the strategy
+// of a table, such as :
+//
+//	func NewStrategy(table int) any { return &MyStrategy{} }
+//
+// built with go build -buildmode=plugin.
+type PluginStrategy interface {
+	// Admit tells if the philosopher of the seat may eat his meal now, along with the reason of a refusal, given
+	// when he got hungry, the time of the dinner and how many philosophers are eating and allowed to
+	Admit(seat, meal int, hungrySince, now time.Time, eaters, maxEaters int) (bool, string)
+	// Served tells that the philosopher of the seat starts eating
+	Served(seat int, now time.Time)
+	// Released tells that the philosopher of the seat released his utensils
+	Released(seat int, now time.Time)
+}
+
+// pluginAdapter makes a PluginStrategy a Strategy
+type pluginAdapter struct {
+	strategy PluginStrategy
+}
+
+// Admit asks the PluginStrategy
+func (adapter pluginAdapter) Admit(request StrategyRequest) (bool, string) {
+	return adapter.strategy.Admit(request.Seat, request.Meal, request.HungrySince, request.Now, request.Eaters, request.MaxEaters)
+}
+
+// Served tells the PluginStrategy
+func (adapter pluginAdapter) Served(seat int, now time.Time) { adapter.strategy.Served(seat, now) }
+
+// Released tells the PluginStrategy
+func (adapter pluginAdapter) Released(seat int, now time.Time) { adapter.strategy.Released(seat, now) }
+
+// loadPluginStrategy opens the plugin of a submission and registers its strategy, it returns the registered name
+func loadPluginStrategy(path string) (string, error) {
+	opened, err := plugin.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("exercise: %v", err)
+	}
+	symbol, err := opened.Lookup("NewStrategy")
+	if err != nil {
+		return "", fmt.Errorf("exercise: %v", err)
+	}
+	var newStrategy, ok = symbol.(func(int) any)
+	if !ok {
+		return "", fmt.Errorf("exercise: NewStrategy of %s is a %T, expected a func(table int) any", path, symbol)
+	}
+	if _, ok := newStrategy(0).(PluginStrategy); !ok {
+		return "", fmt.Errorf("exercise: the strategy of %s lacks the methods of a PluginStrategy", path)
+	}
+	var name = "plugin " + filepath.Base(path)
+	RegisterStrategy(name, func(config Config, table int) Strategy {
+		return pluginAdapter{strategy: newStrategy(table).(PluginStrategy)}
+	})
+	return name, nil
+}
+
+// gradeScenario runs the scenario with the strategy, with the discrete engine and in the check mode, and grades it.
+// The dinner is stopped after the timeout, a strategy refusing every request never letting it end.
+func gradeScenario(scenario ExerciseScenario, strategy string, timeout time.Duration) (ScenarioGrade, error) {
+	config, err := ParseConfig([]byte(scenario.Config), Config{})
+	if err != nil {
+		return ScenarioGrade{}, fmt.Errorf("exercise: scenario %s: %v", scenario.Name, err)
+	}
+	config.Strategy, config.Engine, config.Check = strategy, discreteEngine, true
+	if err := config.Validate(); err != nil {
+		return ScenarioGrade{}, fmt.Errorf("exercise: scenario %s: %v", scenario.Name, err)
+	}
+
+	var grade = ScenarioGrade{Scenario: scenario.Name, Seed: config.Seed}
+	var simulation = NewSimulation(config)
+	var recorder = NewRecorder(false)
+	var eating = make(map[int]map[int]bool) // the seats eating at each table
+	simulation.Events().SetQuiet()
+	simulation.Events().Handle(recorder.Record)
+	simulation.Events().Handle(func(event Event) {
+		// the handlers are called one event at a time
+		if eating[event.Table] == nil {
+			eating[event.Table] = make(map[int]bool)
+		}
+		switch event.Kind {
+		case eventStarted:
+			// the forks and the spoons are not shared by neighbors only, the Ownership checks them
+			for seat := range eating[event.Table] {
+				if config.Utensils == chopSticksVariant && config.Topology.AreNeighbors(seat, event.Philosopher) {
+					grade.Violations = append(grade.Violations, fmt.Sprintf("table %d, %s eats along with his neighbor at seat %d",
+						event.Table, event.Name, seat))
+				}
+			}
+			eating[event.Table][event.Philosopher] = true
+		case eventFinished, eventPaused, eventStarved, eventPhilosopherFailed, eventLeft:
+			delete(eating[event.Table], event.Philosopher)
+		case eventError:
+			grade.Violations = append(grade.Violations, fmt.Sprintf("table %d, %s", event.Table, event.Detail))
+		}
+	})
+
+	var timer = time.AfterFunc(timeout, simulation.Stop)
+	var result = simulation.Run()
+	grade.TimedOut = !timer.Stop()
+
+	grade.Violations = append(grade.Violations, simulation.State().Violations...)
+	grade.Violations = append(grade.Violations, result.Misuses()...)
+	if len(grade.Violations) == 0 {
+		grade.Safety = 1
+	}
+	var reports = recorder.Reports()
+	for _, report := range reports {
+		grade.Meals += report.Meals
+	}
+	grade.Planned = config.TotalMeals() * config.Tables
+	grade.Liveness = min(1, float64(grade.Meals)/float64(max(grade.Planned, 1)))
+	grade.Starved = result.Starved()
+	grade.Fairness = NewFairness(reports).Index
+	return grade, nil
+}
+
+// NewExerciseReport sums up the grades of the scenarios
+func NewExerciseReport(strategy string, grades []ScenarioGrade) ExerciseReport {
+	var report = ExerciseReport{Strategy: strategy, Scenarios: grades}
+	for _, grade := range grades {
+		report.Safety += grade.Safety / float64(len(grades))
+		report.Liveness += grade.Liveness / float64(len(grades))
+		report.Fairness += grade.Fairness / float64(len(grades))
+	}
+	report.Score = safetyWeight*report.Safety + livenessWeight*report.Liveness + fairnessWeight*report.Fairness
+	return report
+}
+
+// exercise is the exercise command, it grades the Strategy of a student, registered in the program or compiled
+// as a Go plugin, on a fixed battery of seeded scenarios : its safety, its liveness and its fairness, printed as a
+// table and written as a JSON report
+func exercise(arguments []string) error {
+	var flags = flag.NewFlagSet("exercise", flag.ExitOnError)
+	var strategy = flags.String("strategy", "", "registered strategy to grade, such as ascetic built with -tags ascetic")
+	var pluginPath = flags.String("plugin", "", "Go plugin exporting the NewStrategy of the strategy to grade, built with go build -buildmode=plugin")
+	var only = flags.String("scenarios", "", "scenarios to run, separated by commas, all of them by default")
+	var timeout = flags.Duration("timeout", 10*time.Second, "stop a scenario after this long, the meals left being uneaten")
+	var reportFile = flags.String("report", "", "write the JSON report in this file (such as grade.json), - for the standard output")
+	var pass = flags.Float64("pass", 0, "fail unless the score out of 100 reaches this one")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s exercise -strategy name | -plugin student.so [-scenarios classic,grid] [-report grade.json] [-pass 60]\n", os.Args[0])
+		var names []string
+		for _, scenario := range exerciseScenarios {
+			names = append(names, scenario.Name)
+		}
+		fmt.Fprintf(flags.Output(), "Scenarios: %s\n", strings.Join(names, ", "))
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	if (*strategy == "") == (*pluginPath == "") {
+		flags.Usage()
+		return fmt.Errorf("exercise: expected either -strategy or -plugin")
+	}
+	if *pluginPath != "" {
+		name, err := loadPluginStrategy(*pluginPath)
+		if err != nil {
+			return err
+		}
+		*strategy = name
+	}
+	if err := validStrategy(*strategy); err != nil {
+		return fmt.Errorf("exercise: %v", err)
+	}
+
+	var scenarios = exerciseScenarios
+	if *only != "" {
+		scenarios = nil
+		for _, name := range strings.Split(*only, ",") {
+			var index = -1
+			for i, scenario := range exerciseScenarios {
+				if scenario.Name == strings.TrimSpace(name) {
+					index = i
+				}
+			}
+			if index < 0 {
+				return fmt.Errorf("exercise: unknown scenario %q", name)
+			}
+			scenarios = append(scenarios, exerciseScenarios[index])
+		}
+	}
+
+	var grades []ScenarioGrade
+	for _, scenario := range scenarios {
+		grade, err := gradeScenario(scenario, *strategy, *timeout)
+		if err != nil {
+			return err
+		}
+		grades = append(grades, grade)
+	}
+	var report = NewExerciseReport(*strategy, grades)
+
+	var output = os.Stdout
+	if *reportFile == "-" {
+		output = os.Stderr
+	}
+	var writer = tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Grading the %s strategy\n", *strategy)
+	fmt.Fprintln(writer, "Scenario\tSafety\tLiveness\tFairness\tMeals")
+	for _, grade := range grades {
+		fmt.Fprintf(writer, "%s\t%.0f\t%.2f\t%.2f\t%d of %d\n", grade.Scenario, grade.Safety, grade.Liveness, grade.Fairness, grade.Meals, grade.Planned)
+	}
+	writer.Flush()
+	for _, grade := range grades {
+		for _, violation := range grade.Violations {
+			fmt.Fprintf(output, "%s : %s\n", grade.Scenario, violation)
+		}
+		if len(grade.Starved) > 0 {
+			fmt.Fprintf(output, "%s : %s starved\n", grade.Scenario, strings.Join(grade.Starved, ", "))
+		}
+		if grade.TimedOut {
+			fmt.Fprintf(output, "%s : stopped after %v\n", grade.Scenario, *timeout)
+		}
+	}
+	fmt.Fprintf(output, "Score : %.1f / 100 (safety %.2f, liveness %.2f, fairness %.2f)\n", report.Score, report.Safety, report.Liveness, report.Fairness)
+
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return fmt.Errorf("exercise: %v", err)
+		}
+		if *reportFile == "-" {
+			fmt.Println(string(data))
+		} else if err := os.WriteFile(*reportFile, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("exercise: %v", err)
+		}
+	}
+	if report.Score < *pass {
+		return fmt.Errorf("exercise: score %.1f below %.1f", report.Score, *pass)
+	}
+	return nil
+}
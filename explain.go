@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// causeNarrations tell why the Host holds back a philosopher whose utensils are free, by cause of rejection
+var causeNarrations = map[string]string{
+	causeAlreadyEating: "since he is already eating",
+	causeMaxEaters:     "to keep the number of philosophers eating within the limit",
+	causeDish:          "since the central dish is full",
+	causeRicePot:       "since the rice pot is empty",
+	causeDeadline:      "to let a philosopher whose deadline is near eat first",
+	causeStrategy:      "as its strategy asks",
+	causeMiddleware:    "as one of its middlewares asks",
+	causeTenant:        "to keep the philosophers of his tenant eating within its quota",
+	chaosMiddleware:    "at random, to test the philosophers against arbitrary refusals",
+	causeTicket:        "to serve the philosophers in the order they got hungry",
+	causeInheritance:   "to let a philosopher of higher priority, blocked by a holder of lower priority, eat first",
+	causeRateLimit:     "since he asks too often",
+	causeOverload:      "since it has more requests waiting than it can decide in time",
+}
+
+// Holding is a utensil a philosopher needs and who holds it, nobody when it is free. A utensil shared with another
+// shard or claimed without the Host is held by someone the Host does not know.
+type Holding struct {
+	Utensil *ChopStick
+	Holder  string
+	Claimed bool
+}
+
+// Free tells if nobody holds the utensil
+func (holding Holding) Free() bool {
+	return holding.Holder == "" && !holding.Claimed
+}
+
+// String tells the utensil and who holds it
+func (holding Holding) String() string {
+	var utensil = fmt.Sprintf("%s %d", holding.Utensil.kind, holding.Utensil.id)
+	switch {
+	case holding.Holder != "":
+		return fmt.Sprintf("%s, which philosopher %s holds", utensil, holding.Holder)
+	case holding.Claimed:
+		return fmt.Sprintf("%s, which someone out of sight of the Host holds", utensil)
+	}
+	return fmt.Sprintf("%s, which is free", utensil)
+}
+
+// Explanation is what the Host knows when it decides about a request to eat in the explain mode, for a lecture :
+// - the philosopher and the meal he asks for
+// - whether the Host lets him eat, and otherwise the cause and the reason of the rejection
+// - the utensils he needs in locking order, the first free candidate of each need or a held one, and who holds them
+// - how many philosophers of the table are eating, and how many are allowed to
+// Narrate tells it in a few sentences, along with what would have happened without the Host.
+type Explanation struct {
+	Philosopher string
+	Meal        int
+	Accepted    bool
+	Cause       string
+	Reason      Reason
+	Holdings    []Holding
+	Eaters      int
+	MaxEaters   int
+}
+
+// explainNeeds returns the utensils a philosopher turned down needs, in locking order, with who holds them
+func explainNeeds(needs []Need, holders map[*ChopStick]int, claims []atomic.Bool, seats []*Philosopher) []Holding {
+	var holdings []Holding
+	for _, need := range needs {
+		var chosen Holding
+		for i, candidate := range need.candidates {
+			var holding = Holding{Utensil: candidate, Claimed: claims[candidate.id].Load()}
+			if seat, held := holders[candidate]; held && seats[seat] != nil {
+				holding.Holder, holding.Claimed = seats[seat].name, false
+			}
+			if i == 0 || holding.Free() && !containsHolding(holdings, candidate) {
+				chosen = holding
+			}
+			if holding.Free() && !containsHolding(holdings, candidate) {
+				break
+			}
+		}
+		holdings = append(holdings, chosen)
+	}
+	sort.Slice(holdings, func(i, j int) bool { return holdings[i].Utensil.id < holdings[j].Utensil.id })
+	return holdings
+}
+
+// explainGrant returns the utensils granted to a philosopher, in locking order, all of them free until he takes them
+func explainGrant(chopSticks []*ChopStick) []Holding {
+	var holdings = make([]Holding, len(chopSticks))
+	for i, chopStick := range chopSticks {
+		holdings[i] = Holding{Utensil: chopStick}
+	}
+	return holdings
+}
+
+// containsHolding tells if the utensil is one of the holdings
+func containsHolding(holdings []Holding, utensil *ChopStick) bool {
+	for _, holding := range holdings {
+		if holding.Utensil == utensil {
+			return true
+		}
+	}
+	return false
+}
+
+// Narrate tells the decision, the utensils of the philosopher, the philosophers eating, and what would have happened
+// without the Host
+func (explanation Explanation) Narrate() string {
+	var sentences []string
+	if explanation.Accepted {
+		sentences = append(sentences, fmt.Sprintf("Philosopher %s asks for his meal %d and the Host lets him eat.", explanation.Philosopher, explanation.Meal+1))
+	} else {
+		var reason = explanation.Reason.String()
+		if reason != "" {
+			reason = strings.ToLower(reason[:1]) + reason[1:]
+		}
+		sentences = append(sentences, fmt.Sprintf("Philosopher %s asks for his meal %d and the Host turns him down: %s.", explanation.Philosopher,
+			explanation.Meal+1, reason))
+	}
+
+	if len(explanation.Holdings) > 0 {
+		var utensils []string
+		for _, holding := range explanation.Holdings {
+			utensils = append(utensils, holding.String())
+		}
+		sentences = append(sentences, fmt.Sprintf("He needs %s.", strings.Join(utensils, ", and ")))
+	}
+	sentences = append(sentences, fmt.Sprintf("%d of the %d philosophers allowed to eat at once are eating.", explanation.Eaters, explanation.MaxEaters))
+	sentences = append(sentences, explanation.withoutHost())
+	return strings.Join(sentences, " ")
+}
+
+// withoutHost tells what the philosopher would have done without the Host : picking up his utensils one after the
+// other, and waiting for the first one held while holding the ones he took
+func (explanation Explanation) withoutHost() string {
+	var taken []string
+	for _, holding := range explanation.Holdings {
+		if holding.Free() {
+			taken = append(taken, fmt.Sprintf("%s %d", holding.Utensil.kind, holding.Utensil.id))
+			continue
+		}
+		if len(taken) == 0 {
+			return fmt.Sprintf("Without a Host he would have waited for %s, holding nothing meanwhile.", holding)
+		}
+		return fmt.Sprintf("Without a Host he would have picked up %s and waited for %s, keeping %s from his neighbors "+
+			"meanwhile: if all the philosophers did so at once, none of them would ever eat.", strings.Join(taken, " and "), holding,
+			strings.Join(taken, " and "))
+	}
+	if explanation.Accepted {
+		return "Without a Host he would have eaten as well, the Host only made sure that his utensils were free."
+	}
+	if narration, found := causeNarrations[explanation.Cause]; found {
+		return fmt.Sprintf("Without a Host he would have eaten right away, the Host holds him back %s.", narration)
+	}
+	return "Without a Host he would have eaten right away."
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Below are the formats of the exports
+const exportCSV = "csv"
+const exportJSON = "json"
+const exportProto = "proto"
+
+// ExportCSV writes the events and the reports of the philosophers of a run of the dinner in the given directory :
+// - events.csv has a row per event, with its run, time, table, philosopher, kind, meal, detail and schema revision,
+// followed by its sequence numbers, its wall clock time and the time elapsed since the start of the dinner
+// - philosophers.csv has a row per philosopher, with the run and the totals of his PhilosopherReport
+// - run.csv has a single row with the RunInfo
+func ExportCSV(dir string, info RunInfo, events []Event, reports []PhilosopherReport) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+
+	var rows = [][]string{
+		{"run", "config_hash", "strategy", "seed", "go_version"},
+		{info.ID, info.ConfigHash, info.Strategy, strconv.FormatInt(info.Seed, 10), info.GoVersion}}
+	if err := writeCSV(filepath.Join(dir, "run.csv"), rows); err != nil {
+		return err
+	}
+
+	rows = [][]string{{"run", "timestamp", "table", "philosopher", "name", "event", "meal", "detail", "version", "seq", "global", "wall", "elapsed_ns"}}
+	for _, event := range events {
+		rows = append(rows, []string{
+			info.ID,
+			event.Time.Format(time.RFC3339Nano),
+			strconv.Itoa(event.Table),
+			strconv.Itoa(event.Philosopher),
+			event.Name,
+			string(event.Kind),
+			strconv.Itoa(event.Meal),
+			event.Detail,
+			strconv.Itoa(event.Version),
+			strconv.FormatUint(event.Seq, 10),
+			strconv.FormatUint(event.Global, 10),
+			event.Wall.Format(time.RFC3339Nano),
+			strconv.FormatInt(int64(event.Elapsed), 10)})
+	}
+	if err := writeCSV(filepath.Join(dir, "events.csv"), rows); err != nil {
+		return err
+	}
+
+	rows = [][]string{{"run", "table", "philosopher", "name", "meals", "accepted", "rejected", "preempted", "paused", "starved", "eating_ms", "waiting_ms"}}
+	for _, report := range reports {
+		rows = append(rows, []string{
+			info.ID,
+			strconv.Itoa(report.Table),
+			strconv.Itoa(report.Philosopher),
+			report.Name,
+			strconv.Itoa(report.Meals),
+			strconv.Itoa(report.Accepted),
+			strconv.Itoa(report.Rejected),
+			strconv.Itoa(report.Preempted),
+			strconv.Itoa(report.Paused),
+			strconv.FormatBool(report.Starved),
+			strconv.FormatInt(report.Eating.Milliseconds(), 10),
+			strconv.FormatInt(report.Waiting.Milliseconds(), 10)})
+	}
+	return writeCSV(filepath.Join(dir, "philosophers.csv"), rows)
+}
+
+// ReadEventsCSV reads the events of the events.csv file written by ExportCSV, the files written before the version
+// column holding events of the first revision, and the ones written before the seq column being numbered in the order
+// of the rows
+func ReadEventsCSV(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("export: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("export: %s: %v", path, err)
+	}
+	if len(rows) == 0 || len(rows[0]) != 8 && len(rows[0]) != 9 && len(rows[0]) != 13 || rows[0][1] != "timestamp" {
+		return nil, fmt.Errorf("export: %s is not an events.csv file", path)
+	}
+
+	var events []Event
+	for i, row := range rows[1:] {
+		var event = Event{Version: 1, Seq: uint64(i + 1), Run: row[0], Name: row[4], Kind: EventKind(row[5]), Detail: row[7]}
+		var errs [9]error
+		event.Time, errs[0] = time.Parse(time.RFC3339Nano, row[1])
+		event.Table, errs[1] = strconv.Atoi(row[2])
+		event.Philosopher, errs[2] = strconv.Atoi(row[3])
+		event.Meal, errs[3] = strconv.Atoi(row[6])
+		if len(row) > 8 {
+			event.Version, errs[4] = strconv.Atoi(row[8])
+		}
+		if len(row) > 9 {
+			var elapsed int64
+			event.Seq, errs[5] = strconv.ParseUint(row[9], 10, 64)
+			event.Global, errs[6] = strconv.ParseUint(row[10], 10, 64)
+			event.Wall, errs[7] = time.Parse(time.RFC3339Nano, row[11])
+			elapsed, errs[8] = strconv.ParseInt(row[12], 10, 64)
+			event.Elapsed = time.Duration(elapsed)
+		}
+		for _, err := range errs {
+			if err != nil {
+				return nil, fmt.Errorf("export: %s, row %d: %v", path, i+2, err)
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ExportJSON writes the events and the reports of the philosophers of a run of the dinner in the given directory :
+// - events.json has an event per line, as the query command reads them
+// - philosophers.json has the list of the reports of the philosophers
+// - run.json has the RunInfo
+func ExportJSON(dir string, info RunInfo, events []Event, reports []PhilosopherReport) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	var lines bytes.Buffer
+	var encoder = json.NewEncoder(&lines)
+	for _, event := range events {
+		encoder.Encode(event)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "events.json"), lines.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	return writeRunJSON(dir, info, reports)
+}
+
+// writeRunJSON writes the philosophers.json and run.json files of ExportJSON
+func writeRunJSON(dir string, info RunInfo, reports []PhilosopherReport) error {
+	for name, value := range map[string]any{"philosophers.json": reports, "run.json": info} {
+		data, _ := json.MarshalIndent(value, "", "\t")
+		if err := os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("export: %v", err)
+		}
+	}
+	return nil
+}
+
+// ExportProto writes the events and the reports of the philosophers of a run of the dinner in the given directory,
+// as ExportJSON does but for the events : events.pb has the Event messages of api/philosophers/v1, each of them
+// prefixed by its length as a varint, the delimited format of protobuf
+func ExportProto(dir string, info RunInfo, events []Event, reports []PhilosopherReport) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	var data []byte
+	for _, event := range events {
+		var message = eventProto(event)
+		data = binary.AppendUvarint(data, uint64(len(message)))
+		data = append(data, message...)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "events.pb"), data, 0o644); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	return writeRunJSON(dir, info, reports)
+}
+
+// ReadEventsProto reads the events of the events.pb file written by ExportProto
+func ReadEventsProto(path string) ([]Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("export: %v", err)
+	}
+	var events []Event
+	for len(data) > 0 {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, fmt.Errorf("export: %s, event %d: invalid length", path, len(events)+1)
+		}
+		event, err := parseEventProto(data[n : n+int(length)])
+		if err != nil {
+			return nil, fmt.Errorf("export: %s, event %d: %v", path, len(events)+1, err)
+		}
+		events = append(events, event)
+		data = data[n+int(length):]
+	}
+	return events, nil
+}
+
+// ReadEvents reads the events of a trace : the events.csv written by ExportCSV, the events.pb written by ExportProto,
+// or events in JSON, either a list or one per line such as the events.json written by ExportJSON, - being the
+// standard input
+func ReadEvents(path string) ([]Event, error) {
+	if strings.HasSuffix(path, ".csv") {
+		return ReadEventsCSV(path)
+	}
+	if strings.HasSuffix(path, ".pb") {
+		return ReadEventsProto(path)
+	}
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("export: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+	var reader = bufio.NewReader(input)
+	var decoder = json.NewDecoder(reader)
+	var events []Event
+	if first, err := reader.Peek(1); err == nil && first[0] == '[' {
+		if err := decoder.Decode(&events); err != nil {
+			return nil, fmt.Errorf("export: %s: %v", path, err)
+		}
+		return unversioned(events), nil
+	}
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err == io.EOF {
+			return unversioned(events), nil
+		} else if err != nil {
+			return nil, fmt.Errorf("export: %s, event %d: %v", path, len(events)+1, err)
+		}
+		events = append(events, event)
+	}
+}
+
+// unversioned marks the events written without the revision of their schema as events of the first revision
+func unversioned(events []Event) []Event {
+	for i := range events {
+		if events[i].Version == 0 {
+			events[i].Version = 1
+		}
+	}
+	return events
+}
+
+// MergeEvents merges the events of several traces, such as the ones of the nodes of a distributed dinner, ordered by
+// the wall clock of the processes emitting them, then by run, by the global sequence of their process and by the
+// sequence of their EventBus, so that the same traces merge the same way whatever their order. The events written
+// before they carried their wall clock time are ordered by their time instead.
+func MergeEvents(traces ...[]Event) []Event {
+	var merged []Event
+	for _, events := range traces {
+		merged = append(merged, events...)
+	}
+	var wall = func(event Event) time.Time {
+		if event.Wall.IsZero() {
+			return event.Time
+		}
+		return event.Wall
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		var a, b = merged[i], merged[j]
+		if !wall(a).Equal(wall(b)) {
+			return wall(a).Before(wall(b))
+		}
+		if a.Run != b.Run {
+			return a.Run < b.Run
+		}
+		if a.Global != b.Global {
+			return a.Global < b.Global
+		}
+		return a.Seq < b.Seq
+	})
+	return merged
+}
+
+// writeCSV writes the rows in a CSV file
+func writeCSV(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	var writer = csv.NewWriter(file)
+	writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("export: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// PhilosopherFailure is a philosopher whose goroutine panicked : his table, his seat, his name, the meal he was at,
+// counted from 0, the value of the panic and the stack of the goroutine when it panicked
+type PhilosopherFailure struct {
+	Table       int    `json:"table"`
+	Philosopher int    `json:"philosopher"`
+	Name        string `json:"name"`
+	Meal        int    `json:"meal"`
+	Panic       string `json:"panic"`
+	Stack       string `json:"stack"`
+}
+
+// String tells the failure in a line, without its stack
+func (failure PhilosopherFailure) String() string {
+	return fmt.Sprintf("%s panicked at meal %d: %s", failure.Name, failure.Meal, failure.Panic)
+}
+
+// Failures is the registry of the philosophers of a table whose goroutine panicked, a panic failing the philosopher
+// instead of crashing the whole process, so that the rest of the dinner takes place and its Result tells the failure.
+// A nil Failures records nothing, such as for the philosophers of a remote table.
+type Failures struct {
+	mutex    sync.Mutex
+	failures []PhilosopherFailure
+}
+
+// Record records the failure of a philosopher
+func (failures *Failures) Record(failure PhilosopherFailure) {
+	if failures == nil {
+		return
+	}
+	failures.mutex.Lock()
+	defer failures.mutex.Unlock()
+	failures.failures = append(failures.failures, failure)
+}
+
+// List returns the failures recorded so far
+func (failures *Failures) List() []PhilosopherFailure {
+	if failures == nil {
+		return nil
+	}
+	failures.mutex.Lock()
+	defer failures.mutex.Unlock()
+	return append([]PhilosopherFailure(nil), failures.failures...)
+}
+
+// holding is what a philosopher holds during his meal, which he must give back when he fails : the utensils the Host
+// granted him until he tells the Host that he released them, whether he locked them on the table, and whether he
+// was admitted without the Host by the lock-free admission
+type holding struct {
+	chopSticks []*ChopStick
+	locked     bool
+	admitted   bool
+}
+
+// recoverFailure turns the panic of the goroutine of a philosopher into his failure, it must be deferred by the
+// goroutine : the philosopher puts back on the table the utensils he locked, through the Ownership in the check mode,
+// tells the Host that he gave back his grant, and gives up his remaining meals so that the dinner can end without him.
+// The failure is told by a philosopherFailed event and recorded in the Failures of his table.
+func (philosopher *Philosopher) recoverFailure(mailbox Mailbox, completion *Completion, held *holding) {
+	var recovered = recover()
+	if recovered == nil {
+		return
+	}
+	var failure = PhilosopherFailure{Table: philosopher.table, Philosopher: philosopher.id, Name: philosopher.name,
+		Meal: philosopher.countEating, Panic: fmt.Sprint(recovered), Stack: string(goroutineStack())}
+	if workload, panicked := recovered.(workloadPanic); panicked {
+		failure.Panic, failure.Stack = fmt.Sprint(workload.value), string(workload.stack)
+	}
+	if held.locked {
+		philosopher.leaveUtensils(held.chopSticks)
+	}
+	switch {
+	case held.admitted:
+		philosopher.admission.Release(philosopher)
+	case held.chopSticks != nil:
+		mailbox.Tell(philosopher.release(true))
+	}
+	philosopher.failures.Record(failure)
+	philosopher.emit(eventPhilosopherFailed, fmt.Sprintf("%s\n%s", failure.Panic, failure.Stack))
+	philosopher.liveness.Leave(philosopher.id)
+	completion.GiveUp(philosopher.table, philosopher.id)
+}
+
+// goroutineStack returns the stack of the calling goroutine, at most 64KB of it
+func goroutineStack() []byte {
+	var stack = make([]byte, 64<<10)
+	return stack[:runtime.Stack(stack, false)]
+}
+
+// Failures returns the philosophers whose goroutine panicked, whatever their table
+func (result Result) Failures() []PhilosopherFailure {
+	var failures []PhilosopherFailure
+	for _, table := range result.Tables {
+		failures = append(failures, table.stats.failures...)
+	}
+	return failures
+}
+
+// failuresSummary tells the failures in a line, the philosophers separated by commas
+func failuresSummary(failures []PhilosopherFailure) string {
+	var lines = make([]string, len(failures))
+	for i, failure := range failures {
+		lines[i] = failure.String()
+	}
+	return strings.Join(lines, ", ")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// SeatFairness is how a philosopher fared compared with the others, he has :
+// - his mean wait per meal, from his first rejection to the start of the meal or his starvation
+// - his mean latency per meal, his mean wait along with the time he spent eating
+// - his share, his mean wait over the mean of the mean waits of all the philosophers, 1 when he waited as much as
+// the others, above 1 when he waited more
+type SeatFairness struct {
+	Table       int           `json:"table"`
+	Philosopher int           `json:"philosopher"`
+	Name        string        `json:"name"`
+	Wait        time.Duration `json:"wait"`
+	Latency     time.Duration `json:"latency"`
+	Share       float64       `json:"share"`
+}
+
+// Fairness tells in a single number how evenly the Host made the philosophers wait :
+// - Jain's fairness index of their mean waits, (Σx)² / (n Σx²), 1 when they all waited as much, down to 1/n when
+// a single philosopher did all the waiting
+// - the ratio of the longest mean latency per meal to the shortest, of the philosophers who ate, along with their names
+// - the fairness of each seat
+type Fairness struct {
+	Index        float64        `json:"index"`
+	LatencyRatio float64        `json:"latencyRatio"`
+	Slowest      string         `json:"slowest"`
+	Fastest      string         `json:"fastest"`
+	Seats        []SeatFairness `json:"seats"`
+}
+
+// NewFairness computes the Fairness of the dinner from the reports of its philosophers
+func NewFairness(reports []PhilosopherReport) Fairness {
+	var fairness = Fairness{Index: 1, LatencyRatio: 1}
+	var sum, squares = 0.0, 0.0
+	var slowest, fastest time.Duration = -1, -1
+	for _, report := range reports {
+		var seat = SeatFairness{Table: report.Table, Philosopher: report.Philosopher, Name: report.Name,
+			Wait: meanDuration(report.Waiting, int64(max(report.Meals, 1)))}
+		var wait = float64(seat.Wait)
+		sum += wait
+		squares += wait * wait
+		if report.Meals > 0 {
+			seat.Latency = meanDuration(report.Waiting+report.Eating, int64(report.Meals))
+			if seat.Latency > slowest {
+				slowest, fairness.Slowest = seat.Latency, report.Name
+			}
+			if fastest < 0 || seat.Latency < fastest {
+				fastest, fairness.Fastest = seat.Latency, report.Name
+			}
+		}
+		fairness.Seats = append(fairness.Seats, seat)
+	}
+	if squares > 0 {
+		fairness.Index = sum * sum / (float64(len(reports)) * squares)
+	}
+	if fastest > 0 {
+		fairness.LatencyRatio = float64(slowest) / float64(fastest)
+	}
+	for i := range fairness.Seats {
+		fairness.Seats[i].Share = 1
+		if sum > 0 {
+			fairness.Seats[i].Share = float64(fairness.Seats[i].Wait) * float64(len(reports)) / sum
+		}
+	}
+	return fairness
+}
+
+// String gives a one line summary of the Fairness
+func (fairness Fairness) String() string {
+	if fairness.Slowest == "" {
+		return fmt.Sprintf("Jain index %.3f of the waits", fairness.Index)
+	}
+	return fmt.Sprintf("Jain index %.3f of the waits, the meals of %s took %.2fx as long as the ones of %s",
+		fairness.Index, fairness.Slowest, fairness.LatencyRatio, fairness.Fastest)
+}
+
+// WriteFairness writes the fairness of each seat, so that the philosophers the Host made wait the most stand out
+func WriteFairness(w io.Writer, fairness Fairness) {
+	var writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tSEAT\tPHILOSOPHER\tMEAN WAIT\tMEAN LATENCY\tSHARE")
+	for _, seat := range fairness.Seats {
+		fmt.Fprintf(writer, "%d\t%d\t%s\t%v\t%v\t%.2f\n", seat.Table, seat.Philosopher, seat.Name,
+			seat.Wait.Round(time.Millisecond), seat.Latency.Round(time.Millisecond), seat.Share)
+	}
+	writer.Flush()
+}
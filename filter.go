@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter selects events with an expression such as philosopher==2 && event==rejected, made of :
+// - comparisons of a field of the event with a value, the numeric fields being seq, global, table, philosopher,
+// meal, queue and ticket, and the text fields run, simulation, name, event (or kind) and detail
+// - the operators ==, != and, for the numeric fields, <, <=, > and >=, =~ matching a text field with a regular expression
+// - values which are numbers, words or quoted strings, the kinds of events being compared without regard to case
+// - comparisons combined with &&, || and !, grouped with parentheses
+// A nil Filter selects every event
+type Filter struct {
+	expression string
+	match      func(Event) bool
+}
+
+// ParseFilter parses a filter expression
+func ParseFilter(expression string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	var parser = filterParser{tokens: tokens}
+	match, err := parser.or()
+	if err == nil && parser.position < len(tokens) {
+		err = fmt.Errorf("unexpected %q", tokens[parser.position].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter: %s: %v", expression, err)
+	}
+	return &Filter{expression: expression, match: match}, nil
+}
+
+// Match tells if the event is selected by the filter
+func (filter *Filter) Match(event Event) bool {
+	return filter == nil || filter.match(event)
+}
+
+// String returns the expression of the filter
+func (filter *Filter) String() string {
+	return filter.expression
+}
+
+// filterToken is a token of a filter expression, quoted tells a quoted string from a word or an operator
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+// filterOperators are the operators of the expressions, the longest first
+var filterOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")"}
+
+// tokenizeFilter splits an expression into words, quoted strings and operators
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expression); {
+		var c = rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			var end = strings.IndexRune(expression[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, filterToken{text: expression[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			var operator = ""
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator != "" {
+				tokens = append(tokens, filterToken{text: operator})
+				i += len(operator)
+				continue
+			}
+			var start = i
+			for i < len(expression) && !unicode.IsSpace(rune(expression[i])) && !strings.ContainsRune("\"'&|=!<>()", rune(expression[i])) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q at %d", expression[i], i)
+			}
+			tokens = append(tokens, filterToken{text: expression[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser parses the tokens of an expression by recursive descent, || binding less than && which binds less than !
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+// next returns the next token, an empty one at the end of the expression
+func (parser *filterParser) next() filterToken {
+	if parser.position >= len(parser.tokens) {
+		return filterToken{}
+	}
+	parser.position++
+	return parser.tokens[parser.position-1]
+}
+
+// accept consumes the next token when it is the given operator
+func (parser *filterParser) accept(operator string) bool {
+	if parser.position < len(parser.tokens) && !parser.tokens[parser.position].quoted && parser.tokens[parser.position].text == operator {
+		parser.position++
+		return true
+	}
+	return false
+}
+
+func (parser *filterParser) or() (func(Event) bool, error) {
+	left, err := parser.and()
+	for err == nil && parser.accept("||") {
+		var right func(Event) bool
+		if right, err = parser.and(); err == nil {
+			var first = left
+			left = func(event Event) bool { return first(event) || right(event) }
+		}
+	}
+	return left, err
+}
+
+func (parser *filterParser) and() (func(Event) bool, error) {
+	left, err := parser.unary()
+	for err == nil && parser.accept("&&") {
+		var right func(Event) bool
+		if right, err = parser.unary(); err == nil {
+			var first = left
+			left = func(event Event) bool { return first(event) && right(event) }
+		}
+	}
+	return left, err
+}
+
+func (parser *filterParser) unary() (func(Event) bool, error) {
+	if parser.accept("!") {
+		operand, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(event Event) bool { return !operand(event) }, nil
+	}
+	if parser.accept("(") {
+		inner, err := parser.or()
+		if err != nil {
+			return nil, err
+		}
+		if !parser.accept(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	}
+	return parser.comparison()
+}
+
+// comparison parses a field, an operator and a value
+func (parser *filterParser) comparison() (func(Event) bool, error) {
+	var field, operator, value = parser.next(), parser.next(), parser.next()
+	if field.text == "" || field.quoted {
+		return nil, fmt.Errorf("expected a field")
+	}
+	if operator.quoted || !strings.Contains(" == != < <= > >= =~ ", " "+operator.text+" ") {
+		return nil, fmt.Errorf("expected an operator after %s", field.text)
+	}
+	if value.text == "" && !value.quoted {
+		return nil, fmt.Errorf("expected a value after %s%s", field.text, operator.text)
+	}
+
+	if number := numericField(field.text); number != nil {
+		if operator.text == "=~" {
+			return nil, fmt.Errorf("%s is a number, it cannot be matched with =~", field.text)
+		}
+		expected, err := strconv.ParseInt(value.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is a number, got %q", field.text, value.text)
+		}
+		return func(event Event) bool { return compareNumbers(number(event), operator.text, expected) }, nil
+	}
+
+	var text = textField(field.text)
+	if text == nil {
+		return nil, fmt.Errorf("unknown field %q, expected seq, global, table, philosopher, meal, queue, ticket, run, simulation, name, event or detail", field.text)
+	}
+	switch operator.text {
+	case "=~":
+		pattern, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, err
+		}
+		return func(event Event) bool { return pattern.MatchString(text(event)) }, nil
+	case "==", "!=":
+		var expected = value.text
+		if field.text == "event" || field.text == "kind" {
+			var kind, found = findEventKind(expected)
+			if !found {
+				return nil, fmt.Errorf("unknown kind of event %q, expected one of %v", expected, eventKinds)
+			}
+			expected = string(kind)
+		}
+		var equal = operator.text == "=="
+		return func(event Event) bool { return (text(event) == expected) == equal }, nil
+	}
+	return nil, fmt.Errorf("%s is a text, it can only be compared with ==, != or =~", field.text)
+}
+
+// numericField returns the getter of a numeric field of the events, nil for the other fields
+func numericField(name string) func(Event) int64 {
+	switch name {
+	case "seq":
+		return func(event Event) int64 { return int64(event.Seq) }
+	case "global":
+		return func(event Event) int64 { return int64(event.Global) }
+	case "table":
+		return func(event Event) int64 { return int64(event.Table) }
+	case "philosopher":
+		return func(event Event) int64 { return int64(event.Philosopher) }
+	case "meal":
+		return func(event Event) int64 { return int64(event.Meal) }
+	case "queue":
+		return func(event Event) int64 { return int64(event.Queue) }
+	case "ticket":
+		return func(event Event) int64 { return int64(event.Ticket) }
+	}
+	return nil
+}
+
+// textField returns the getter of a text field of the events, nil for the other fields
+func textField(name string) func(Event) string {
+	switch name {
+	case "run":
+		return func(event Event) string { return event.Run }
+	case "simulation":
+		return func(event Event) string { return event.Simulation }
+	case "name":
+		return func(event Event) string { return event.Name }
+	case "event", "kind":
+		return func(event Event) string { return string(event.Kind) }
+	case "detail":
+		return func(event Event) string { return event.Detail }
+	}
+	return nil
+}
+
+// compareNumbers applies a comparison operator
+func compareNumbers(value int64, operator string, expected int64) bool {
+	switch operator {
+	case "==":
+		return value == expected
+	case "!=":
+		return value != expected
+	case "<":
+		return value < expected
+	case "<=":
+		return value <= expected
+	case ">":
+		return value > expected
+	}
+	return value >= expected
+}
@@ -0,0 +1,188 @@
+//go:build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// fixture is the fixture command, it turns the recorded trace of a dinner into a table-driven Go test replaying the
+// requests of a table to its Hosts, which must make the same decisions, so that an interleaving met in a real run
+// becomes a regression test of the Host. The trace is replayed before the test is written, the traces whose
+// decisions depend on the timing of the philosophers rather than on the order of the messages, which the discrete
+// engine never records, being refused with the first step the Host answers differently.
+func fixture(arguments []string) error {
+	var flags = flag.NewFlagSet("fixture", flag.ExitOnError)
+	var path = flags.String("store", "runs.db", "SQLite database where the run was saved along with its events")
+	var eventsFile = flags.String("events", "", "events.csv, events.json or events.pb file written by -export, instead of a run of the -store")
+	var configFile = flags.String("config", "", "JSON file describing the dinner of the -events file")
+	var seed = flags.Int64("seed", 0, "seed of the run of the -events file, told in the summary of the run (the one of the -config file by default)")
+	var table = flags.Int("table", 0, "table whose Hosts are replayed")
+	var name = flags.String("name", "", "name of the test, TestHostReplay followed by it (the run id or the name of the events file by default)")
+	var output = flags.String("o", "", "file where the test is written, such as host_replay_test.go (the standard output by default)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s fixture [-store runs.db] [-o host_replay_test.go] <run id>\n       %s fixture -events events.csv -config dinner.json -seed 42\n",
+			os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	var events []Event
+	var config Config
+	var err error
+	if *eventsFile != "" {
+		if flags.NArg() > 0 {
+			return fmt.Errorf("fixture: -events cannot be combined with a run id")
+		}
+		if config, err = LoadConfig(*configFile, Config{}); err != nil {
+			return err
+		}
+		if *seed != 0 {
+			config.Seed = *seed
+		}
+		if events, err = ReadEvents(*eventsFile); err != nil {
+			return err
+		}
+		if *name == "" {
+			*name = strings.TrimSuffix(*eventsFile, "."+lastField(*eventsFile, "."))
+		}
+	} else {
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("fixture: %q is not a run id", flags.Arg(0))
+		}
+		if config, events, err = loadStoredRun(*path, id); err != nil {
+			return err
+		}
+		if *name == "" {
+			*name = "Run" + flags.Arg(0)
+		}
+	}
+	if *table < 0 || *table >= config.Tables {
+		return fmt.Errorf("fixture: the dinner has no table %d", *table)
+	}
+
+	var steps = HostSteps(events, *table)
+	if len(steps) == 0 {
+		return fmt.Errorf("fixture: the trace holds no decision of the Hosts of table %d", *table)
+	}
+	mismatches, err := ReplayHost(config, *table, steps)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("fixture: the trace does not replay, record it with the discrete engine : %s", mismatches[0])
+	}
+	source, err := HostReplayTest(testName(*name), config, *table, steps)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	if err := os.WriteFile(*output, source, 0644); err != nil {
+		return fmt.Errorf("fixture: %v", err)
+	}
+	fmt.Printf("%d steps of table %d written to %s as TestHostReplay%s\n", len(steps), *table, *output, testName(*name))
+	return nil
+}
+
+// loadStoredRun reads the configuration and the events of a run saved in a Store with -store-events
+func loadStoredRun(path string, id int64) (Config, []Event, error) {
+	if _, err := os.Stat(path); err != nil {
+		return Config{}, nil, fmt.Errorf("fixture: %v", err)
+	}
+	store, err := OpenStore(path)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	runs, err := store.Runs(id)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	if len(runs) == 0 {
+		return Config{}, nil, fmt.Errorf("fixture: no run %d in %s", id, path)
+	}
+	config, err := ParseConfig([]byte(runs[0].Config), Config{})
+	if err != nil {
+		return Config{}, nil, err
+	}
+	events, err := store.Events(id)
+	if err == nil && len(events) == 0 {
+		err = fmt.Errorf("fixture: run %d has no event in %s, save it with -store-events", id, path)
+	}
+	return config, events, err
+}
+
+// HostReplayTest writes the source of a table-driven Go test of the main package replaying the steps of a table of
+// the dinner of the configuration to its Hosts, and expecting the same answers to the requests to eat
+func HostReplayTest(name string, config Config, table int, steps []HostStep) ([]byte, error) {
+	configJSON, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("fixture: %v", err)
+	}
+	var literal = strconv.Quote(string(configJSON))
+	if !bytes.ContainsRune(configJSON, '`') {
+		literal = "`" + string(configJSON) + "`"
+	}
+
+	var source bytes.Buffer
+	fmt.Fprintf(&source, "// Code generated by the fixture command from a trace of the dinner of config %s, seed %d. DO NOT EDIT.\n\n",
+		NewRunInfo(config).ConfigHash, config.Seed)
+	fmt.Fprintf(&source, "package main\n\nimport \"testing\"\n\n")
+	fmt.Fprintf(&source, "// TestHostReplay%s replays the requests of table %d to its Hosts, which must answer them as they did\n", name, table)
+	fmt.Fprintf(&source, "func TestHostReplay%s(t *testing.T) {\n", name)
+	fmt.Fprintf(&source, "config, err := ParseConfig([]byte(%s), Config{})\n", literal)
+	fmt.Fprintf(&source, "if err != nil {\nt.Fatal(err)\n}\n")
+	fmt.Fprintf(&source, "var steps = []HostStep{\n")
+	for _, step := range steps {
+		fmt.Fprintf(&source, "{At: %d, Seat: %d, Meal: %d, Command: %q", int64(step.At), step.Seat, step.Meal, step.Command)
+		if step.Command == askStep || step.Command == starveStep {
+			fmt.Fprintf(&source, ", HungrySince: %d", int64(step.HungrySince))
+		}
+		if step.Allowed {
+			fmt.Fprintf(&source, ", Allowed: true")
+		}
+		fmt.Fprintf(&source, "},\n")
+	}
+	fmt.Fprintf(&source, "}\n")
+	fmt.Fprintf(&source, "mismatches, err := ReplayHost(config, %d, steps)\n", table)
+	fmt.Fprintf(&source, "if err != nil {\nt.Fatal(err)\n}\n")
+	fmt.Fprintf(&source, "for _, mismatch := range mismatches {\nt.Error(mismatch)\n}\n}\n")
+	return format.Source(source.Bytes())
+}
+
+// testName turns a name into the suffix of the name of a Go test, keeping its letters and digits
+func testName(name string) string {
+	var suffix []rune
+	var upper = true
+	for _, r := range lastField(name, "/") {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		suffix, upper = append(suffix, r), false
+	}
+	return string(suffix)
+}
+
+// lastField returns what follows the last separator in the text, the whole text without separator
+func lastField(text, separator string) string {
+	return text[strings.LastIndex(text, separator)+len(separator):]
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ganttWidth is how many columns the bars of a Gantt chart span
+const ganttWidth = 72
+
+// Timeline follows the meals of a dinner as they are eaten, its emergent schedule, it is meant to be an EventBus
+// handler. The times are taken from the first event, in simulated time with the discrete engine.
+type Timeline struct {
+	mutex  sync.Mutex
+	start  time.Time
+	last   time.Time
+	eating map[[2]int]int // the meal each philosopher is eating, by table and seat, as an index of meals
+	meals  []ScheduledMeal
+}
+
+// NewTimeline creates an empty Timeline
+func NewTimeline() *Timeline {
+	return &Timeline{eating: make(map[[2]int]int)}
+}
+
+// Record follows the starts, the ends and the pauses of the meals, a failure ending the meal being eaten
+func (timeline *Timeline) Record(event Event) {
+	timeline.mutex.Lock()
+	defer timeline.mutex.Unlock()
+	if timeline.start.IsZero() {
+		timeline.start = event.Time
+	}
+	timeline.last = event.Time
+	var philosopher = [2]int{event.Table, event.Philosopher}
+	switch event.Kind {
+	case eventStarted:
+		timeline.eating[philosopher] = len(timeline.meals)
+		timeline.meals = append(timeline.meals, ScheduledMeal{Table: event.Table, Seat: event.Philosopher, Name: event.Name,
+			Meal: event.Meal, Start: event.Time.Sub(timeline.start)})
+	case eventFinished, eventPaused, eventPhilosopherFailed:
+		if index, eating := timeline.eating[philosopher]; eating {
+			timeline.meals[index].End = event.Time.Sub(timeline.start)
+			delete(timeline.eating, philosopher)
+		}
+	}
+}
+
+// Meals returns the meals eaten so far in the order they started, the ones still being eaten ending with the last event
+func (timeline *Timeline) Meals() []ScheduledMeal {
+	if timeline == nil {
+		return nil
+	}
+	timeline.mutex.Lock()
+	defer timeline.mutex.Unlock()
+	var meals = append([]ScheduledMeal(nil), timeline.meals...)
+	for _, index := range timeline.eating {
+		meals[index].End = timeline.last.Sub(timeline.start)
+	}
+	return meals
+}
+
+// GanttRow is a row of a Gantt chart : the meals of a seat in the Plan, or at a table of the dinner. The name of the
+// philosopher is only given on the first row of his seat.
+type GanttRow struct {
+	Seat     int
+	Table    int // -1 for the Plan
+	Name     string
+	Schedule string
+	Planned  bool
+	Meals    []ScheduledMeal
+}
+
+// NewGantt lays out the Gantt chart of the meals of a dinner, a row per seat and per table, under the row of the
+// same seat in the Plan when there is one, and returns its rows along with the span of the chart
+func NewGantt(plan *Plan, meals []ScheduledMeal) ([]GanttRow, time.Duration) {
+	var rows = make(map[[2]int]*GanttRow)
+	var span time.Duration
+	var tables = make(map[int]bool)
+	var add = func(table int, meal ScheduledMeal, planned bool) {
+		var key = [2]int{meal.Seat, table}
+		if rows[key] == nil {
+			rows[key] = &GanttRow{Seat: meal.Seat, Table: table, Name: meal.Name, Planned: planned}
+		}
+		rows[key].Meals = append(rows[key].Meals, meal)
+		span = max(span, meal.End)
+	}
+	if plan != nil {
+		for _, meal := range plan.Meals {
+			add(-1, meal, true)
+		}
+	}
+	for _, meal := range meals {
+		add(meal.Table, meal, false)
+		tables[meal.Table] = true
+	}
+	var sorted = make([]GanttRow, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Seat != sorted[j].Seat {
+			return sorted[i].Seat < sorted[j].Seat
+		}
+		return sorted[i].Table < sorted[j].Table
+	})
+	for index := range sorted {
+		var row = &sorted[index]
+		if index > 0 && sorted[index-1].Seat == row.Seat {
+			row.Name = ""
+		}
+		switch {
+		case row.Planned:
+			row.Schedule = "plan"
+		case len(tables) > 1:
+			row.Schedule = fmt.Sprintf("table %d", row.Table)
+		default:
+			row.Schedule = "dinner"
+		}
+	}
+	if span <= 0 {
+		span = time.Millisecond
+	}
+	return sorted, span
+}
+
+// WriteGantt writes the Gantt chart of the meals of a dinner (see NewGantt), so that the planned and the emergent
+// schedules can be compared. The planned meals are drawn with =, the eaten ones with #, on the same scale.
+func WriteGantt(w io.Writer, plan *Plan, meals []ScheduledMeal) {
+	var rows, span = NewGantt(plan, meals)
+	var writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "PHILOSOPHER\tSCHEDULE\t 0%*v\n", ganttWidth-1, span.Round(time.Millisecond))
+	for _, row := range rows {
+		var mark = byte('#')
+		if row.Planned {
+			mark = '='
+		}
+		var bar = []byte(strings.Repeat(" ", ganttWidth))
+		for _, meal := range row.Meals {
+			var from = int(int64(meal.Start) * ganttWidth / int64(span))
+			var to = max(int(int64(meal.End)*ganttWidth/int64(span)), from+1)
+			for column := from; column < min(to, ganttWidth); column++ {
+				bar[column] = mark
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%s\t|%s|\n", row.Name, row.Schedule, bar)
+	}
+	writer.Flush()
+}
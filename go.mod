@@ -0,0 +1,3 @@
+module github.com/frferrari/godiningphilosophers
+
+go 1.24
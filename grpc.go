@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Below are the gRPC status codes returned by the API
+const grpcOK = 0
+const grpcInvalidArgument = 3
+const grpcNotFound = 5
+const grpcResourceExhausted = 8
+const grpcFailedPrecondition = 9
+const grpcUnimplemented = 12
+const grpcInternal = 13
+
+const grpcService = "/philosophers.v1.SimulationService/"
+
+// GRPCServer serves the API described in api/philosophers/v1/simulation.proto, it controls the simulations of a Registry.
+// A request without a simulation id is about the last simulation started through this server, as it was when
+// the server only controlled one simulation at a time.
+// It speaks gRPC over cleartext HTTP/2 with the standard library, the messages being encoded by hand (see protoMessage)
+// since the API is small and this keeps the program free of dependencies.
+type GRPCServer struct {
+	mutex    sync.Mutex
+	registry *Registry
+	lastID   string
+}
+
+// grpcError is an error along with the gRPC status code to answer
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (err grpcError) Error() string {
+	return err.message
+}
+
+// ServeGRPC listens on the given address and serves the API for the simulations of the registry until an error occurs
+func ServeGRPC(address string, registry *Registry) error {
+	var server = &GRPCServer{registry: registry}
+	var mux = http.NewServeMux()
+	mux.HandleFunc(grpcService+"StartSimulation", server.unary(server.startSimulation))
+	mux.HandleFunc(grpcService+"GetState", server.unary(server.getState))
+	mux.HandleFunc(grpcService+"UpdateConfig", server.unary(server.updateConfig))
+	mux.HandleFunc(grpcService+"StreamEvents", server.streamEvents)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeGRPCStatus(w, grpcUnimplemented, "unknown method "+r.URL.Path)
+	})
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	var httpServer = &http.Server{Addr: address, Handler: mux, Protocols: &protocols}
+	return httpServer.ListenAndServe()
+}
+
+// simulation returns the simulation with the given id, the last one started through this server when the id is empty
+func (server *GRPCServer) simulation(id string) (*Simulation, error) {
+	if id == "" {
+		server.mutex.Lock()
+		id = server.lastID
+		server.mutex.Unlock()
+		if id == "" {
+			return nil, grpcError{grpcFailedPrecondition, "no dinner has been started"}
+		}
+	}
+	var simulation = server.registry.Get(id)
+	if simulation == nil {
+		return nil, grpcError{grpcNotFound, fmt.Sprintf("no simulation %q", id)}
+	}
+	return simulation, nil
+}
+
+// startSimulation handles StartSimulation
+func (server *GRPCServer) startSimulation(request []byte) (protoMessage, error) {
+	var configJSON []byte
+	err := parseProto(request, func(number int, varint uint64, bytes []byte) error {
+		if number == 1 {
+			configJSON = bytes
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, grpcError{grpcInvalidArgument, err.Error()}
+	}
+	config, err := ParseConfig(configJSON, Config{})
+	if err != nil {
+		return nil, grpcError{grpcInvalidArgument, err.Error()}
+	}
+
+	id, simulation, err := server.registry.Create(config)
+	if err != nil {
+		if limitErr, ok := err.(LimitError); ok && !limitErr.busy {
+			return nil, grpcError{grpcInvalidArgument, err.Error()}
+		}
+		return nil, grpcError{grpcResourceExhausted, err.Error()}
+	}
+	server.mutex.Lock()
+	server.lastID = id
+	server.mutex.Unlock()
+
+	var response protoMessage
+	response.Int(1, int64(config.Tables))
+	response.Int(2, int64(config.Philosophers))
+	response.Int(3, int64(config.Meals))
+	response.Int(4, int64(config.MaxEaters))
+	response.String(5, id)
+	response.String(6, simulation.Info().ID)
+	response.String(7, simulation.Info().ConfigHash)
+	response.String(8, simulation.Info().Strategy)
+	response.Int(9, simulation.Info().Seed)
+	response.String(10, simulation.Info().GoVersion)
+	response.Uint(11, schemaVersion)
+	return response, nil
+}
+
+// getState handles GetState
+func (server *GRPCServer) getState(request []byte) (protoMessage, error) {
+	id, err := parseSimulationID(request, 1)
+	if err != nil {
+		return nil, err
+	}
+	simulation, err := server.simulation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var state = simulation.State()
+	var response protoMessage
+	response.Bool(1, state.Running)
+	response.Bool(2, state.Failed)
+	response.Uint(3, state.Events)
+	for _, philosopher := range state.Philosophers {
+		var message protoMessage
+		message.Int(1, int64(philosopher.Table))
+		message.Int(2, int64(philosopher.Philosopher))
+		message.String(3, philosopher.Name)
+		message.String(4, string(philosopher.Phase))
+		message.Int(5, int64(philosopher.MealsEaten))
+		response.Message(4, message)
+	}
+	return response, nil
+}
+
+// updateConfig handles UpdateConfig
+func (server *GRPCServer) updateConfig(request []byte) (protoMessage, error) {
+	var maxEaters int32
+	var id string
+	err := parseProto(request, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			maxEaters = int32(varint)
+		case 2:
+			id = string(bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, grpcError{grpcInvalidArgument, err.Error()}
+	}
+
+	simulation, err := server.simulation(id)
+	if err != nil {
+		return nil, err
+	}
+	if maxEaters != 0 {
+		if err := simulation.SetMaxEaters(int(maxEaters)); err != nil {
+			return nil, grpcError{grpcFailedPrecondition, err.Error()}
+		}
+	}
+
+	var response protoMessage
+	response.Int(1, int64(simulation.Config().MaxEaters))
+	return response, nil
+}
+
+// streamEvents handles StreamEvents, it streams the events until the dinner is over or the client goes away
+func (server *GRPCServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	request, err := readGRPCMessage(r)
+	if err != nil {
+		writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+		return
+	}
+	id, err := parseSimulationID(request, 1)
+	if err != nil {
+		writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+		return
+	}
+	simulation, err := server.simulation(id)
+	if err != nil {
+		writeGRPCStatus(w, err.(grpcError).code, err.Error())
+		return
+	}
+
+	var events = simulation.Events().Subscribe(1000)
+	defer simulation.Events().Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	var flusher, _ = w.(http.Flusher)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				w.Header().Set("Grpc-Status", strconv.Itoa(grpcOK))
+				return
+			}
+			if err := writeGRPCMessage(w, eventProto(event)); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// parseSimulationID returns the simulation id found in the given field of a request, empty when it is not set
+func parseSimulationID(request []byte, field int) (string, error) {
+	var id string
+	err := parseProto(request, func(number int, varint uint64, bytes []byte) error {
+		if number == field {
+			id = string(bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", grpcError{grpcInvalidArgument, err.Error()}
+	}
+	return id, nil
+}
+
+// unary turns a function handling a unary RPC into an HTTP handler
+func (server *GRPCServer) unary(method func(request []byte) (protoMessage, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, err := readGRPCMessage(r)
+		if err != nil {
+			writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		response, err := method(request)
+		if err != nil {
+			var code = grpcInternal
+			if status, ok := err.(grpcError); ok {
+				code = status.code
+			}
+			writeGRPCStatus(w, code, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		if err := writeGRPCMessage(w, response); err != nil {
+			return
+		}
+		w.Header().Set("Grpc-Status", strconv.Itoa(grpcOK))
+	}
+}
+
+// readGRPCMessage reads the single message sent by the client, compressed messages are not supported
+func readGRPCMessage(r *http.Request) ([]byte, error) {
+	if r.ProtoMajor != 2 {
+		return nil, fmt.Errorf("gRPC requires HTTP/2")
+	}
+	var prefix [5]byte
+	if _, err := io.ReadFull(r.Body, prefix[:]); err != nil {
+		return nil, fmt.Errorf("reading message: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("compressed messages are not supported")
+	}
+	var message = make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r.Body, message); err != nil {
+		return nil, fmt.Errorf("reading message: %v", err)
+	}
+	return message, nil
+}
+
+// writeGRPCMessage writes a length prefixed message
+func writeGRPCMessage(w io.Writer, message protoMessage) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// writeGRPCStatus answers an error without any message, the status being sent in the headers (Trailers-Only response)
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const historySize = 1000 // the Host remembers its last 1000 decisions
+
+// Decision is a decision of the Host about a request to eat
+type Decision struct {
+	at          time.Time
+	philosopher string
+	accepted    bool
+	reason      Reason
+}
+
+// Reason tells why the Host rejects a request to eat, it is only formatted when it is read so that a quiet dinner
+// never formats it. The format refers to the neighbor, the utensil, the philosopher given way to, the reason given
+// by the Strategy, the ticket of the philosopher given way to and the time until the next token of a throttled
+// philosopher by their index. held is the utensil the neighbor holds, when he holds one.
+type Reason struct {
+	format   string
+	neighbor int
+	utensil  UtensilKind
+	urgent   string
+	strategy string
+	ticket   uint64
+	wait     time.Duration
+	held     *ChopStick
+}
+
+// String formats the reason
+func (reason Reason) String() string {
+	if !strings.Contains(reason.format, "%") {
+		return reason.format
+	}
+	return fmt.Sprintf(reason.format, reason.neighbor, reason.utensil, reason.urgent, reason.strategy, reason.ticket, reason.wait)
+}
+
+// History keeps the last decisions of a Host, so that they can be dumped when something goes wrong
+// The decisions are kept in a ring preallocated on the first decision, the oldest one being overwritten
+type History struct {
+	decisions []Decision
+	next      int
+	full      bool
+}
+
+// Record adds a decision to the history, forgetting the oldest one when the history is full
+func (history *History) Record(decision Decision) {
+	if history.decisions == nil {
+		history.decisions = make([]Decision, historySize)
+	}
+	history.decisions[history.next] = decision
+	history.next = (history.next + 1) % historySize
+	if history.next == 0 {
+		history.full = true
+	}
+}
+
+// Dump describes the decisions taken since the given time, relatively to it
+func (history *History) Dump(since time.Time) string {
+	var ordered = history.decisions[:history.next]
+	if history.full {
+		ordered = append(append([]Decision{}, history.decisions[history.next:]...), ordered...)
+	}
+	var lines []string
+	for _, decision := range ordered {
+		if decision.at.Before(since) {
+			continue
+		}
+		if decision.accepted {
+			lines = append(lines, fmt.Sprintf("  +%.3fs accepts %s", decision.at.Sub(since).Seconds(), decision.philosopher))
+		} else {
+			lines = append(lines, fmt.Sprintf("  +%.3fs rejects %s (%s)", decision.at.Sub(since).Seconds(), decision.philosopher, decision.reason))
+		}
+	}
+	if len(lines) == 0 {
+		return "  no decision"
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,208 @@
+package main
+
+import "time"
+
+// HostMessage is a message sent to the mailbox of a Host, each kind of message having its own struct :
+// - GrantRequest when a philosopher would like to eat, the Host answers on his feedback channel
+// - Confirm when a philosopher granted a meal with the two-phase grants took its utensils, or gave them up
+// - Release when a philosopher finished his meal, or paused it when he was asked to
+// - Starve when a philosopher ran out of energy
+// - SitDown when a guest takes a seat in the open mode
+// - ResumeGrant when a dinner restored from a Snapshot gives back the utensils of a philosopher who was eating
+// - SetMaxEaters when the number of philosophers allowed to eat at the same time is changed during the dinner
+// - Shutdown when the dinner is stopped before all the meals are eaten
+// - QueryState when someone wants to know what the Host is doing, the Host answers on the reply channel
+// The messages are flattened into a Request before they reach the mailbox, so that sending one allocates nothing,
+// a Request received from a remote philosopher being a HostMessage as well
+type HostMessage interface {
+	envelope() Request
+}
+
+// GrantRequest asks the Host to let the philosopher of a seat eat his meal, he got hungry at hungrySince
+type GrantRequest struct {
+	philosopher int
+	meal        int
+	hungrySince time.Time
+	sent        time.Time
+}
+
+// Confirm tells the Host that the philosopher of a seat took the utensils of the meal it granted him with the two-phase
+// grants, aborted telling that he gave the grant back instead, one of them being still held at the end of the confirm timeout
+type Confirm struct {
+	philosopher int
+	meal        int
+	aborted     bool
+	sent        time.Time
+}
+
+// Release tells the Host that the philosopher of a seat left his utensils, paused tells that his meal is not over
+type Release struct {
+	philosopher int
+	meal        int
+	paused      bool
+	sent        time.Time
+}
+
+// Starve tells the Host that the philosopher of a seat ran out of energy, hungry since hungrySince
+type Starve struct {
+	philosopher int
+	meal        int
+	hungrySince time.Time
+	sent        time.Time
+}
+
+// SitDown tells the Host that a guest takes the seat, replacing its previous occupant
+type SitDown struct {
+	philosopher int
+	guest       *Philosopher
+}
+
+// ResumeGrant gives back to the philosopher of a seat the utensils of the meal he was eating when the snapshot was taken
+type ResumeGrant struct {
+	philosopher int
+	meal        int
+}
+
+// SetMaxEaters changes how many philosophers the Host allows to eat at the same time
+type SetMaxEaters struct {
+	maxEaters int
+}
+
+// Shutdown tells the Host that the dinner is stopped
+type Shutdown struct{}
+
+// QueryState asks the Host for its HostState, sent on reply
+type QueryState struct {
+	reply chan HostState
+}
+
+// envelope flattens the request to eat into a Request, the same way as the other messages below
+func (request GrantRequest) envelope() Request {
+	return Request{command: wantToEat, philosopher: request.philosopher, meal: request.meal, hungrySince: request.hungrySince, sent: request.sent}
+}
+
+func (confirm Confirm) envelope() Request {
+	var command = confirmedEating
+	if confirm.aborted {
+		command = abortedEating
+	}
+	return Request{command: command, philosopher: confirm.philosopher, meal: confirm.meal, sent: confirm.sent}
+}
+
+func (release Release) envelope() Request {
+	var command = finishedEating
+	if release.paused {
+		command = pausedEating
+	}
+	return Request{command: command, philosopher: release.philosopher, meal: release.meal, sent: release.sent}
+}
+
+func (starve Starve) envelope() Request {
+	return Request{command: starved, philosopher: starve.philosopher, meal: starve.meal, hungrySince: starve.hungrySince, sent: starve.sent}
+}
+
+func (message SitDown) envelope() Request {
+	return Request{command: sitDown, philosopher: message.philosopher, guest: message.guest}
+}
+
+func (resume ResumeGrant) envelope() Request {
+	return Request{command: resumeEating, philosopher: resume.philosopher, meal: resume.meal}
+}
+
+func (update SetMaxEaters) envelope() Request {
+	return Request{command: updateMaxEaters, maxEaters: update.maxEaters}
+}
+
+func (Shutdown) envelope() Request {
+	return Request{command: stopDinner}
+}
+
+func (query QueryState) envelope() Request {
+	return Request{command: queryState, reply: query.reply}
+}
+
+func (request Request) envelope() Request {
+	return request
+}
+
+// Mailbox is the bounded channel a Host receives its messages from, it holds requestChannelSize messages before
+// the senders block
+type Mailbox chan Request
+
+// Tell sends the message to the Host without waiting for its answer
+func (mailbox Mailbox) Tell(message HostMessage) {
+	mailbox <- message.envelope()
+}
+
+// HostState is what a Host tells when it is asked with QueryState, or publishes for the State of a Simulation :
+// - the table and the seats of its shard
+// - the seats eating, the utensils it gave them, and how many philosophers eat at the table out of the ones allowed
+// - the seats among them whose grant is reserved but not confirmed yet with the two-phase grants
+// - how many messages wait in its mailbox, and whether the dinner is stopped
+// - how many messages it received, how many requests to eat it accepted and rejected, and how many of them it rejected
+// for each cause
+// It is a copy taken between two messages, which the Host does not change afterwards.
+type HostState struct {
+	Table     int             `json:"table"`
+	First     int             `json:"first"`
+	Last      int             `json:"last"`
+	Eating    []int           `json:"eating"`
+	Reserved  []int           `json:"reserved,omitempty"`
+	Holders   []UtensilHolder `json:"holders,omitempty"`
+	Eaters    int             `json:"eaters"`
+	MaxEaters int             `json:"maxEaters"`
+	Mailbox   int             `json:"mailbox"`
+	Stopping  bool            `json:"stopping"`
+	Requests  int             `json:"requests"`
+	Accepted  int             `json:"accepted"`
+	Rejected  int             `json:"rejected"`
+	Causes    map[string]int  `json:"causes,omitempty"`
+}
+
+// UtensilHolder is a utensil the Host gave to the philosopher of a seat, sorted by utensil in a HostState
+type UtensilHolder struct {
+	Utensil int         `json:"utensil"`
+	Kind    UtensilKind `json:"kind"`
+	Seat    int         `json:"seat"`
+	Name    string      `json:"name"`
+}
+
+// HostActor is the Host of a shard of a table seen as an actor : it owns the record of the seats of the shard and
+// the utensils they hold, and only changes them when it processes a message of its Mailbox, one at a time (see Run)
+// Anything talking to a Host, the philosophers, the engines running them or the Simulation, goes through its
+// Mailbox, which lets a Host be driven on its own by Ask and State.
+type HostActor struct {
+	table   *Table
+	shard   *Shard
+	mailbox Mailbox
+}
+
+// NewHostActor creates the Host of a shard of the table, it receives its messages once Run is called
+func NewHostActor(table *Table, shard *Shard) *HostActor {
+	return &HostActor{table: table, shard: shard, mailbox: shard.requestChan}
+}
+
+// Tell sends the message to the Host without waiting for its answer
+func (host *HostActor) Tell(message HostMessage) {
+	host.mailbox.Tell(message)
+}
+
+// Ask sends the request to eat to the Host and waits for its answer, on the feedback channel of the philosopher
+// which nobody else must read meanwhile, a request to pause arriving first being skipped
+func (host *HostActor) Ask(request GrantRequest) Grant {
+	host.mailbox.Tell(request)
+	var feedbackChannel = host.table.philosophers[request.philosopher].feedbackChannel
+	var grant = <-feedbackChannel
+	for grant.preempt {
+		grant = <-feedbackChannel
+	}
+	return grant
+}
+
+// State asks the Host what it is doing and waits for its answer, the messages already in its mailbox being
+// processed first
+func (host *HostActor) State() HostState {
+	var reply = make(chan HostState, 1)
+	host.mailbox.Tell(QueryState{reply: reply})
+	return <-reply
+}
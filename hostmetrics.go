@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// hostLatencyBuckets are the upper bounds of the buckets counting the decision latencies of the Hosts
+var hostLatencyBuckets = [...]time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second}
+
+// HostMetrics measures the Host of a shard while the dinner takes place, so that they can be read at any time :
+// - how many requests it received, and the peak depth of its queue of requests when it received them
+// - how many requests to eat it decided, and how long they waited from their sending until the answer of the Host,
+// in total, at most and per bucket of hostLatencyBuckets
+// - how long the Host was busy processing the requests
+// - how many requests to eat its Overload shed, before they reached the Host or by the Host, and the retry-after
+// hints it gave, in total and at most
+// The latencies are measured on the wall clock even when the dinner is simulated, since they tell how fast the Host is,
+// a request of a simulated dinner having no sending time and waiting from its receipt
+type HostMetrics struct {
+	requests   atomic.Int64
+	depthPeak  atomic.Int64
+	decisions  atomic.Int64
+	latency    atomic.Int64
+	maxLatency atomic.Int64
+	buckets    [len(hostLatencyBuckets) + 1]atomic.Int64
+	busy       atomic.Int64
+	shed       atomic.Int64
+	shedByHost atomic.Int64
+	retryAfter atomic.Int64
+	maxRetry   atomic.Int64
+}
+
+// HostLoad is what the HostMetrics of a shard tell at some point, along with the requests waiting in its queue
+type HostLoad struct {
+	Table         int           `json:"table"`
+	Shard         int           `json:"shard"`
+	Requests      int64         `json:"requests"`
+	Depth         int           `json:"depth"`
+	DepthPeak     int64         `json:"depthPeak"`
+	Decisions     int64         `json:"decisions"`
+	Latency       time.Duration `json:"latency"`
+	MaxLatency    time.Duration `json:"maxLatency"`
+	Buckets       []int64       `json:"buckets"`
+	Busy          time.Duration `json:"busy"`
+	Shed          int64         `json:"shed"`
+	ShedByHost    int64         `json:"shedByHost"`
+	RetryAfter    time.Duration `json:"retryAfter"`
+	MaxRetryAfter time.Duration `json:"maxRetryAfter"`
+}
+
+// Received counts a request received while depth other requests were waiting
+func (metrics *HostMetrics) Received(depth int) {
+	metrics.requests.Add(1)
+	storeMax(&metrics.depthPeak, int64(depth))
+}
+
+// Decided counts a request to eat answered after the given latency
+func (metrics *HostMetrics) Decided(latency time.Duration) {
+	metrics.decisions.Add(1)
+	metrics.latency.Add(int64(latency))
+	storeMax(&metrics.maxLatency, int64(latency))
+	var bucket = 0
+	for bucket < len(hostLatencyBuckets) && latency > hostLatencyBuckets[bucket] {
+		bucket++
+	}
+	metrics.buckets[bucket].Add(1)
+}
+
+// Busy adds the time the Host took to process a request
+func (metrics *HostMetrics) Busy(processing time.Duration) {
+	metrics.busy.Add(int64(processing))
+}
+
+// Shed counts a request to eat shed by the Host, or before reaching it, with the hint telling when to retry
+func (metrics *HostMetrics) Shed(byHost bool, retryAfter time.Duration) {
+	if byHost {
+		metrics.shedByHost.Add(1)
+	} else {
+		metrics.shed.Add(1)
+	}
+	metrics.retryAfter.Add(int64(retryAfter))
+	storeMax(&metrics.maxRetry, int64(retryAfter))
+}
+
+// HostLoads returns what the HostMetrics of each shard of the table tell now
+func (table *Table) HostLoads() []HostLoad {
+	var loads []HostLoad
+	for index, shard := range table.shards {
+		var metrics = &shard.metrics
+		var load = HostLoad{
+			Table:         table.id,
+			Shard:         index,
+			Requests:      metrics.requests.Load(),
+			Depth:         len(shard.requestChan),
+			DepthPeak:     metrics.depthPeak.Load(),
+			Decisions:     metrics.decisions.Load(),
+			Latency:       time.Duration(metrics.latency.Load()),
+			MaxLatency:    time.Duration(metrics.maxLatency.Load()),
+			Buckets:       make([]int64, len(metrics.buckets)),
+			Busy:          time.Duration(metrics.busy.Load()),
+			Shed:          metrics.shed.Load(),
+			ShedByHost:    metrics.shedByHost.Load(),
+			RetryAfter:    time.Duration(metrics.retryAfter.Load()),
+			MaxRetryAfter: time.Duration(metrics.maxRetry.Load())}
+		for bucket := range metrics.buckets {
+			load.Buckets[bucket] = metrics.buckets[bucket].Load()
+		}
+		loads = append(loads, load)
+	}
+	return loads
+}
+
+// HostLoads returns what the HostMetrics of each shard of each table tell now
+func (simulation *Simulation) HostLoads() []HostLoad {
+	var loads []HostLoad
+	for _, table := range simulation.tables {
+		loads = append(loads, table.HostLoads()...)
+	}
+	return loads
+}
+
+// WriteHostLoads writes the load of the Host of each shard at the end of the dinner, with the share of the dinner it
+// spent processing requests so that a Host close to 100% stands out as the bottleneck
+func WriteHostLoads(w io.Writer, result Result, elapsed time.Duration) {
+	var writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tSHARD\tREQUESTS\tQUEUE PEAK\tDECISIONS\tMEAN LATENCY\tMAX LATENCY\tBUSY\tSHED")
+	for _, table := range result.Tables {
+		for _, load := range table.HostLoads() {
+			var busy = 0.0
+			if elapsed > 0 {
+				busy = 100 * float64(load.Busy) / float64(elapsed)
+			}
+			fmt.Fprintf(writer, "%d\t%d\t%d\t%d\t%d\t%v\t%v\t%v (%.1f%%)\t%d\n", load.Table, load.Shard, load.Requests, load.DepthPeak,
+				load.Decisions, meanDuration(load.Latency, load.Decisions), load.MaxLatency.Round(time.Microsecond),
+				load.Busy.Round(time.Microsecond), busy, load.Shed+load.ShedByHost)
+		}
+	}
+	writer.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const causeInheritance = "inheritance" // the cause of the rejections giving way to a philosopher blocked by a holder of lower priority
+
+// Inversions detects the priority inversions at a table : a philosopher of high priority turned down because a
+// philosopher of lower priority holds one of his utensils opens an inversion window, and the window is an inversion
+// when philosophers of a priority between theirs are served in his way before he is, taking his utensils or the last
+// place at the table, as a medium priority task runs while a high priority one waits for a lock held by a low priority one. It is owned by the Host, and when priorityInheritance
+// is set the holder inherits the priority of the philosopher he blocks while the window is open :
+// - the Host gives way to the blocked philosopher, by rejecting the requests of lower priority which would take his
+// utensils or the last place at the table
+// - the holder cannot be preempted by the philosophers of a priority below the one he inherits
+// A nil Inversions means that all the philosophers have the same priority, so that nothing can be inverted.
+type Inversions struct {
+	inherit bool
+	windows map[int]*inversionWindow // the open windows, by seat of the blocked philosopher
+	count   int
+	longest time.Duration
+	total   time.Duration
+}
+
+// inversionWindow is a philosopher blocked by a holder of lower priority since the given time, along with the meals
+// of medium priority served in his way meanwhile
+type inversionWindow struct {
+	blocked *Philosopher
+	holder  *Philosopher
+	utensil *ChopStick
+	since   time.Time
+	served  []string
+}
+
+// NewInversions creates the inversion detector of a Host, it returns nil when the philosophers all have the same priority
+func NewInversions(config Config) *Inversions {
+	var first = config.Priority(0)
+	for philosopher := 1; philosopher < config.Philosophers; philosopher++ {
+		if config.Priority(philosopher) != first {
+			return &Inversions{inherit: config.PriorityInheritance, windows: make(map[int]*inversionWindow)}
+		}
+	}
+	return nil
+}
+
+// Blocked records that the philosopher was turned down because the holder holds the utensil, and tells if the holder
+// inherits his priority from now on, the window staying open until the philosopher is served or leaves and telling
+// the holder of the lowest priority met meanwhile
+func (inversions *Inversions) Blocked(philosopher, holder *Philosopher, utensil *ChopStick, now time.Time) bool {
+	if inversions == nil || holder.priority >= philosopher.priority {
+		return false
+	}
+	if window, open := inversions.windows[philosopher.id]; open {
+		if holder.priority < window.holder.priority {
+			window.holder, window.utensil = holder, utensil
+		}
+	} else {
+		inversions.windows[philosopher.id] = &inversionWindow{blocked: philosopher, holder: holder, utensil: utensil, since: now}
+	}
+	return inversions.inherit
+}
+
+// GiveWay tells if the philosopher has to be rejected in favor of a philosopher of higher priority blocked by a
+// holder of lower priority, either because they need the same utensils or because lastPlace tells that only one more
+// philosopher can eat, which only happens with priority inheritance. It returns the name of the blocked philosopher.
+func (inversions *Inversions) GiveWay(philosopher *Philosopher, lastPlace bool) (string, bool) {
+	if inversions == nil || !inversions.inherit {
+		return "", false
+	}
+	var blocked []*inversionWindow
+	for _, window := range inversions.windows {
+		if window.blocked.priority > philosopher.priority {
+			blocked = append(blocked, window)
+		}
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].since.Before(blocked[j].since) })
+	for _, window := range blocked {
+		if lastPlace || shareUtensils(window.blocked.needs, philosopher.needs) {
+			return window.blocked.name, true
+		}
+	}
+	return "", false
+}
+
+// Served records that the philosopher starts eating, lastPlace telling that he takes the last place at the table :
+// it is a meal of medium priority for the windows of the philosophers of higher priority blocked by a holder of lower
+// priority than his, when he needs their utensils or takes the last place, and it closes his own window.
+// It returns what the closed window tells when it was an inversion, empty otherwise.
+func (inversions *Inversions) Served(philosopher *Philosopher, now time.Time, lastPlace bool) string {
+	if inversions == nil {
+		return ""
+	}
+	for _, window := range inversions.windows {
+		if window.holder.priority < philosopher.priority && philosopher.priority < window.blocked.priority &&
+			(lastPlace || shareUtensils(window.blocked.needs, philosopher.needs)) {
+			window.served = append(window.served, philosopher.name)
+		}
+	}
+	var window, open = inversions.windows[philosopher.id]
+	if !open {
+		return ""
+	}
+	delete(inversions.windows, philosopher.id)
+	if len(window.served) == 0 {
+		return ""
+	}
+	var lasted = now.Sub(window.since)
+	inversions.count++
+	inversions.total += lasted
+	inversions.longest = max(inversions.longest, lasted)
+	return fmt.Sprintf("%s (priority %d) waited %v for the %s %d held by %s (priority %d), while %d meals of medium priority were served %v",
+		philosopher.name, philosopher.priority, lasted.Round(time.Millisecond), window.utensil.kind, window.utensil.id,
+		window.holder.name, window.holder.priority, len(window.served), window.served)
+}
+
+// Left forgets a philosopher who left the table without eating
+func (inversions *Inversions) Left(philosopher *Philosopher) {
+	if inversions != nil {
+		delete(inversions.windows, philosopher.id)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "fmt"
+
+// KitchenRequest is used by the Hosts to send messages to the Kitchen :
+// - takeRice when a Host is about to let a philosopher eat and needs a serving from the rice pot
+// - releaseRice when the philosopher has finished eating and the serving can be given back
+type KitchenRequest struct {
+	command         string
+	table           int
+	feedbackChannel chan bool
+}
+
+// Below are the allowed command for the KitchenRequest struct
+const takeRice = "takeRice"
+const releaseRice = "releaseRice"
+
+// Kitchen is the higher level coordinator shared by all the tables, it owns a rice pot from which
+// at most capacity philosophers (whatever their table) can be served at the same time.
+// The Hosts only talk to the Kitchen once their own table rules allow a philosopher to eat,
+// so the arbitration is hierarchical : table first, then kitchen.
+type Kitchen struct {
+	capacity    int
+	requestChan chan KitchenRequest
+	events      *EventBus
+}
+
+// NewKitchen creates a Kitchen whose rice pot can serve capacity philosophers at the same time
+func NewKitchen(capacity int, events *EventBus) *Kitchen {
+	return &Kitchen{capacity: capacity, requestChan: make(chan KitchenRequest), events: events}
+}
+
+// Run processes the requests of the Hosts until the Kitchen is closed
+func (kitchen *Kitchen) Run() {
+	var servings = 0
+	var servingsPerTable = make(map[int]int)
+
+	for request := range kitchen.requestChan {
+		switch request.command {
+		case takeRice:
+			if servings < kitchen.capacity {
+				servings++
+				servingsPerTable[request.table]++
+				var detail string
+				if !kitchen.events.Quiet() {
+					detail = fmt.Sprintf("%d/%d", servings, kitchen.capacity)
+				}
+				kitchen.events.Emit(Event{Table: request.table, Kind: eventRiceServed, Detail: detail})
+				request.feedbackChannel <- true
+			} else {
+				request.feedbackChannel <- false
+			}
+		case releaseRice:
+			if servingsPerTable[request.table] > 0 {
+				servings--
+				servingsPerTable[request.table]--
+			}
+		}
+	}
+}
+
+// TakeRice asks the Kitchen for a serving of rice on behalf of a table, it returns false when the pot is at capacity
+func (kitchen *Kitchen) TakeRice(table int) bool {
+	var feedbackChannel = make(chan bool)
+	kitchen.requestChan <- KitchenRequest{command: takeRice, table: table, feedbackChannel: feedbackChannel}
+	return <-feedbackChannel
+}
+
+// ReleaseRice gives a serving back to the Kitchen once a philosopher of the table has finished eating
+func (kitchen *Kitchen) ReleaseRice(table int) {
+	kitchen.requestChan <- KitchenRequest{command: releaseRice, table: table}
+}
+
+// Close stops the Kitchen, it must only be called once all the tables have finished
+func (kitchen *Kitchen) Close() {
+	close(kitchen.requestChan)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Liveness watches the philosophers of a table through their heartbeats, so that a philosopher who hangs or whose
+// goroutine is gone is noticed while the dinner takes place :
+// - each philosopher beats every heartbeat interval while he thinks, waits for the Host and eats, which records the
+// time of his last heartbeat in his seat
+// - the monitor looks at the seats every interval, a philosopher silent for longer than the liveness timeout is
+// unresponsive, which is a liveness incident, and he is responsive again once he beats
+// - a philosopher who ate all his meals or left the table no longer beats, his seat is not watched until another
+// philosopher sits on it
+// The monitor is paused along with the Simulation, whose philosophers then stop beating as they tell what they do.
+// A nil Liveness means that the heartbeats are disabled.
+type Liveness struct {
+	interval     time.Duration
+	timeout      time.Duration
+	beats        []atomic.Int64
+	silentSince  []time.Time
+	suspended    atomic.Bool
+	table        *Table
+	incidents    int
+	unresponsive map[string]int
+	started      bool
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewLiveness creates the liveness monitor of a table, it returns nil when the configuration sets no heartbeat interval
+func NewLiveness(table *Table) *Liveness {
+	if table.config.HeartbeatInterval == 0 {
+		return nil
+	}
+	return &Liveness{
+		interval:     time.Duration(table.config.HeartbeatInterval),
+		timeout:      time.Duration(table.config.LivenessTimeout),
+		beats:        make([]atomic.Int64, len(table.philosophers)),
+		silentSince:  make([]time.Time, len(table.philosophers)),
+		unresponsive: make(map[string]int),
+		table:        table,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{})}
+}
+
+// Heartbeat returns the channel ticking when the philosophers have to beat, nil when the heartbeats are disabled
+// so that waiting on it costs nothing
+func (liveness *Liveness) Heartbeat() (<-chan time.Time, func()) {
+	if liveness == nil {
+		return nil, func() {}
+	}
+	var ticker = time.NewTicker(liveness.interval)
+	return ticker.C, ticker.Stop
+}
+
+// Beat records that the philosopher of the given seat is alive
+func (liveness *Liveness) Beat(seat int) {
+	if liveness == nil {
+		return
+	}
+	liveness.beats[seat].Store(time.Now().UnixNano())
+}
+
+// Leave records that the philosopher of the given seat is done, his seat is no longer watched
+func (liveness *Liveness) Leave(seat int) {
+	if liveness == nil {
+		return
+	}
+	liveness.beats[seat].Store(0)
+}
+
+// Suspend stops the monitor while the Simulation is paused, and restarts it when it resumes as if every philosopher
+// had just beaten
+func (liveness *Liveness) Suspend(suspended bool) {
+	if liveness == nil {
+		return
+	}
+	if !suspended {
+		var now = time.Now().UnixNano()
+		for seat := range liveness.beats {
+			if liveness.beats[seat].Load() != 0 {
+				liveness.beats[seat].Store(now)
+			}
+		}
+	}
+	liveness.suspended.Store(suspended)
+}
+
+// Start starts the monitor
+func (liveness *Liveness) Start() {
+	if liveness == nil {
+		return
+	}
+	liveness.started = true
+	go liveness.run()
+}
+
+// run looks at the heartbeats of the philosophers every interval until Stop is called
+func (liveness *Liveness) run() {
+	var ticker = time.NewTicker(liveness.interval)
+	defer ticker.Stop()
+	defer close(liveness.done)
+	for {
+		select {
+		case <-liveness.stop:
+			return
+		case now := <-ticker.C:
+			if !liveness.suspended.Load() {
+				liveness.check(now)
+			}
+		}
+	}
+}
+
+// check tells the philosophers who became unresponsive, and the ones who are responsive again
+func (liveness *Liveness) check(now time.Time) {
+	for seat := range liveness.beats {
+		var beat = liveness.beats[seat].Load()
+		if beat == 0 {
+			liveness.silentSince[seat] = time.Time{}
+			continue
+		}
+		var last = time.Unix(0, beat)
+		var philosopher = liveness.table.philosophers[seat]
+		if now.Sub(last) > liveness.timeout && liveness.silentSince[seat].IsZero() {
+			liveness.silentSince[seat] = last
+			liveness.incidents++
+			liveness.unresponsive[philosopher.name]++
+			liveness.emit(philosopher, eventUnresponsive, fmt.Sprintf("no heartbeat for %v", now.Sub(last).Round(time.Millisecond)))
+		} else if now.Sub(last) <= liveness.timeout && !liveness.silentSince[seat].IsZero() {
+			liveness.emit(philosopher, eventResponsive, fmt.Sprintf("after %v without heartbeat", last.Sub(liveness.silentSince[seat]).Round(time.Millisecond)))
+			liveness.silentSince[seat] = time.Time{}
+		}
+	}
+}
+
+// emit tells the EventBus of the table that the philosopher of a seat became unresponsive, or responsive again,
+// the events of a guest being named after the seat since the monitor does not know who sits on it
+func (liveness *Liveness) emit(philosopher *Philosopher, kind EventKind, detail string) {
+	liveness.table.events.Emit(Event{
+		Table:       liveness.table.id,
+		Philosopher: philosopher.id,
+		Name:        philosopher.name,
+		Kind:        kind,
+		Detail:      detail})
+}
+
+// Stop stops the started monitor and waits for it, the incidents can then be read
+func (liveness *Liveness) Stop() {
+	if liveness == nil || !liveness.started {
+		return
+	}
+	close(liveness.stop)
+	<-liveness.done
+}
@@ -0,0 +1,101 @@
+//go:build !js
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTTPWorkload is the Workload sending a request to an endpoint for each meal, the {table}, {philosopher} and {meal}
+// of the url being replaced by those of the meal, a status of 400 or above failing the call
+func HTTPWorkload(client *http.Client, method, url string) Workload {
+	return func(ctx context.Context, meal WorkloadMeal) error {
+		var replacer = strings.NewReplacer("{table}", fmt.Sprint(meal.Table), "{philosopher}", fmt.Sprint(meal.Philosopher),
+			"{meal}", fmt.Sprint(meal.Meal))
+		request, err := http.NewRequestWithContext(ctx, method, replacer.Replace(url), nil)
+		if err != nil {
+			return err
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		io.Copy(io.Discard, response.Body)
+		if response.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("status %s", response.Status)
+		}
+		return nil
+	}
+}
+
+// loadgen is the loadgen command, it runs the dinner of a configuration as a load generator : each meal is a request
+// to an endpoint, sent while the philosopher holds his utensils, so that the endpoint sees the contention pattern of
+// the dinner, shaped by the Hosts and their strategy, then it tells how the requests went and the summary of the Hosts
+func loadgen(arguments []string) error {
+	var flags = flag.NewFlagSet("loadgen", flag.ExitOnError)
+	var configFile = flags.String("config", "", "JSON file describing the dinner (philosophers, meals, maxEaters, strategy)")
+	var topology = flags.String("topology", "", "seating of the philosophers, ring:N, grid:WxH or torus:WxH (overrides the config file)")
+	var url = flags.String("url", "", "endpoint requested at each meal, where {table}, {philosopher} and {meal} are replaced by those of the meal")
+	var method = flags.String("method", http.MethodGet, "method of the requests")
+	var timeout = flags.Duration("timeout", 10*time.Second, "how long a request may take before it fails")
+	var duration = flags.Duration("duration", 0, "stop the load after this duration (such as 30s), once all the meals are eaten otherwise")
+	var quiet = flags.Bool("quiet", false, "do not print the events, only the summary of the load")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s loadgen -url http://localhost:8080/items/{philosopher} [-config dinner.json] [-duration 30s]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+	if *url == "" {
+		flags.Usage()
+		return fmt.Errorf("loadgen: missing -url")
+	}
+
+	var overrides Config
+	if *topology != "" {
+		parsed, err := ParseTopology(*topology)
+		if err != nil {
+			return err
+		}
+		overrides.Topology = parsed
+	}
+	config, err := LoadConfig(*configFile, overrides)
+	if err != nil {
+		return err
+	}
+
+	generator, err := NewLoadGenerator(config, HTTPWorkload(&http.Client{Timeout: *timeout}, *method, *url))
+	if err != nil {
+		return err
+	}
+	if !*quiet {
+		generator.Events().Handle(LogEvents(NewConsoleLogger(os.Stdout), NewMessages(config, lifecycleVerbosity)))
+	}
+	if *duration > 0 {
+		var timer = time.AfterFunc(*duration, generator.Stop)
+		defer timer.Stop()
+	}
+	var report = generator.Run()
+
+	fmt.Printf("Load : %s\n", report)
+	var messages = make([]string, 0, len(report.Errors))
+	for message := range report.Errors {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+	for _, message := range messages {
+		fmt.Printf("  %d x %s\n", report.Errors[message], message)
+	}
+	for _, table := range report.Result.Tables {
+		fmt.Printf("Table %d : %s\n", table.id, table.stats)
+	}
+	return nil
+}
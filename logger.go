@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// LogLevel tells how important a message is, the levels have the values of the slog levels
+type LogLevel int
+
+// Below are the levels of the messages
+const LevelDebug LogLevel = -4
+const LevelInfo LogLevel = 0
+const LevelWarn LogLevel = 4
+const LevelError LogLevel = 8
+
+// Logger is where the simulation writes what happens, the events of the dinner and the state of the distributed Hosts,
+// so that an application embedding the simulation gets them in its own logs. keysAndValues are alternating keys
+// and values, as in slog, describing the message :
+// - the console logger prints the message alone, the way the dinner has always been told
+// - the adapters for slog, zap and zerolog hand the message and its fields to the logging library
+// - the no-op logger drops everything
+type Logger interface {
+	Log(level LogLevel, message string, keysAndValues ...any)
+}
+
+// String gives the name of the level, in lower case as most logging libraries do
+func (level LogLevel) String() string {
+	switch {
+	case level < LevelInfo:
+		return "debug"
+	case level < LevelWarn:
+		return "info"
+	case level < LevelError:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ConsoleLogger prints the messages alone, one per line, without their level nor their fields
+type ConsoleLogger struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewConsoleLogger creates a ConsoleLogger printing on the given writer, usually the standard output
+func NewConsoleLogger(writer io.Writer) *ConsoleLogger {
+	return &ConsoleLogger{writer: writer}
+}
+
+// Log prints the message
+func (logger *ConsoleLogger) Log(level LogLevel, message string, keysAndValues ...any) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	fmt.Fprintln(logger.writer, message)
+}
+
+// NopLogger drops the messages
+type NopLogger struct{}
+
+// Log does nothing
+func (NopLogger) Log(LogLevel, string, ...any) {}
+
+// SlogLogger hands the messages to a slog.Logger
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger creates a Logger writing to the given slog.Logger
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+// Log logs the message at the slog level of the same value
+func (logger *SlogLogger) Log(level LogLevel, message string, keysAndValues ...any) {
+	logger.logger.Log(context.Background(), slog.Level(level), message, keysAndValues...)
+}
+
+// ZapSugaredLogger is the part of the *zap.SugaredLogger of go.uber.org/zap used by the ZapLogger, which is
+// therefore given the application's logger without the simulation depending on zap
+type ZapSugaredLogger interface {
+	Debugw(message string, keysAndValues ...any)
+	Infow(message string, keysAndValues ...any)
+	Warnw(message string, keysAndValues ...any)
+	Errorw(message string, keysAndValues ...any)
+}
+
+// ZapLogger hands the messages to a zap SugaredLogger
+type ZapLogger struct {
+	logger ZapSugaredLogger
+}
+
+// NewZapLogger creates a Logger writing to the given zap SugaredLogger, such as zap.L().Sugar()
+func NewZapLogger(logger ZapSugaredLogger) *ZapLogger {
+	return &ZapLogger{logger: logger}
+}
+
+// Log logs the message with the method of its level
+func (logger *ZapLogger) Log(level LogLevel, message string, keysAndValues ...any) {
+	switch {
+	case level < LevelInfo:
+		logger.logger.Debugw(message, keysAndValues...)
+	case level < LevelWarn:
+		logger.logger.Infow(message, keysAndValues...)
+	case level < LevelError:
+		logger.logger.Warnw(message, keysAndValues...)
+	default:
+		logger.logger.Errorw(message, keysAndValues...)
+	}
+}
+
+// ZerologEvent is the part of the *zerolog.Event of github.com/rs/zerolog used by the ZerologLogger, E being the
+// event itself, *zerolog.Event, as its methods return it to be chained
+type ZerologEvent[E any] interface {
+	Fields(fields any) E
+	Msg(message string)
+}
+
+// ZerologLevels is the part of the *zerolog.Logger used by the ZerologLogger, which is therefore given the
+// application's logger without the simulation depending on zerolog
+type ZerologLevels[E ZerologEvent[E]] interface {
+	Debug() E
+	Info() E
+	Warn() E
+	Error() E
+}
+
+// ZerologLogger hands the messages to a zerolog Logger
+type ZerologLogger[E ZerologEvent[E]] struct {
+	logger ZerologLevels[E]
+}
+
+// NewZerologLogger creates a Logger writing to the given zerolog Logger, such as
+// NewZerologLogger[*zerolog.Event](&log.Logger), Go not telling the type of the events from the Logger alone
+func NewZerologLogger[E ZerologEvent[E]](logger ZerologLevels[E]) *ZerologLogger[E] {
+	return &ZerologLogger[E]{logger: logger}
+}
+
+// Log logs the message with the event of its level, the keys and values being its fields, in their order
+func (logger *ZerologLogger[E]) Log(level LogLevel, message string, keysAndValues ...any) {
+	var event E
+	switch {
+	case level < LevelInfo:
+		event = logger.logger.Debug()
+	case level < LevelWarn:
+		event = logger.logger.Info()
+	case level < LevelError:
+		event = logger.logger.Warn()
+	default:
+		event = logger.logger.Error()
+	}
+	event.Fields(keysAndValues).Msg(message)
+}
+
+// zerologLines writes the JSON lines zerolog writes with its default settings, a level, a time in RFC 3339, the
+// fields and a message, durations being in milliseconds, so that -log zerolog does not depend on zerolog
+type zerologLines struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// zerologLine is a line being written by zerologLines
+type zerologLine struct {
+	lines  *zerologLines
+	level  LogLevel
+	fields []any
+}
+
+func (lines *zerologLines) Debug() *zerologLine { return &zerologLine{lines: lines, level: LevelDebug} }
+func (lines *zerologLines) Info() *zerologLine  { return &zerologLine{lines: lines, level: LevelInfo} }
+func (lines *zerologLines) Warn() *zerologLine  { return &zerologLine{lines: lines, level: LevelWarn} }
+func (lines *zerologLines) Error() *zerologLine { return &zerologLine{lines: lines, level: LevelError} }
+
+// Fields adds the keys and values of a list of them, as zerolog does
+func (line *zerologLine) Fields(fields any) *zerologLine {
+	if keysAndValues, ok := fields.([]any); ok {
+		line.fields = append(line.fields, keysAndValues...)
+	}
+	return line
+}
+
+// Msg writes the line as JSON, the fields keep their order
+func (line *zerologLine) Msg(message string) {
+	var encoded = []byte(`{"level":`)
+	encoded = appendJSON(encoded, line.level.String())
+	encoded = append(encoded, `,"time":`...)
+	encoded = appendJSON(encoded, time.Now().Format(time.RFC3339))
+	for i := 0; i+1 < len(line.fields); i += 2 {
+		encoded = append(encoded, ',')
+		encoded = appendJSON(encoded, fmt.Sprint(line.fields[i]))
+		encoded = append(encoded, ':')
+		encoded = appendJSON(encoded, zerologValue(line.fields[i+1]))
+	}
+	encoded = append(encoded, `,"message":`...)
+	encoded = appendJSON(encoded, message)
+	encoded = append(encoded, "}\n"...)
+
+	line.lines.mutex.Lock()
+	defer line.lines.mutex.Unlock()
+	line.lines.writer.Write(encoded)
+}
+
+// zerologValue converts a field the way zerolog writes it
+func zerologValue(value any) any {
+	switch value := value.(type) {
+	case time.Duration:
+		return float64(value) / float64(time.Millisecond)
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return value
+}
+
+// appendJSON appends the value encoded in JSON, or its text when it cannot be encoded
+func appendJSON(line []byte, value any) []byte {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return append(line, encoded...)
+}
+
+// NewLogger creates the Logger of the given name for the -log flag, writing to the given writer :
+// console, slog (text), json (slog JSON), zerolog or none
+func NewLogger(name string, writer io.Writer) (Logger, error) {
+	switch name {
+	case "console":
+		return NewConsoleLogger(writer), nil
+	case "slog":
+		return NewSlogLogger(slog.New(slog.NewTextHandler(writer, nil))), nil
+	case "json":
+		return NewSlogLogger(slog.New(slog.NewJSONHandler(writer, nil))), nil
+	case "zerolog":
+		return NewZerologLogger[*zerologLine](&zerologLines{writer: writer}), nil
+	case "none":
+		return NopLogger{}, nil
+	}
+	return nil, fmt.Errorf("unknown logger %q, expecting console, slog, json, zerolog or none", name)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestZerologLoggerWritesLevelAndFields checks that the ZerologLogger logs with the event of the level of the message,
+// its keys and values becoming the fields of the event
+func TestZerologLoggerWritesLevelAndFields(t *testing.T) {
+	var buffer bytes.Buffer
+	var logger = NewZerologLogger[*zerologLine](&zerologLines{writer: &buffer})
+	logger.Log(LevelWarn, "starving", "philosopher", "3", "waited", 1500*time.Millisecond)
+
+	var line map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &line); err != nil {
+		t.Fatalf("%q : %v", buffer.String(), err)
+	}
+	for key, want := range map[string]any{"level": "warn", "message": "starving", "philosopher": "3", "waited": 1500.0} {
+		if line[key] != want {
+			t.Errorf("%s : got %v, want %v", key, line[key], want)
+		}
+	}
+}
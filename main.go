@@ -0,0 +1,690 @@
+//go:build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Start of the program
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := history(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := bench(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "debug" {
+		if err := debug(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		if err := query(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		if err := verify(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "exercise" {
+		if err := exercise(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tune" {
+		if err := tune(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		if err := loadgen(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fixture" {
+		if err := fixture(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := diff(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		if err := bundle(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "versus" {
+		if err := versus(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var configFile = flag.String("config", "", "JSON file describing the dinner (philosophers, meals, maxEaters, topology)")
+	var topology = flag.String("topology", "", "seating of the philosophers, ring:N, grid:WxH or torus:WxH (overrides the config file)")
+	var grpcAddress = flag.String("grpc", "", "serve the gRPC control API on this address (such as :50051) instead of running a dinner")
+	var httpAddress = flag.String("http", "", "serve the REST API on this address (such as :8080) instead of running a dinner")
+	var serveAddress = flag.String("serve", "", "run the Host of the table on this address (such as :7000) for philosophers joining from other processes")
+	var serveText = flag.String("serve-text", "", "run the Host of the table on this address (such as :7001) for philosophers talking the text protocol, one JSON message per line")
+	var clientCommand = flag.String("clients", "", "run this command once per seat (such as 'python3 examples/philosopher.py'), a philosopher talking the text protocol over its standard input and output")
+	var replicas = flag.String("replicas", "", "addresses of the replicas of the Host serving the table, separated by commas (such as localhost:7000,localhost:7001)")
+	var replica = flag.Int("replica", 0, "index of this replica among the addresses of -replicas")
+	var joinAddress = flag.String("join", "", "run a single philosopher joining the table served on this address, or on one of several addresses separated by commas")
+	var seat = flag.Int("seat", 0, "seat of the philosopher joining a served table")
+	var sinkTexts sinkFlags
+	flag.Var(&sinkTexts, "sink", "write the events to this sink instead of the console, such as jsonl:events.jsonl;filter=event==starved;buffer=1024, may be repeated (console, file, jsonl, sqlite, nats or prometheus)")
+	var natsAddress = flag.String("nats", "", "publish the events to the NATS server at this address (such as nats://localhost:4222)")
+	var natsEncoding = flag.String("nats-encoding", natsJSON, "encoding of the events published to NATS, json or proto for the Event message of api/philosophers/v1")
+	var natsSubject = flag.String("nats-subject", defaultNATSSubject, "subject of the events published to NATS, where {kind}, {table} and {philosopher} are replaced by the fields of the event")
+	var storePath = flag.String("store", "", "save the run in this SQLite database (such as runs.db), see the history command")
+	var sample = flag.String("sample", "", "only keep a sample of the events saved by -store-events and -export : every:N keeps one event out of N, reservoir:N a uniform sample of N events, both can be combined such as every:10,reservoir:100000")
+	var storeEvents = flag.Bool("store-events", false, "also save the trace of the events in the -store database")
+	var export = flag.String("export", "", "export the events and the totals of each philosopher in this format (csv, json or proto) to the -out directory")
+	var exportDir = flag.String("out", ".", "directory where -export writes its files and -report its report, or the file of the report when it has the extension of its format")
+	var reportFormat = flag.String("report", "", "write a report of the dinner holding its summary, its fairness, its rejections and the Gantt chart of its meals, in this format (md or html), such as -report html -out report.html")
+	var resultPath = flag.String("result", "", "save the throughput, the waits, the rejections and the violations of the run in this file (such as run.json), see the diff command")
+	var snapshotPath = flag.String("snapshot", "", "save a snapshot of the dinner in this file (such as dinner.json) once -snapshot-after meals are eaten, then leave (discrete engine only)")
+	var snapshotAfter = flag.Int("snapshot-after", 1, "how many meals are eaten before -snapshot is taken")
+	var seats seatFlags
+	flag.Var(&seats, "philosopher", "override the settings of a seat, such as 3:eat=2s,meals=10,priority=5, may be repeated")
+	var restorePath = flag.String("restore", "", "resume the dinner saved in this snapshot file by -snapshot, whose configuration it holds")
+	var quiet = flag.Bool("quiet", false, "do not print the events nor format their details, only the summary of the dinner")
+	var verbose = flag.Bool("v", false, "also print the decisions of the Hosts, the preemptions, the rice served and the backpressure")
+	var veryVerbose = flag.Bool("vv", false, "print the decisions of the Hosts along with the requests waiting for them")
+	var progressInterval = flag.Duration("progress", 0, "report the meals eaten, the throughput and the time left on the standard error every this duration (such as 5s), as a bar on a terminal")
+	var filterExpression = flag.String("filter", "", "only print the events selected by this expression, such as 'philosopher==2 && event==rejected'")
+	var warmup = flag.Duration("warmup", 0, "leave the events of this beginning of the dinner (such as 5s) out of the steady state summary")
+	var cooldown = flag.Duration("cooldown", 0, "leave the events of this end of the dinner (such as 2s) out of the steady state summary")
+	var speed = flag.String("speed", "", "run the dinner this many times faster, such as 10x, or slower, such as 0.1x (overrides the config file)")
+	var hosts = flag.Bool("hosts", false, "print the load of each Host at the end of the dinner : its requests, its queue, its decision latency and how busy it was")
+	var check = flag.Bool("check", false, "follow who holds each utensil and report their misuses, such as releasing a utensil not held, as protocol violations, failing the run when there are some")
+	var explain = flag.Bool("explain", false, "narrate each decision of the Host : who holds the utensils, why the request was rejected and what would have happened without the Host")
+	var fairness = flag.Bool("fairness", false, "print the mean wait, the mean latency and the share of the waits of each philosopher at the end of the dinner")
+	var dryRun = flag.Bool("plan", false, "compute the schedule of the dinner offline and print it as a Gantt chart, then leave without running the dinner")
+	var gantt = flag.Bool("gantt", false, "print the Gantt chart of the meals at the end of the dinner, under the schedule computed offline for comparison")
+	var contention = flag.Int("contention", 0, "print this many of the most contended utensils at the end of the dinner")
+	var logName = flag.String("log", "console", "how the events are written on the standard output : console, slog, json (slog JSON), zerolog or none")
+	var pprofAddress = flag.String("pprof", "", "serve the CPU, heap and goroutine profiles of the process on this address (such as :6060) under /debug/pprof/")
+	var tracePath = flag.String("trace", "", "record an execution trace of the dinner in this file (such as trace.out), see go tool trace")
+	var maxSimulations = flag.Int("max-simulations", 0, "how many simulations -grpc and -http hold, taking place or over, 0 for no limit")
+	var maxPhilosophers = flag.Int("max-philosophers", 0, "how many philosophers -grpc and -http allow in a simulation, 0 for no limit")
+	var maxDuration = flag.Duration("max-duration", 0, "how long -grpc and -http let a simulation last before stopping it, 0 for no limit")
+	var retention = flag.Duration("retention", time.Hour, "how long -grpc and -http keep a simulation once over, 0 until room is needed")
+	flag.Parse()
+
+	logger, err := NewLogger(*logName, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var verbosity = lifecycleVerbosity
+	if *verbose {
+		verbosity = decisionsVerbosity
+	}
+	if *veryVerbose {
+		verbosity = queuesVerbosity
+	}
+	if *quiet && verbosity != lifecycleVerbosity {
+		fmt.Fprintln(os.Stderr, "-quiet cannot be combined with -v nor -vv")
+		os.Exit(1)
+	}
+
+	var filter *Filter
+	if *filterExpression != "" {
+		if filter, err = ParseFilter(*filterExpression); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if *pprofAddress != "" {
+		fmt.Printf("Serving the profiles on %s/debug/pprof/\n", *pprofAddress)
+		servePprof(*pprofAddress)
+	}
+
+	if *joinAddress != "" {
+		if err := JoinTable(strings.Split(*joinAddress, ","), *seat, logger); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Philosopher %d has finished eating, good bye\n", *seat)
+		return
+	}
+
+	if *grpcAddress != "" || *httpAddress != "" {
+		// both APIs can be served at the same time, they share the simulations
+		var registry = NewRegistry(Limits{Simulations: *maxSimulations, Philosophers: *maxPhilosophers, Duration: *maxDuration,
+			Retention: *retention})
+		dumpOnSignal(func() any { return registry.List() })
+		var failed = make(chan error, 2)
+		if *grpcAddress != "" {
+			fmt.Printf("Serving the gRPC API on %s\n", *grpcAddress)
+			go func() { failed <- ServeGRPC(*grpcAddress, registry) }()
+		}
+		if *httpAddress != "" {
+			fmt.Printf("Serving the REST API on %s\n", *httpAddress)
+			go func() { failed <- ServeREST(*httpAddress, registry) }()
+		}
+		fmt.Fprintln(os.Stderr, <-failed)
+		os.Exit(1)
+	}
+
+	var overrides Config
+	if *topology != "" {
+		parsed, err := ParseTopology(*topology)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		overrides.Topology = parsed
+	}
+	if *speed != "" {
+		if overrides.Speed, err = ParseSpeed(*speed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, text := range seats {
+		settings, err := ParseSeatSettings(text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		overrides.Seats = append(overrides.Seats, settings)
+	}
+
+	var snapshot []byte
+	var config Config
+	if *restorePath != "" {
+		if *configFile != "" || *topology != "" || *speed != "" || len(seats) > 0 {
+			fmt.Fprintln(os.Stderr, "-restore takes the configuration of the snapshot, it cannot be combined with -config, -topology, -speed nor -philosopher")
+			os.Exit(1)
+		}
+		if snapshot, err = os.ReadFile(*restorePath); err == nil {
+			var restored Snapshot
+			restored, err = ParseSnapshot(snapshot)
+			config = restored.Config
+		}
+	} else {
+		config, err = LoadConfig(*configFile, overrides)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *check {
+		config.Check = true
+	}
+	if *explain {
+		config.Explain = true
+	}
+	if *dryRun {
+		if config.ArrivalRate > 0 {
+			fmt.Fprintln(os.Stderr, "-plan cannot plan the dinner of the open mode, whose guests arrive when they want")
+			os.Exit(1)
+		}
+		plan, err := NewPlan(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Plan : %s\n", plan)
+		WriteGantt(os.Stdout, &plan, nil)
+		return
+	}
+
+	// the events are printed on the console unless sinks are given, -filter selecting the lines of the console
+	var specs []SinkSpec
+	for _, text := range sinkTexts {
+		spec, err := ParseSinkSpec(text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 && !*quiet {
+		specs = append(specs, SinkSpec{Kind: consoleSink})
+	}
+	if *natsAddress != "" {
+		specs = append(specs, SinkSpec{Kind: natsSink, Target: *natsAddress, Buffer: natsBufferSize})
+	}
+	var sinks = &Sinks{}
+	var sinkOptions = SinkOptions{Messages: NewMessages(config, verbosity), Logger: logger, LogName: *logName,
+		NATSSubject: *natsSubject, NATSEncoding: *natsEncoding}
+	for _, spec := range specs {
+		if (spec.Kind == consoleSink || spec.Kind == fileSink) && spec.Filter == nil {
+			spec.Filter = filter
+		}
+		sink, err := NewSink(spec, sinkOptions)
+		if err != nil {
+			sinks.Close()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		sinks.Add(spec, sink)
+	}
+
+	if *export != "" && *export != exportCSV && *export != exportJSON && *export != exportProto {
+		fmt.Fprintf(os.Stderr, "unknown export format %q, expected %s, %s or %s\n", *export, exportCSV, exportJSON, exportProto)
+		os.Exit(1)
+	}
+	var reportPath string
+	if *reportFormat != "" {
+		if *reportFormat != reportMarkdown && *reportFormat != reportHTML {
+			fmt.Fprintf(os.Stderr, "unknown report format %q, expected %s or %s\n", *reportFormat, reportMarkdown, reportHTML)
+			os.Exit(1)
+		}
+		reportPath = filepath.Join(*exportDir, "report."+*reportFormat)
+		if filepath.Ext(*exportDir) == "."+*reportFormat {
+			if *export != "" {
+				fmt.Fprintln(os.Stderr, "-export writes its files in the -out directory, which cannot be the file of -report")
+				os.Exit(1)
+			}
+			reportPath = *exportDir
+		}
+	}
+	var store *Store
+	if *storePath != "" {
+		if store, err = OpenStore(*storePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	// the reports of the philosophers give the fairness of the dinner, the events are only kept to be saved
+	var recorder = NewRecorder(*storeEvents || *export != "")
+	if *sample != "" {
+		sampling, err := ParseTraceSampling(*sample, config.Seed)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		recorder.Sample(sampling)
+	}
+
+	var steady *SteadyState
+	if *warmup > 0 || *cooldown > 0 {
+		steady = NewSteadyState(*warmup, *cooldown)
+	}
+
+	// the whole dinner is followed for -result, without warm-up nor cool-down
+	var whole *SteadyState
+	if *resultPath != "" {
+		whole = NewSteadyState(0, 0)
+	}
+
+	var slos = NewSLOTracker(config)
+
+	var timeline *Timeline
+	if *gantt || *reportFormat != "" {
+		timeline = NewTimeline()
+	}
+
+	var progress *Progress
+	if *progressInterval > 0 {
+		progress = NewProgress(config, os.Stderr, *progressInterval)
+	}
+
+	// observe writes the events of the dinner to the Sinks, without their details when it is quiet, and hands them
+	// all to the optional Store, SteadyState, SLOTracker and Progress
+	var observe = func(events *EventBus) {
+		if *quiet {
+			events.SetQuiet()
+		}
+		sinks.Attach(events)
+		events.Handle(recorder.Record)
+		if steady != nil {
+			events.Handle(steady.Handle)
+		}
+		if whole != nil {
+			events.Handle(whole.Handle)
+		}
+		if slos != nil {
+			events.Handle(slos.Handle)
+		}
+		if timeline != nil {
+			events.Handle(timeline.Record)
+		}
+		if progress != nil {
+			events.Handle(progress.Handle)
+			progress.Start()
+		}
+	}
+
+	var stopTrace = func() {}
+	if *tracePath != "" {
+		if stopTrace, err = startTrace(*tracePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var result Result
+	var info RunInfo
+	var violations []string
+	var started = time.Now()
+	if *replicas != "" {
+		var events = NewEventBus()
+		info = NewRunInfo(config)
+		events.SetRun(info.ID)
+		observe(events)
+		var peers = strings.Split(*replicas, ",")
+		server, err := NewReplica(peers, *replica, config, events, logger)
+		if err == nil {
+			fmt.Printf("Replica %d of the Host on %s, waiting for %d philosophers\n", *replica, peers[*replica], config.Philosophers)
+			result, err = server.Run()
+		}
+		if err != nil {
+			stopTrace()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else if *serveText != "" || *clientCommand != "" {
+		var events = NewEventBus()
+		info = NewRunInfo(config)
+		events.SetRun(info.ID)
+		observe(events)
+		if *serveText != "" {
+			fmt.Printf("Serving the table to the text protocol on %s, waiting for %d philosophers\n", *serveText, config.Philosophers)
+		}
+		result, err = ServeTextTable(*serveText, strings.Fields(*clientCommand), config, events)
+		if err != nil {
+			stopTrace()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else if *serveAddress != "" {
+		var events = NewEventBus()
+		info = NewRunInfo(config)
+		events.SetRun(info.ID)
+		observe(events)
+		fmt.Printf("Serving the table on %s, waiting for %d philosophers\n", *serveAddress, config.Philosophers)
+		result, err = ServeTable(*serveAddress, config, events)
+		if err != nil {
+			stopTrace()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else {
+		var simulation = NewSimulation(config)
+		if snapshot != nil {
+			if err := simulation.Restore(snapshot); err != nil {
+				stopTrace()
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		info = simulation.Info()
+		observe(simulation.Events())
+		var stopDump = dumpOnSignal(func() any { return simulation.State() })
+		defer stopDump()
+		if *snapshotPath != "" {
+			// the dinner is paused once enough meals are eaten, the snapshot is taken and the process leaves
+			var meals, reached = 0, make(chan struct{})
+			simulation.Events().Handle(func(event Event) {
+				if event.Kind == eventFinished {
+					if meals++; meals == *snapshotAfter {
+						simulation.Pause()
+						close(reached)
+					}
+				}
+			})
+			simulation.Start()
+			select {
+			case <-reached:
+				err := saveSnapshot(simulation, *snapshotPath)
+				progress.Stop()
+				stopTrace()
+				sinks.Close()
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				fmt.Printf("Snapshot of run %s saved in %s once %d meals were eaten, resume it with -restore %s\n", info.ID, *snapshotPath, *snapshotAfter, *snapshotPath)
+				return
+			case <-simulation.Done():
+				result = simulation.Wait()
+			}
+		} else {
+			result = simulation.Run()
+		}
+		violations = simulation.State().Violations
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "Phases : %s\n", violation)
+		}
+	}
+	for _, misuse := range result.Misuses() {
+		fmt.Fprintf(os.Stderr, "Ownership : %s\n", misuse)
+		violations = append(violations, misuse)
+	}
+	var finished = time.Now()
+	progress.Stop()
+	stopTrace()
+	if err := sinks.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	var run = Run{Info: info, Started: started, Finished: finished, Config: config, Result: result, Reports: recorder.Reports()}
+	if store != nil {
+		var saved = run
+		if *storeEvents {
+			saved.Events = recorder.Events()
+		}
+		if id, err := store.Save(saved); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Printf("Run %d saved in %s\n", id, *storePath)
+		}
+	}
+	for _, sink := range sinks.SQLite() {
+		if id, err := sink.Save(run); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Printf("Run %d saved in %s\n", id, sink.path)
+		}
+	}
+	if *export != "" {
+		var exportRun = ExportCSV
+		if *export == exportJSON {
+			exportRun = ExportJSON
+		} else if *export == exportProto {
+			exportRun = ExportProto
+		}
+		if err := exportRun(*exportDir, info, recorder.Events(), recorder.Reports()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if whole != nil {
+		if err := SaveRunResult(*resultPath, NewRunResult(info, config, result, whole.Summary(), recorder.Reports(), violations)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	var reports = recorder.Reports()
+	printResult(config, info, result, reports, recorder.Span())
+	if steady != nil {
+		var summary = steady.Summary()
+		fmt.Printf("Steady state : %s\n", summary)
+		fmt.Printf("Waits : %s\n", summary.Histogram())
+	}
+	if *hosts {
+		WriteHostLoads(os.Stdout, result, finished.Sub(started))
+	}
+	WriteTenantLoads(os.Stdout, result)
+	WriteSLOs(os.Stdout, slos.Statuses())
+	if *fairness {
+		WriteFairness(os.Stdout, NewFairness(reports))
+	}
+	if *contention > 0 {
+		WriteContention(os.Stdout, result, *contention)
+	}
+	if *gantt {
+		writeGantt(config, timeline)
+	}
+	if reportPath != "" {
+		if err := saveReport(reportPath, *reportFormat, NewReport(config, info, result, reports, recorder.Span(), timeline, violations)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Printf("Report saved in %s\n", reportPath)
+		}
+	}
+	if result.Failed() {
+		os.Exit(1)
+	}
+	if *check && len(violations) > 0 {
+		fmt.Printf("The dinner broke the protocol, %d violations\n", len(violations))
+		os.Exit(1)
+	}
+	if result.Ended != "" {
+		fmt.Println("The dinner ended before all the meals were eaten, good bye")
+		return
+	}
+	fmt.Println("All philosophers have finished eating, good bye")
+}
+
+// saveSnapshot takes a snapshot of the simulation and writes it in the file
+func saveSnapshot(simulation *Simulation, path string) error {
+	snapshot, err := simulation.Snapshot()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, snapshot, 0644)
+}
+
+// printResult prints what identifies the run, the summary of each table, the fairness of the Hosts, the retries of
+// the philosophers turned down, how close the dinner which lasted span came to its Optimum, and the starved
+// philosophers when the dinner failed, along with why it ended before all the meals were eaten. The fairness is left
+// out when no meal was eaten here, as when the philosophers are served remotely or by the replicas, and so are the
+// retries and the Optimum
+func printResult(config Config, info RunInfo, result Result, reports []PhilosopherReport, span time.Duration) {
+	fmt.Printf("Run : %s\n", info)
+	if result.Ended != "" {
+		fmt.Printf("Ended : %s, before all the meals were eaten\n", result.Ended)
+	}
+	for _, table := range result.Tables {
+		if config.Tables > 1 {
+			fmt.Printf("Table %d : %s\n", table.id, table.stats)
+		} else {
+			fmt.Printf("Host : %s\n", table.stats)
+		}
+		if table.reception != nil {
+			fmt.Printf("Reception : %s\n", table.reception)
+		}
+		if table.stats.deadlines != nil {
+			fmt.Printf("Deadlines : %s\n", table.stats.deadlines)
+		}
+		if table.utensilWaits != nil {
+			fmt.Printf("Utensil waits : %s\n", table.utensilWaits)
+		}
+		if config.ShedDepth > 0 {
+			fmt.Printf("Overload : %s\n", overloadSummary(table.HostLoads()))
+		}
+	}
+	if mealsOf(reports) > 0 {
+		fmt.Printf("Fairness : %s\n", NewFairness(reports))
+		fmt.Printf("Retries : %s\n", NewRetries(config.Backoff, reports))
+		if config.ArrivalRate == 0 {
+			fmt.Printf("Optimum : %s\n", NewEfficiency(config, reports, span))
+		}
+	}
+
+	if starved := result.Starved(); len(starved) > 0 {
+		fmt.Printf("The dinner failed, starved philosophers : %s\n", strings.Join(starved, ", "))
+	}
+	if failures := result.Failures(); len(failures) > 0 {
+		fmt.Printf("The dinner failed, failed philosophers : %s\n", failuresSummary(failures))
+		for _, failure := range failures {
+			fmt.Fprintf(os.Stderr, "%s\n%s\n", failure, failure.Stack)
+		}
+	}
+}
+
+// saveReport writes the report in the file, in the format
+func saveReport(path string, format string, report Report) error {
+	var file, err = os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteReport(file, format, report); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeGantt prints the Gantt chart of the meals of the Timeline, under the ones of the Plan of the dinner unless
+// its guests arrived when they wanted
+func writeGantt(config Config, timeline *Timeline) {
+	var plan *Plan
+	if config.ArrivalRate == 0 {
+		if planned, err := NewPlan(config); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Printf("Plan : %s\n", planned)
+			plan = &planned
+		}
+	}
+	WriteGantt(os.Stdout, plan, timeline.Meals())
+}
+
+// sinkFlags collects the descriptions of the Sinks given by each -sink flag
+type sinkFlags []string
+
+func (sinks *sinkFlags) String() string {
+	return strings.Join(*sinks, " ")
+}
+
+func (sinks *sinkFlags) Set(text string) error {
+	*sinks = append(*sinks, text)
+	return nil
+}
+
+// seatFlags collects the settings of the seats given by each -philosopher flag
+type seatFlags []string
+
+func (seats *seatFlags) String() string {
+	return strings.Join(*seats, " ")
+}
+
+func (seats *seatFlags) Set(text string) error {
+	*seats = append(*seats, text)
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const causeMiddleware = "middleware" // the cause of the vetoes of the middlewares which tell none
+
+const chaosMiddleware = "chaos" // the middleware turning down a share of the requests at random, see chaosRate
+
+// Verdict is the answer of a Decider to a request to eat : whether the philosopher may eat and, when he may not,
+// the cause counted in the Stats of the Host and the reason told to him
+type Verdict struct {
+	Admitted bool
+	Cause    string
+	Reason   string
+}
+
+// Decider decides about a request to eat once the rules of the table are met, the innermost one asking the Strategy
+type Decider func(request StrategyRequest) Verdict
+
+// Middleware wraps the Decider of the Host, so that logging, quotas, chaos or limits of its own can be added around
+// the Strategy without changing it : it may veto a request before or after asking next, or let next decide. The
+// middlewares are called from the goroutine of the Host only, and the first middleware of the chain is the outermost.
+type Middleware func(next Decider) Decider
+
+// MiddlewareFactory creates a Middleware of the Host of a table from a validated configuration
+type MiddlewareFactory func(config Config, table int) Middleware
+
+// middlewares are the registered middlewares, by name
+var middlewares = struct {
+	sync.Mutex
+	factories map[string]MiddlewareFactory
+}{factories: map[string]MiddlewareFactory{chaosMiddleware: NewChaosMiddleware}}
+
+// RegisterMiddleware makes a Middleware available under the given name, for the middlewares of the Config, it is meant
+// to be called from an init function and panics when the name is already taken, as RegisterStrategy does
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewares.Lock()
+	defer middlewares.Unlock()
+	if name == "" || factory == nil {
+		panic("RegisterMiddleware: the middleware needs a name and a factory")
+	}
+	if _, found := middlewares.factories[name]; found {
+		panic(fmt.Sprintf("RegisterMiddleware: middleware %q registered twice", name))
+	}
+	middlewares.factories[name] = factory
+}
+
+// Middlewares returns the names of the registered middlewares, sorted
+func Middlewares() []string {
+	middlewares.Lock()
+	defer middlewares.Unlock()
+	var names []string
+	for name := range middlewares.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validMiddleware checks that the middleware is registered
+func validMiddleware(name string) error {
+	middlewares.Lock()
+	var _, found = middlewares.factories[name]
+	middlewares.Unlock()
+	if !found {
+		return fmt.Errorf("config: unknown middleware %q, expected one of %v", name, Middlewares())
+	}
+	return nil
+}
+
+// NewDecider chains the middlewares of the configuration, then the extra ones, around the Strategy of the Host of a
+// table, the middlewares of the configuration being registered
+func NewDecider(config Config, table int, strategy Strategy, extra []Middleware) Decider {
+	var decider = Decider(func(request StrategyRequest) Verdict {
+		var admitted, why = strategy.Admit(request)
+		return Verdict{Admitted: admitted, Cause: causeStrategy, Reason: why}
+	})
+	var chain []Middleware
+	middlewares.Lock()
+	for _, name := range config.Middlewares {
+		chain = append(chain, middlewares.factories[name](config, table))
+	}
+	middlewares.Unlock()
+	chain = append(chain, extra...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		decider = chain[i](decider)
+	}
+	return func(request StrategyRequest) Verdict {
+		var verdict = decider(request)
+		if !verdict.Admitted && verdict.Cause == "" {
+			verdict.Cause = causeMiddleware
+		}
+		return verdict
+	}
+}
+
+// Use adds middlewares around the Strategy of every Host, after the middlewares of the configuration, it must be
+// called before Start
+func (simulation *Simulation) Use(middlewares ...Middleware) error {
+	simulation.mutex.Lock()
+	defer simulation.mutex.Unlock()
+	if simulation.started {
+		return errors.New("middleware: the dinner has already started")
+	}
+	for _, table := range simulation.tables {
+		table.middlewares = append(table.middlewares, middlewares...)
+	}
+	return nil
+}
+
+// NewChaosMiddleware creates the chaos middleware, which turns down the share chaosRate of the requests the rules of
+// the table let through, drawn from its own Random, so that the tolerance of the philosophers to arbitrary refusals
+// can be tested with the same seed again
+func NewChaosMiddleware(config Config, table int) Middleware {
+	var random = NewRandom(config.Seed, table, -6)
+	return func(next Decider) Decider {
+		return func(request StrategyRequest) Verdict {
+			if random.Float64() < config.ChaosRate {
+				return Verdict{Cause: chaosMiddleware, Reason: "Turned down by chaos"}
+			}
+			return next(request)
+		}
+	}
+}
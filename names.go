@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// philosopherNames are the names given to the seats by "names": "philosophers", in the order of the seats
+var philosopherNames = Names{
+	"Plato", "Aristotle", "Kant", "Confucius", "Socrates", "Descartes", "Spinoza", "Hume", "Nietzsche", "Leibniz",
+	"Locke", "Hegel", "Kierkegaard", "Heraclitus", "Epicurus", "Seneca", "Augustine", "Aquinas", "Avicenna", "Averroes",
+	"Maimonides", "Hobbes", "Rousseau", "Voltaire", "Montaigne", "Pascal", "Schopenhauer", "Wittgenstein", "Russell", "Arendt",
+	"Beauvoir", "Sartre", "Camus", "Laozi", "Mencius", "Zhuangzi", "Nagarjuna", "Hypatia", "Diogenes", "Thales",
+}
+
+// Names are the names of the seats of a table, in the order of the seats, the seats without a name being known by their number
+type Names []string
+
+// UnmarshalJSON accepts either a list of names or "philosophers" for the names of famous philosophers,
+// null leaves the seats without a name
+func (names *Names) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var description string
+	if err := json.Unmarshal(data, &description); err == nil {
+		if description != "philosophers" {
+			return fmt.Errorf("names: unknown names %q, expected a list of names or \"philosophers\"", description)
+		}
+		*names = philosopherNames
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*names = list
+	return nil
+}
+
+// Name returns the name of the given seat, its number when it has no name
+func (names Names) Name(seat int) string {
+	if seat < len(names) {
+		return names[seat]
+	}
+	return fmt.Sprint(seat)
+}
+
+// Validate checks that the seats of a table of the given number of philosophers have different names,
+// a name cannot be the number of a seat without a name
+func (names Names) Validate(philosophers int) error {
+	var seats = make(map[string]int)
+	for seat := 0; seat < min(len(names), philosophers); seat++ {
+		var name = names[seat]
+		if name == "" {
+			return fmt.Errorf("config: the name of seat %d is empty", seat)
+		}
+		if other, found := seats[name]; found {
+			return fmt.Errorf("config: seats %d and %d are both named %q", other, seat, name)
+		}
+		if number, err := strconv.Atoi(name); err == nil && number >= len(names) && number < philosophers && name == fmt.Sprint(number) {
+			return fmt.Errorf("config: seat %d is named %q, which is the number of seat %d", seat, name, number)
+		}
+		seats[name] = seat
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultNATSPort = "4222"                           // port of a NATS server when the address does not tell
+const defaultNATSSubject = "philosophers.{table}.{kind}" // subject of the events published to NATS
+const natsBufferSize = 4096                              // events waiting to be published before the publisher starts missing some, by default
+
+// Below are the encodings of the events published to NATS
+const natsJSON = "json"   // the JSON form of the Event, as the JSON lines of the events
+const natsProto = "proto" // the Event message of api/philosophers/v1, as the gRPC stream
+
+// NATSPublisher publishes the events of a dinner to a NATS server, each event is a JSON or protobuf message published
+// on a subject made from a template where {kind}, {table} and {philosopher} are replaced by the fields of the event,
+// so that a consumer subscribes to "philosophers.*.starved" to be told about the starved philosophers only
+// It speaks the text protocol of NATS directly (https://docs.nats.io/reference/reference-protocols/nats-protocol)
+// and is a Sink with a buffer by default, a slow server never slows the dinner down.
+type NATSPublisher struct {
+	mutex    sync.Mutex
+	conn     net.Conn
+	writer   *bufio.Writer
+	subject  string
+	encoding string
+	err      error
+}
+
+// DialNATS connects to the NATS server at the given address, such as nats://localhost:4222, the events being
+// published with the given encoding
+func DialNATS(address string, subject string, encoding string) (*NATSPublisher, error) {
+	if encoding != natsJSON && encoding != natsProto {
+		return nil, fmt.Errorf("nats: unknown encoding %q, expected %q or %q", encoding, natsJSON, natsProto)
+	}
+	address = strings.TrimPrefix(address, "nats://")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultNATSPort)
+	}
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %v", err)
+	}
+	var reader = bufio.NewReader(conn)
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("nats: %s is not a NATS server", address)
+	}
+
+	var publisher = &NATSPublisher{conn: conn, writer: bufio.NewWriter(conn), subject: subject, encoding: encoding}
+	fmt.Fprintf(publisher.writer, "CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"philosophers\"}\r\n")
+	if err := publisher.writer.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats: %v", err)
+	}
+	go publisher.listen(reader)
+	return publisher, nil
+}
+
+// listen answers the PING of the server and keeps the first error it reports
+func (publisher *NATSPublisher) listen(reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			publisher.mutex.Lock()
+			publisher.writer.WriteString("PONG\r\n")
+			publisher.writer.Flush()
+			publisher.mutex.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			publisher.mutex.Lock()
+			if publisher.err == nil {
+				publisher.err = fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+			}
+			publisher.mutex.Unlock()
+		}
+	}
+}
+
+// Write publishes a single event, the messages being sent once flushed
+func (publisher *NATSPublisher) Write(event Event) error {
+	var payload []byte = eventProto(event)
+	if publisher.encoding == natsJSON {
+		payload, _ = json.Marshal(event)
+	}
+	var subject = strings.NewReplacer(
+		"{kind}", string(event.Kind),
+		"{table}", strconv.Itoa(event.Table),
+		"{philosopher}", strconv.Itoa(event.Philosopher)).Replace(publisher.subject)
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	fmt.Fprintf(publisher.writer, "PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+	return nil
+}
+
+// Flush sends the messages published so far, it returns the first error met while publishing
+func (publisher *NATSPublisher) Flush() error {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	if err := publisher.writer.Flush(); err != nil && publisher.err == nil {
+		publisher.err = fmt.Errorf("nats: %v", err)
+	}
+	return publisher.err
+}
+
+// Close sends the messages published so far, then closes the connection to the server
+// It returns the first error met while publishing
+func (publisher *NATSPublisher) Close() error {
+	var err = publisher.Flush()
+	publisher.conn.Close()
+	return err
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const retransmitDelay = 200 * time.Millisecond // a dropped packet is sent again after this delay, as TCP does
+const packetSize = 4096                        // the simulated network carries the data by packets of at most this size
+
+// Network describes the network simulated between the Host and the philosophers joining from other processes :
+// - latency is how long the data takes to go from one end of a connection to the other, in each direction
+// - jitter adds a random delay between 0 and jitter to each packet, the packets of a connection still arrive in order
+// - dropRate is the probability that a packet is lost, it is then sent again after retransmitDelay
+// - partitions cut the connections between some philosophers and some replicas of the Host for a while
+type Network struct {
+	Latency    Duration    `json:"latency"`
+	Jitter     Duration    `json:"jitter"`
+	DropRate   float64     `json:"dropRate"`
+	Partitions []Partition `json:"partitions"`
+}
+
+// Partition cuts the connections between the given seats and the given replicas of the Host, from and until
+// are measured from the start of the Host. No seats means all the philosophers, and no replicas means all the replicas.
+// A philosopher who loses his connection tries to join again, and is refused until the end of the partition.
+type Partition struct {
+	Seats    []int    `json:"seats"`
+	Replicas []int    `json:"replicas"`
+	From     Duration `json:"from"`
+	Until    Duration `json:"until"`
+}
+
+// Validate checks the settings of the simulated network
+func (network *Network) Validate(philosophers int) error {
+	if network.Latency < 0 || network.Jitter < 0 {
+		return fmt.Errorf("config: the network latency and jitter cannot be negative, got %v and %v", time.Duration(network.Latency), time.Duration(network.Jitter))
+	}
+	if network.DropRate < 0 || network.DropRate >= 1 {
+		return fmt.Errorf("config: the network drop rate must be between 0 and 1 excluded, got %g", network.DropRate)
+	}
+	for _, partition := range network.Partitions {
+		if partition.From < 0 || partition.Until <= partition.From {
+			return fmt.Errorf("config: a network partition must end after it starts, got from %v until %v", time.Duration(partition.From), time.Duration(partition.Until))
+		}
+		for _, seat := range partition.Seats {
+			if seat < 0 || seat >= philosophers {
+				return fmt.Errorf("config: network partition of seat %d, there are %d philosophers", seat, philosophers)
+			}
+		}
+	}
+	return nil
+}
+
+// Scaled returns the settings of the network for a dinner of the given speed, nil for no simulated network
+func (network *Network) Scaled(speed float64) *Network {
+	if network == nil {
+		return nil
+	}
+	var scaled = *network
+	var scale = func(duration Duration) Duration { return Duration(float64(duration) / speed) }
+	scaled.Latency, scaled.Jitter = scale(network.Latency), scale(network.Jitter)
+	scaled.Partitions = make([]Partition, len(network.Partitions))
+	for i, partition := range network.Partitions {
+		partition.From, partition.Until = scale(partition.From), scale(partition.Until)
+		scaled.Partitions[i] = partition
+	}
+	return &scaled
+}
+
+// SimulatedNetwork applies the settings of the Network to the connections accepted by a replica of the Host
+type SimulatedNetwork struct {
+	settings *Network
+	replica  int
+	start    time.Time
+}
+
+// NewSimulatedNetwork starts simulating the network for the given replica of the Host, 0 when the Host is not replicated
+// It returns nil when there is no network to simulate, the connections are then left as they are
+func NewSimulatedNetwork(settings *Network, replica int) *SimulatedNetwork {
+	if settings == nil {
+		return nil
+	}
+	return &SimulatedNetwork{settings: settings, replica: replica, start: time.Now()}
+}
+
+// Link carries the data of a connection through the simulated network, seat is the philosopher at the other end
+// or -1 for another replica of the Host, who is never partitioned
+// It returns nil after closing the connection when the philosopher is partitioned from this replica
+func (network *SimulatedNetwork) Link(conn net.Conn, seat int) net.Conn {
+	if network == nil {
+		return conn
+	}
+	var now = time.Now()
+	if network.partitioned(seat, now) {
+		conn.Close()
+		return nil
+	}
+
+	local, remote := net.Pipe()
+	var link = &simulatedLink{network: network, ends: []net.Conn{conn, remote}}
+	go link.carry(conn, remote)
+	go link.carry(remote, conn)
+	if cut, ok := network.nextPartition(seat, now); ok {
+		time.AfterFunc(cut.Sub(now), link.cut)
+	}
+	return local
+}
+
+// partitioned tells if the philosopher at the given seat is cut from this replica at the given time
+func (network *SimulatedNetwork) partitioned(seat int, at time.Time) bool {
+	for _, partition := range network.settings.Partitions {
+		if partition.concerns(seat, network.replica) &&
+			!at.Before(network.start.Add(time.Duration(partition.From))) && at.Before(network.start.Add(time.Duration(partition.Until))) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPartition returns when the next partition cutting the philosopher at the given seat from this replica starts
+func (network *SimulatedNetwork) nextPartition(seat int, after time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, partition := range network.settings.Partitions {
+		var from = network.start.Add(time.Duration(partition.From))
+		if partition.concerns(seat, network.replica) && from.After(after) && (next.IsZero() || from.Before(next)) {
+			next = from
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// concerns tells if the partition cuts the philosopher at the given seat from the given replica
+func (partition Partition) concerns(seat int, replica int) bool {
+	return seat >= 0 && (len(partition.Seats) == 0 || contains(partition.Seats, seat)) &&
+		(len(partition.Replicas) == 0 || contains(partition.Replicas, replica))
+}
+
+// contains tells if the value is in the list
+func contains(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long a packet sent now takes to arrive
+func (network *SimulatedNetwork) delay() time.Duration {
+	var delay = time.Duration(network.settings.Latency)
+	if network.settings.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(network.settings.Jitter)))
+	}
+	for rand.Float64() < network.settings.DropRate {
+		delay += retransmitDelay
+	}
+	return delay
+}
+
+// simulatedLink carries the data between the real connection and the end of the pipe used by the Host
+type simulatedLink struct {
+	network *SimulatedNetwork
+	ends    []net.Conn
+	once    sync.Once
+}
+
+// packet is some data carried by a simulated link, delivered at the given time
+type packet struct {
+	data []byte
+	at   time.Time
+}
+
+// carry reads the data from one end and delivers it to the other end once delayed by the network
+func (link *simulatedLink) carry(from net.Conn, to net.Conn) {
+	var packets = make(chan packet, 64)
+	go func() {
+		var broken = false
+		for packet := range packets {
+			time.Sleep(time.Until(packet.at))
+			if _, err := to.Write(packet.data); err != nil && !broken {
+				broken = true
+				link.cut()
+			}
+		}
+		to.Close()
+	}()
+
+	var last time.Time
+	for {
+		var data = make([]byte, packetSize)
+		n, err := from.Read(data)
+		if n > 0 {
+			var at = time.Now().Add(link.network.delay())
+			if at.Before(last) {
+				at = last
+			}
+			last = at
+			packets <- packet{data: data[:n], at: at}
+		}
+		if err != nil {
+			close(packets)
+			return
+		}
+	}
+}
+
+// cut closes both ends of the link
+func (link *simulatedLink) cut() {
+	link.once.Do(func() {
+		for _, end := range link.ends {
+			end.Close()
+		}
+	})
+}
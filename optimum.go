@@ -0,0 +1,164 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+)
+
+// Below are the mean durations drawn by thinkingTime and mealTime when the settings of a seat do not tell them
+const meanThinkingTime = 149500 * time.Microsecond
+const meanMealTime = 299500 * time.Microsecond
+
+// Optimum is the best any Host could do with the dinner of a configuration, whatever its strategy : its parallelism
+// is how many philosophers can eat at the same time, all the tables included, the largest set of philosophers of a
+// table sharing no chopstick, ⌊N/2⌋ around a ring, or the smaller pool of forks and spoons, bounded by the napkins and
+// the central dish of each table and by the rice pot.
+// The guests of the open mode arrive when they want, so there is no Optimum for them.
+type Optimum struct {
+	Parallelism int
+	table       int // the parallelism of each table, before the rice pot bounds them all
+	pot         int
+}
+
+// NewOptimum computes the Optimum of the dinner of a validated configuration
+func NewOptimum(config Config) Optimum {
+	var parallelism = largestIndependentSet(config.Topology)
+	if config.Utensils == forksAndSpoonsVariant {
+		parallelism = min(config.Forks, config.Spoons, config.Philosophers)
+	}
+	if config.Napkins > 0 {
+		parallelism = min(parallelism, config.Napkins)
+	}
+	if config.DishCapacity > 0 {
+		parallelism = min(parallelism, config.DishCapacity)
+	}
+	parallelism = max(parallelism, 1)
+	var optimum = Optimum{Parallelism: parallelism * config.Tables, table: parallelism}
+	if config.PotCapacity > 0 && config.PotCapacity < optimum.Parallelism {
+		optimum.Parallelism, optimum.pot = config.PotCapacity, config.PotCapacity
+	}
+	return optimum
+}
+
+// Ideal is the makespan of the meals of the reports when the philosophers eat as many at once as the Optimum allows
+// and none of them is ever kept waiting : the meals of a table spread over its parallelism, those of all the tables
+// over the rice pot, and no less than the meals of the busiest philosopher one after the other. It only counts the
+// meals actually served, with the durations drawn for them, so that a dinner cannot beat it.
+func (optimum Optimum) Ideal(reports []PhilosopherReport) time.Duration {
+	var work = make(map[int]time.Duration)
+	var all, ideal time.Duration
+	for _, report := range reports {
+		work[report.Table] += report.Eating
+		all += report.Eating
+		ideal = max(ideal, report.Eating)
+	}
+	for _, eating := range work {
+		ideal = max(ideal, eating/time.Duration(optimum.table))
+	}
+	if optimum.pot > 0 {
+		ideal = max(ideal, all/time.Duration(optimum.pot))
+	}
+	return ideal
+}
+
+// largestIndependentSet returns the size of a set of philosophers of which no two share a chopstick, picking the
+// philosopher with the fewest neighbors left first, the lowest seat among them, which finds the largest set on a
+// ring, a grid or a torus. The philosophers wait in a Candidates heap, a philosopher whose neighbors left change being
+// pushed again and his stale entry skipped, so that the set of a million philosophers is found at once.
+func largestIndependentSet(topology Topology) int {
+	var degrees = make([]int, len(topology))
+	var candidates = make(Candidates, len(topology))
+	for philosopher, neighbors := range topology {
+		degrees[philosopher] = len(neighbors)
+		candidates[philosopher] = Candidate{degree: len(neighbors), philosopher: philosopher}
+	}
+	heap.Init(&candidates)
+	var removed = make([]bool, len(topology))
+	var size = 0
+	for candidates.Len() > 0 {
+		var picked = heap.Pop(&candidates).(Candidate)
+		if removed[picked.philosopher] || degrees[picked.philosopher] != picked.degree {
+			continue
+		}
+		size++
+		removed[picked.philosopher] = true
+		for _, neighbor := range topology[picked.philosopher] {
+			if removed[neighbor] {
+				continue
+			}
+			removed[neighbor] = true
+			for _, next := range topology[neighbor] {
+				if !removed[next] {
+					degrees[next]--
+					heap.Push(&candidates, Candidate{degree: degrees[next], philosopher: next})
+				}
+			}
+		}
+	}
+	return size
+}
+
+// Candidate is a philosopher who may join the independent set, with the number of his neighbors left at the time
+type Candidate struct {
+	degree      int
+	philosopher int
+}
+
+// Candidates is the min-heap of the candidates, the one with the fewest neighbors left first, then the lowest seat
+type Candidates []Candidate
+
+func (candidates Candidates) Len() int { return len(candidates) }
+func (candidates Candidates) Less(i, j int) bool {
+	if candidates[i].degree != candidates[j].degree {
+		return candidates[i].degree < candidates[j].degree
+	}
+	return candidates[i].philosopher < candidates[j].philosopher
+}
+func (candidates Candidates) Swap(i, j int) {
+	candidates[i], candidates[j] = candidates[j], candidates[i]
+}
+func (candidates *Candidates) Push(x any) { *candidates = append(*candidates, x.(Candidate)) }
+func (candidates *Candidates) Pop() any {
+	var old = *candidates
+	var candidate = old[len(old)-1]
+	*candidates = old[:len(old)-1]
+	return candidate
+}
+
+// Efficiency is how close a dinner came to its Optimum :
+// - how long it lasted, from its first event to its last one, and how many philosophers ate at the same time on
+// average, from the time they spent eating
+// - its efficiency, the Ideal makespan of the meals eaten over the one of the dinner, in percent
+type Efficiency struct {
+	Optimum     Optimum
+	Ideal       time.Duration
+	Makespan    time.Duration
+	Parallelism float64
+	Percent     float64
+}
+
+// NewEfficiency compares the dinner of the reports, which lasted the given makespan, with the Optimum of its
+// configuration
+func NewEfficiency(config Config, reports []PhilosopherReport, makespan time.Duration) Efficiency {
+	var efficiency = Efficiency{Optimum: NewOptimum(config), Makespan: makespan}
+	efficiency.Ideal = efficiency.Optimum.Ideal(reports)
+	if makespan <= 0 {
+		return efficiency
+	}
+	var eating time.Duration
+	for _, report := range reports {
+		eating += report.Eating
+	}
+	efficiency.Parallelism = float64(eating) / float64(makespan)
+	efficiency.Percent = 100 * float64(efficiency.Ideal) / float64(makespan)
+	return efficiency
+}
+
+// String gives a one line summary of the Efficiency
+func (efficiency Efficiency) String() string {
+	var optimum = efficiency.Optimum
+	return fmt.Sprintf("%.2f of at most %d philosophers eating at once (%.0f%%), makespan %v for an ideal %v (%.0f%% efficient)",
+		efficiency.Parallelism, optimum.Parallelism, 100*efficiency.Parallelism/float64(optimum.Parallelism),
+		efficiency.Makespan.Round(time.Millisecond), efficiency.Ideal.Round(time.Millisecond), efficiency.Percent)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const causeOverload = "overload" // the cause of the requests turned down at once by a Host overloaded with requests
+
+// Below are the bounds of the retry-after hints of an overloaded Host
+const minRetryAfter = time.Millisecond
+const maxRetryAfter = time.Second
+
+// Overload sheds the requests to eat the Host of a shard cannot decide in time, rather than letting them pile up in
+// its mailbox, and the philosophers block behind it, when they come faster than it decides, such as on large tables
+// or with chatty retries. Once the mailbox holds shedDepth messages :
+// - a philosopher does not send his request to eat, which the Host would only receive once the mailbox is drained
+// - the Host turns down at once the requests to eat it receives, without looking at the rules of the table
+// Both tell the philosopher when to retry instead of his backoff : the time the Host needs to decide the messages
+// waiting, from the mean time it took to process a message so far, between minRetryAfter and maxRetryAfter. The
+// releases of the utensils, the starvations and the confirmations are never shed, the Host needing them to go on.
+// The requests shed are counted by the HostMetrics of the shard.
+// A nil Overload never sheds, the philosophers block when the mailbox is full.
+type Overload struct {
+	depth   int
+	metrics *HostMetrics
+}
+
+// NewOverload creates the Overload of the Host of a shard, nil unless the configuration sets shedDepth
+func NewOverload(config Config, metrics *HostMetrics) *Overload {
+	if config.ShedDepth == 0 {
+		return nil
+	}
+	return &Overload{depth: config.ShedDepth, metrics: metrics}
+}
+
+// Shed tells whether a philosopher should not send his request to eat to the mailbox, along with when to retry
+func (overload *Overload) Shed(mailbox Mailbox) (time.Duration, bool) {
+	if overload == nil || len(mailbox) < overload.depth {
+		return 0, false
+	}
+	var retryAfter = overload.retryAfter(len(mailbox))
+	overload.metrics.Shed(false, retryAfter)
+	return retryAfter, true
+}
+
+// ShedAtHost tells whether the Host should turn down the request to eat it received while depth messages were
+// waiting, along with when to retry
+func (overload *Overload) ShedAtHost(depth int) (time.Duration, bool) {
+	if overload == nil || depth < overload.depth {
+		return 0, false
+	}
+	var retryAfter = overload.retryAfter(depth)
+	overload.metrics.Shed(true, retryAfter)
+	return retryAfter, true
+}
+
+// retryAfter estimates how long the Host needs to process the depth messages waiting for it
+func (overload *Overload) retryAfter(depth int) time.Duration {
+	var requests = overload.metrics.requests.Load()
+	if requests == 0 {
+		return minRetryAfter
+	}
+	var processing = time.Duration(overload.metrics.busy.Load() / requests)
+	return min(max(processing*time.Duration(depth+1), minRetryAfter), maxRetryAfter)
+}
+
+// ShedRequestToEat sends a message back to the philosopher denying him to eat because the Host is overloaded,
+// telling him when to retry. The detail is only formatted when the events are not quiet
+func ShedRequestToEat(philosopher *Philosopher, retryAfter time.Duration, depth int) {
+	var detail string
+	if !philosopher.events.Quiet() {
+		detail = shedDetail(retryAfter, depth)
+	}
+	philosopher.emit(eventThrottled, detail)
+	philosopher.feedbackChannel <- Grant{retryAfter: retryAfter}
+}
+
+// shedDetail tells why a request to eat was shed, and when to retry
+func shedDetail(retryAfter time.Duration, depth int) string {
+	return fmt.Sprintf("the Host is overloaded with %d messages waiting, retry after %v", depth, retryAfter.Round(time.Microsecond))
+}
+
+// overloadSummary tells how many requests to eat the Hosts of a table shed, and the retry-after hints they gave
+func overloadSummary(loads []HostLoad) string {
+	var shed, shedByHost, hints int64
+	var retryAfter, longest time.Duration
+	for _, load := range loads {
+		shed += load.Shed
+		shedByHost += load.ShedByHost
+		retryAfter += load.RetryAfter
+		longest = max(longest, load.MaxRetryAfter)
+	}
+	hints = shed + shedByHost
+	return fmt.Sprintf("%d requests shed before reaching the Host, %d by the Host, retry after %v on average, at most %v",
+		shed, shedByHost, meanDuration(retryAfter, hints), longest.Round(time.Microsecond))
+}
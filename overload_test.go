@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSheddingDegradesGracefully checks with the dinner of examples/overload.json, whose philosophers retry at once,
+// that the load shedding degrades it gracefully : the Host was overloaded, since it shed requests, but shed at most a
+// quarter of the requests to eat, its mailbox never filled up so that no philosopher blocked behind it, and all the
+// meals were eaten in at most 4 times the ideal makespan. The dinner takes place in real time, the same seed does not
+// give the same dinner, so the bounds leave room for a slow machine.
+func TestSheddingDegradesGracefully(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		var dinner = runExample(t, "examples/overload.json", seed)
+		var config = dinner.config
+		var requests, shed, peak int64
+		for _, table := range dinner.result.Tables {
+			requests += int64(table.stats.accepted)
+			for cause, count := range table.stats.rejected {
+				// the requests shed by the Host are counted along with the ones shed before reaching it
+				if cause != causeOverload {
+					requests += int64(count)
+				}
+			}
+			for _, load := range table.HostLoads() {
+				shed += load.Shed + load.ShedByHost
+				peak = max(peak, load.DepthPeak)
+			}
+		}
+		requests += shed
+
+		if shed == 0 {
+			t.Errorf("seed %d : no request was shed, the Host was not overloaded", seed)
+		}
+		if 4*shed > requests {
+			t.Errorf("seed %d : %d of the %d requests to eat were shed, more than a quarter", seed, shed, requests)
+		}
+		if peak >= int64(config.RequestChannelSize) {
+			t.Errorf("seed %d : the mailbox of a Host filled up, %d of %d messages", seed, peak, config.RequestChannelSize)
+		}
+		if planned := config.TotalMeals() * config.Tables; dinner.meals() != planned {
+			t.Errorf("seed %d : %d of the %d meals were eaten", seed, dinner.meals(), planned)
+		}
+		if efficiency := NewEfficiency(config, dinner.reports, dinner.span); efficiency.Percent < 25 {
+			t.Errorf("seed %d : the dinner lasted %v, more than 4 times its ideal %v", seed, efficiency.Makespan.Round(time.Millisecond),
+				efficiency.Ideal.Round(time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Ownership is the registry of who holds each utensil of a table in the check mode, kept up to date by the
+// philosophers as they acquire and release their utensils, so that the misuses of the utensils are caught as they
+// happen instead of showing later as a deadlock or two neighbors eating together :
+// - acquiring a utensil held by another philosopher
+// - acquiring the utensils out of their locking order, which the Host gives them in
+// - releasing a utensil the philosopher does not hold
+// The misuses are kept as protocol violations, the philosopher still getting or releasing the utensils as he asked.
+// A nil Ownership means that the utensils are not checked.
+type Ownership struct {
+	mutex      sync.Mutex
+	table      int
+	holders    []string
+	violations []string
+}
+
+// NewOwnership creates the Ownership of the utensils of a table, it returns nil unless the configuration asks
+// for the check mode
+func NewOwnership(config Config, table int, utensils []*ChopStick) *Ownership {
+	if !config.Check {
+		return nil
+	}
+	return &Ownership{table: table, holders: make([]string, len(utensils))}
+}
+
+// Acquired records that the philosopher took the utensils, in the given order
+func (ownership *Ownership) Acquired(philosopher *Philosopher, chopSticks []*ChopStick) {
+	if ownership == nil {
+		return
+	}
+	ownership.mutex.Lock()
+	defer ownership.mutex.Unlock()
+	for i, chopStick := range chopSticks {
+		if i > 0 && chopStick.id <= chopSticks[i-1].id {
+			ownership.violate(philosopher, "acquires %s %d after %s %d, out of the locking order", chopStick.kind, chopStick.id,
+				chopSticks[i-1].kind, chopSticks[i-1].id)
+		}
+		if holder := ownership.holders[chopStick.id]; holder != "" {
+			ownership.violate(philosopher, "acquires %s %d held by %s", chopStick.kind, chopStick.id, holder)
+		}
+		ownership.holders[chopStick.id] = philosopher.name
+	}
+}
+
+// Released records that the philosopher put the utensils back on the table
+func (ownership *Ownership) Released(philosopher *Philosopher, chopSticks []*ChopStick) {
+	if ownership == nil {
+		return
+	}
+	ownership.mutex.Lock()
+	defer ownership.mutex.Unlock()
+	for _, chopStick := range chopSticks {
+		if holder := ownership.holders[chopStick.id]; holder != philosopher.name {
+			if holder == "" {
+				holder = "nobody"
+			}
+			ownership.violate(philosopher, "releases %s %d held by %s", chopStick.kind, chopStick.id, holder)
+			continue
+		}
+		ownership.holders[chopStick.id] = ""
+	}
+}
+
+// Violations returns the misuses seen so far
+func (ownership *Ownership) Violations() []string {
+	if ownership == nil {
+		return nil
+	}
+	ownership.mutex.Lock()
+	defer ownership.mutex.Unlock()
+	return append([]string(nil), ownership.violations...)
+}
+
+// violate records a misuse of the philosopher during his current meal, the mutex being held
+func (ownership *Ownership) violate(philosopher *Philosopher, format string, args ...any) {
+	ownership.violations = append(ownership.violations, fmt.Sprintf("table %d, %s, meal %d: %s", ownership.table,
+		philosopher.name, philosopher.countEating, fmt.Sprintf(format, args...)))
+}
+
+// takeUtensils makes the philosopher take the utensils granted for his meal, checked by the Ownership and measured
+// by the UtensilWaits
+func (philosopher *Philosopher) takeUtensils(chopSticks []*ChopStick) {
+	var waited, blocking = philosopher.allocator.Acquire(chopSticks)
+	philosopher.ownership.Acquired(philosopher, chopSticks)
+	philosopher.utensilWaits.Acquired(philosopher, waited, blocking)
+}
+
+// leaveUtensils makes the philosopher put the utensils of his meal back on the table, checked by the Ownership
+// before another philosopher can take them
+func (philosopher *Philosopher) leaveUtensils(chopSticks []*ChopStick) {
+	philosopher.ownership.Released(philosopher, chopSticks)
+	philosopher.allocator.Release(chopSticks)
+}
+
+// Misuses returns the misuses of the utensils caught on all the tables in the check mode
+func (result Result) Misuses() []string {
+	var misuses []string
+	for _, table := range result.Tables {
+		misuses = append(misuses, table.stats.misuses...)
+	}
+	return misuses
+}
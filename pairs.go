@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Below are the allowed values for the acquisition setting of the Config
+const sequentialAcquisition = "sequential" // the philosopher locks his utensils one after the other, the default
+const atomicAcquisition = "atomic"         // the PairAllocator gives him all his utensils together, or none
+
+// PairAllocator hands the utensils of a meal, the pair of chopsticks in the classic dinner, to a philosopher all at
+// once : he never holds one of them while he waits for another, which rules out hold-and-wait by construction instead
+// of relying on the Host to only grant free utensils. It keeps which utensils are taken behind a single mutex, a
+// philosopher finding one of his taken waits until they are all free and takes them together.
+// Since it only looks at the utensils, it can be shared by the Hosts, the engines and the strategies of a table alike :
+// TryAcquire takes the utensils when they are all free without waiting, and Free tells if they are.
+// The ChopSticks are still locked and unlocked, all of them being free, so that their contention is measured the same way.
+// A nil PairAllocator means that the utensils are locked one after the other, in locking order.
+type PairAllocator struct {
+	mutex   sync.Mutex
+	freed   *sync.Cond
+	taken   []bool
+	waits   atomic.Int64
+	granted atomic.Int64
+}
+
+// NewPairAllocator creates the PairAllocator of the utensils of a table, it returns nil unless the configuration
+// asks for the atomic acquisition
+func NewPairAllocator(config Config, utensils []*ChopStick) *PairAllocator {
+	if config.Acquisition != atomicAcquisition {
+		return nil
+	}
+	var allocator = &PairAllocator{taken: make([]bool, len(utensils))}
+	allocator.freed = sync.NewCond(&allocator.mutex)
+	return allocator
+}
+
+// Acquire takes all the utensils, sorted in locking order, waiting until they are all free, and returns how long it
+// waited along with the utensil it waited for : the one it waited the longest for when they are locked one after
+// the other, the first one taken when they are acquired together, nil when it did not wait
+func (allocator *PairAllocator) Acquire(chopSticks []*ChopStick) (time.Duration, *ChopStick) {
+	var waited = time.Duration(0)
+	var blocking *ChopStick
+	if allocator == nil {
+		var longest = time.Duration(0)
+		for _, chopStick := range chopSticks {
+			var wait = chopStick.lock()
+			if wait > longest {
+				blocking, longest = chopStick, wait
+			}
+			waited += wait
+		}
+		return waited, blocking
+	}
+	allocator.mutex.Lock()
+	if !allocator.free(chopSticks) {
+		allocator.waits.Add(1)
+		for _, chopStick := range chopSticks {
+			if allocator.taken[chopStick.id] {
+				blocking = chopStick
+				break
+			}
+		}
+		var start = blocking.clock.Now()
+		for !allocator.free(chopSticks) {
+			allocator.freed.Wait()
+		}
+		waited = blocking.clock.Now().Sub(start)
+	}
+	allocator.take(chopSticks)
+	allocator.mutex.Unlock()
+	return waited, blocking
+}
+
+// AcquireBefore takes all the utensils as Acquire does unless one of them is still held at the deadline, in which case
+// it takes none of them, giving back the ones already locked. It returns how long it waited, the utensil it waited
+// for, and whether it took them.
+func (allocator *PairAllocator) AcquireBefore(chopSticks []*ChopStick, deadline time.Time) (time.Duration, *ChopStick, bool) {
+	var waited = time.Duration(0)
+	var blocking *ChopStick
+	if allocator == nil {
+		var longest = time.Duration(0)
+		for i, chopStick := range chopSticks {
+			var wait, locked = chopStick.lockBefore(deadline)
+			if wait > longest || !locked {
+				blocking, longest = chopStick, wait
+			}
+			waited += wait
+			if !locked {
+				for j := i - 1; j >= 0; j-- {
+					chopSticks[j].Unlock()
+				}
+				return waited, blocking, false
+			}
+		}
+		return waited, blocking, true
+	}
+	if allocator.TryAcquire(chopSticks) {
+		return 0, nil, true
+	}
+	allocator.waits.Add(1)
+	var start = time.Now()
+	for {
+		allocator.mutex.Lock()
+		if blocking == nil {
+			for _, chopStick := range chopSticks {
+				if allocator.taken[chopStick.id] {
+					blocking = chopStick
+					break
+				}
+			}
+		}
+		if allocator.free(chopSticks) {
+			allocator.take(chopSticks)
+			allocator.mutex.Unlock()
+			return time.Since(start), blocking, true
+		}
+		allocator.mutex.Unlock()
+		if !time.Now().Before(deadline) {
+			return time.Since(start), blocking, false
+		}
+		time.Sleep(confirmPoll)
+	}
+}
+
+// TryAcquire takes all the utensils when they are all free, it returns false without taking any otherwise
+func (allocator *PairAllocator) TryAcquire(chopSticks []*ChopStick) bool {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+	if !allocator.free(chopSticks) {
+		return false
+	}
+	allocator.take(chopSticks)
+	return true
+}
+
+// Free tells if the utensils are all free
+func (allocator *PairAllocator) Free(chopSticks []*ChopStick) bool {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+	return allocator.free(chopSticks)
+}
+
+// Release gives back all the utensils, in the reverse of the locking order
+func (allocator *PairAllocator) Release(chopSticks []*ChopStick) {
+	if allocator == nil {
+		for i := len(chopSticks) - 1; i >= 0; i-- {
+			chopSticks[i].Unlock()
+		}
+		return
+	}
+	allocator.mutex.Lock()
+	for i := len(chopSticks) - 1; i >= 0; i-- {
+		chopSticks[i].Unlock()
+		allocator.taken[chopSticks[i].id] = false
+	}
+	allocator.mutex.Unlock()
+	allocator.freed.Broadcast()
+}
+
+// Waits returns how many acquisitions found a utensil taken and waited, along with the number of acquisitions
+func (allocator *PairAllocator) Waits() (int, int) {
+	if allocator == nil {
+		return 0, 0
+	}
+	return int(allocator.waits.Load()), int(allocator.granted.Load())
+}
+
+// free tells if the utensils are all free, the mutex being held
+func (allocator *PairAllocator) free(chopSticks []*ChopStick) bool {
+	for _, chopStick := range chopSticks {
+		if allocator.taken[chopStick.id] {
+			return false
+		}
+	}
+	return true
+}
+
+// take marks the utensils as taken and locks them, the mutex being held and the utensils free
+func (allocator *PairAllocator) take(chopSticks []*ChopStick) {
+	for _, chopStick := range chopSticks {
+		allocator.taken[chopStick.id] = true
+		chopStick.Lock()
+	}
+	allocator.granted.Add(1)
+}
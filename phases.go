@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Phase is what a philosopher is doing, as told by the events of the dinner
+type Phase string
+
+// Below are the phases of a philosopher
+const (
+	PhaseThinking Phase = "thinking" // he thinks before he gets hungry, he has not asked to eat yet
+	PhaseHungry   Phase = "hungry"   // he was turned down or throttled, or he paused his meal, and asks again after thinking
+	PhaseWaiting  Phase = "waiting"  // the Host allowed him to eat, he waits for his utensils
+	PhaseEating   Phase = "eating"   // he eats
+	PhaseDone     Phase = "done"     // he ate all his meals
+	PhaseStarved  Phase = "starved"  // he starved and gave up his remaining meals
+	PhaseFailed   Phase = "failed"   // his goroutine panicked, he gave back what he held and his remaining meals
+	PhaseLeft     Phase = "left"     // he left the table, a guest who is done or a philosopher sent away when the dinner is stopped
+)
+
+// Phases are all the phases of a philosopher
+var Phases = []Phase{PhaseThinking, PhaseHungry, PhaseWaiting, PhaseEating, PhaseDone, PhaseStarved, PhaseFailed, PhaseLeft}
+
+// ErrIllegalTransition is returned when an event cannot happen to a philosopher in his phase, which tells that
+// the Host or the engine running the philosophers broke the protocol
+var ErrIllegalTransition = errors.New("illegal transition")
+
+// phaseTransitions tells, for each phase, the phase a philosopher moves to on each kind of event
+// The kinds of events left out, such as the decisions of the Kitchen or the heartbeats, do not change his phase,
+// and a philosopher moving to PhaseThinking after his last meal is done instead (see PhaseMachine)
+var phaseTransitions = map[Phase]map[EventKind]Phase{
+	PhaseThinking: {
+		eventRejected:          PhaseHungry,
+		eventThrottled:         PhaseHungry,
+		eventAccepted:          PhaseWaiting,
+		eventStarted:           PhaseEating,
+		eventStarved:           PhaseStarved,
+		eventLeft:              PhaseLeft,
+		eventPhilosopherFailed: PhaseFailed},
+	PhaseHungry: {
+		eventRejected:          PhaseHungry,
+		eventThrottled:         PhaseHungry,
+		eventAccepted:          PhaseWaiting,
+		eventStarted:           PhaseEating,
+		eventStarved:           PhaseStarved,
+		eventLeft:              PhaseLeft,
+		eventPhilosopherFailed: PhaseFailed},
+	PhaseWaiting: {
+		eventStarted:           PhaseEating,
+		eventLeft:              PhaseLeft,
+		eventPhilosopherFailed: PhaseFailed},
+	PhaseEating: {
+		eventFinished:          PhaseThinking,
+		eventPaused:            PhaseHungry,
+		eventLeft:              PhaseLeft,
+		eventPhilosopherFailed: PhaseFailed},
+	PhaseDone: {
+		eventLeft:              PhaseLeft,
+		eventPhilosopherFailed: PhaseFailed},
+	PhaseStarved: {
+		eventLeft: PhaseLeft},
+	PhaseFailed: {
+		eventLeft: PhaseLeft},
+	PhaseLeft: {},
+}
+
+// Transition returns the phase a philosopher moves to when the event happens to him, his phase being unchanged
+// by the kinds of events which tell nothing about it, it returns ErrIllegalTransition when the event cannot happen
+// to him in his phase
+func Transition(from Phase, kind EventKind) (Phase, error) {
+	var transitions, known = phaseTransitions[from]
+	if !known {
+		return from, fmt.Errorf("%w: unknown phase %q", ErrIllegalTransition, from)
+	}
+	if !changesPhase(kind) {
+		return from, nil
+	}
+	var to, allowed = transitions[kind]
+	if !allowed {
+		return from, fmt.Errorf("%w: a %s philosopher cannot be %s", ErrIllegalTransition, from, kind)
+	}
+	return to, nil
+}
+
+// changesPhase tells if the kind of events is about the phase of the philosopher it names
+func changesPhase(kind EventKind) bool {
+	switch kind {
+	case eventRejected, eventThrottled, eventAccepted, eventStarted, eventFinished, eventPaused, eventStarved, eventLeft,
+		eventPhilosopherFailed:
+		return true
+	}
+	return false
+}
+
+// PhaseMachine follows the phase of a philosopher from the events happening to him, he has :
+// - his phase, PhaseThinking when he sits down
+// - how many meals he eats, 0 when they are not known so that he is never done, and how many he ate
+// A guest seated by the Reception tells how many meals he eats.
+type PhaseMachine struct {
+	Phase Phase
+	Meals int
+	Eaten int
+}
+
+// NewPhaseMachine creates the PhaseMachine of a philosopher sitting down to eat the given meals, 0 when they are
+// not known
+func NewPhaseMachine(meals int) *PhaseMachine {
+	return &PhaseMachine{Phase: PhaseThinking, Meals: meals}
+}
+
+// Apply moves the philosopher to his next phase according to the event, an illegal event leaves him in his phase
+// and returns ErrIllegalTransition
+func (machine *PhaseMachine) Apply(event Event) error {
+	if event.Kind == eventSeated {
+		machine.Meals = event.Meal
+		return nil
+	}
+	var to, err = Transition(machine.Phase, event.Kind)
+	if err != nil {
+		return fmt.Errorf("%s, meal %d: %w", event.Name, event.Meal, err)
+	}
+	if event.Kind == eventFinished {
+		machine.Eaten++
+		if machine.Meals > 0 && machine.Eaten >= machine.Meals {
+			to = PhaseDone
+		}
+	}
+	machine.Phase = to
+	return nil
+}
@@ -1,185 +1,899 @@
-package main
-
-import (
-	"fmt"
-	"math"
-	"math/rand"
-	"sync"
-	"time"
-)
-
-const maxPhilosophers = 5 // There are five philosophers around the table
-const maxChopSticks = 5   // There are five chopticks on the table
-const maxTimeToEat = 3    // philosophers can eat max 3 times
-
-// ChopStick represents a chopstick along with a meachnisme to lock it
-type ChopStick struct{ sync.Mutex }
-
-// Philosopher allows to handle the process of eating for a philosopher, he has :
-// - a unique identifier (from 0 to maxPhilosophers)
-// - a count of how many times he has been eating (he should not eat more than maxTimeToEat)
-// - access to 2 chopsticks,
-// - and a channel in which the Host sends a message to allow/deny the philosopher to eat
-type Philosopher struct {
-	id                            int
-	countEating                   int
-	leftChopStick, rightChopStick *ChopStick
-	feedbackChannel               chan bool
-}
-
-// Request is used by the philosophers to send messages to the Host :
-// - wantToEat when they would like to eat, this can be accepted or rejected by the Host
-// - finishedEating when a philosopher wants to signal that he has finished eating
-type Request struct {
-	command     string
-	philosopher Philosopher
-}
-
-// Below are the allowed command for the Request struct
-const wantToEat = "wantToEat"
-const finishedEating = "finishedEating"
-
-// eat function allows to start the process of eating for a philosopher
-// To eat a philosopher sends a request to the Host, who can accept or reject the request
-// - if the request to eat is accepted by the Host through the philosopher's feedback channel, the philosopher :
-//   * locks the chopstick he has access to
-//   * then eats during some time
-//   * unlocks the chopsticks
-//   * increments his count of eating
-//   * and sends a message to the Host that he has finished eating
-// This process loops until the philosopher reaches 3 times eating, at which point the process stops
-func (philosopher Philosopher) eat(requestChan chan Request, wg *sync.WaitGroup) {
-	philosopher.countEating = 0
-
-	for philosopher.countEating < 3 {
-		time.Sleep(time.Duration(rand.Intn(300)) * time.Millisecond)
-
-		requestChan <- Request{command: wantToEat, philosopher: philosopher}
-		isPhilosopherAllowedToEat := <-philosopher.feedbackChannel
-
-		if isPhilosopherAllowedToEat {
-			philosopher.leftChopStick.Lock()
-			philosopher.rightChopStick.Lock()
-			fmt.Printf("starting  eating %d (%d)\n", philosopher.id, philosopher.countEating)
-			time.Sleep(time.Duration((rand.Intn(500) + 50)) * time.Millisecond)
-			fmt.Printf("finishing eating %d (%d)\n", philosopher.id, philosopher.countEating)
-			philosopher.rightChopStick.Unlock()
-			philosopher.leftChopStick.Unlock()
-
-			philosopher.countEating++
-
-			wg.Done()
-
-			requestChan <- Request{command: finishedEating, philosopher: philosopher}
-		}
-	}
-
-	close(philosopher.feedbackChannel)
-}
-
-// Start of the program
-func main() {
-	// Creating the ChopSticks
-	var chopSticks = make([]*ChopStick, maxChopSticks)
-	for chopStick := 0; chopStick < maxChopSticks; chopStick++ {
-		chopSticks[chopStick] = new(ChopStick)
-	}
-
-	// Creating the Philosophers
-	var philosophers = make([]*Philosopher, maxPhilosophers)
-	for philosopher := 0; philosopher < maxPhilosophers; philosopher++ {
-		// philosopher 0 will have chopstick 0 and 1
-		// philosopher 1 will have chopstick 1 and 2
-		// philosopher 2 will have chopstick 2 and 3
-		// philosopher 3 will have chopstick 3 and 4
-		// philosopher 4 will have chopstick 4 and 0
-		var leftChopStickID = philosopher
-		var rightChopStickID = (philosopher + 1) % maxPhilosophers
-		philosophers[philosopher] = &Philosopher{
-			id:              philosopher,
-			countEating:     0,
-			leftChopStick:   chopSticks[leftChopStickID],
-			rightChopStick:  chopSticks[rightChopStickID],
-			feedbackChannel: make(chan bool)}
-	}
-
-	// A wait group to allow the main program to wait for all the philosophers to eat 3 times
-	var wg sync.WaitGroup
-	wg.Add(maxPhilosophers * maxTimeToEat)
-
-	// A channel in which the philosophers send their requests to the Host
-	var requestChan = make(chan Request)
-
-	// The host will ensure that a max of 2 philosophers eat at the same time
-	// and that this philosophers are not neighborhood otherwise we could
-	// end up with a deadlock
-	go Host(requestChan)
-
-	// Create and start the goroutines for the philosophers
-	for _, philosopher := range philosophers {
-		go philosopher.eat(requestChan, &wg)
-	}
-
-	// Wait for all the philosophers to eat 3 times
-	wg.Wait()
-
-	close(requestChan)
-
-	fmt.Println("All philosophers have finished eating, good bye")
-}
-
-// Host receives requests to eat from the philosophers, the host decide to accept or reject each request and ensures that :
-// - only 2 philosophers eat at the same time
-// - the 2 philosophers eating at the same time cannot be neighborhood
-// The Host also processes the messages sent by the philosophers when they have finished eating, this allows the Host
-//   to authorize only 2 philosophers to eat at the same time
-func Host(requestChan chan Request) {
-	var philosophersEating = make(map[int]Philosopher)
-
-	for {
-		request := <-requestChan
-
-		switch request.command {
-		case wantToEat:
-			if len(philosophersEating) == 0 {
-				philosophersEating[request.philosopher.id] = request.philosopher
-				AcceptRequestToEat(&request.philosopher)
-			} else if len(philosophersEating) == 1 {
-				var keys []int
-				for k := range philosophersEating {
-					keys = append(keys, k)
-				}
-				var philosopherCurrentlyEating = keys[0]
-				var philosopherAskingToEat = request.philosopher.id
-				// Neighborhoods ?
-				if philosopherCurrentlyEating == 0 && philosopherAskingToEat == maxPhilosophers {
-					RejectRequestToEat(&request.philosopher, "Neighborhood 0-")
-				} else if philosopherCurrentlyEating == maxPhilosophers && philosopherAskingToEat == 0 {
-					RejectRequestToEat(&request.philosopher, "Neiborhood -0")
-				} else if math.Abs(float64(philosopherAskingToEat-philosopherCurrentlyEating)) == 1.0 {
-					RejectRequestToEat(&request.philosopher, "Neighborhood")
-				} else if philosopherAskingToEat == philosopherCurrentlyEating {
-					RejectRequestToEat(&request.philosopher, "Philosopher alread eating")
-				} else {
-					AcceptRequestToEat(&request.philosopher)
-				}
-			} else {
-				RejectRequestToEat(&request.philosopher, "All allowed philoshopers are already eating")
-			}
-		case finishedEating:
-			delete(philosophersEating, request.philosopher.id)
-		}
-	}
-}
-
-// RejectRequestToEat sends a message back to the philosopher denying him to eat
-func RejectRequestToEat(philosopher *Philosopher, rejectReason string) {
-	fmt.Printf("Host rejects request to eat from %d, reason %s\n", philosopher.id, rejectReason)
-	philosopher.feedbackChannel <- false
-}
-
-// AcceptRequestToEat sends a message back to the philosopher allowing him to eat
-func AcceptRequestToEat(philosopher *Philosopher) {
-	fmt.Printf("Host accepts request to eat from %d\n", philosopher.id)
-	philosopher.feedbackChannel <- true
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Philosopher allows to handle the process of eating for a philosopher, he has :
+// - a unique identifier (from 0 to the number of philosophers) and the id of his table
+// - a priority, only used when the Host preempts eating philosophers
+// - a name used in the messages, which also tells his table when there are several tables
+// - a count of how many times he has been eating (he should not eat more than meals)
+// - how long he thinks and eats when the settings of his seat tell it, drawn at random otherwise, and the speed of
+// the dinner which scales these durations
+// - how long he tries to take the utensils of a two-phase grant before giving it back, 0 when the grants are final
+// - the utensils he needs to eat, which he shares with his neighbors
+// - his energy, nil unless the health model is enabled
+// - the Random drawing how long he thinks and eats
+// - the Admission letting him eat without asking the Host, nil unless the lock-free admission is enabled
+// - the EventBus telling what happens to him
+// - the Liveness he sends his heartbeats to, nil unless the heartbeats are enabled
+// - the RateLimiter pacing his requests, nil unless the philosophers pace themselves
+// - the PairAllocator giving him his utensils together, nil unless the acquisition is atomic
+// - the Ownership checking how he uses his utensils, nil unless in the check mode
+// - the UtensilWaits measuring how long he takes to lock his utensils, nil unless chopstickWait is set
+// - the Backoff telling how long he waits before asking again once turned down, thinking again when nil
+// - the Workload he calls instead of eating, nil unless the dinner is a load generator
+// - the Failures of his table recording his panic, if any, nil for the philosophers of a remote table
+// - the number of requests waiting for the Host when it last decided about him, and the ticket he drew for his current
+// meal with the ticket admission, only kept on the records of the Host
+// - and a channel in which the Host sends a message to allow/deny the philosopher to eat
+type Philosopher struct {
+	id              int
+	table           int
+	name            string
+	priority        int
+	countEating     int
+	meals           int
+	thinking        time.Duration
+	eating          time.Duration
+	speed           float64
+	confirmTimeout  time.Duration
+	needs           []Need
+	energy          *Energy
+	random          *Random
+	admission       *Admission
+	events          *EventBus
+	liveness        *Liveness
+	limiter         *RateLimiter
+	allocator       *PairAllocator
+	ownership       *Ownership
+	utensilWaits    *UtensilWaits
+	overload        *Overload
+	backoff         Backoff
+	workload        Workload
+	failures        *Failures
+	queue           int
+	ticket          uint64
+	feedbackChannel chan Grant
+}
+
+// Request is the envelope the messages to the Host (see HostMessage) are flattened into in its Mailbox, its command
+// telling which message it holds :
+// - wantToEat when they would like to eat, this can be accepted or rejected by the Host
+// - confirmedEating when a philosopher granted a meal with the two-phase grants took its utensils, abortedEating when
+// he gave them up
+// - finishedEating when a philosopher wants to signal that he has finished eating
+// - pausedEating when a philosopher asked to pause has released his utensils before finishing his meal
+// - starved when a philosopher ran out of energy
+// - sitDown when a guest takes a seat in the open mode, replacing the previous occupant known by the Host
+// - updateMaxEaters when the number of philosophers allowed to eat at the same time is changed during the dinner
+// - stopDinner when the dinner is stopped before all the meals are eaten
+// - resumeEating when a dinner restored from a Snapshot gives back the utensils of a philosopher who was eating
+// - queryState when the Host is asked for its HostState
+// A request only tells the seat of the philosopher, the Host knows everything else about him, along with his
+// current meal and when he got hungry for the last time, which is when the deadlines of his meal start
+// The requests of the philosophers also tell when they were sent, so that the Host knows how long they waited to reach it
+type Request struct {
+	command     string
+	philosopher int
+	meal        int
+	hungrySince time.Time
+	sent        time.Time
+	maxEaters   int
+	guest       *Philosopher
+	reply       chan HostState
+}
+
+// Below are the allowed command for the Request struct
+const wantToEat = "wantToEat"
+const confirmedEating = "confirmedEating"
+const abortedEating = "abortedEating"
+const finishedEating = "finishedEating"
+const pausedEating = "pausedEating"
+const starved = "starved"
+const sitDown = "sitDown"
+const updateMaxEaters = "updateMaxEaters"
+const stopDinner = "stopDinner"
+const resumeEating = "resumeEating"
+const queryState = "queryState"
+
+// Grant is the answer of the Host to a request to eat, when the philosopher is allowed to eat
+// it holds the utensils the Host picked for him, sorted in locking order
+// The Host also sends a Grant with preempt set to ask an eating philosopher to pause, and a Grant
+// with shutdown set tells the philosopher that the Host is gone (such as a lost connection to a remote Host)
+// or that the dinner is stopped, which revokes the meal he is eating
+// A rejection shed by an overloaded Host tells in retryAfter when to ask again (see Overload)
+type Grant struct {
+	allowed    bool
+	preempt    bool
+	shutdown   bool
+	retryAfter time.Duration
+	chopSticks []*ChopStick
+}
+
+// eat function allows to start the process of eating for a philosopher
+// To eat a philosopher sends a request to the Host, who can accept or reject the request
+// - if the request to eat is accepted by the Host through the philosopher's feedback channel, the philosopher :
+//   - locks the utensils picked by the Host, one after the other or all together with the atomic acquisition
+//   - with the two-phase grants, confirms to the Host that he took them, or gives the grant back when one of them is
+//     still held at the end of the confirm timeout and asks again later
+//   - then eats during some time
+//   - unlocks the utensils
+//   - increments his count of eating
+//   - and sends a message to the Host that he has finished eating
+//
+// This process loops until the philosopher reaches his number of meals, at which point the process stops
+// With the lock-free admission, the philosopher first tries to claim his utensils by himself and only asks the Host
+// when one of them is taken, after a meal eaten without the Host he gives them back himself
+// While eating, the philosopher listens to his feedback channel : when the Host asks him to pause he unlocks
+// the utensils, tells the Host that he paused and asks to eat again later to finish the rest of his meal
+// When the Host is gone or the dinner is stopped, the philosopher leaves the table without eating his remaining meals :
+// a shutdown Grant received while he waits for an answer is not a rejection, and one received while he eats revokes
+// his meal, he releases his utensils at once and tells the Host before leaving
+// When the health model is enabled, a philosopher whose energy is exhausted before he could eat starves :
+// he tells the Host and leaves the table, giving up his remaining meals so that the dinner can end
+// When the philosophers pace themselves, the philosopher waits for a token of his RateLimiter before asking the Host
+// A panic of the philosopher, or of his Workload, fails him alone : he gives back what he holds and his remaining
+// meals, the rest of the dinner takes place without him (see recoverFailure)
+// Once turned down, the philosopher waits as long as his Backoff tells before asking again
+// When the heartbeats are enabled, the philosopher beats while he thinks, waits for the answer of the Host and eats,
+// and tells the Liveness once he is done, unless his goroutine is gone
+// In an execution trace, the philosopher is a task and each of his meals is a subtask, from the moment he gets hungry
+// until he finishes it, whose regions tell when he waits for the Host, acquires his utensils and eats
+func (philosopher Philosopher) eat(mailbox Mailbox, completion *Completion) {
+	var ctx, task = trace.NewTask(context.Background(), "philosopher")
+	defer task.End()
+	trace.Log(ctx, "name", philosopher.name)
+	// the task of the current meal, only created while tracing since a task is allocated even when it is not traced
+	var mealCtx, meal = ctx, (*trace.Task)(nil)
+	var endMeal = func() {
+		if meal != nil {
+			meal.End()
+			mealCtx, meal = ctx, nil
+		}
+	}
+	defer endMeal()
+
+	philosopher.countEating = 0
+	// a panic fails the philosopher alone, he gives back what he holds and the dinner goes on without him
+	var held holding
+	defer philosopher.recoverFailure(mailbox, completion, &held)
+	var hungrySince = time.Now()
+	var mealLeft = time.Duration(0)
+	var retry Retry
+	// a single timer for all the meals, so that eating allocates nothing
+	var mealOver = time.NewTimer(time.Hour)
+	mealOver.Stop()
+	var heartbeat, stopHeartbeat = philosopher.liveness.Heartbeat()
+	defer stopHeartbeat()
+	philosopher.liveness.Beat(philosopher.id)
+
+	for philosopher.countEating < philosopher.meals {
+		var region = trace.StartRegion(mealCtx, "thinking")
+		philosopher.think(philosopher.nextWait(philosopher.random, &retry), mealOver, heartbeat)
+		region.End()
+
+		if meal == nil && trace.IsEnabled() {
+			mealCtx, meal = trace.NewTask(ctx, "meal")
+			trace.Logf(mealCtx, "meal", "%s meal %d", philosopher.name, philosopher.countEating)
+		}
+		region = trace.StartRegion(mealCtx, "waiting")
+		grant, admitted := philosopher.admission.TryEat(&philosopher)
+		if !admitted {
+			if philosopher.limiter != nil {
+				philosopher.pace(mealOver, heartbeat)
+			}
+			if retryAfter, shed := philosopher.overload.Shed(mailbox); shed {
+				// the Host would only receive the request once its mailbox is drained, he asks again later
+				philosopher.shed(retryAfter, len(mailbox))
+				grant = Grant{retryAfter: retryAfter}
+			} else {
+				mailbox.Tell(philosopher.askToEat(hungrySince))
+				grant = philosopher.await(heartbeat)
+				for grant.preempt {
+					// a request to pause which arrived after the previous meal was over
+					grant = philosopher.await(heartbeat)
+				}
+			}
+		}
+		region.End()
+		if grant.shutdown {
+			// the Host is gone or the dinner is stopped, the remaining meals cannot be eaten
+			completion.GiveUp(philosopher.table, philosopher.id)
+			break
+		}
+
+		if starving, since := philosopher.energy.Starved(time.Now()); starving && !grant.allowed {
+			mailbox.Tell(philosopher.starve(since))
+			completion.GiveUp(philosopher.table, philosopher.id)
+			break
+		}
+
+		if !grant.allowed {
+			retry.Rejected()
+			retry.after = grant.retryAfter
+		} else {
+			held = holding{chopSticks: grant.chopSticks, admitted: admitted}
+			region = trace.StartRegion(mealCtx, "acquiring")
+			if philosopher.confirmTimeout == 0 {
+				philosopher.takeUtensils(grant.chopSticks)
+			} else if !philosopher.confirmGrant(mailbox, grant.chopSticks) {
+				// the utensils are still held by the previous eaters, he asks again later
+				held = holding{}
+				region.End()
+				retry.Rejected()
+				continue
+			}
+			held.locked = true
+			region.End()
+			retry.Reset()
+			if mealLeft == 0 {
+				mealLeft = philosopher.mealTime(philosopher.random)
+			}
+			var start = time.Now()
+			var workCtx, cancelWork = context.WithCancel(mealCtx)
+			var worked = philosopher.work(workCtx, grant.chopSticks)
+			if worked == nil {
+				mealOver.Reset(mealLeft)
+			}
+			region = trace.StartRegion(mealCtx, "eating")
+			philosopher.emit(eventStarted, "")
+			var paused, revoked = false, false
+		eating:
+			for {
+				select {
+				case <-mealOver.C:
+					philosopher.emit(eventFinished, "")
+					break eating
+				case err := <-worked:
+					if failure, panicked := err.(workloadPanic); panicked {
+						// the panic of the workload is the one of the philosopher calling it
+						cancelWork()
+						panic(failure)
+					}
+					if err != nil {
+						philosopher.emit(eventFinished, fmt.Sprintf("workload failed, %v", err))
+					} else {
+						philosopher.emit(eventFinished, "")
+					}
+					worked = nil
+					break eating
+				case interruption := <-philosopher.feedbackChannel:
+					mealOver.Stop()
+					select {
+					case <-mealOver.C:
+					default:
+					}
+					if worked != nil {
+						// the utensils are only given back once the workload has returned
+						cancelWork()
+						if failure, panicked := (<-worked).(workloadPanic); panicked {
+							panic(failure)
+						}
+						worked = nil
+					}
+					paused, revoked = true, interruption.shutdown
+					mealLeft -= time.Since(start)
+					if revoked {
+						philosopher.emit(eventPaused, revokedMeal)
+					} else {
+						philosopher.emit(eventPaused, "")
+					}
+					break eating
+				case <-heartbeat:
+					philosopher.liveness.Beat(philosopher.id)
+				}
+			}
+			region.End()
+			cancelWork()
+			philosopher.energy.Eat(start, time.Now())
+			held.locked = false
+			philosopher.leaveUtensils(grant.chopSticks)
+
+			if paused {
+				held = holding{}
+				mailbox.Tell(philosopher.release(true))
+				if revoked {
+					completion.GiveUp(philosopher.table, philosopher.id)
+					break
+				}
+				continue
+			}
+
+			philosopher.countEating++
+			hungrySince = time.Now()
+			mealLeft = 0
+			endMeal()
+
+			// the Host is told before the Completion, the table is closed once all the meals are completed
+			held = holding{}
+			if admitted {
+				philosopher.admission.Release(&philosopher)
+			} else {
+				mailbox.Tell(philosopher.release(false))
+			}
+			completion.Complete(philosopher.table, philosopher.id)
+		}
+	}
+	philosopher.liveness.Leave(philosopher.id)
+}
+
+// thinkingTime draws from the Random how long the philosopher thinks before he gets hungry, unless the settings of
+// his seat tell it, at the speed of the dinner
+func (philosopher *Philosopher) thinkingTime(random *Random) time.Duration {
+	var thinking = time.Duration(random.Intn(300)) * time.Millisecond
+	if philosopher.thinking > 0 {
+		thinking = philosopher.thinking
+	}
+	return time.Duration(float64(thinking) / philosopher.speed)
+}
+
+// mealTime draws from the Random how long a meal of the philosopher lasts, unless the settings of his seat tell it,
+// at the speed of the dinner
+func (philosopher *Philosopher) mealTime(random *Random) time.Duration {
+	var meal = time.Duration(random.Intn(500)+50) * time.Millisecond
+	if philosopher.eating > 0 {
+		meal = philosopher.eating
+	}
+	return time.Duration(float64(meal) / philosopher.speed)
+}
+
+// think lets the philosopher think for the given duration with the timer, beating at each tick of heartbeat
+func (philosopher Philosopher) think(duration time.Duration, timer *time.Timer, heartbeat <-chan time.Time) {
+	if heartbeat == nil {
+		time.Sleep(duration)
+		return
+	}
+	timer.Reset(duration)
+	for {
+		select {
+		case <-timer.C:
+			return
+		case <-heartbeat:
+			philosopher.liveness.Beat(philosopher.id)
+		}
+	}
+}
+
+// pace waits, thinking, until the philosopher is allowed to ask the Host again, telling how long he waits
+func (philosopher Philosopher) pace(timer *time.Timer, heartbeat <-chan time.Time) {
+	philosopher.limiter.Pace(time.Now, func(delay time.Duration) {
+		var detail string
+		if !philosopher.events.Quiet() {
+			detail = fmt.Sprintf("waiting %v before asking", delay.Round(time.Millisecond))
+		}
+		philosopher.emit(eventThrottled, detail)
+		philosopher.think(delay, timer, heartbeat)
+	})
+}
+
+// shed tells that the philosopher did not send his request to eat to the overloaded Host, which the detail of the
+// event tells unless the events are quiet
+func (philosopher Philosopher) shed(retryAfter time.Duration, depth int) {
+	var detail string
+	if !philosopher.events.Quiet() {
+		detail = shedDetail(retryAfter, depth)
+	}
+	philosopher.emit(eventThrottled, detail)
+}
+
+// await waits for the next answer of the Host, beating at each tick of heartbeat
+func (philosopher Philosopher) await(heartbeat <-chan time.Time) Grant {
+	for {
+		select {
+		case grant := <-philosopher.feedbackChannel:
+			return grant
+		case <-heartbeat:
+			philosopher.liveness.Beat(philosopher.id)
+		}
+	}
+}
+
+// askToEat builds the request of the philosopher to eat his current meal
+func (philosopher Philosopher) askToEat(hungrySince time.Time) GrantRequest {
+	return GrantRequest{philosopher: philosopher.id, meal: philosopher.countEating, hungrySince: hungrySince, sent: time.Now()}
+}
+
+// release builds the message of the philosopher telling the Host that he left his utensils, before the end of his
+// current meal when paused
+func (philosopher Philosopher) release(paused bool) Release {
+	return Release{philosopher: philosopher.id, meal: philosopher.countEating, paused: paused, sent: time.Now()}
+}
+
+// starve builds the message of the philosopher telling the Host that he starved, hungry since the given time
+func (philosopher Philosopher) starve(hungrySince time.Time) Starve {
+	return Starve{philosopher: philosopher.id, meal: philosopher.countEating, hungrySince: hungrySince, sent: time.Now()}
+}
+
+// emit tells the EventBus that something happened to the philosopher during his current meal
+func (philosopher Philosopher) emit(kind EventKind, detail string) {
+	philosopher.events.Emit(Event{
+		Table:       philosopher.table,
+		Philosopher: philosopher.id,
+		Name:        philosopher.name,
+		Kind:        kind,
+		Meal:        philosopher.countEating,
+		Detail:      detail,
+		Queue:       philosopher.queue,
+		Ticket:      philosopher.ticket})
+}
+
+// Run lets the Host receive requests to eat from the philosophers of its shard until its Mailbox is closed, the host decide to accept or reject each request and ensures that :
+// - only maxEaters philosophers eat at the same time
+// - a utensil is never given to two philosophers at the same time, with chopsticks this means that the philosophers
+// eating at the same time are never neighbors in the topology
+// - when the table has a central dish, only the allowed number of philosophers serve themselves at the same time
+// - when there is a Kitchen, the philosopher also gets a serving from the shared rice pot
+// - when the seats belong to tenants, the philosophers of a tenant eating at the same time stay within its quota
+// The Host also processes the messages sent by the philosophers when they have finished eating, this allows the Host
+// to authorize only maxEaters philosophers to eat at the same time
+// The Host keeps its own record of each seat, and a decision only looks at the seats around the philosopher
+// (see Seating) and at the set of the seats eating, so that it does not depend on the size of the table
+// A very large table is split into shards, each of them having its own Host : the Hosts share the count of the philosophers
+// eating, and claim the chopsticks shared with the seats of other shards, the rest of their decisions being their own
+// When preemption is enabled, the Host asks the philosophers in the way of a starving philosopher of higher priority to pause
+// In the deadline mode, the Host gives way to the philosophers whose meal deadline is approaching
+// With the ticket admission, the Host serves the philosophers in the order of the tickets they drew when they got hungry
+// When the requests are rate limited, the Host throttles the requests to eat beyond the rate of each philosopher first
+// Once the rules of the table let a philosopher eat, the Strategy of the Host, wrapped by its Middlewares, has the last word
+// When a philosopher starves, the Host dumps the decisions it took since he got hungry
+// The Host measures the depth of its queue of requests, and reports the requests which waited too long to reach it
+// Its HostMetrics tell, while the dinner takes place, how long the requests to eat waited for a decision and how busy it is
+// The Host reads the time from the Clock of the table, so that it decides the same way when the dinner is simulated
+// Once the dinner is stopped, the Host revokes the meals being eaten and sends the philosophers away as they ask to eat,
+// the worker pool, which cannot be interrupted while waiting for an answer, and the meals eaten without the Host
+// being left to end
+// When a dinner is restored from a Snapshot, the Host gives back their utensils to the philosophers who were eating
+// without deciding, their meal having been accepted before the snapshot
+// With the two-phase grants, a grant only reserves the utensils : the Host waits for the philosopher to confirm that he
+// took them, and frees them again when he gives the grant back instead (see Reservations)
+// The Host refuses the requests breaking the protocol, such as the end of a meal it did not grant, with an error event
+// The Host answers QueryState with its HostState, after the messages received before it
+// Once the table is closed, the Host leaves its Stats in the shard
+// In an execution trace, the Host is a task with a region for each request it processes, named after its command
+func (host *HostActor) Run() {
+	var table, shard = host.table, host.shard
+	var ctx, task = trace.NewTask(context.Background(), "host")
+	defer task.End()
+	trace.Logf(ctx, "seats", "table %d, seats %d to %d", table.id, shard.first, shard.last-1)
+
+	var seats = make([]*Philosopher, len(table.philosophers))
+	for seat := shard.first; seat < shard.last; seat++ {
+		var record = *table.philosophers[seat]
+		seats[seat] = &record
+	}
+	var seating = table.seating
+	var eating = NewSeatSet(len(seats))
+	var servings = make([]*Serving, len(seats))
+	var pool = make([]Serving, len(seats)) // the Serving of each seat, reused from one meal to the next
+	var holders = make(map[*ChopStick]int)
+	var available = make(map[UtensilKind]int)
+	for _, utensil := range table.utensils {
+		available[utensil.kind]++
+	}
+	var stats = newStats()
+	stats.queueCapacity = cap(shard.requestChan)
+	var history History
+	var deadlines = NewDeadlines(table.config)
+	var preemption = NewPreemption(table.config)
+	var inversions = NewInversions(table.config)
+	var reservations = NewReservations(table.config)
+	var strategy = NewStrategy(table.config, table.id)
+	var decide = NewDecider(table.config, table.id, strategy, table.middlewares)
+	var tickets = NewTickets(table.config)
+	var limiter = NewRateLimiter(table.config, len(seats))
+	var stopping = false
+	var depth = 0 // the requests waiting for the Host when it received the current one
+	// explain tells why the Host decided, the utensils granted to an accepted philosopher or those a philosopher turned
+	// down needs being only looked up when the decisions are explained, so that the Host does not allocate otherwise
+	var explain = func(philosopher *Philosopher, cause string, reason Reason, chopSticks []*ChopStick) {
+		if !table.config.Explain || philosopher.events.Quiet() {
+			return
+		}
+		var holdings = explainGrant(chopSticks)
+		if cause != "" {
+			holdings = explainNeeds(philosopher.needs, holders, table.claims, seats)
+		}
+		var explanation = Explanation{Philosopher: philosopher.name, Meal: philosopher.countEating, Accepted: cause == "", Cause: cause,
+			Reason: reason, Holdings: holdings, Eaters: int(table.eaters.Load()), MaxEaters: int(table.maxEaters.Load())}
+		philosopher.emit(eventExplained, explanation.Narrate())
+	}
+	var reject = func(request Request, cause string, rejectReason Reason) {
+		var philosopher = seats[request.philosopher]
+		for _, victim := range preemption.Victims(philosopher, request.hungrySince, table.clock.Now(), cause, eating, servings) {
+			preemption.Preempted(victim)
+			victim.philosopher.queue = depth
+			PreemptPhilosopher(victim.philosopher, philosopher.name)
+		}
+		stats.rejected[cause]++
+		history.Record(Decision{at: table.clock.Now(), philosopher: philosopher.name, reason: rejectReason})
+		explain(philosopher, cause, rejectReason, nil)
+		RejectRequestToEat(philosopher, rejectReason)
+	}
+	var shed = func(request Request, retryAfter time.Duration) {
+		var philosopher = seats[request.philosopher]
+		stats.rejected[causeOverload]++
+		history.Record(Decision{at: table.clock.Now(), philosopher: philosopher.name, reason: Reason{format: "Host overloaded"}})
+		ShedRequestToEat(philosopher, retryAfter, depth)
+	}
+	var throttle = func(request Request, rejectReason Reason) {
+		var philosopher = seats[request.philosopher]
+		stats.rejected[causeRateLimit]++
+		history.Record(Decision{at: table.clock.Now(), philosopher: philosopher.name, reason: rejectReason})
+		explain(philosopher, causeRateLimit, rejectReason, nil)
+		ThrottleRequestToEat(philosopher, rejectReason)
+	}
+	var pick = func(seat int) ([]*ChopStick, Reason, bool) {
+		if seating.utensils[seat] == nil {
+			return pickUtensils(seats[seat].needs, holders, available)
+		}
+		for _, neighbor := range seating.neighbors[seat] {
+			if eating.Has(neighbor) {
+				return nil, Reason{format: neighborHolds, neighbor: neighbor, utensil: chopStickKind,
+					held: sharedChopStick(seating.utensils[seat], seating.utensils[neighbor])}, false
+			}
+		}
+		for i, crossing := range seating.crossings[seat] {
+			if !table.claims[crossing.chopStick.id].CompareAndSwap(false, true) {
+				unclaim(seating.crossings[seat][:i], table.claims)
+				return nil, Reason{format: neighborHolds, neighbor: crossing.neighbor, utensil: chopStickKind, held: crossing.chopStick}, false
+			}
+		}
+		return seating.utensils[seat], Reason{}, true
+	}
+	var serve = func(seat int, chopSticks []*ChopStick, hungrySince time.Time) {
+		eating.Add(seat)
+		pool[seat] = Serving{philosopher: seats[seat], chopSticks: chopSticks, priority: preemption.Served(seats[seat], hungrySince, table.clock.Now())}
+		servings[seat] = &pool[seat]
+		for _, chopStick := range chopSticks {
+			holders[chopStick] = seat
+			available[chopStick.kind]--
+		}
+		table.dish.Acquire()
+		table.tenants.Served(seat, table.clock.Now().Sub(hungrySince))
+		if detail := inversions.Served(seats[seat], table.clock.Now(), table.eaters.Load() == table.maxEaters.Load()); detail != "" {
+			seats[seat].emit(eventInversion, detail)
+		}
+		strategy.Served(seat, table.clock.Now())
+	}
+	var release = func(philosopher int) {
+		unclaim(seating.crossings[philosopher], table.claims)
+		table.eaters.Add(-1)
+		for _, chopStick := range servings[philosopher].chopSticks {
+			delete(holders, chopStick)
+			available[chopStick.kind]++
+		}
+		eating.Remove(philosopher)
+		reservations.Released(philosopher)
+		table.tenants.Released(philosopher)
+		strategy.Released(philosopher, table.clock.Now())
+		servings[philosopher] = nil
+		preemption.Released(philosopher)
+		table.dish.Release()
+		if table.kitchen != nil {
+			table.kitchen.ReleaseRice(table.id)
+		}
+	}
+
+	var backpressure = time.Duration(table.config.BackpressureThreshold)
+	var measure = func(request Request) {
+		depth = len(shard.requestChan)
+		shard.metrics.Received(depth)
+		stats.requests++
+		stats.queueDepth += depth
+		stats.queuePeak = max(stats.queuePeak, depth)
+		if backpressure == 0 || request.sent.IsZero() {
+			return
+		}
+		if waited := table.clock.Now().Sub(request.sent); waited >= backpressure {
+			var philosopher = seats[request.philosopher]
+			philosopher.countEating = request.meal
+			philosopher.queue = depth
+			stats.backpressure++
+			var detail string
+			if !philosopher.events.Quiet() {
+				detail = waited.String()
+			}
+			philosopher.emit(eventBackpressure, detail)
+		}
+	}
+
+	var snapshot = func() HostState {
+		var state = HostState{Table: table.id, First: shard.first, Last: shard.last, Eating: append([]int(nil), eating.Members()...),
+			Reserved: reservations.Seats(), Eaters: int(table.eaters.Load()), MaxEaters: int(table.maxEaters.Load()), Mailbox: depth, Stopping: stopping,
+			Requests: stats.requests, Accepted: stats.accepted}
+		state.Causes = make(map[string]int, len(stats.rejected))
+		for cause, count := range stats.rejected {
+			state.Rejected += count
+			state.Causes[cause] = count
+		}
+		for chopStick, seat := range holders {
+			state.Holders = append(state.Holders, UtensilHolder{Utensil: chopStick.id, Kind: chopStick.kind, Seat: seat, Name: seats[seat].name})
+		}
+		sort.Slice(state.Holders, func(i, j int) bool { return state.Holders[i].Utensil < state.Holders[j].Utensil })
+		return state
+	}
+	// the HostState is published between two messages, so that the State of the Simulation never sees a decision
+	// half taken, nor blocks on a Host held by a paused dinner
+	var publish = func() {
+		if table.observed.Load() {
+			var state = snapshot()
+			shard.published.Store(&state)
+		}
+	}
+	publish()
+
+	for request := range shard.requestChan {
+		var received = time.Now()
+		if err := checkRequest(shard, request, eating, reservations); err != nil {
+			table.emitError(shard, request, err)
+			continue
+		}
+		measure(request)
+		var region = trace.StartRegion(ctx, request.command)
+		switch request.command {
+		case wantToEat:
+			var philosopherAskingToEat = request.philosopher
+			var philosopher = seats[philosopherAskingToEat]
+			philosopher.countEating = request.meal
+			philosopher.queue = depth
+			if stopping {
+				DismissPhilosopher(philosopher)
+				region.End()
+				continue
+			}
+			deadlines.Waiting(philosopher, request.hungrySince)
+			philosopher.ticket = tickets.Draw(philosopher)
+			if retryAfter, overloaded := shard.overload.ShedAtHost(depth); overloaded {
+				shed(request, retryAfter)
+			} else if wait, ok := limiter.Take(philosopherAskingToEat, table.clock.Now()); !ok {
+				throttle(request, Reason{format: "Too many requests, next one allowed in %[6]v", wait: wait.Round(time.Millisecond)})
+			} else if eating.Has(philosopherAskingToEat) {
+				reject(request, causeAlreadyEating, Reason{format: "Philosopher already eating"})
+			} else if table.eaters.Load() >= table.maxEaters.Load() {
+				reject(request, causeMaxEaters, Reason{format: "All allowed philosophers are already eating"})
+			} else if urgent, ok := deadlines.GiveWay(philosopher, table.clock.Now(), table.eaters.Load()+1 == table.maxEaters.Load()); ok {
+				reject(request, causeDeadline, Reason{format: "Giving way to %[3]s whose deadline is near", urgent: urgent})
+			} else if first, ok := tickets.GiveWay(philosopher, table.eaters.Load()+1 == table.maxEaters.Load()); ok {
+				reject(request, causeTicket, Reason{format: "Giving way to %[3]s whose ticket %[5]d comes first", urgent: first.philosopher.name, ticket: first.number})
+			} else if chopSticks, reason, ok := pick(philosopherAskingToEat); !ok {
+				if reason.held != nil {
+					reason.held.Denied()
+				}
+				if holder, held := holders[reason.held]; held && inversions.Blocked(philosopher, seats[holder], reason.held, table.clock.Now()) {
+					// the holder inherits the priority of the philosopher he blocks, so that no one below it preempts him
+					servings[holder].priority = max(servings[holder].priority, float64(philosopher.priority))
+				}
+				reject(request, causeUtensils, reason)
+			} else if blocked, ok := inversions.GiveWay(philosopher, table.eaters.Load()+1 == table.maxEaters.Load()); ok {
+				unclaim(seating.crossings[philosopherAskingToEat], table.claims)
+				reject(request, causeInheritance, Reason{format: "Giving way to %[3]s, whose priority the holder of his utensil inherits", urgent: blocked})
+			} else if table.tenants.Full(philosopherAskingToEat) {
+				table.tenants.Rejected(philosopherAskingToEat)
+				reject(request, causeTenant, Reason{format: "Tenant %[4]s already eats its quota", strategy: table.tenants.Name(philosopherAskingToEat)})
+			} else if verdict := decide(StrategyRequest{Table: table.id, Seat: philosopherAskingToEat, Name: philosopher.name,
+				Priority: philosopher.priority, Meal: philosopher.countEating, HungrySince: request.hungrySince, Now: table.clock.Now(),
+				Eaters: int(table.eaters.Load()), MaxEaters: int(table.maxEaters.Load())}); !verdict.Admitted {
+				unclaim(seating.crossings[philosopherAskingToEat], table.claims)
+				reject(request, verdict.Cause, Reason{format: "%[4]s", strategy: verdict.Reason})
+			} else if table.dish.Full() {
+				reject(request, causeDish, Reason{format: "Central dish is full"})
+			} else if table.kitchen != nil && !table.kitchen.TakeRice(table.id) {
+				reject(request, causeRicePot, Reason{format: "Rice pot is empty"})
+			} else if !table.reserveEater() {
+				// the Host of another shard took the last place since it was checked, there is no dish nor kitchen with shards
+				unclaim(seating.crossings[philosopherAskingToEat], table.claims)
+				reject(request, causeMaxEaters, Reason{format: "All allowed philosophers are already eating"})
+			} else {
+				serve(philosopherAskingToEat, chopSticks, request.hungrySince)
+				deadlines.Served(philosopher, request.hungrySince, table.clock.Now())
+				tickets.Served(philosopher)
+				stats.accepted++
+				history.Record(Decision{at: table.clock.Now(), philosopher: philosopher.name, accepted: true})
+				explain(philosopher, "", Reason{}, chopSticks)
+				reservations.Reserved(philosopherAskingToEat, table.clock.Now())
+				AcceptRequestToEat(philosopher, chopSticks)
+			}
+			var sent = request.sent
+			if sent.IsZero() {
+				sent = received
+			}
+			shard.metrics.Decided(time.Since(sent))
+		case resumeEating:
+			// the philosophers eating at the time of the snapshot could all eat together, their utensils are free
+			var philosopher = seats[request.philosopher]
+			philosopher.countEating = request.meal
+			var chopSticks, _, _ = pick(request.philosopher)
+			table.eaters.Add(1)
+			if table.kitchen != nil {
+				table.kitchen.TakeRice(table.id)
+			}
+			serve(request.philosopher, chopSticks, request.hungrySince)
+			philosopher.feedbackChannel <- Grant{allowed: true, chopSticks: chopSticks}
+		case confirmedEating:
+			reservations.Confirmed(request.philosopher, false, table.clock.Now())
+		case abortedEating:
+			// the utensils reserved for him are still held by the previous eaters, they are free for the others again
+			reservations.Confirmed(request.philosopher, true, table.clock.Now())
+			release(request.philosopher)
+		case finishedEating:
+			release(request.philosopher)
+		case pausedEating:
+			release(request.philosopher)
+			stats.paused++
+		case sitDown:
+			var guest = *request.guest
+			seats[request.philosopher] = &guest
+			tickets.Left(request.philosopher)
+			limiter.Refill(request.philosopher)
+		case updateMaxEaters:
+			table.maxEaters.Store(int64(request.maxEaters))
+		case queryState:
+			request.reply <- snapshot()
+		case stopDinner:
+			stopping = true
+			if table.pool == nil {
+				for _, seat := range eating.Members() {
+					RevokeGrant(servings[seat].philosopher)
+				}
+			}
+		case starved:
+			var philosopher = seats[request.philosopher]
+			philosopher.countEating = request.meal
+			philosopher.queue = depth
+			deadlines.Left(philosopher)
+			tickets.Left(request.philosopher)
+			inversions.Left(philosopher)
+			stats.starved = append(stats.starved, philosopher.name)
+			philosopher.emit(eventStarved, fmt.Sprintf("after %.2fs of hunger, decisions of the Host since he got hungry :\n%s",
+				table.clock.Now().Sub(request.hungrySince).Seconds(), history.Dump(request.hungrySince)))
+		}
+		region.End()
+		publish()
+		shard.metrics.Busy(time.Since(received))
+	}
+
+	if table.dish != nil {
+		stats.dishPeak = table.dish.peak
+		stats.dishLimit = table.dish.capacity
+	}
+	stats.deadlines = deadlines
+	stats.inversions = inversions
+	stats.reservations = reservations
+	if preemption != nil {
+		stats.preempted = preemption.counts
+		stats.longestWaiter, stats.longestWait = preemption.LongestWait()
+	}
+	shard.stats = stats
+	close(shard.hostDone)
+}
+
+// unclaim gives back the chopsticks shared with other shards, once they are not held anymore
+func unclaim(crossings []Crossing, claims []atomic.Bool) {
+	for _, crossing := range crossings {
+		claims[crossing.chopStick.id].Store(false)
+	}
+}
+
+// revokedMeal is the detail of the paused event of a meal revoked because the dinner is stopped
+const revokedMeal = "the dinner is stopped"
+
+// Below are the formats of the reasons telling that a utensil is missing
+const neighborHolds = "Neighbor %[1]d holds the %[2]s"
+const noneLeft = "No %[2]s left on the table"
+
+// pickUtensils picks a free utensil for each need of a philosopher, sorted in locking order, none for a philosopher
+// who needs no utensil such as the only one of a ring
+// When a need cannot be fulfilled it returns false along with the reason, which is either that no utensil
+// of this kind is left on the table or that the ones within reach are held by neighbors
+func pickUtensils(needs []Need, holders map[*ChopStick]int, available map[UtensilKind]int) ([]*ChopStick, Reason, bool) {
+	var picked = make([]*ChopStick, 0, len(needs))
+	var pickedPerKind = make(map[UtensilKind]int)
+
+	for _, need := range needs {
+		if available[need.kind]-pickedPerKind[need.kind] <= 0 {
+			return nil, Reason{format: noneLeft, utensil: need.kind}, false
+		}
+		var chosen, heldByHolder *ChopStick
+		var holder = -1
+		for _, candidate := range need.candidates {
+			if owner, held := holders[candidate]; held {
+				holder, heldByHolder = owner, candidate
+			} else if !containsChopStick(picked, candidate) {
+				chosen = candidate
+				break
+			}
+		}
+		if chosen == nil {
+			return nil, Reason{format: neighborHolds, neighbor: holder, utensil: need.kind, held: heldByHolder}, false
+		}
+		picked = append(picked, chosen)
+		pickedPerKind[need.kind]++
+	}
+
+	sort.Slice(picked, func(i, j int) bool { return picked[i].id < picked[j].id })
+	return picked, Reason{}, true
+}
+
+// sharedChopStick returns the first of the utensils which is also one of the others, nil when there is none
+func sharedChopStick(utensils, others []*ChopStick) *ChopStick {
+	for _, utensil := range utensils {
+		if containsChopStick(others, utensil) {
+			return utensil
+		}
+	}
+	return nil
+}
+
+// containsChopStick tells if the utensil is part of the given ones
+func containsChopStick(chopSticks []*ChopStick, chopStick *ChopStick) bool {
+	for _, c := range chopSticks {
+		if c == chopStick {
+			return true
+		}
+	}
+	return false
+}
+
+// RejectRequestToEat sends a message back to the philosopher denying him to eat
+// The reason is only formatted when the events are not quiet
+func RejectRequestToEat(philosopher *Philosopher, rejectReason Reason) {
+	var detail string
+	if !philosopher.events.Quiet() {
+		detail = rejectReason.String()
+	}
+	philosopher.emit(eventRejected, detail)
+	philosopher.feedbackChannel <- Grant{allowed: false}
+}
+
+// ThrottleRequestToEat sends a message back to the philosopher denying him to eat because he asks too often
+// The reason is only formatted when the events are not quiet
+func ThrottleRequestToEat(philosopher *Philosopher, rejectReason Reason) {
+	var detail string
+	if !philosopher.events.Quiet() {
+		detail = rejectReason.String()
+	}
+	philosopher.emit(eventThrottled, detail)
+	philosopher.feedbackChannel <- Grant{allowed: false}
+}
+
+// DismissPhilosopher sends a message back to the philosopher telling him that the dinner is stopped
+func DismissPhilosopher(philosopher *Philosopher) {
+	philosopher.feedbackChannel <- Grant{shutdown: true}
+}
+
+// RevokeGrant tells an eating philosopher that the dinner is stopped, so that he leaves his meal and the table
+// The Host never blocks on it : a full feedback channel holds a request to pause, after which the philosopher asks
+// to eat again and is sent away
+func RevokeGrant(philosopher *Philosopher) {
+	select {
+	case philosopher.feedbackChannel <- Grant{shutdown: true}:
+	default:
+	}
+}
+
+// PreemptPhilosopher sends a message to an eating philosopher asking him to pause in favor of a starving philosopher
+// The feedback channels are buffered so that the Host never blocks on a philosopher who just finished eating
+func PreemptPhilosopher(philosopher *Philosopher, starving string) {
+	philosopher.emit(eventPreempted, starving)
+	philosopher.feedbackChannel <- Grant{preempt: true}
+}
+
+// AcceptRequestToEat sends a message back to the philosopher allowing him to eat with the given utensils
+func AcceptRequestToEat(philosopher *Philosopher, chopSticks []*ChopStick) {
+	var utensils []string
+	if len(chopSticks) > 0 && chopSticks[len(chopSticks)-1].kind != chopStickKind && !philosopher.events.Quiet() {
+		for _, utensil := range chopSticks {
+			utensils = append(utensils, fmt.Sprintf("%s %d", utensil.kind, utensil.id))
+		}
+	}
+	philosopher.emit(eventAccepted, strings.Join(utensils, " and "))
+	philosopher.feedbackChannel <- Grant{allowed: true, chopSticks: chopSticks}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSingleSeatRingEndsAllMeals checks that the only philosopher of a ring, who needs no chopstick, is served until
+// he ate all his meals instead of being turned down forever, with both engines
+func TestSingleSeatRingEndsAllMeals(t *testing.T) {
+	for _, engine := range []string{concurrentEngine, discreteEngine} {
+		config, err := ParseConfig([]byte(`{"topology": "ring:1", "meals": 3, "speed": 20, "engine": "`+engine+`"}`), Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var simulation = NewSimulation(config)
+		var recorder = NewRecorder(false)
+		simulation.Events().SetQuiet()
+		simulation.Events().Handle(recorder.Record)
+		var timer = time.AfterFunc(10*time.Second, simulation.Stop)
+		var result = simulation.Run()
+		if !timer.Stop() {
+			t.Fatalf("%s engine : the dinner was stopped after 10s, ended %q", engine, result.Ended)
+		}
+		var meals = 0
+		for _, report := range recorder.Reports() {
+			meals += report.Meals
+		}
+		if meals != 3 {
+			t.Errorf("%s engine : %d of the 3 meals were eaten", engine, meals)
+		}
+	}
+}
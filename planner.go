@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const planStrategy = "plan" // the strategy making the Host follow the schedule computed offline by the planner
+
+func init() {
+	RegisterStrategy(planStrategy, func(config Config, table int) Strategy {
+		var plan, _ = NewPlan(config)
+		return NewPlanStrategy(config, plan)
+	})
+}
+
+// ScheduledMeal is a meal of a schedule, planned or emergent : the table and the seat of the philosopher, the number
+// of his meal counted from 0, and when it starts and ends from the start of the dinner. A meal paused by a
+// preemption has a piece per time the philosopher eats it.
+type ScheduledMeal struct {
+	Table int           `json:"table"`
+	Seat  int           `json:"seat"`
+	Name  string        `json:"name"`
+	Meal  int           `json:"meal"`
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+}
+
+// Plan is a feasible schedule of the dinner of a table computed offline, before it takes place : who eats when.
+// The planner plays the Host with the mean thinking times and meal durations of the seats : a
+// philosopher gets hungry once he has thought, and the hungry philosophers are served in the order they got hungry,
+// by seat when they got hungry together, as soon as their utensils are free and the maxEaters, the napkins and the
+// central dish of the table allow it. Every table of the dinner follows the same plan, the rice pot shared between
+// them is not planned.
+// The meals are in the order they start, the ones starting together in the order they are served, and the makespan
+// is when the last one ends.
+type Plan struct {
+	Meals    []ScheduledMeal `json:"meals"`
+	Makespan time.Duration   `json:"makespan"`
+}
+
+// NewPlan computes the Plan of the dinner of a validated configuration
+func NewPlan(config Config) (Plan, error) {
+	var utensils, needs, err = layUtensils(config)
+	if err != nil {
+		return Plan{}, err
+	}
+	var thinking, eating = make([]time.Duration, config.Philosophers), make([]time.Duration, config.Philosophers)
+	var ready, ends = make([]time.Duration, config.Philosophers), make([]time.Duration, config.Philosophers)
+	var left, held = make([]int, config.Philosophers), make([][]*ChopStick, config.Philosophers)
+	var holders = make(map[*ChopStick]int)
+	var available = make(map[UtensilKind]int)
+	for seat := range needs {
+		var settings = config.Seat(seat)
+		thinking[seat], eating[seat] = meanThinkingTime, meanMealTime
+		if settings.Think > 0 {
+			thinking[seat] = time.Duration(settings.Think)
+		}
+		if settings.Eat > 0 {
+			eating[seat] = time.Duration(settings.Eat)
+		}
+		thinking[seat], eating[seat] = config.Scale(thinking[seat]), config.Scale(eating[seat])
+		ready[seat], left[seat] = thinking[seat], config.MealsOf(seat)
+	}
+	for _, utensil := range utensils {
+		available[utensil.kind]++
+	}
+
+	var plan Plan
+	var eaters = 0
+	var now = time.Duration(0)
+	var hungry = make([]int, 0, config.Philosophers)
+	for {
+		// the hungry philosophers are served in the order they got hungry, as long as the rules of the table allow it
+		hungry = hungry[:0]
+		for seat := range needs {
+			if left[seat] > 0 && held[seat] == nil && ready[seat] <= now {
+				hungry = append(hungry, seat)
+			}
+		}
+		sort.SliceStable(hungry, func(i, j int) bool { return ready[hungry[i]] < ready[hungry[j]] })
+		for _, seat := range hungry {
+			if eaters >= config.MaxEaters || (config.DishCapacity > 0 && eaters >= config.DishCapacity) {
+				break
+			}
+			var chopSticks, _, ok = pickUtensils(needs[seat], holders, available)
+			if !ok {
+				continue
+			}
+			for _, chopStick := range chopSticks {
+				holders[chopStick] = seat
+				available[chopStick.kind]--
+			}
+			held[seat], ends[seat] = chopSticks, now+eating[seat]
+			eaters++
+			plan.Meals = append(plan.Meals, ScheduledMeal{Seat: seat, Name: config.Names.Name(seat),
+				Meal: config.MealsOf(seat) - left[seat], Start: now, End: ends[seat]})
+		}
+
+		// the next step is the end of a meal, or a philosopher getting hungry
+		var next = time.Duration(-1)
+		for seat := range needs {
+			if held[seat] != nil && (next < 0 || ends[seat] < next) {
+				next = ends[seat]
+			} else if held[seat] == nil && left[seat] > 0 && ready[seat] > now && (next < 0 || ready[seat] < next) {
+				next = ready[seat]
+			}
+		}
+		if next < 0 {
+			break
+		}
+		now = next
+		for seat := range needs {
+			if held[seat] != nil && ends[seat] <= now {
+				for _, chopStick := range held[seat] {
+					delete(holders, chopStick)
+					available[chopStick.kind]++
+				}
+				held[seat] = nil
+				eaters--
+				left[seat]--
+				ready[seat] = now + thinking[seat]
+				plan.Makespan = max(plan.Makespan, now)
+			}
+		}
+	}
+	for seat := range needs {
+		if left[seat] > 0 {
+			return plan, fmt.Errorf("plan: %s cannot eat, his utensils are never free", config.Names.Name(seat))
+		}
+	}
+	return plan, nil
+}
+
+// String gives a one line summary of the Plan
+func (plan Plan) String() string {
+	var eating time.Duration
+	for _, meal := range plan.Meals {
+		eating += meal.End - meal.Start
+	}
+	var parallelism = 0.0
+	if plan.Makespan > 0 {
+		parallelism = float64(eating) / float64(plan.Makespan)
+	}
+	return fmt.Sprintf("%d meals planned, makespan %v, %.2f philosophers eating at once on average", len(plan.Meals),
+		plan.Makespan.Round(time.Millisecond), parallelism)
+}
+
+// PlanStrategy makes the Host follow a Plan : it admits the meals in the order they start in the Plan, a meal being
+// admitted once all the meals planned before it were served. The times of the Plan are not followed, the philosophers
+// thinking and eating for the durations they draw, only its order is, which cannot deadlock : the first meal of the
+// Plan not served yet only waits for meals already being eaten.
+// The meals a philosopher ate before the Strategy knew of him, such as before a snapshot, count as served, and the
+// meals beyond the Plan are admitted as the greedy strategy does.
+type PlanStrategy struct {
+	ranks    [][]int // the rank of each meal of each seat in the Plan
+	order    []ScheduledMeal
+	served   []bool
+	next     int         // the rank of the first meal of the Plan not served yet
+	admitted map[int]int // the meal each seat was admitted for last
+}
+
+// NewPlanStrategy creates the PlanStrategy of a Host following the Plan
+func NewPlanStrategy(config Config, plan Plan) *PlanStrategy {
+	var strategy = &PlanStrategy{ranks: make([][]int, config.Philosophers), order: plan.Meals,
+		served: make([]bool, len(plan.Meals)), admitted: make(map[int]int)}
+	for rank, meal := range plan.Meals {
+		strategy.ranks[meal.Seat] = append(strategy.ranks[meal.Seat], rank)
+	}
+	return strategy
+}
+
+// Admit admits the meal when all the meals planned before it were served
+func (strategy *PlanStrategy) Admit(request StrategyRequest) (bool, string) {
+	var ranks = strategy.ranks[request.Seat]
+	for meal := 0; meal < min(request.Meal, len(ranks)); meal++ {
+		strategy.serve(ranks[meal])
+	}
+	if request.Meal < len(ranks) && ranks[request.Meal] > strategy.next {
+		var first = strategy.order[strategy.next]
+		return false, fmt.Sprintf("%s eats his meal %d first, as planned", first.Name, first.Meal+1)
+	}
+	strategy.admitted[request.Seat] = request.Meal
+	return true, ""
+}
+
+// Served marks the meal the philosopher of the seat was admitted for as served
+func (strategy *PlanStrategy) Served(seat int, _ time.Time) {
+	if meal := strategy.admitted[seat]; meal < len(strategy.ranks[seat]) {
+		strategy.serve(strategy.ranks[seat][meal])
+	}
+}
+
+// Released does nothing, the Plan only orders the starts of the meals
+func (strategy *PlanStrategy) Released(int, time.Time) {}
+
+// serve marks the meal of the given rank as served, and moves next past the served meals
+func (strategy *PlanStrategy) serve(rank int) {
+	strategy.served[rank] = true
+	for strategy.next < len(strategy.served) && strategy.served[strategy.next] {
+		strategy.next++
+	}
+}
+
+// validatePlan checks that the dinner can follow its Plan : the guests of the open mode are not known in advance,
+// each shard would have its own Host following the whole Plan, and the lock-free admission does not ask the Host
+func (config Config) validatePlan() error {
+	switch {
+	case config.ArrivalRate > 0:
+		return fmt.Errorf("config: the %s strategy cannot plan the dinner of the open mode, whose guests arrive when they want", planStrategy)
+	case config.Shards > 1:
+		return fmt.Errorf("config: the %s strategy follows a single plan per table, it does not work with shards", planStrategy)
+	case config.Admission == lockFreeAdmission:
+		return fmt.Errorf("config: the %s strategy needs the Host to decide, it does not work with the %s admission", planStrategy, lockFreeAdmission)
+	}
+	if _, err := NewPlan(config); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Below are the allowed values for the execution setting of the Config
+const goroutinesExecution = "goroutines"
+const workerPoolExecution = "workerPool"
+
+// WorkerPool runs the philosophers of a table as state machines on a fixed number of workers, instead of one
+// goroutine each, so that millions of philosophers fit in memory. Each worker owns a contiguous range of seats :
+// - the state of the philosophers of these seats, which the goroutine of a philosopher would keep on its stack
+// - a timer for each of them, telling when he stops thinking or finishes his meal, the worker sleeping until the next one
+// - a feedback channel shared by the philosophers of these seats, the worker waiting for the answer of the Host
+// to one philosopher at a time
+// - a Random shared by the philosophers of these seats, so the draws of a seed also depend on the number of workers
+// The Host cannot ask a philosopher to pause while the worker waits for the answer to another one, so the worker
+// pool does not work with preemption, nor with the open mode where guests come and go
+type WorkerPool struct {
+	table   *Table
+	workers []*Worker
+}
+
+// Worker runs the philosophers of the seats from first to last (excluded)
+type Worker struct {
+	first           int
+	last            int
+	diners          []Diner
+	timers          Timers
+	feedbackChannel chan Grant
+	random          *Random
+}
+
+// Diner is the state of a philosopher run by a worker or by the DiscreteEngine :
+// - when he got hungry for the last time, and when he started his current meal
+// - the utensils he eats with, and whether he took them without asking the Host
+// - whether he is eating, the next timer ending his meal rather than his thinking
+// - the rejections of his current meal, telling how long he backs off before asking again
+// - what is left of a paused meal, and the number of his current timer, only used by the DiscreteEngine
+type Diner struct {
+	hungrySince time.Time
+	mealStart   time.Time
+	chopSticks  []*ChopStick
+	admitted    bool
+	eating      bool
+	retry       Retry
+	mealLeft    time.Duration
+	timer       int
+}
+
+// Timer tells when the philosopher of a seat has to be woken up, relatively to the start of the worker
+type Timer struct {
+	at   time.Duration
+	seat int
+}
+
+// Timers is the min-heap of the timers of a worker, the next one first
+type Timers []Timer
+
+func (timers Timers) Len() int           { return len(timers) }
+func (timers Timers) Less(i, j int) bool { return timers[i].at < timers[j].at }
+func (timers Timers) Swap(i, j int)      { timers[i], timers[j] = timers[j], timers[i] }
+func (timers *Timers) Push(x any)        { *timers = append(*timers, x.(Timer)) }
+func (timers *Timers) Pop() any {
+	var old = *timers
+	var timer = old[len(old)-1]
+	*timers = old[:len(old)-1]
+	return timer
+}
+
+// NewWorkerPool creates the workers of a table, and gives the feedback channel of its worker to each philosopher
+// before the Hosts copy their records
+func NewWorkerPool(table *Table) *WorkerPool {
+	var seats = len(table.philosophers)
+	var pool = &WorkerPool{table: table, workers: make([]*Worker, min(table.config.Workers, seats))}
+	for index := range pool.workers {
+		var worker = &Worker{
+			first:           index * seats / len(pool.workers),
+			last:            (index + 1) * seats / len(pool.workers),
+			feedbackChannel: make(chan Grant, table.config.FeedbackChannelSize),
+			random:          NewRandom(table.config.Seed, table.id, -2-index)}
+		worker.diners = make([]Diner, worker.last-worker.first)
+		worker.timers = make(Timers, 0, worker.last-worker.first)
+		for seat := worker.first; seat < worker.last; seat++ {
+			table.philosophers[seat].feedbackChannel = worker.feedbackChannel
+		}
+		pool.workers[index] = worker
+	}
+	return pool
+}
+
+// Start starts the workers, each meal eaten is told to the Completion
+func (pool *WorkerPool) Start(completion *Completion) {
+	for _, worker := range pool.workers {
+		go worker.run(pool.table, completion)
+	}
+}
+
+// run lets the philosophers of the worker think and eat until they have eaten all their meals or left the table
+func (worker *Worker) run(table *Table, completion *Completion) {
+	var start = time.Now()
+	for seat := worker.first; seat < worker.last; seat++ {
+		worker.diners[seat-worker.first].hungrySince = start
+		worker.think(table.philosophers[seat], 0)
+	}
+
+	for worker.timers.Len() > 0 {
+		var timer = heap.Pop(&worker.timers).(Timer)
+		if wait := timer.at - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+		var now = time.Since(start)
+		if worker.diners[timer.seat-worker.first].eating {
+			worker.finish(table, timer.seat, now, start.Add(now), completion)
+		} else {
+			worker.ask(table, timer.seat, now, start.Add(now), completion)
+		}
+	}
+}
+
+// think lets the philosopher think for a while before he asks to eat, or back off once turned down
+func (worker *Worker) think(philosopher *Philosopher, now time.Duration) {
+	var thinking = philosopher.nextWait(worker.random, &worker.diners[philosopher.id-worker.first].retry)
+	heap.Push(&worker.timers, Timer{at: now + thinking, seat: philosopher.id})
+}
+
+// ask makes the philosopher of the seat ask to eat, the way eat does, and starts his meal when he is allowed to
+func (worker *Worker) ask(table *Table, seat int, now time.Duration, at time.Time, completion *Completion) {
+	var philosopher = table.philosophers[seat]
+	var diner = &worker.diners[seat-worker.first]
+
+	grant, admitted := philosopher.admission.TryEat(philosopher)
+	if !admitted {
+		table.requests(seat).Tell(philosopher.askToEat(diner.hungrySince))
+		grant = <-worker.feedbackChannel
+	}
+	if grant.shutdown {
+		// the dinner is stopped, the remaining meals cannot be eaten
+		worker.leave(philosopher, completion)
+		return
+	}
+	if starving, since := philosopher.energy.Starved(at); starving && !grant.allowed {
+		table.requests(seat).Tell(philosopher.starve(since))
+		worker.leave(philosopher, completion)
+		return
+	}
+	if !grant.allowed {
+		diner.retry.Rejected()
+		worker.think(philosopher, now)
+		return
+	}
+	diner.retry.Reset()
+
+	philosopher.takeUtensils(grant.chopSticks)
+	diner.chopSticks = grant.chopSticks
+	diner.admitted = admitted
+	diner.eating = true
+	diner.mealStart = at
+	philosopher.emit(eventStarted, "")
+	var meal = philosopher.mealTime(worker.random)
+	heap.Push(&worker.timers, Timer{at: now + meal, seat: seat})
+}
+
+// finish ends the meal of the philosopher of the seat, and lets him think before his next meal if he has some left
+func (worker *Worker) finish(table *Table, seat int, now time.Duration, at time.Time, completion *Completion) {
+	var philosopher = table.philosophers[seat]
+	var diner = &worker.diners[seat-worker.first]
+
+	philosopher.emit(eventFinished, "")
+	philosopher.energy.Eat(diner.mealStart, at)
+	philosopher.leaveUtensils(diner.chopSticks)
+	philosopher.countEating++
+	diner.hungrySince = at
+	diner.eating = false
+	diner.chopSticks = nil
+
+	// the Host is told before the Completion, the table is closed once all the meals are completed
+	if diner.admitted {
+		philosopher.admission.Release(philosopher)
+	} else {
+		table.requests(seat).Tell(philosopher.release(false))
+	}
+	completion.Complete(table.id, seat)
+
+	if philosopher.countEating < philosopher.meals {
+		worker.think(philosopher, now)
+	}
+}
+
+// leave gives up the remaining meals of the philosopher
+func (worker *Worker) leave(philosopher *Philosopher, completion *Completion) {
+	completion.GiveUp(philosopher.table, philosopher.id)
+}
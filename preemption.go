@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Preemption lets the Host ask eating philosophers to pause so that a starving philosopher of higher priority can eat.
+// A philosopher is starving once he has been hungry for longer than the configured duration, and he can only preempt
+// the philosophers of lower priority that stand in his way : the ones holding the utensils he needs, or the eater of
+// lowest priority when all allowed philosophers are already eating.
+// The protocol is cooperative, the Host sends a preempt Grant over the feedback channel of the eating philosopher, who
+// releases his utensils at the next safe point, tells the Host that he paused and asks to eat again later.
+// With aging, the priorities compared are the effective ones : a hungry philosopher gains priority as he waits, and an
+// eating philosopher keeps the one he had when he was served, so that a philosopher of low priority who waited long
+// enough can neither be kept waiting nor be preempted by the others. The Preemption keeps the longest wait of each
+// philosopher before he was served, which aging bounds.
+// A nil Preemption means that preemption is disabled.
+type Preemption struct {
+	after   time.Duration
+	config  Config
+	pending map[int]bool
+	counts  map[string]int
+	waits   map[string]time.Duration
+}
+
+// Serving is a philosopher currently eating along with the utensils the Host gave him, and his effective priority
+// when he was served
+type Serving struct {
+	philosopher *Philosopher
+	chopSticks  []*ChopStick
+	priority    float64
+}
+
+// NewPreemption creates the preemption policy of a Host, it returns nil when the configuration does not enable it
+func NewPreemption(config Config) *Preemption {
+	if config.PreemptAfter == 0 {
+		return nil
+	}
+	return &Preemption{after: config.Scale(time.Duration(config.PreemptAfter)), config: config, pending: make(map[int]bool), counts: make(map[string]int),
+		waits: make(map[string]time.Duration)}
+}
+
+// Victims returns the eating philosophers which should pause so that the rejected philosopher can eat later on,
+// none when the philosopher is not starving yet or when one of the philosophers in his way has no lower priority.
+// eating holds the seats of the philosophers eating, and servings what each of them is eating with.
+// Philosophers already asked to pause are not returned again.
+func (preemption *Preemption) Victims(philosopher *Philosopher, hungrySince time.Time, now time.Time, cause string,
+	eating *SeatSet, servings []*Serving) []*Serving {
+	if preemption == nil || now.Sub(hungrySince) < preemption.after {
+		return nil
+	}
+
+	var inTheWay []*Serving
+	switch cause {
+	case causeUtensils:
+		for _, seat := range eating.Members() {
+			if shareUtensils(philosopher.needs, servings[seat].philosopher.needs) {
+				inTheWay = append(inTheWay, servings[seat])
+			}
+		}
+	case causeMaxEaters:
+		for _, seat := range eating.Members() {
+			var serving = servings[seat]
+			if len(inTheWay) == 0 || serving.priority < inTheWay[0].priority {
+				inTheWay = []*Serving{serving}
+			}
+		}
+	}
+
+	var priority = preemption.config.AgedPriority(philosopher.priority, now.Sub(hungrySince))
+	var victims []*Serving
+	for _, serving := range inTheWay {
+		if serving.priority >= priority {
+			return nil
+		}
+		if !preemption.pending[serving.philosopher.id] {
+			victims = append(victims, serving)
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool { return victims[i].philosopher.id < victims[j].philosopher.id })
+	return victims
+}
+
+// Served records that the philosopher starts or resumes eating after having been hungry since the given time, a zero
+// time telling a meal given back by a snapshot, and returns his effective priority
+func (preemption *Preemption) Served(philosopher *Philosopher, hungrySince time.Time, now time.Time) float64 {
+	if preemption == nil || hungrySince.IsZero() {
+		return float64(philosopher.priority)
+	}
+	var waited = now.Sub(hungrySince)
+	preemption.waits[philosopher.name] = max(preemption.waits[philosopher.name], waited)
+	return preemption.config.AgedPriority(philosopher.priority, waited)
+}
+
+// LongestWait returns the philosopher who waited the longest before being served, and how long
+func (preemption *Preemption) LongestWait() (string, time.Duration) {
+	var longest, waited = "", time.Duration(0)
+	for name, wait := range preemption.waits {
+		if wait > waited || (wait == waited && name < longest) {
+			longest, waited = name, wait
+		}
+	}
+	return longest, waited
+}
+
+// Preempted records that the philosopher has been asked to pause
+func (preemption *Preemption) Preempted(serving *Serving) {
+	preemption.pending[serving.philosopher.id] = true
+	preemption.counts[serving.philosopher.name]++
+}
+
+// Released records that the philosopher does not eat anymore, whether he paused or finished his meal
+func (preemption *Preemption) Released(philosopher int) {
+	if preemption == nil {
+		return
+	}
+	delete(preemption.pending, philosopher)
+}
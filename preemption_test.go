@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAgingBoundsLowPriorityWait checks with the dinner of examples/aging.json that the philosopher of the lowest
+// priority eats all his meals, and never waits for one longer than it takes the aging to lift him above the highest
+// priority and to preempt the philosopher eating, the others each eating their longest meal before him and he asking
+// again after his longest backoff
+func TestAgingBoundsLowPriorityWait(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		var dinner = runExample(t, "examples/aging.json", seed)
+		var config, lowest, highest = dinner.config, 0, 0
+		for seat := 0; seat < config.Philosophers; seat++ {
+			if config.Priority(seat) < config.Priority(lowest) {
+				lowest = seat
+			}
+			highest = max(highest, config.Priority(seat))
+		}
+		if config.AgingRate == 0 {
+			t.Fatal("the priorities of examples/aging.json do not age")
+		}
+		var catchUp = time.Duration(float64(highest-config.Priority(lowest)) / (config.AgingRate * config.Speed) * float64(time.Second))
+		var others = time.Duration(config.Philosophers - 1)
+		var bound = catchUp + config.Scale(time.Duration(config.PreemptAfter)+others*550*time.Millisecond+300*time.Millisecond)
+
+		var report = dinner.report(lowest)
+		if report.Meals != config.MealsOf(lowest) || report.Starved {
+			t.Errorf("seed %d : %s ate %d of his %d meals", seed, report.Name, report.Meals, config.MealsOf(lowest))
+		}
+		if wait := dinner.longestWait[report.Name]; wait > bound {
+			t.Errorf("seed %d : %s waited %v for a meal, more than %v", seed, report.Name, wait.Round(time.Millisecond),
+				bound.Round(time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !js
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime/trace"
+)
+
+// servePprof serves the profiles of the process on the given address, such as :6060, under /debug/pprof/
+// The REST API has its own ServeMux, so the profiles are never served on its address
+func servePprof(address string) {
+	go func() {
+		if err := http.ListenAndServe(address, nil); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+}
+
+// startTrace records an execution trace of the dinner in the given file, the returned function stops it
+// The philosophers, their meals and the Hosts are tasks of the trace, whose regions tell when a philosopher thinks,
+// waits, acquires his utensils or eats and when a Host decides, so that go tool trace shows what each of them did
+func startTrace(path string) (func(), error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := trace.Start(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		trace.Stop()
+		if err := file.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}, nil
+}
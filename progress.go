@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const progressRedraw = 200 * time.Millisecond // how often the progress bar is redrawn on a terminal
+const progressBarWidth = 30                   // characters of the progress bar
+
+// Progress reports how far a long dinner is, from its events : the meals eaten out of the meals of the dinner,
+// the current throughput and the estimated time left. On a terminal it redraws a progress bar, otherwise it writes
+// a line at each interval, so that the logs of a CI job tell how far the dinner went. The meals of the dinner are :
+// - the meals of all the philosophers of all the tables, less the meals a philosopher gives up when he starves
+// - in the open mode, the meals of all the guests, each of them eating meals at most until he is seated
+// and tells how many meals he will eat
+// Nil Progress reports nothing
+type Progress struct {
+	file     *os.File
+	terminal bool
+	interval time.Duration
+	meals    []int
+	open     bool
+	total    atomic.Int64
+	eaten    atomic.Int64
+	guests   map[string]int
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewProgress creates the Progress of the dinner of a validated configuration, reporting on the given file
+// every interval
+func NewProgress(config Config, file *os.File, interval time.Duration) *Progress {
+	var progress = &Progress{
+		file:     file,
+		interval: interval,
+		meals:    config.SeatMeals(),
+		open:     config.ArrivalRate > 0,
+		guests:   make(map[string]int),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{})}
+	if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		progress.terminal = true
+	}
+	if progress.open {
+		progress.total.Store(int64(config.Tables * config.Guests * config.Meals))
+	} else {
+		progress.total.Store(int64(config.Tables * config.TotalMeals()))
+	}
+	return progress
+}
+
+// Handle counts the meals eaten and the meals given up, it is meant to be an EventBus handler
+func (progress *Progress) Handle(event Event) {
+	switch event.Kind {
+	case eventFinished:
+		progress.eaten.Add(1)
+	case eventSeated:
+		// the handlers are called one event at a time
+		progress.guests[event.Name] = event.Meal
+		progress.total.Add(int64(event.Meal - progress.meals[event.Philosopher]))
+	case eventStarved, eventPhilosopherFailed:
+		var meals = progress.meals[event.Philosopher]
+		if progress.open {
+			meals = progress.guests[event.Name]
+		}
+		progress.total.Add(int64(event.Meal - meals))
+	case eventLeft:
+		delete(progress.guests, event.Name)
+	}
+}
+
+// Start reports the progress in the background until Stop is called
+func (progress *Progress) Start() {
+	if progress == nil {
+		return
+	}
+	var every = progress.interval
+	if progress.terminal {
+		every = progressRedraw
+	}
+	go func() {
+		defer close(progress.done)
+		var ticker = time.NewTicker(every)
+		defer ticker.Stop()
+		var start = time.Now()
+		var last, lastEaten = start, int64(0)
+		for {
+			select {
+			case <-progress.stop:
+				var eaten, elapsed = progress.eaten.Load(), time.Since(start)
+				progress.report(eaten, elapsed, float64(eaten)/elapsed.Seconds())
+				if progress.terminal {
+					fmt.Fprintln(progress.file)
+				}
+				return
+			case now := <-ticker.C:
+				var eaten = progress.eaten.Load()
+				var throughput = float64(eaten-lastEaten) / now.Sub(last).Seconds()
+				progress.report(eaten, now.Sub(start), throughput)
+				last, lastEaten = now, eaten
+			}
+		}
+	}()
+}
+
+// Stop reports the progress a last time, with the mean throughput of the dinner, and stops reporting it
+func (progress *Progress) Stop() {
+	if progress == nil {
+		return
+	}
+	close(progress.stop)
+	<-progress.done
+}
+
+// report writes the progress of the meals eaten after elapsed, throughput being the meals per second eaten lately,
+// the time left is estimated from the mean throughput since the start
+func (progress *Progress) report(eaten int64, elapsed time.Duration, throughput float64) {
+	var total = max(progress.total.Load(), eaten, 1)
+	var done = float64(eaten) / float64(total)
+	var eta = "unknown"
+	if eaten > 0 {
+		eta = time.Duration(float64(elapsed) * float64(total-eaten) / float64(eaten)).Round(time.Second).String()
+	}
+	var line = fmt.Sprintf("%3d%%  %d/%d meals  %.0f meals/s  ETA %s", eaten*100/total, eaten, total, throughput, eta)
+	if !progress.terminal {
+		fmt.Fprintf(progress.file, "Progress : %s\n", line)
+		return
+	}
+	var filled = int(done * progressBarWidth)
+	fmt.Fprintf(progress.file, "\r[%s%s] %s\033[K", strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), line)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Below are the protobuf wire types used by the API messages
+const protoVarint = 0
+const protoBytes = 2
+
+// protoMessage is a protobuf message being encoded, the API messages are few and simple
+// so they are written by hand following api/philosophers/v1/simulation.proto
+type protoMessage []byte
+
+// tag writes the key of a field
+func (message *protoMessage) tag(field int, wireType int) {
+	*message = binary.AppendUvarint(*message, uint64(field)<<3|uint64(wireType))
+}
+
+// Uint writes an unsigned integer field, skipped when 0 as proto3 does
+func (message *protoMessage) Uint(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	message.tag(field, protoVarint)
+	*message = binary.AppendUvarint(*message, value)
+}
+
+// Int writes an int32 or int64 field, negative values being written on ten bytes as proto3 does
+func (message *protoMessage) Int(field int, value int64) {
+	message.Uint(field, uint64(value))
+}
+
+// Bool writes a bool field
+func (message *protoMessage) Bool(field int, value bool) {
+	if value {
+		message.Uint(field, 1)
+	}
+}
+
+// String writes a string field, skipped when empty
+func (message *protoMessage) String(field int, value string) {
+	if value == "" {
+		return
+	}
+	message.tag(field, protoBytes)
+	*message = binary.AppendUvarint(*message, uint64(len(value)))
+	*message = append(*message, value...)
+}
+
+// Packed writes a repeated integer field, packed as proto3 does
+func (message *protoMessage) Packed(field int, values []int64) {
+	if len(values) == 0 {
+		return
+	}
+	var packed []byte
+	for _, value := range values {
+		packed = binary.AppendUvarint(packed, uint64(value))
+	}
+	message.tag(field, protoBytes)
+	*message = binary.AppendUvarint(*message, uint64(len(packed)))
+	*message = append(*message, packed...)
+}
+
+// Message writes an embedded message field, always written so that repeated empty messages are kept
+func (message *protoMessage) Message(field int, value protoMessage) {
+	message.tag(field, protoBytes)
+	*message = binary.AppendUvarint(*message, uint64(len(value)))
+	*message = append(*message, value...)
+}
+
+// parseVarints decodes the values of a packed repeated integer field
+func parseVarints(data []byte) ([]uint64, error) {
+	var values []uint64
+	for len(data) > 0 {
+		value, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("protobuf: invalid packed varint")
+		}
+		values = append(values, value)
+		data = data[n:]
+	}
+	return values, nil
+}
+
+// parseProto calls the given function for each field of a protobuf message, with its value as an integer
+// for varint fields or as bytes for length delimited fields. Other wire types are skipped.
+func parseProto(data []byte, field func(number int, varint uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("protobuf: invalid field key")
+		}
+		data = data[n:]
+		var number = int(key >> 3)
+
+		switch key & 7 {
+		case protoVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("protobuf: invalid varint for field %d", number)
+			}
+			data = data[n:]
+			if err := field(number, value, nil); err != nil {
+				return err
+			}
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("protobuf: invalid length for field %d", number)
+			}
+			var value = data[n : n+int(length)]
+			data = data[n+int(length):]
+			if err := field(number, 0, value); err != nil {
+				return err
+			}
+		case 1: // fixed64
+			if len(data) < 8 {
+				return fmt.Errorf("protobuf: truncated field %d", number)
+			}
+			data = data[8:]
+		case 5: // fixed32
+			if len(data) < 4 {
+				return fmt.Errorf("protobuf: truncated field %d", number)
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d for field %d", key&7, number)
+		}
+	}
+	return nil
+}
+
+// eventProto encodes an Event as the Event message of api/philosophers/v1/simulation.proto
+func eventProto(event Event) protoMessage {
+	var message protoMessage
+	message.Uint(1, event.Seq)
+	message.Int(2, event.Time.UnixNano())
+	message.Int(3, int64(event.Table))
+	message.Int(4, int64(event.Philosopher))
+	message.String(5, event.Name)
+	message.String(6, string(event.Kind))
+	message.Int(7, int64(event.Meal))
+	message.String(8, event.Detail)
+	message.String(9, event.Simulation)
+	message.String(10, event.Run)
+	message.Uint(11, event.Ticket)
+	message.Uint(12, uint64(event.Version))
+	message.Int(13, int64(event.Queue))
+	message.Uint(14, event.Global)
+	if !event.Wall.IsZero() {
+		message.Int(15, event.Wall.UnixNano())
+	}
+	message.Int(16, int64(event.Elapsed))
+	return message
+}
+
+// parseEventProto decodes the Event message of api/philosophers/v1/simulation.proto, whatever the revision of its
+// schema : the fields it does not know are skipped, and an event without schema_version is of the first revision
+func parseEventProto(data []byte) (Event, error) {
+	var event = Event{Version: 1}
+	var err = parseProto(data, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			event.Seq = varint
+		case 2:
+			event.Time = time.Unix(0, int64(varint))
+		case 3:
+			event.Table = int(int32(varint))
+		case 4:
+			event.Philosopher = int(int32(varint))
+		case 5:
+			event.Name = string(bytes)
+		case 6:
+			event.Kind = EventKind(bytes)
+		case 7:
+			event.Meal = int(int32(varint))
+		case 8:
+			event.Detail = string(bytes)
+		case 9:
+			event.Simulation = string(bytes)
+		case 10:
+			event.Run = string(bytes)
+		case 11:
+			event.Ticket = varint
+		case 12:
+			event.Version = int(varint)
+		case 13:
+			event.Queue = int(int32(varint))
+		case 14:
+			event.Global = varint
+		case 15:
+			event.Wall = time.Unix(0, int64(varint))
+		case 16:
+			event.Elapsed = time.Duration(varint)
+		}
+		return nil
+	})
+	return event, err
+}
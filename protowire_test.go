@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// protoFieldPattern matches the declaration of a field of a message, such as "  uint64 seq = 1;"
+var protoFieldPattern = regexp.MustCompile(`^\s*(?:repeated\s+)?[\w.]+\s+(\w+)\s*=\s*(\d+)\s*;`)
+
+// protoFields reads the numbers of the fields of the message of the given name in the .proto file, by field name
+func protoFields(t *testing.T, path string, name string) map[string]int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]int
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields == nil {
+			if strings.HasPrefix(strings.TrimSpace(line), "message "+name+" ") {
+				fields = make(map[string]int)
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "}") {
+			return fields
+		}
+		if match := protoFieldPattern.FindStringSubmatch(line); match != nil {
+			fields[match[1]], _ = strconv.Atoi(match[2])
+		}
+	}
+	t.Fatalf("%s : no message %s", path, name)
+	return nil
+}
+
+// TestEventProtoMatchesSchema checks that eventProto writes each field of an Event with the number the Event message
+// of api/philosophers/v1/simulation.proto gives it, and that parseEventProto reads them back
+func TestEventProtoMatchesSchema(t *testing.T) {
+	var event = Event{Version: 2, Seq: 101, Global: 114, Time: time.Unix(0, 102), Wall: time.Unix(0, 115),
+		Elapsed: 116, Simulation: "simulation", Run: "run", Table: 103, Philosopher: 104, Name: "name",
+		Kind: EventKind("kind"), Meal: 107, Detail: "detail", Queue: 113, Ticket: 111}
+	// what each field of the schema is expected to carry, a varint or the bytes of a string
+	var expected = map[string]any{
+		"seq": uint64(101), "time_unix_nano": uint64(102), "table": uint64(103), "philosopher": uint64(104),
+		"name": "name", "kind": "kind", "meal": uint64(107), "detail": "detail", "simulation_id": "simulation",
+		"run_id": "run", "ticket": uint64(111), "schema_version": uint64(2), "queue": uint64(113),
+		"global_seq": uint64(114), "wall_unix_nano": uint64(115), "elapsed_nanos": uint64(116),
+	}
+
+	var numbers = protoFields(t, "api/philosophers/v1/simulation.proto", "Event")
+	for name := range numbers {
+		if _, ok := expected[name]; !ok {
+			t.Errorf("field %s = %d of the schema : not checked, is it written by eventProto?", name, numbers[name])
+		}
+	}
+	var written = make(map[int]any)
+	if err := parseProto(eventProto(event), func(number int, varint uint64, bytes []byte) error {
+		if bytes != nil {
+			written[number] = string(bytes)
+		} else {
+			written[number] = varint
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range expected {
+		number, ok := numbers[name]
+		if !ok {
+			t.Errorf("field %s : not in the schema", name)
+			continue
+		}
+		if written[number] != value {
+			t.Errorf("field %s = %d : eventProto wrote %v, want %v", name, number, written[number], value)
+		}
+	}
+
+	parsed, err := parseEventProto(eventProto(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, event) {
+		t.Errorf("parseEventProto read %+v, want %+v", parsed, event)
+	}
+}
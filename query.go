@@ -0,0 +1,108 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// query is the query command, it prints the events of recorded traces selected by a filter expression (see Filter) :
+// the files of the traces, events.csv, events.pb or events in JSON such as events.json, - being the standard input, or the
+// trace of a run saved in a Store with -store-events. The selected events are printed in JSON, one per line, so that
+// the output is a trace the command reads again, or as the lines of the console
+func query(arguments []string) error {
+	var flags = flag.NewFlagSet("query", flag.ExitOnError)
+	var expression = flags.String("e", "", "filter expression selecting the events, such as 'philosopher==2 && event==rejected', every event by default")
+	var path = flags.String("store", "runs.db", "SQLite database where the -run was saved along with its events")
+	var run = flags.Int64("run", 0, "id of a run of the -store whose events are queried, instead of trace files")
+	var format = flags.String("format", "json", "how the selected events are printed, json (one per line) or text")
+	var count = flags.Bool("count", false, "only print how many events are selected")
+	var merge = flags.Bool("merge", false, "merge the events of the traces in the order they were emitted, instead of printing one trace after the other")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s query [-e expression] [-format json|text] [-count] [-merge] trace.json...\n       %s query [-e expression] -store runs.db -run 1\n", os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	// the flags may come after the files, as in query trace.json -e 'event==starved'
+	var files []string
+	for flags.Parse(arguments); flags.NArg() > 0; flags.Parse(arguments) {
+		files = append(files, flags.Arg(0))
+		arguments = flags.Args()[1:]
+	}
+	if *format != "json" && *format != "text" {
+		return fmt.Errorf("query: unknown format %q, expected json or text", *format)
+	}
+	if (*run == 0) == (len(files) == 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var filter *Filter
+	if *expression != "" {
+		var err error
+		if filter, err = ParseFilter(*expression); err != nil {
+			return err
+		}
+	}
+
+	var events []Event
+	if *run != 0 {
+		if _, err := os.Stat(*path); err != nil {
+			return fmt.Errorf("query: %v", err)
+		}
+		store, err := OpenStore(*path)
+		if err != nil {
+			return err
+		}
+		if events, err = store.Events(*run); err != nil {
+			return err
+		}
+	}
+	var traces = [][]Event{events}
+	for _, file := range files {
+		read, err := ReadEvents(file)
+		if err != nil {
+			return err
+		}
+		traces = append(traces, read)
+	}
+	if *merge {
+		events = MergeEvents(traces...)
+	} else {
+		events = nil
+		for _, trace := range traces {
+			events = append(events, trace...)
+		}
+	}
+
+	var selected = 0
+	var encoder = json.NewEncoder(os.Stdout)
+	for _, event := range events {
+		if !filter.Match(event) {
+			continue
+		}
+		selected++
+		switch {
+		case *count:
+		case *format == "json":
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		default:
+			var message, _, _ = strings.Cut(eventMessage(event), "\n")
+			if message == "" {
+				message = strings.TrimSpace(fmt.Sprintf("%s %s %s", event.Name, event.Kind, event.Detail))
+			}
+			fmt.Printf("%s  %s  %s\n", strconv.FormatUint(event.Seq, 10), event.Time.Format(time.StampMilli), message)
+		}
+	}
+	if *count {
+		fmt.Println(selected)
+	}
+	return nil
+}
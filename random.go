@@ -0,0 +1,111 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// Random draws the random numbers of a part of the dinner, such as the thinking times and the meal durations
+// of a philosopher, from the seed of the configuration : the simulations running in the same process never share
+// their draws, and a seed gives the same draws to each part whatever the other parts do.
+// A nil Random draws from the global source of math/rand, as a philosopher joining from another process does.
+// The draws from its source are counted, so that a Random is restored by drawing as many times from the same seed.
+type Random struct {
+	mutex   sync.Mutex
+	seed    int64
+	counter *countingSource
+	source  *rand.Rand
+}
+
+// countingSource is a source of math/rand counting the numbers drawn from it
+type countingSource struct {
+	source rand.Source64
+	draws  uint64
+}
+
+func (counting *countingSource) Int63() int64    { counting.draws++; return counting.source.Int63() }
+func (counting *countingSource) Uint64() uint64  { counting.draws++; return counting.source.Uint64() }
+func (counting *countingSource) Seed(seed int64) { counting.draws = 0; counting.source.Seed(seed) }
+
+// NewRandom creates the Random of a part of the dinner, identified by the given numbers (such as the table and the seat)
+func NewRandom(seed int64, part ...int) *Random {
+	var hash = fnv.New64a()
+	var buffer [8]byte
+	for _, value := range append([]int64{seed}, toInt64(part)...) {
+		for i := range buffer {
+			buffer[i] = byte(value >> (8 * i))
+		}
+		hash.Write(buffer[:])
+	}
+	var random = &Random{seed: int64(hash.Sum64())}
+	random.counter = &countingSource{source: rand.NewSource(random.seed).(rand.Source64)}
+	random.source = rand.New(random.counter)
+	return random
+}
+
+// Draws returns how many numbers were drawn from the source of the Random
+func (random *Random) Draws() uint64 {
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.counter.draws
+}
+
+// Restore brings the Random back to the state it had after the given number of draws
+func (random *Random) Restore(draws uint64) {
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	random.counter.Seed(random.seed)
+	for random.counter.draws < draws {
+		random.counter.Int63()
+	}
+}
+
+// toInt64 converts the numbers identifying a part of the dinner
+func toInt64(values []int) []int64 {
+	var converted = make([]int64, len(values))
+	for i, value := range values {
+		converted[i] = int64(value)
+	}
+	return converted
+}
+
+// Intn returns a number in [0, n)
+func (random *Random) Intn(n int) int {
+	if random == nil {
+		return rand.Intn(n)
+	}
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.source.Intn(n)
+}
+
+// ExpFloat64 returns a number exponentially distributed with a rate of 1
+func (random *Random) ExpFloat64() float64 {
+	if random == nil {
+		return rand.ExpFloat64()
+	}
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.source.ExpFloat64()
+}
+
+// Int63n returns a number in [0, n)
+func (random *Random) Int63n(n int64) int64 {
+	if random == nil {
+		return rand.Int63n(n)
+	}
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.source.Int63n(n)
+}
+
+// Float64 returns a number in [0, 1)
+func (random *Random) Float64() float64 {
+	if random == nil {
+		return rand.Float64()
+	}
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.source.Float64()
+}
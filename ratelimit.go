@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Below are the places where the requests to eat are limited
+const hostRateLimiter = "host"                 // the Host throttles the requests beyond the rate
+const philosophersRateLimiter = "philosophers" // the philosophers also pace themselves, waiting for a token before asking
+
+// RateLimiter paces the requests to eat of the philosophers, it is owned by the Host which enforces it :
+// - each seat has a token bucket holding at most burst tokens, refilled at rate tokens per second
+// - each request to eat takes a token, a request arriving when the bucket of the philosopher is empty is throttled,
+// the Host turning it down without looking any further, and the philosopher asks again after thinking as usual
+// The buckets are full when the dinner starts, and they are refilled on the Clock of the table so that the
+// DiscreteEngine throttles the same requests on every run.
+// When the philosophers pace themselves, each of them also has a RateLimiter of his own, for a single seat, and waits
+// for a token before asking the Host, so that the Host is not asked more often than it allows.
+// A nil RateLimiter means that the requests are not limited.
+type RateLimiter struct {
+	rate    float64
+	burst   float64
+	tokens  []float64
+	updated []time.Time
+}
+
+// NewRateLimiter creates the rate limiter of a Host for the seats of a table, it returns nil when the configuration
+// sets no request rate
+func NewRateLimiter(config Config, seats int) *RateLimiter {
+	if config.RequestRate == 0 {
+		return nil
+	}
+	var limiter = &RateLimiter{rate: config.RequestRate * config.Speed, burst: float64(config.RequestBurst), tokens: make([]float64, seats),
+		updated: make([]time.Time, seats)}
+	for seat := range limiter.tokens {
+		limiter.tokens[seat] = limiter.burst
+	}
+	return limiter
+}
+
+// Take takes a token from the bucket of the seat, it returns false along with the time until the next token when the
+// bucket is empty
+func (limiter *RateLimiter) Take(seat int, now time.Time) (time.Duration, bool) {
+	if limiter == nil {
+		return 0, true
+	}
+	if !limiter.updated[seat].IsZero() {
+		var refill = now.Sub(limiter.updated[seat]).Seconds() * limiter.rate
+		limiter.tokens[seat] = math.Min(limiter.burst, limiter.tokens[seat]+refill)
+	}
+	limiter.updated[seat] = now
+	if limiter.tokens[seat] < 1 {
+		return time.Duration((1 - limiter.tokens[seat]) / limiter.rate * float64(time.Second)), false
+	}
+	limiter.tokens[seat]--
+	return 0, true
+}
+
+// Pace waits until the bucket of a philosopher pacing himself has a token and takes it, with the given function
+// waiting for a duration, it returns how long the philosopher waited
+func (limiter *RateLimiter) Pace(now func() time.Time, wait func(time.Duration)) time.Duration {
+	var waited = time.Duration(0)
+	for {
+		delay, ok := limiter.Take(0, now())
+		if ok {
+			return waited
+		}
+		wait(delay)
+		waited += delay
+	}
+}
+
+// Refill fills the bucket of a seat given to a guest
+func (limiter *RateLimiter) Refill(seat int) {
+	if limiter == nil {
+		return
+	}
+	limiter.tokens[seat], limiter.updated[seat] = limiter.burst, time.Time{}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reception welcomes the guests of a table in the open mode, where the philosophers are not seated
+// once and for all but arrive according to a Poisson process, wait for a free seat, eat a random
+// number of meals and leave. It measures the table as a queueing system.
+type Reception struct {
+	table     *Table
+	leaveChan chan Visit
+	stop      chan struct{}
+	random    *Random
+	start     time.Time
+	visits    []Visit
+}
+
+// Visit records the stay of a guest, from his arrival to his departure
+type Visit struct {
+	guest   int
+	seat    int
+	arrived time.Time
+	seated  time.Time
+	left    time.Time
+}
+
+// NewReception creates the Reception of a table, the seats of the table are the philosophers created by NewTable
+func NewReception(table *Table) *Reception {
+	return &Reception{table: table, leaveChan: make(chan Visit), stop: make(chan struct{}), random: NewRandom(table.config.Seed, table.id, -1)}
+}
+
+// Run lets arriveRate guests per second arrive until the configured number of guests is reached, and seats them
+// as soon as a seat is free. Each guest eats between 1 and meals times, and the Completion is only told that the
+// Reception is closed once the last guest has left so that meals of guests not yet arrived cannot be missed.
+func (reception *Reception) Run(completion *Completion) {
+	defer completion.CloseReception(reception.table.id)
+
+	var config = reception.table.config
+	var freeSeats = make([]int, len(reception.table.philosophers))
+	for seat := range freeSeats {
+		freeSeats[seat] = seat
+	}
+	var waiting []Visit
+	var seated = 0
+	var arrived = 0
+
+	reception.start = time.Now()
+	var nextArrival = time.After(reception.interArrival())
+	var stop = reception.stop
+
+	for arrived < config.Guests || len(waiting) > 0 || seated > 0 {
+		select {
+		case <-nextArrival:
+			waiting = append(waiting, Visit{guest: arrived, arrived: time.Now()})
+			arrived++
+			if arrived < config.Guests {
+				nextArrival = time.After(reception.interArrival())
+			} else {
+				nextArrival = nil
+			}
+		case visit := <-reception.leaveChan:
+			visit.left = time.Now()
+			reception.visits = append(reception.visits, visit)
+			freeSeats = append(freeSeats, visit.seat)
+			seated--
+		case <-stop:
+			// the dinner is stopped, the guests not seated yet go away and no more guest arrives
+			arrived = config.Guests
+			waiting = nil
+			nextArrival = nil
+			stop = nil
+		}
+
+		for len(waiting) > 0 && len(freeSeats) > 0 {
+			var visit = waiting[0]
+			waiting = waiting[1:]
+			visit.seat = freeSeats[0]
+			freeSeats = freeSeats[1:]
+			visit.seated = time.Now()
+			seated++
+			reception.seat(visit, 1+reception.random.Intn(config.Meals), completion)
+		}
+	}
+}
+
+// seat starts the guest on the given seat, he takes the place of the philosopher created for this seat
+// and the Host is told about him before he asks to eat
+func (reception *Reception) seat(visit Visit, meals int, completion *Completion) {
+	var guest = *reception.table.philosophers[visit.seat]
+	guest.name = fmt.Sprintf("g%d@%s", visit.guest, guest.name)
+	guest.meals = meals
+	guest.energy = NewEnergy(reception.table.config)
+	if guest.limiter != nil {
+		guest.limiter = NewRateLimiter(reception.table.config, 1)
+	}
+	guest.feedbackChannel = make(chan Grant, reception.table.config.FeedbackChannelSize)
+	guest.events.Emit(Event{Table: guest.table, Philosopher: guest.id, Name: guest.name, Kind: eventSeated, Meal: meals})
+	reception.table.requests(visit.seat).Tell(SitDown{philosopher: visit.seat, guest: &guest})
+
+	completion.Seat(reception.table.id, visit.seat, meals)
+	go func() {
+		guest.eat(reception.table.requests(visit.seat), completion)
+		guest.emit(eventLeft, "")
+		reception.leaveChan <- visit
+	}()
+}
+
+// interArrival draws the time until the next guest arrives, exponentially distributed for a Poisson process
+func (reception *Reception) interArrival() time.Duration {
+	var config = reception.table.config
+	return time.Duration(reception.random.ExpFloat64() / (config.ArrivalRate * config.Speed) * float64(time.Second))
+}
+
+// String gives a summary of the queueing metrics :
+// - the arrival rate λ observed, against the service rate μ of one seat and the capacity of all the seats
+// - the utilization ρ = λ / capacity, above 1 the queue of guests waiting for a seat keeps growing
+// - the mean time in system W, split between waiting for a seat and being seated
+// - the mean number of guests in system L, which should be close to λW (Little's law)
+func (reception *Reception) String() string {
+	if len(reception.visits) == 0 {
+		return "no guest"
+	}
+
+	var lastArrival, end time.Time
+	var inSystem, waiting, service time.Duration
+	for _, visit := range reception.visits {
+		if visit.arrived.After(lastArrival) {
+			lastArrival = visit.arrived
+		}
+		if visit.left.After(end) {
+			end = visit.left
+		}
+		inSystem += visit.left.Sub(visit.arrived)
+		waiting += visit.seated.Sub(visit.arrived)
+		service += visit.left.Sub(visit.seated)
+	}
+
+	var guests = float64(len(reception.visits))
+	var seats = float64(len(reception.table.philosophers))
+	var arrivalRate = guests / lastArrival.Sub(reception.start).Seconds()
+	var serviceRate = guests / service.Seconds()
+	var meanInSystem = inSystem.Seconds() / guests
+	return fmt.Sprintf("%d guests, arrival rate λ %.2f/s, service rate μ %.2f/s per seat (capacity %.2f/s), utilization ρ %.2f, "+
+		"time in system W %.2fs (waiting for a seat %.2fs), guests in system L %.2f (λW %.2f)",
+		len(reception.visits), arrivalRate, serviceRate, seats*serviceRate, arrivalRate/(seats*serviceRate),
+		meanInSystem, waiting.Seconds()/guests, inSystem.Seconds()/end.Sub(reception.start).Seconds(), arrivalRate*meanInSystem)
+}
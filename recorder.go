@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PhilosopherReport sums up the dinner of a philosopher, as told by the events :
+// - meals is how many meals he finished
+// - accepted, rejected, throttled and preempted count the answers of the Host, paused counts the meals he actually paused
+// - mostRetries is the most requests of a single meal turned down, by the Host or its rate limiter
+// - eating is the time spent eating, waiting is the time spent between a rejected request and the start of the meal
+// or his starvation
+type PhilosopherReport struct {
+	Table       int           `json:"table"`
+	Philosopher int           `json:"philosopher"`
+	Name        string        `json:"name"`
+	Meals       int           `json:"meals"`
+	Accepted    int           `json:"accepted"`
+	Rejected    int           `json:"rejected"`
+	Throttled   int           `json:"throttled"`
+	Preempted   int           `json:"preempted"`
+	Paused      int           `json:"paused"`
+	MostRetries int           `json:"mostRetries"`
+	Starved     bool          `json:"starved"`
+	Eating      time.Duration `json:"eating"`
+	Waiting     time.Duration `json:"waiting"`
+}
+
+// TraceSampling bounds the events a Recorder keeps, so that the trace of a long dinner can be recorded without
+// growing without bound :
+// - every keeps one event out of every, 0 or 1 keeping them all
+// - reservoir keeps a uniform sample of at most this many of the events of the whole dinner, drawn from the seed,
+// 0 for no bound
+// Both can be combined, the reservoir sampling the events kept by every.
+type TraceSampling struct {
+	Every     int
+	Reservoir int
+	Seed      int64
+}
+
+// ParseTraceSampling parses the sampling of a trace, such as every:10 or reservoir:100000 or both separated by a comma
+func ParseTraceSampling(text string, seed int64) (TraceSampling, error) {
+	var sampling = TraceSampling{Seed: seed}
+	for _, part := range strings.Split(text, ",") {
+		var name, value, _ = strings.Cut(strings.TrimSpace(part), ":")
+		var count, err = strconv.Atoi(value)
+		if err != nil || count < 1 {
+			return sampling, fmt.Errorf("sample: invalid count %q in %q, expected a positive number", value, part)
+		}
+		switch name {
+		case "every":
+			sampling.Every = count
+		case "reservoir":
+			sampling.Reservoir = count
+		default:
+			return sampling, fmt.Errorf("sample: unknown sampling %q, expected every:N or reservoir:N", name)
+		}
+	}
+	return sampling, nil
+}
+
+// Recorder follows the events of a dinner to sum up what each philosopher did, and keeps the events themselves
+// when asked to, all of them or a sample bounded by a TraceSampling, it is meant to be an EventBus handler. It also
+// keeps the time of its first and last events, the span of the dinner in simulated time with the discrete engine.
+type Recorder struct {
+	mutex       sync.Mutex
+	keepEvents  bool
+	sampling    TraceSampling
+	random      *Random
+	offered     int64 // the events the Recorder could have kept
+	seen        int64 // the events it could have kept once sampled by every
+	events      []Event
+	first       time.Time
+	last        time.Time
+	reports     map[string]*PhilosopherReport
+	hungrySince map[string]time.Time
+	eatingSince map[string]time.Time
+	retries     map[string]int
+}
+
+// NewRecorder creates a Recorder, keepEvents tells if it keeps the whole trace of the events
+func NewRecorder(keepEvents bool) *Recorder {
+	return &Recorder{
+		keepEvents:  keepEvents,
+		reports:     make(map[string]*PhilosopherReport),
+		hungrySince: make(map[string]time.Time),
+		eatingSince: make(map[string]time.Time),
+		retries:     make(map[string]int)}
+}
+
+// Record updates the reports according to an event
+func (recorder *Recorder) Record(event Event) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.keepEvents {
+		recorder.keep(event)
+	}
+	if recorder.first.IsZero() {
+		recorder.first = event.Time
+	}
+	recorder.last = event.Time
+	if event.Name == "" {
+		return
+	}
+	var report = recorder.reports[event.Name]
+	if report == nil {
+		report = &PhilosopherReport{Table: event.Table, Philosopher: event.Philosopher, Name: event.Name}
+		recorder.reports[event.Name] = report
+	}
+
+	switch event.Kind {
+	case eventAccepted:
+		report.Accepted++
+	case eventRejected, eventThrottled:
+		if event.Kind == eventRejected {
+			report.Rejected++
+		} else {
+			report.Throttled++
+		}
+		recorder.retries[event.Name]++
+		report.MostRetries = max(report.MostRetries, recorder.retries[event.Name])
+		if _, hungry := recorder.hungrySince[event.Name]; !hungry {
+			recorder.hungrySince[event.Name] = event.Time
+		}
+	case eventPreempted:
+		report.Preempted++
+	case eventStarted:
+		delete(recorder.retries, event.Name)
+		if since, hungry := recorder.hungrySince[event.Name]; hungry {
+			report.Waiting += event.Time.Sub(since)
+			delete(recorder.hungrySince, event.Name)
+		}
+		recorder.eatingSince[event.Name] = event.Time
+	case eventFinished, eventPaused:
+		if since, eating := recorder.eatingSince[event.Name]; eating {
+			report.Eating += event.Time.Sub(since)
+			delete(recorder.eatingSince, event.Name)
+		}
+		if event.Kind == eventFinished {
+			report.Meals++
+		} else {
+			report.Paused++
+		}
+	case eventStarved:
+		report.Starved = true
+		delete(recorder.retries, event.Name)
+		if since, hungry := recorder.hungrySince[event.Name]; hungry {
+			report.Waiting += event.Time.Sub(since)
+			delete(recorder.hungrySince, event.Name)
+		}
+	case eventPhilosopherFailed:
+		delete(recorder.retries, event.Name)
+		delete(recorder.hungrySince, event.Name)
+		if since, eating := recorder.eatingSince[event.Name]; eating {
+			report.Eating += event.Time.Sub(since)
+			delete(recorder.eatingSince, event.Name)
+		}
+	}
+}
+
+// Sample makes the Recorder keep a sample of the events from now on instead of all of them
+func (recorder *Recorder) Sample(sampling TraceSampling) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.sampling = sampling
+	recorder.random = NewRandom(sampling.Seed, -5)
+}
+
+// keep keeps the event when it is sampled, the mutex being held. The reservoir replaces one of the events it holds
+// with the probability reservoir/seen, which keeps each event with the same probability (Vitter's algorithm R).
+func (recorder *Recorder) keep(event Event) {
+	recorder.offered++
+	if recorder.sampling.Every > 1 && (recorder.offered-1)%int64(recorder.sampling.Every) != 0 {
+		return
+	}
+	recorder.seen++
+	if recorder.sampling.Reservoir == 0 || len(recorder.events) < recorder.sampling.Reservoir {
+		recorder.events = append(recorder.events, event)
+		return
+	}
+	if index := recorder.random.Int63n(recorder.seen); index < int64(recorder.sampling.Reservoir) {
+		recorder.events[index] = event
+	}
+}
+
+// Events returns the events recorded so far in the order they were emitted, none unless the Recorder keeps them
+func (recorder *Recorder) Events() []Event {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	var events = append([]Event(nil), recorder.events...)
+	if recorder.sampling.Reservoir > 0 {
+		sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	}
+	return events
+}
+
+// Span returns how long the dinner lasted, from its first event to its last one
+func (recorder *Recorder) Span() time.Duration {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return recorder.last.Sub(recorder.first)
+}
+
+// Reports returns a copy of the reports, sorted by table and philosopher
+func (recorder *Recorder) Reports() []PhilosopherReport {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	var reports []PhilosopherReport
+	for _, report := range recorder.reports {
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		var a, b = reports[i], reports[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Philosopher != b.Philosopher {
+			return a.Philosopher < b.Philosopher
+		}
+		return a.Name < b.Name
+	})
+	return reports
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits bound what the clients of a server can ask for, so that a server shared by a classroom stays responsive :
+// - simulations is how many simulations the server holds, taking place or over : when they are all held, the one over
+// for the longest time is forgotten to make room, and a new simulation is refused only when they all take place
+// - philosophers is how many philosophers a simulation can have, all tables included
+// - duration is how long a simulation can last, it is stopped afterwards
+// - retention is how long a simulation is kept once over, it is forgotten afterwards
+// A limit left to 0 does not apply
+type Limits struct {
+	Simulations  int
+	Philosophers int
+	Duration     time.Duration
+	Retention    time.Duration
+}
+
+// LimitError tells that a simulation cannot be created because of the Limits of the Registry, busy tells that
+// it could be created later, once other simulations are over
+type LimitError struct {
+	message string
+	busy    bool
+}
+
+func (err LimitError) Error() string {
+	return err.message
+}
+
+// Registry holds the simulations of the servers, each of them being known by its id
+// The simulations are isolated from each other : each one draws its random numbers from its own seed,
+// and its events are labelled with its id so that they can be told apart in the metrics.
+// A single Registry can be shared by the REST and gRPC servers of the process.
+type Registry struct {
+	mutex       sync.Mutex
+	limits      Limits
+	lastID      int
+	simulations map[string]*Simulation
+	// finished tells when each simulation held which is over ended
+	finished map[string]time.Time
+}
+
+// SimulationInfo is what the Registry tells about a simulation
+type SimulationInfo struct {
+	ID     string  `json:"id"`
+	Run    RunInfo `json:"run"`
+	Config Config  `json:"config"`
+	State  *State  `json:"state,omitempty"`
+}
+
+// NewRegistry creates an empty Registry enforcing the given limits
+func NewRegistry(limits Limits) *Registry {
+	return &Registry{limits: limits, simulations: make(map[string]*Simulation), finished: make(map[string]time.Time)}
+}
+
+// Create creates and starts a simulation from a validated configuration, unless a limit would be exceeded
+func (registry *Registry) Create(config Config) (string, *Simulation, error) {
+	if registry.limits.Philosophers > 0 && config.Tables*config.Philosophers > registry.limits.Philosophers {
+		return "", nil, LimitError{message: fmt.Sprintf("a simulation cannot have more than %d philosophers, got %d",
+			registry.limits.Philosophers, config.Tables*config.Philosophers)}
+	}
+
+	registry.mutex.Lock()
+	if registry.limits.Simulations > 0 && len(registry.simulations) >= registry.limits.Simulations && !registry.evictOldest() {
+		registry.mutex.Unlock()
+		return "", nil, LimitError{message: fmt.Sprintf("%d simulations are already taking place", registry.limits.Simulations), busy: true}
+	}
+	registry.lastID++
+	var id = strconv.Itoa(registry.lastID)
+	var simulation = NewSimulation(config)
+	simulation.Events().SetLabel(id)
+	simulation.Observe()
+	registry.simulations[id] = simulation
+	registry.mutex.Unlock()
+
+	simulation.Start()
+	var timer *time.Timer
+	if registry.limits.Duration > 0 {
+		timer = time.AfterFunc(registry.limits.Duration, simulation.Stop)
+	}
+	go func() {
+		<-simulation.Done()
+		if timer != nil {
+			timer.Stop()
+		}
+		registry.finish(id, simulation)
+	}()
+	return id, simulation, nil
+}
+
+// finish records when the simulation with the given id ended, and forgets it once the retention is over
+func (registry *Registry) finish(id string, simulation *Simulation) {
+	registry.mutex.Lock()
+	if registry.simulations[id] == simulation {
+		registry.finished[id] = time.Now()
+	}
+	registry.mutex.Unlock()
+
+	if registry.limits.Retention > 0 {
+		time.AfterFunc(registry.limits.Retention, func() {
+			registry.mutex.Lock()
+			defer registry.mutex.Unlock()
+			if registry.simulations[id] == simulation {
+				delete(registry.simulations, id)
+				delete(registry.finished, id)
+			}
+		})
+	}
+}
+
+// evictOldest forgets the simulation over for the longest time, it returns false when they all take place.
+// The mutex must be held
+func (registry *Registry) evictOldest() bool {
+	var oldest string
+	for id, ended := range registry.finished {
+		if oldest == "" || ended.Before(registry.finished[oldest]) {
+			oldest = id
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	delete(registry.simulations, oldest)
+	delete(registry.finished, oldest)
+	return true
+}
+
+// Get returns the simulation with the given id, nil when there is none
+func (registry *Registry) Get(id string) *Simulation {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return registry.simulations[id]
+}
+
+// Remove stops the simulation with the given id and forgets it, it returns false when there is none
+func (registry *Registry) Remove(id string) bool {
+	registry.mutex.Lock()
+	var simulation = registry.simulations[id]
+	delete(registry.simulations, id)
+	delete(registry.finished, id)
+	registry.mutex.Unlock()
+
+	if simulation == nil {
+		return false
+	}
+	simulation.Stop()
+	return true
+}
+
+// List returns the simulations along with their state, sorted by id
+func (registry *Registry) List() []SimulationInfo {
+	registry.mutex.Lock()
+	var infos = make([]SimulationInfo, 0, len(registry.simulations))
+	for id, simulation := range registry.simulations {
+		var state = simulation.State()
+		infos = append(infos, SimulationInfo{ID: id, Run: simulation.Info(), Config: simulation.Config(), State: &state})
+	}
+	registry.mutex.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		a, _ := strconv.Atoi(infos[i].ID)
+		b, _ := strconv.Atoi(infos[j].ID)
+		return a < b
+	})
+	return infos
+}
+
+// WriteMetrics writes the metrics of the simulations in the text format of Prometheus, labelled by simulation
+func (registry *Registry) WriteMetrics(w io.Writer) {
+	var infos = registry.List()
+	var running = 0
+	for _, info := range infos {
+		if info.State.Running {
+			running++
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_simulations Simulations held by the server, by status.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_simulations gauge\n")
+	fmt.Fprintf(w, "philosophers_simulations{status=\"running\"} %d\n", running)
+	fmt.Fprintf(w, "philosophers_simulations{status=\"over\"} %d\n", len(infos)-running)
+
+	fmt.Fprintf(w, "# HELP philosophers_run_info Run of each simulation, its config hash, strategy, seed and Go version.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_run_info gauge\n")
+	for _, info := range infos {
+		fmt.Fprintf(w, "philosophers_run_info{simulation=%q,run=%q,config_hash=%q,strategy=%q,seed=\"%d\",go_version=%q} 1\n",
+			info.ID, info.Run.ID, info.Run.ConfigHash, info.Run.Strategy, info.Run.Seed, info.Run.GoVersion)
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_events_total Events emitted by a simulation.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_events_total counter\n")
+	for _, info := range infos {
+		fmt.Fprintf(w, "philosophers_events_total{simulation=%q} %d\n", info.ID, info.State.Events)
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_request_queue_depth Requests of a simulation waiting for its Hosts.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_request_queue_depth gauge\n")
+	for _, info := range infos {
+		fmt.Fprintf(w, "philosophers_request_queue_depth{simulation=%q} %d\n", info.ID, info.State.QueueDepth)
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_backpressure_total Requests of a simulation which waited too long to reach a Host.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_backpressure_total counter\n")
+	for _, info := range infos {
+		fmt.Fprintf(w, "philosophers_backpressure_total{simulation=%q} %d\n", info.ID, info.State.Backpressure)
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_liveness_incidents_total Philosophers of a simulation who sent no heartbeat for too long.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_liveness_incidents_total counter\n")
+	for _, info := range infos {
+		fmt.Fprintf(w, "philosophers_liveness_incidents_total{simulation=%q} %d\n", info.ID, info.State.Incidents)
+	}
+
+	var loads = make(map[string][]HostLoad)
+	for _, info := range infos {
+		if simulation := registry.Get(info.ID); simulation != nil {
+			loads[info.ID] = simulation.HostLoads()
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_host_queue_depth Requests waiting for the Host of a shard of a table.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_queue_depth gauge\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			fmt.Fprintf(w, "philosophers_host_queue_depth{simulation=%q,table=\"%d\",shard=\"%d\"} %d\n", info.ID, load.Table, load.Shard, load.Depth)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_host_queue_depth_peak Most requests waiting for the Host of a shard when it received one.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_queue_depth_peak gauge\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			fmt.Fprintf(w, "philosophers_host_queue_depth_peak{simulation=%q,table=\"%d\",shard=\"%d\"} %d\n", info.ID, load.Table, load.Shard, load.DepthPeak)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_host_requests_total Requests received by the Host of a shard.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_requests_total counter\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			fmt.Fprintf(w, "philosophers_host_requests_total{simulation=%q,table=\"%d\",shard=\"%d\"} %d\n", info.ID, load.Table, load.Shard, load.Requests)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_host_busy_seconds_total Time the Host of a shard spent processing requests.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_busy_seconds_total counter\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			fmt.Fprintf(w, "philosophers_host_busy_seconds_total{simulation=%q,table=\"%d\",shard=\"%d\"} %g\n", info.ID, load.Table, load.Shard, load.Busy.Seconds())
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_host_shed_total Requests to eat shed by the overloaded Host of a shard, before reaching it or by the Host.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_shed_total counter\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			fmt.Fprintf(w, "philosophers_host_shed_total{simulation=%q,table=\"%d\",shard=\"%d\",by=\"philosopher\"} %d\n", info.ID, load.Table, load.Shard, load.Shed)
+			fmt.Fprintf(w, "philosophers_host_shed_total{simulation=%q,table=\"%d\",shard=\"%d\",by=\"host\"} %d\n", info.ID, load.Table, load.Shard, load.ShedByHost)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_host_retry_after_seconds_total Retry-after hints given by the overloaded Host of a shard.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_retry_after_seconds_total counter\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			fmt.Fprintf(w, "philosophers_host_retry_after_seconds_total{simulation=%q,table=\"%d\",shard=\"%d\"} %g\n", info.ID, load.Table, load.Shard, load.RetryAfter.Seconds())
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_host_decision_latency_seconds Time from the sending of a request to eat until the decision of the Host.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_host_decision_latency_seconds histogram\n")
+	for _, info := range infos {
+		for _, load := range loads[info.ID] {
+			var labels = fmt.Sprintf("simulation=%q,table=\"%d\",shard=\"%d\"", info.ID, load.Table, load.Shard)
+			var count int64
+			for bucket, bound := range hostLatencyBuckets {
+				count += load.Buckets[bucket]
+				fmt.Fprintf(w, "philosophers_host_decision_latency_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound.Seconds(), count)
+			}
+			// the count is the one of the buckets, which may be read a decision ahead of the other counters
+			count += load.Buckets[len(hostLatencyBuckets)]
+			fmt.Fprintf(w, "philosophers_host_decision_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, count)
+			fmt.Fprintf(w, "philosophers_host_decision_latency_seconds_sum{%s} %g\n", labels, load.Latency.Seconds())
+			fmt.Fprintf(w, "philosophers_host_decision_latency_seconds_count{%s} %d\n", labels, count)
+		}
+	}
+
+	var tenants = make(map[string][]TenantLoad)
+	for _, info := range infos {
+		if simulation := registry.Get(info.ID); simulation != nil {
+			tenants[info.ID] = simulation.TenantLoads()
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_tenant_eating Philosophers of a tenant of a table eating now.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_tenant_eating gauge\n")
+	for _, info := range infos {
+		for _, load := range tenants[info.ID] {
+			fmt.Fprintf(w, "philosophers_tenant_eating{simulation=%q,table=\"%d\",tenant=%q} %d\n", info.ID, load.Table, load.Tenant, load.Eating)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_tenant_quota Philosophers of a tenant of a table allowed to eat at the same time, 0 for no quota.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_tenant_quota gauge\n")
+	for _, info := range infos {
+		for _, load := range tenants[info.ID] {
+			fmt.Fprintf(w, "philosophers_tenant_quota{simulation=%q,table=\"%d\",tenant=%q} %d\n", info.ID, load.Table, load.Tenant, load.Quota)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_tenant_meals_total Meals started by the philosophers of a tenant of a table.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_tenant_meals_total counter\n")
+	for _, info := range infos {
+		for _, load := range tenants[info.ID] {
+			fmt.Fprintf(w, "philosophers_tenant_meals_total{simulation=%q,table=\"%d\",tenant=%q} %d\n", info.ID, load.Table, load.Tenant, load.Meals)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_tenant_rejected_total Requests of the philosophers of a tenant of a table turned down by its quota.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_tenant_rejected_total counter\n")
+	for _, info := range infos {
+		for _, load := range tenants[info.ID] {
+			fmt.Fprintf(w, "philosophers_tenant_rejected_total{simulation=%q,table=\"%d\",tenant=%q} %d\n", info.ID, load.Table, load.Tenant, load.Rejected)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_tenant_wait_seconds_total Time the philosophers of a tenant of a table waited for their meals.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_tenant_wait_seconds_total counter\n")
+	for _, info := range infos {
+		for _, load := range tenants[info.ID] {
+			fmt.Fprintf(w, "philosophers_tenant_wait_seconds_total{simulation=%q,table=\"%d\",tenant=%q} %g\n", info.ID, load.Table, load.Tenant, load.Waited.Seconds())
+		}
+	}
+
+	var slos = make(map[string][]SLOStatus)
+	for _, info := range infos {
+		if simulation := registry.Get(info.ID); simulation != nil {
+			slos[info.ID] = simulation.SLOs()
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_slo_compliance Share of the meals of a simulation started within the threshold of an SLO.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_slo_compliance gauge\n")
+	for _, info := range infos {
+		for _, status := range slos[info.ID] {
+			fmt.Fprintf(w, "philosophers_slo_compliance{simulation=%q,slo=%q} %g\n", info.ID, status.Name, status.Compliance)
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_slo_objective Share of the meals of a simulation an SLO expects to start within its threshold.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_slo_objective gauge\n")
+	for _, info := range infos {
+		for _, status := range slos[info.ID] {
+			fmt.Fprintf(w, "philosophers_slo_objective{simulation=%q,slo=%q} %g\n", info.ID, status.Name, status.Objective)
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_slo_error_budget_remaining Share of the error budget of an SLO left, negative once exhausted.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_slo_error_budget_remaining gauge\n")
+	for _, info := range infos {
+		for _, status := range slos[info.ID] {
+			fmt.Fprintf(w, "philosophers_slo_error_budget_remaining{simulation=%q,slo=%q} %g\n", info.ID, status.Name, status.Budget)
+		}
+	}
+	fmt.Fprintf(w, "# HELP philosophers_slo_burn_rate How fast the meals of the window of an SLO use its error budget, 1 using it exactly.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_slo_burn_rate gauge\n")
+	for _, info := range infos {
+		for _, status := range slos[info.ID] {
+			fmt.Fprintf(w, "philosophers_slo_burn_rate{simulation=%q,slo=%q} %g\n", info.ID, status.Name, status.BurnRate)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_meals_total Meals eaten by a philosopher of a simulation.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_meals_total counter\n")
+	for _, info := range infos {
+		for _, philosopher := range info.State.Philosophers {
+			fmt.Fprintf(w, "philosophers_meals_total{simulation=%q,philosopher=%q} %d\n", info.ID, philosopher.Name, philosopher.MealsEaten)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP philosophers_phase Philosophers of a simulation, by what they are doing.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_phase gauge\n")
+	for _, info := range infos {
+		var phases = make(map[Phase]int)
+		for _, philosopher := range info.State.Philosophers {
+			phases[philosopher.Phase]++
+		}
+		for _, phase := range Phases {
+			fmt.Fprintf(w, "philosophers_phase{simulation=%q,phase=%q} %d\n", info.ID, phase, phases[phase])
+		}
+	}
+}
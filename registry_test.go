@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// quickConfig is a dinner over in a few milliseconds
+func quickConfig(t *testing.T) Config {
+	config, err := ParseConfig([]byte(`{"philosophers": 2, "meals": 1, "speed": 50, "engine": "discrete"}`), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// created creates a simulation and waits for its end, the Registry having been told about it
+func created(t *testing.T, registry *Registry) string {
+	id, simulation, err := registry.Create(quickConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-simulation.Done()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		registry.mutex.Lock()
+		var _, finished = registry.finished[id]
+		registry.mutex.Unlock()
+		if finished {
+			return id
+		}
+	}
+	t.Fatalf("simulation %s : the Registry was not told that it is over", id)
+	return id
+}
+
+// TestRegistryEvictsOldestFinished checks that the limit of simulations covers the ones over, the oldest of them
+// being forgotten to make room for a new one
+func TestRegistryEvictsOldestFinished(t *testing.T) {
+	var registry = NewRegistry(Limits{Simulations: 2})
+	var first = created(t, registry)
+	var second = created(t, registry)
+	var third = created(t, registry)
+	if registry.Get(first) != nil {
+		t.Errorf("simulation %s : still held beyond the limit of 2", first)
+	}
+	if registry.Get(second) == nil || registry.Get(third) == nil {
+		t.Errorf("simulations %s and %s : forgotten while within the limit of 2", second, third)
+	}
+	if held := len(registry.List()); held != 2 {
+		t.Errorf("%d simulations held, the limit is 2", held)
+	}
+}
+
+// TestRegistryForgetsAfterRetention checks that a simulation over is forgotten once the retention is over
+func TestRegistryForgetsAfterRetention(t *testing.T) {
+	var registry = NewRegistry(Limits{Retention: 20 * time.Millisecond})
+	var id = created(t, registry)
+	for deadline := time.Now().Add(5 * time.Second); registry.Get(id) != nil; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("simulation %s : still held 5s after the retention of 20ms", id)
+		}
+	}
+}
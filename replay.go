@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Below are the commands of the steps replayed to a Host
+const askStep = "ask"
+const releaseStep = "release"
+const pauseStep = "pause"
+const starveStep = "starve"
+const abortStep = "abort"
+
+// HostStep is a message of a recorded dinner replayed to the Host of a table, at the offset At from the start of the
+// dinner : the request to eat of the philosopher of a seat, hungry since the offset HungrySince, along with whether
+// the Host allowed him to eat, or the end of his meal, its pause, his starvation or the grant he gave back
+type HostStep struct {
+	At          time.Duration
+	Seat        int
+	Meal        int
+	Command     string
+	HungrySince time.Duration
+	Allowed     bool
+}
+
+// String tells the step in a few words
+func (step HostStep) String() string {
+	if step.Command == askStep {
+		return fmt.Sprintf("%v %s %d meal %d, allowed %v", step.At, step.Command, step.Seat, step.Meal, step.Allowed)
+	}
+	return fmt.Sprintf("%v %s %d meal %d", step.At, step.Command, step.Seat, step.Meal)
+}
+
+// HostReplay drives the Hosts of a table on their own, without philosophers, the way the bench command does : each
+// step is sent to the Host of its seat once the previous one is processed, on a Clock moved to the time of the step,
+// so that the same steps lead to the same decisions whatever the machine. The requests to pause the Host sends are
+// dropped, since the steps tell when the meals were actually paused, and the two-phase grants are only confirmed
+// when they are given back, the utensils being reserved for the philosopher either way.
+type HostReplay struct {
+	table *Table
+	clock *Clock
+	start time.Time
+}
+
+// errReplayed tells the configurations whose decisions are not all taken by the Hosts of the table
+var errReplayed = errors.New("replay: the Hosts cannot be replayed on their own with the lock-free admission, the open mode, a rice pot nor the rate limiter of the philosophers")
+
+// NewHostReplay starts the Hosts of a table of a validated configuration, the philosophers being run by goroutines
+// so that each of them has his own feedback channel
+func NewHostReplay(config Config, table int) (*HostReplay, error) {
+	if config.Admission == lockFreeAdmission || config.ArrivalRate > 0 || config.PotCapacity > 0 || config.RateLimiter == philosophersRateLimiter {
+		return nil, errReplayed
+	}
+	config.Execution = goroutinesExecution
+	var replay = &HostReplay{table: NewTable(table, config, nil, nil), start: time.Unix(0, 0)}
+	replay.clock = NewClock(replay.start)
+	replay.table.clock = replay.clock
+	for _, utensil := range replay.table.utensils {
+		utensil.clock = replay.clock
+	}
+	replay.table.startHosts()
+	return replay, nil
+}
+
+// Step sends the step to the Host of its seat and waits until it is processed, it returns whether the Host allowed
+// the philosopher to eat, false for the steps which are not requests to eat
+func (replay *HostReplay) Step(step HostStep) bool {
+	replay.clock.Set(replay.start.Add(step.At))
+	var philosopher = replay.table.philosophers[step.Seat]
+	philosopher.countEating = step.Meal
+	var host = replay.table.shardOf(step.Seat).host
+	var allowed = false
+	switch step.Command {
+	case askStep:
+		allowed = host.Ask(GrantRequest{philosopher: step.Seat, meal: step.Meal, hungrySince: replay.start.Add(step.HungrySince), sent: replay.clock.Now()}).allowed
+	case releaseStep, pauseStep:
+		host.Tell(Release{philosopher: step.Seat, meal: step.Meal, paused: step.Command == pauseStep, sent: replay.clock.Now()})
+		host.State()
+	case abortStep:
+		host.Tell(Confirm{philosopher: step.Seat, meal: step.Meal, aborted: true, sent: replay.clock.Now()})
+		host.State()
+	case starveStep:
+		host.Tell(Starve{philosopher: step.Seat, meal: step.Meal, hungrySince: replay.start.Add(step.HungrySince), sent: replay.clock.Now()})
+		host.State()
+	}
+	for _, philosopher := range replay.table.philosophers {
+		for drained := false; !drained; {
+			select {
+			case <-philosopher.feedbackChannel:
+			default:
+				drained = true
+			}
+		}
+	}
+	return allowed
+}
+
+// Close stops the Hosts, their Stats being left in the table
+func (replay *HostReplay) Close() {
+	replay.table.Close()
+}
+
+// ReplayHost replays the steps to the Hosts of a table of a validated configuration, and returns the steps whose
+// request to eat got another answer
+func ReplayHost(config Config, table int, steps []HostStep) ([]string, error) {
+	replay, err := NewHostReplay(config, table)
+	if err != nil {
+		return nil, err
+	}
+	defer replay.Close()
+	var mismatches []string
+	for i, step := range steps {
+		if allowed := replay.Step(step); step.Command == askStep && allowed != step.Allowed {
+			mismatches = append(mismatches, fmt.Sprintf("step %d, %s : the Host answered %v", i, step, allowed))
+		}
+	}
+	return mismatches, nil
+}
+
+// HostSteps extracts from the events of a recorded dinner the steps of the Hosts of a table : the decisions of the
+// Hosts, accepted, rejected or throttled, the meals finished or paused, the starvations and the grants given back. A philosopher is hungry
+// from his first rejection, or from the decision when he is accepted at once, as for the SteadyState.
+func HostSteps(events []Event, table int) []HostStep {
+	var steps []HostStep
+	var start time.Time
+	var hungrySince = make(map[int]time.Duration)
+	var granted = make(map[int]time.Duration) // since when the philosophers allowed to eat were hungry, until they give the grant back
+	for _, event := range events {
+		if start.IsZero() {
+			start = event.Time
+		}
+		if event.Table != table {
+			continue
+		}
+		var at = event.Time.Sub(start)
+		var step = HostStep{At: at, Seat: event.Philosopher, Meal: event.Meal}
+		switch event.Kind {
+		case eventAccepted, eventRejected, eventThrottled:
+			if _, hungry := hungrySince[event.Philosopher]; !hungry {
+				hungrySince[event.Philosopher] = at
+			}
+			step.Command, step.HungrySince, step.Allowed = askStep, hungrySince[event.Philosopher], event.Kind == eventAccepted
+			if step.Allowed {
+				granted[event.Philosopher] = hungrySince[event.Philosopher]
+				delete(hungrySince, event.Philosopher)
+			}
+		case eventFinished:
+			step.Command = releaseStep
+		case eventPaused:
+			step.Command = pauseStep
+		case eventAborted:
+			step.Command = abortStep
+			hungrySince[event.Philosopher] = granted[event.Philosopher]
+		case eventStarved:
+			step.Command, step.HungrySince = starveStep, hungrySince[event.Philosopher]
+			delete(hungrySince, event.Philosopher)
+		default:
+			continue
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
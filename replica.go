@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const heartbeatInterval = 200 * time.Millisecond // the leading Host tells the standby Hosts what was eaten at least this often
+const electionTimeout = time.Second              // a standby Host who has not heard from the leader for this long looks for a new one
+const notTheLeader = "this Host is a standby, the table is led by another replica"
+
+// Replica is one of the Hosts able to serve the same table, a single replica leads the table at a time
+// The replicas know each other from a fixed list of addresses, and the leader is the first replica of the list
+// who is alive when there is no leader yet :
+// - the leader serves the philosophers and streams to the standby replicas how many meals each philosopher has eaten
+// - a standby follows the leader, and refuses the philosophers who then try the next replica
+// - when a standby stops hearing from the leader, he looks for a new one among the other replicas and takes the lead
+// himself when no replica before him in the list is alive
+// The new leader resumes the dinner from the replicated meals, a philosopher who was eating when the leader was lost
+// joins the new leader and eats again the meal he could not finish.
+type Replica struct {
+	mutex   sync.Mutex
+	index   int
+	peers   []string
+	config  Config
+	events  *EventBus
+	logger  Logger
+	term    int
+	eaten   []int
+	server  *TableServer
+	network *SimulatedNetwork
+	done    chan struct{}
+	streams sync.WaitGroup
+}
+
+// PeerState is what a replica learned when trying to follow another replica
+type PeerState int
+
+const (
+	peerUnreachable PeerState = iota
+	peerStandby
+	peerLost
+	peerFinished
+)
+
+// NewReplica creates the replica at the given index of the list of addresses of all the replicas,
+// which writes to the logger when it follows, loses or becomes the leader
+func NewReplica(peers []string, index int, config Config, events *EventBus, logger Logger) (*Replica, error) {
+	if index < 0 || index >= len(peers) {
+		return nil, fmt.Errorf("there is no replica %d among %d replicas", index, len(peers))
+	}
+	if err := checkServedConfig(config); err != nil {
+		return nil, err
+	}
+	return &Replica{
+		index:   index,
+		peers:   peers,
+		config:  config,
+		events:  events,
+		logger:  logger,
+		eaten:   make([]int, config.Philosophers),
+		network: NewSimulatedNetwork(config.Network.Scaled(config.Speed), index),
+		done:    make(chan struct{})}, nil
+}
+
+// Run follows the leader of the table or leads it, until all the philosophers have eaten all their meals
+// The Result only holds the table when this replica was leading it at the end of the dinner
+func (replica *Replica) Run() (Result, error) {
+	listener, err := net.Listen("tcp", replica.peers[replica.index])
+	if err != nil {
+		return Result{}, err
+	}
+	defer listener.Close()
+	go replica.accept(listener)
+
+election:
+	for {
+		var before = false
+		for index, peer := range replica.peers {
+			if index == replica.index {
+				continue
+			}
+			switch replica.follow(peer) {
+			case peerStandby:
+				before = before || index < replica.index
+			case peerLost:
+				continue election
+			case peerFinished:
+				return Result{}, nil
+			}
+		}
+		if !before {
+			return replica.lead(), nil
+		}
+		time.Sleep(heartbeatInterval)
+	}
+}
+
+// follow connects to another replica as a standby, and keeps the meals eaten up to date while he leads the table
+func (replica *Replica) follow(peer string) PeerState {
+	conn, err := net.DialTimeout("tcp", peer, electionTimeout)
+	if err != nil {
+		return peerUnreachable
+	}
+	defer conn.Close()
+
+	var join protoMessage
+	join.Bool(2, true)
+	join.Uint(3, schemaVersion)
+	if err := writeFrame(conn, join); err != nil {
+		return peerUnreachable
+	}
+	conn.SetReadDeadline(time.Now().Add(electionTimeout))
+	frame, err := readFrame(conn)
+	if err != nil {
+		return peerUnreachable
+	}
+	var welcomeError string
+	parseProto(frame, func(number int, varint uint64, bytes []byte) error {
+		if number == 1 {
+			welcomeError = string(bytes)
+		}
+		return nil
+	})
+	if welcomeError != "" {
+		return peerStandby
+	}
+
+	replica.logger.Log(LevelInfo, fmt.Sprintf("Replica %d follows the leader at %s", replica.index, peer),
+		"replica", replica.index, "leader", peer)
+	for {
+		conn.SetReadDeadline(time.Now().Add(electionTimeout))
+		frame, err := readFrame(conn)
+		if err != nil {
+			replica.logger.Log(LevelWarn, fmt.Sprintf("Replica %d lost the leader at %s (%v), looking for a new one", replica.index, peer, err),
+				"replica", replica.index, "leader", peer, "error", err)
+			return peerLost
+		}
+		term, eaten, finished, err := decodeReplicaState(frame)
+		if err != nil {
+			return peerLost
+		}
+		replica.mutex.Lock()
+		var stale = term < replica.term
+		if !stale {
+			replica.term = term
+			copy(replica.eaten, eaten)
+		}
+		replica.mutex.Unlock()
+		if stale {
+			// a Host who lost the lead without knowing it yet
+			return peerLost
+		}
+		if finished {
+			replica.logger.Log(LevelInfo, fmt.Sprintf("Replica %d : the leader at %s has served all the meals", replica.index, peer),
+				"replica", replica.index, "leader", peer)
+			return peerFinished
+		}
+	}
+}
+
+// lead serves the table from the meals eaten so far, until all the philosophers have eaten all their meals
+func (replica *Replica) lead() Result {
+	replica.mutex.Lock()
+	replica.term++
+	var eaten = 0
+	for _, meals := range replica.eaten {
+		eaten += meals
+	}
+	replica.logger.Log(LevelInfo, fmt.Sprintf("Replica %d leads the table (term %d), %d meals have been eaten so far", replica.index, replica.term, eaten),
+		"replica", replica.index, "term", replica.term, "eaten", eaten)
+	replica.server = NewTableServer(replica.config, replica.events, replica.eaten)
+	var server = replica.server
+	replica.mutex.Unlock()
+
+	var result = server.Wait()
+	close(replica.done)
+
+	// give the standby replicas a chance to learn that the dinner is over
+	var streamsDone = make(chan struct{})
+	go func() {
+		replica.streams.Wait()
+		close(streamsDone)
+	}()
+	select {
+	case <-streamsDone:
+	case <-time.After(electionTimeout):
+	}
+	return result
+}
+
+// accept serves the connections of the philosophers and of the standby replicas
+func (replica *Replica) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			join, err := readJoin(conn)
+			if err != nil {
+				refuse(conn, err.Error())
+				return
+			}
+			replica.mutex.Lock()
+			var server = replica.server
+			if server != nil && join.replica {
+				replica.streams.Add(1)
+			}
+			replica.mutex.Unlock()
+
+			switch {
+			case server == nil:
+				refuse(conn, notTheLeader)
+			case join.replica:
+				defer replica.streams.Done()
+				if conn = replica.network.Link(conn, -1); conn != nil {
+					replica.stream(conn, server)
+				}
+			default:
+				if conn = replica.network.Link(conn, join.seat); conn != nil {
+					server.serve(frameConn{conn}, join.seat)
+				}
+			}
+		}()
+	}
+}
+
+// stream sends the meals eaten to a standby replica each time a philosopher eats, and at least every heartbeatInterval
+func (replica *Replica) stream(conn net.Conn, server *TableServer) {
+	defer conn.Close()
+	if err := writeFrame(conn, protoMessage{}); err != nil {
+		return
+	}
+	for {
+		var finished bool
+		select {
+		case <-replica.done:
+			finished = true
+		default:
+		}
+		eaten, changed := server.Eaten()
+		replica.mutex.Lock()
+		var state = encodeReplicaState(replica.term, eaten, finished)
+		replica.mutex.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(electionTimeout))
+		if err := writeFrame(conn, state); err != nil || finished {
+			return
+		}
+		select {
+		case <-changed:
+		case <-replica.done:
+		case <-time.After(heartbeatInterval):
+		}
+	}
+}
+
+// encodeReplicaState encodes what the leader tells the standby replicas as a ReplicaState message
+func encodeReplicaState(term int, eaten []int, finished bool) protoMessage {
+	var message protoMessage
+	message.Int(1, int64(term))
+	var meals = make([]int64, len(eaten))
+	for seat := range eaten {
+		meals[seat] = int64(eaten[seat])
+	}
+	message.Packed(2, meals)
+	message.Bool(3, finished)
+	return message
+}
+
+// decodeReplicaState decodes a ReplicaState message
+func decodeReplicaState(frame []byte) (int, []int, bool, error) {
+	var term int
+	var eaten []int
+	var finished bool
+	err := parseProto(frame, func(number int, varint uint64, bytes []byte) error {
+		switch number {
+		case 1:
+			term = int(varint)
+		case 2:
+			var meals = []uint64{varint}
+			if bytes != nil {
+				var err error
+				if meals, err = parseVarints(bytes); err != nil {
+					return err
+				}
+			}
+			for _, meal := range meals {
+				eaten = append(eaten, int(meal))
+			}
+		case 3:
+			finished = varint != 0
+		}
+		return nil
+	})
+	return term, eaten, finished, err
+}
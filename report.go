@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Below are the formats of the reports written by WriteReport
+const reportMarkdown = "md"
+const reportHTML = "html"
+
+// Below are the widths of the labels and of the bars of the Gantt chart of an HTML report, in pixels
+const ganttLabels = 180
+const ganttPixels = 720
+
+// Report is the summary of a dinner written as a document which holds everything, so that it can be attached to an
+// issue or handed to students : what identifies the run and its configuration, the summary of each table with its
+// rejections by cause and the load of its Hosts, the fairness, the retries and the efficiency of the dinner, the
+// totals of each philosopher, and the Gantt chart of the meals under the Plan of the dinner
+type Report struct {
+	Info         RunInfo
+	Config       Config
+	Tables       []TableReport
+	Fairness     Fairness
+	Retries      Retries
+	Efficiency   *Efficiency
+	Philosophers []PhilosopherReport
+	Plan         *Plan
+	Gantt        []GanttRow
+	Span         time.Duration
+	Starved      []string
+	Violations   []string
+}
+
+// TableReport is the part of a Report about a table : its summary, its rejections by cause, from the most
+// frequent, and the load of the Host of each of its shards
+type TableReport struct {
+	Table      int
+	Summary    string
+	Rejections []Rejection
+	Loads      []HostLoad
+}
+
+// Rejection is how many requests to eat a Host turned down for a cause, and their share of all its rejections
+type Rejection struct {
+	Cause string
+	Count int
+	Share float64
+}
+
+// NewReport gathers the Report of the dinner of the reports, which lasted span, its meals being followed by the
+// Timeline, and its protocol violations
+func NewReport(config Config, info RunInfo, result Result, reports []PhilosopherReport, span time.Duration, timeline *Timeline, violations []string) Report {
+	var report = Report{Info: info, Config: config, Fairness: NewFairness(reports), Retries: NewRetries(config.Backoff, reports),
+		Philosophers: reports, Starved: result.Starved(), Violations: violations}
+	for _, table := range result.Tables {
+		var tableReport = TableReport{Table: table.id, Summary: table.stats.String(), Loads: table.HostLoads()}
+		var total = 0
+		for cause, count := range table.stats.rejected {
+			tableReport.Rejections = append(tableReport.Rejections, Rejection{Cause: cause, Count: count})
+			total += count
+		}
+		for i := range tableReport.Rejections {
+			tableReport.Rejections[i].Share = float64(tableReport.Rejections[i].Count) / float64(total)
+		}
+		sort.Slice(tableReport.Rejections, func(i, j int) bool {
+			var first, second = tableReport.Rejections[i], tableReport.Rejections[j]
+			return first.Count > second.Count || (first.Count == second.Count && first.Cause < second.Cause)
+		})
+		report.Tables = append(report.Tables, tableReport)
+	}
+	if config.ArrivalRate == 0 {
+		var efficiency = NewEfficiency(config, reports, span)
+		report.Efficiency = &efficiency
+		if plan, err := NewPlan(config); err == nil {
+			report.Plan = &plan
+		}
+	}
+	report.Gantt, report.Span = NewGantt(report.Plan, timeline.Meals())
+	return report
+}
+
+// WriteReport writes the Report in the format, md for Markdown or html for a page holding its own style and chart
+func WriteReport(w io.Writer, format string, report Report) error {
+	switch format {
+	case reportMarkdown:
+		return markdownReport.Execute(w, report)
+	case reportHTML:
+		return htmlReport.Execute(w, report)
+	}
+	return fmt.Errorf("report: unknown format %q, expected %s or %s", format, reportMarkdown, reportHTML)
+}
+
+// GanttText returns the Gantt chart of the Report as text, as WriteGantt writes it
+func (report Report) GanttText() string {
+	var meals []ScheduledMeal
+	for _, row := range report.Gantt {
+		if !row.Planned {
+			meals = append(meals, row.Meals...)
+		}
+	}
+	var chart bytes.Buffer
+	WriteGantt(&chart, report.Plan, meals)
+	return chart.String()
+}
+
+// BarX returns where a time of the dinner is drawn on the Gantt chart of an HTML report, in pixels from the left
+// of the chart
+func (report Report) BarX(at time.Duration) float64 {
+	return ganttLabels + float64(at)*ganttPixels/float64(report.Span)
+}
+
+// ChartWidth returns the width of the Gantt chart of an HTML report, in pixels
+func (report Report) ChartWidth() int {
+	return ganttLabels + ganttPixels
+}
+
+// BarWidth returns the width of a meal on the Gantt chart of an HTML report, in pixels, at least one
+func (report Report) BarWidth(meal ScheduledMeal) float64 {
+	return max(report.BarX(meal.End)-report.BarX(meal.Start), 1)
+}
+
+// reportFuncs are the functions of the templates of the reports
+var reportFuncs = map[string]any{
+	"round": func(duration time.Duration) time.Duration { return duration.Round(time.Microsecond) },
+	"mean":  func(total time.Duration, count int64) time.Duration { return meanDuration(total, count) },
+	"percent": func(share float64) string {
+		return fmt.Sprintf("%.1f%%", 100*share)
+	},
+	"cell": func(text string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(text, "|", `\|`), "\n", " ")
+	},
+	"pixels": func(share float64) string { return fmt.Sprintf("%.0fpx", 100*share) },
+	"add":    func(a, b int) int { return a + b },
+	"mul":    func(a, b int) int { return a * b },
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(`# Dinner {{.Info.ID}}
+
+| Run | |
+|---|---|
+| strategy | {{.Info.Strategy}} |
+| config hash | {{.Info.ConfigHash}} |
+| seed | {{.Info.Seed}} |
+| go version | {{.Info.GoVersion}} |
+| philosophers | {{.Config.Philosophers}} at {{.Config.Tables}} table(s), {{.Config.Utensils}} |
+| meals | {{.Config.Meals}} each, at most {{.Config.MaxEaters}} eating at once |
+{{range .Tables}}
+## Table {{.Table}}
+
+{{cell .Summary}}
+{{if .Rejections}}
+| Rejection cause | Requests | Share |
+|---|---:|---:|
+{{range .Rejections}}| {{cell .Cause}} | {{.Count}} | {{percent .Share}} |
+{{end}}{{end}}
+| Shard | Requests | Queue peak | Decisions | Mean latency | Max latency | Busy | Shed |
+|---:|---:|---:|---:|---:|---:|---:|---:|
+{{range .Loads}}| {{.Shard}} | {{.Requests}} | {{.DepthPeak}} | {{.Decisions}} | {{mean .Latency .Decisions}} | {{round .MaxLatency}} | {{round .Busy}} | {{.Shed}} + {{.ShedByHost}} |
+{{end}}{{end}}
+## Dinner
+
+- Fairness : {{cell .Fairness.String}}
+- Retries : {{cell .Retries.String}}
+{{if .Efficiency}}- Optimum : {{cell .Efficiency.String}}
+{{end}}{{if .Plan}}- Plan : {{cell .Plan.String}}
+{{end}}{{if .Starved}}- Starved : {{range $index, $name := .Starved}}{{if $index}}, {{end}}{{cell $name}}{{end}}
+{{end}}{{if .Violations}}- {{len .Violations}} protocol violations
+{{end}}
+| Table | Philosopher | Meals | Accepted | Rejected | Throttled | Preempted | Eating | Waiting | Mean wait | Share of the waits |
+|---:|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|
+{{range $index, $report := .Philosophers}}{{$seat := index $.Fairness.Seats $index}}| {{.Table}} | {{cell .Name}} | {{.Meals}} | {{.Accepted}} | {{.Rejected}} | {{.Throttled}} | {{.Preempted}} | {{round .Eating}} | {{round .Waiting}} | {{round $seat.Wait}} | {{printf "%.2f" $seat.Share}} |
+{{end}}
+## Meals
+
+` + "```" + `
+{{.GanttText}}` + "```" + `
+{{if .Violations}}
+## Protocol violations
+
+{{range .Violations}}- {{cell .}}
+{{end}}{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Dinner {{.Info.ID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.bar { display: inline-block; height: 0.8em; background: #5b8def; }
+.failed { color: #b00020; }
+svg text { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>Dinner {{.Info.ID}}</h1>
+<table>
+<tr><td>strategy</td><td>{{.Info.Strategy}}</td></tr>
+<tr><td>config hash</td><td>{{.Info.ConfigHash}}</td></tr>
+<tr><td>seed</td><td>{{.Info.Seed}}</td></tr>
+<tr><td>go version</td><td>{{.Info.GoVersion}}</td></tr>
+<tr><td>philosophers</td><td>{{.Config.Philosophers}} at {{.Config.Tables}} table(s), {{.Config.Utensils}}</td></tr>
+<tr><td>meals</td><td>{{.Config.Meals}} each, at most {{.Config.MaxEaters}} eating at once</td></tr>
+</table>
+{{range .Tables}}
+<h2>Table {{.Table}}</h2>
+<p>{{.Summary}}</p>
+{{if .Rejections}}<table>
+<tr><th>Rejection cause</th><th>Requests</th><th>Share</th><th></th></tr>
+{{range .Rejections}}<tr><td>{{.Cause}}</td><td>{{.Count}}</td><td>{{percent .Share}}</td><td><span class="bar" style="width: {{pixels .Share}}"></span></td></tr>
+{{end}}</table>{{end}}
+<table>
+<tr><th>Shard</th><th>Requests</th><th>Queue peak</th><th>Decisions</th><th>Mean latency</th><th>Max latency</th><th>Busy</th><th>Shed</th></tr>
+{{range .Loads}}<tr><td>{{.Shard}}</td><td>{{.Requests}}</td><td>{{.DepthPeak}}</td><td>{{.Decisions}}</td><td>{{mean .Latency .Decisions}}</td><td>{{round .MaxLatency}}</td><td>{{round .Busy}}</td><td>{{.Shed}} + {{.ShedByHost}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Dinner</h2>
+<ul>
+<li>Fairness : {{.Fairness.String}}</li>
+<li>Retries : {{.Retries.String}}</li>
+{{if .Efficiency}}<li>Optimum : {{.Efficiency.String}}</li>{{end}}
+{{if .Plan}}<li>Plan : {{.Plan.String}}</li>{{end}}
+{{if .Starved}}<li class="failed">Starved : {{range $index, $name := .Starved}}{{if $index}}, {{end}}{{$name}}{{end}}</li>{{end}}
+{{if .Violations}}<li class="failed">{{len .Violations}} protocol violations</li>{{end}}
+</ul>
+<table>
+<tr><th>Philosopher</th><th>Table</th><th>Meals</th><th>Accepted</th><th>Rejected</th><th>Throttled</th><th>Preempted</th><th>Eating</th><th>Waiting</th><th>Mean wait</th><th>Share of the waits</th></tr>
+{{range $index, $report := .Philosophers}}{{$seat := index $.Fairness.Seats $index}}<tr><td>{{.Name}}</td><td>{{.Table}}</td><td>{{.Meals}}</td><td>{{.Accepted}}</td><td>{{.Rejected}}</td><td>{{.Throttled}}</td><td>{{.Preempted}}</td><td>{{round .Eating}}</td><td>{{round .Waiting}}</td><td>{{round $seat.Wait}}</td><td>{{printf "%.2f" $seat.Share}}</td></tr>
+{{end}}</table>
+<h2>Meals</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.ChartWidth}}" height="{{add 30 (mul 18 (len .Gantt))}}">
+<text x="{{.BarX 0}}" y="12">0</text><text x="{{.ChartWidth}}" y="12" text-anchor="end">{{round .Span}}</text>
+{{range $row, $gantt := .Gantt}}<text x="0" y="{{add 34 (mul 18 $row)}}">{{$gantt.Name}}</text><text x="100" y="{{add 34 (mul 18 $row)}}">{{$gantt.Schedule}}</text>
+{{range $gantt.Meals}}<rect x="{{printf "%.1f" ($.BarX .Start)}}" y="{{add 22 (mul 18 $row)}}" width="{{printf "%.1f" ($.BarWidth .)}}" height="14" fill="{{if $gantt.Planned}}#c7d3e8{{else}}#5b8def{{end}}"><title>{{.Name}} meal {{add .Meal 1}}, {{round .Start}} to {{round .End}}</title></rect>
+{{end}}{{end}}</svg>
+{{if .Violations}}<h2 class="failed">Protocol violations</h2>
+<ul>
+{{range .Violations}}<li>{{.}}</li>
+{{end}}</ul>{{end}}
+</body>
+</html>
+`))
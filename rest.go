@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const maxConfigSize = 1 << 20 // a configuration sent to the REST API is small, anything bigger is refused
+
+// RESTServer serves an HTTP API creating and controlling the simulations of a Registry, each of them
+// being known by its id. The bodies are JSON, the configurations in the format of the -config file :
+// - POST /simulations creates and starts a simulation from the configuration in the body, and answers its id,
+// or 429 when the server already holds as many simulations as it allows
+// - GET /simulations lists the simulations along with their state
+// - GET /simulations/{id}/state tells what the philosophers of a simulation are doing
+// - GET /simulations/{id}/tenants tells what the tenants of each table are doing (see TenantLoad)
+// - GET /simulations/{id}/slos tells how a simulation does against its SLOs so far (see SLOStatus)
+// - POST /simulations/{id}/pause, /resume and /step control a simulation as the WebAssembly build does
+// - GET /simulations/{id}/snapshot answers a Snapshot of a simulation of the discrete engine, to be restored by -restore
+// - DELETE /simulations/{id} stops a simulation and forgets it
+// - GET /metrics tells about all the simulations in the text format of Prometheus
+type RESTServer struct {
+	registry *Registry
+}
+
+// ServeREST listens on the given address and serves the REST API for the simulations of the registry until an error occurs
+func ServeREST(address string, registry *Registry) error {
+	var server = NewRESTServer(registry)
+	return http.ListenAndServe(address, server.Handler())
+}
+
+// NewRESTServer creates a RESTServer controlling the simulations of the registry
+func NewRESTServer(registry *Registry) *RESTServer {
+	return &RESTServer{registry: registry}
+}
+
+// Handler returns the routes of the API
+func (server *RESTServer) Handler() http.Handler {
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/simulations", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			server.create(w, r)
+		case http.MethodGet:
+			server.list(w, r)
+		default:
+			writeREST(w, 0, nil, restError{http.StatusMethodNotAllowed, r.Method + " is not allowed on /simulations"})
+		}
+	})
+	mux.HandleFunc("/simulations/", server.route)
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeREST(w, 0, nil, restError{http.StatusMethodNotAllowed, r.Method + " is not allowed on /metrics"})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		server.registry.WriteMetrics(w)
+	})
+	return mux
+}
+
+// route dispatches the requests about a single simulation, /simulations/{id} and /simulations/{id}/{action}
+func (server *RESTServer) route(w http.ResponseWriter, r *http.Request) {
+	var id, action, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/simulations/"), "/")
+	var simulation = server.registry.Get(id)
+	if simulation == nil {
+		writeREST(w, 0, nil, restError{http.StatusNotFound, fmt.Sprintf("no simulation %q", id)})
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodDelete && action == "":
+		server.registry.Remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && action == "state":
+		writeREST(w, http.StatusOK, simulation.State(), nil)
+	case r.Method == http.MethodGet && action == "tenants":
+		writeREST(w, http.StatusOK, simulation.TenantLoads(), nil)
+	case r.Method == http.MethodGet && action == "slos":
+		writeREST(w, http.StatusOK, simulation.SLOs(), nil)
+	case r.Method == http.MethodPost && action == "pause":
+		if err := running(simulation); err != nil {
+			writeREST(w, 0, nil, err)
+			return
+		}
+		simulation.Pause()
+		writeREST(w, http.StatusOK, simulation.State(), nil)
+	case r.Method == http.MethodPost && action == "resume":
+		simulation.Resume()
+		writeREST(w, http.StatusOK, simulation.State(), nil)
+	case r.Method == http.MethodGet && action == "snapshot":
+		snapshot, err := simulation.Snapshot()
+		if err != nil {
+			writeREST(w, 0, nil, restError{http.StatusConflict, err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(snapshot)
+	case r.Method == http.MethodPost && action == "step":
+		if err := running(simulation); err != nil {
+			writeREST(w, 0, nil, err)
+			return
+		}
+		simulation.Step()
+		writeREST(w, http.StatusOK, simulation.State(), nil)
+	default:
+		writeREST(w, 0, nil, restError{http.StatusNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path)})
+	}
+}
+
+// restError is an error along with the HTTP status to answer
+type restError struct {
+	status  int
+	message string
+}
+
+func (err restError) Error() string {
+	return err.message
+}
+
+// create handles POST /simulations
+func (server *RESTServer) create(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxConfigSize))
+	if err != nil {
+		writeREST(w, http.StatusBadRequest, nil, err)
+		return
+	}
+	config, err := ParseConfig(body, Config{})
+	if err != nil {
+		writeREST(w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	id, simulation, err := server.registry.Create(config)
+	if err != nil {
+		if limitErr, ok := err.(LimitError); ok && limitErr.busy {
+			err = restError{http.StatusTooManyRequests, err.Error()}
+		}
+		writeREST(w, 0, nil, err)
+		return
+	}
+
+	w.Header().Set("Location", "/simulations/"+id)
+	writeREST(w, http.StatusCreated, SimulationInfo{ID: id, Run: simulation.Info(), Config: config}, nil)
+}
+
+// list handles GET /simulations
+func (server *RESTServer) list(w http.ResponseWriter, r *http.Request) {
+	writeREST(w, http.StatusOK, server.registry.List(), nil)
+}
+
+// running checks that the dinner of the simulation is not over
+func running(simulation *Simulation) error {
+	select {
+	case <-simulation.Done():
+		return restError{http.StatusConflict, ErrShutdown.Error()}
+	default:
+		return nil
+	}
+}
+
+// writeREST answers the body in JSON with the given status, or the error along with its status
+func writeREST(w http.ResponseWriter, status int, body any, err error) {
+	if err != nil {
+		status = http.StatusBadRequest
+		if restErr, ok := err.(restError); ok {
+			status = restErr.status
+		}
+		body = map[string]string{"error": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultRotatedFiles = 3 // files kept besides the current one when a file rotates, by default
+
+// RotatingFile is a file which is rotated once it grows beyond its size, so that a trace can be recorded for as long
+// as a dinner lasts : events.jsonl is renamed events.jsonl.1, events.jsonl.1 is renamed events.jsonl.2 and so on,
+// the files beyond keep being removed, and a new events.jsonl is created. The file only rotates at the end of a line,
+// so that no line is split between two files.
+type RotatingFile struct {
+	path string
+	size int64
+	keep int
+	file *os.File
+	used int64
+}
+
+// NewRotatingFile creates the file of the given path, which rotates once it holds size bytes, keeping the given
+// number of older files
+func NewRotatingFile(path string, size int64, keep int) (*RotatingFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("rotate: %v", err)
+	}
+	return &RotatingFile{path: path, size: size, keep: keep, file: file}, nil
+}
+
+// Write writes the bytes, rotating the file after the last line ending which fits, or after the first one when
+// a single line does not fit
+func (rotating *RotatingFile) Write(data []byte) (int, error) {
+	var written = 0
+	for rotating.used+int64(len(data)) >= rotating.size {
+		var room = min(max(rotating.size-rotating.used, 0), int64(len(data)))
+		var end = bytes.LastIndexByte(data[:room], '\n') + 1
+		if end == 0 {
+			end = bytes.IndexByte(data, '\n') + 1
+		}
+		if end == 0 {
+			break
+		}
+		n, err := rotating.file.Write(data[:end])
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("rotate: %v", err)
+		}
+		if err := rotating.rotate(); err != nil {
+			return written, err
+		}
+		data = data[end:]
+	}
+	n, err := rotating.file.Write(data)
+	rotating.used += int64(n)
+	if err != nil {
+		return written + n, fmt.Errorf("rotate: %v", err)
+	}
+	return written + n, nil
+}
+
+// rotate renames the current file and the older ones, removing the oldest one, and creates a new file
+func (rotating *RotatingFile) rotate() error {
+	if err := rotating.file.Close(); err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	os.Remove(fmt.Sprintf("%s.%d", rotating.path, rotating.keep))
+	for i := rotating.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rotating.path, i), fmt.Sprintf("%s.%d", rotating.path, i+1))
+	}
+	if rotating.keep > 0 {
+		os.Rename(rotating.path, rotating.path+".1")
+	}
+	file, err := os.Create(rotating.path)
+	if err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	rotating.file, rotating.used = file, 0
+	return nil
+}
+
+// Close closes the current file
+func (rotating *RotatingFile) Close() error {
+	return rotating.file.Close()
+}
+
+// ParseByteSize parses a size such as 512KB, 10MB or 1GB, in powers of 1024, or a number of bytes
+func ParseByteSize(text string) (int64, error) {
+	var units = []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	var number, unit = strings.ToUpper(strings.TrimSpace(text)), int64(1)
+	for _, candidate := range units {
+		if strings.HasSuffix(number, candidate.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, candidate.suffix)), candidate.size
+			break
+		}
+	}
+	value, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("invalid size %q, expected such as 512KB, 10MB or 1GB", text)
+	}
+	return value * unit, nil
+}
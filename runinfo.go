@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ" // the base 32 alphabet of the ULIDs
+
+// RunInfo identifies a run of the dinner, it is stamped into the events, the logs, the Store, the exports and
+// the metrics so that the outputs of different runs can be correlated and deduplicated :
+// - the id of the run, a ULID (https://github.com/ulid/spec) sorting the runs by the time they started
+// - the hash of the configuration without its seed, the same for the runs of the same dinner
+// - the strategy of the dinner, its admission along with the Strategy of the Host unless it is the greedy one,
+// its engine and its execution
+// - the seed of the dinner, which makes the runs of the same configuration and seed comparable
+// - the version of Go which ran the dinner
+type RunInfo struct {
+	ID         string `json:"id"`
+	ConfigHash string `json:"configHash"`
+	Strategy   string `json:"strategy"`
+	Seed       int64  `json:"seed"`
+	GoVersion  string `json:"goVersion"`
+}
+
+// NewRunInfo creates the RunInfo of a new run of the dinner of a validated configuration
+func NewRunInfo(config Config) RunInfo {
+	var admission = config.Admission
+	if config.Strategy != greedyStrategy {
+		admission += ":" + config.Strategy
+	}
+	return RunInfo{
+		ID:         NewULID(time.Now()),
+		ConfigHash: ConfigHash(config),
+		Strategy:   fmt.Sprintf("%s/%s/%s", admission, config.Engine, config.Execution),
+		Seed:       config.Seed,
+		GoVersion:  runtime.Version()}
+}
+
+// ConfigHash returns the first 12 hexadecimal digits of the SHA-256 of the configuration in JSON, its seed left out
+func ConfigHash(config Config) string {
+	config.Seed = 0
+	data, _ := json.Marshal(config)
+	var sum = sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// NewULID returns a new ULID for the given time : 48 bits of milliseconds since the epoch then 80 random bits,
+// written in 26 characters of Crockford's base 32
+func NewULID(at time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(at.UnixMilli())<<16)
+	rand.Read(id[6:])
+
+	// 130 bits are written, the two leading ones being 0
+	var text [26]byte
+	var high, low = binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		text[i] = crockford[low&31]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return string(text[:])
+}
+
+// Fields returns the RunInfo as the alternating keys and values of a Logger
+func (info RunInfo) Fields() []any {
+	return []any{"run", info.ID, "configHash", info.ConfigHash, "strategy", info.Strategy, "seed", info.Seed, "goVersion", info.GoVersion}
+}
+
+// String gives a one line summary of the RunInfo
+func (info RunInfo) String() string {
+	return fmt.Sprintf("%s, config %s, strategy %s, seed %d, %s", info.ID, info.ConfigHash, info.Strategy, info.Seed, info.GoVersion)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SeatSettings overrides the settings of the dinner for the philosopher of one seat, the settings left empty
+// being the ones of the dinner :
+// - meals is how many times he eats
+// - eat and think are how long each of his meals lasts and how long he thinks before he gets hungry, instead of
+// being drawn at random (the draws still take place, so that the other philosophers draw the same durations)
+// - priority replaces his priority in priorities
+// - tenant is the group he belongs to, whose philosophers eating at once are limited by its quota in tenantQuotas
+type SeatSettings struct {
+	Seat     int      `json:"seat"`
+	Meals    int      `json:"meals,omitempty"`
+	Eat      Duration `json:"eat,omitempty"`
+	Think    Duration `json:"think,omitempty"`
+	Priority *int     `json:"priority,omitempty"`
+	Tenant   string   `json:"tenant,omitempty"`
+}
+
+// ParseSeatSettings parses the settings of a seat written as on the command line, the seat followed by the
+// settings separated by commas, such as "3:eat=2s,meals=10,priority=5"
+func ParseSeatSettings(text string) (SeatSettings, error) {
+	var settings SeatSettings
+	seat, fields, found := strings.Cut(text, ":")
+	var err error
+	if settings.Seat, err = strconv.Atoi(strings.TrimSpace(seat)); err != nil || !found {
+		return settings, fmt.Errorf("config: the settings of a seat should look like 3:eat=2s,meals=10,priority=5, got %q", text)
+	}
+	for _, field := range strings.Split(fields, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		var duration time.Duration
+		var number int
+		switch name {
+		case "meals":
+			number, err = strconv.Atoi(value)
+			settings.Meals = number
+		case "eat":
+			duration, err = time.ParseDuration(value)
+			settings.Eat = Duration(duration)
+		case "think":
+			duration, err = time.ParseDuration(value)
+			settings.Think = Duration(duration)
+		case "priority":
+			number, err = strconv.Atoi(value)
+			settings.Priority = &number
+		case "tenant":
+			settings.Tenant = value
+		default:
+			return settings, fmt.Errorf("config: unknown setting %q of seat %d, expected meals, eat, think, priority or tenant", name, settings.Seat)
+		}
+		if err != nil {
+			return settings, fmt.Errorf("config: invalid %s of seat %d: %v", name, settings.Seat, err)
+		}
+	}
+	return settings, nil
+}
+
+// merge replaces the settings by the ones set in overrides
+func (settings *SeatSettings) merge(overrides SeatSettings) {
+	if overrides.Meals != 0 {
+		settings.Meals = overrides.Meals
+	}
+	if overrides.Eat != 0 {
+		settings.Eat = overrides.Eat
+	}
+	if overrides.Think != 0 {
+		settings.Think = overrides.Think
+	}
+	if overrides.Priority != nil {
+		settings.Priority = overrides.Priority
+	}
+	if overrides.Tenant != "" {
+		settings.Tenant = overrides.Tenant
+	}
+}
+
+// mergeSeats merges the settings of the seats given in overrides over the ones of the configuration
+func (config *Config) mergeSeats(overrides []SeatSettings) {
+	for _, override := range overrides {
+		var merged = false
+		for i := range config.Seats {
+			if config.Seats[i].Seat == override.Seat {
+				config.Seats[i].merge(override)
+				merged = true
+			}
+		}
+		if !merged {
+			config.Seats = append(config.Seats, override)
+		}
+	}
+}
+
+// validateSeats checks the settings of the seats
+func (config Config) validateSeats() error {
+	var seen = make(map[int]bool)
+	for _, settings := range config.Seats {
+		if settings.Seat < 0 || settings.Seat >= config.Philosophers {
+			return fmt.Errorf("config: settings given for seat %d, the table has seats 0 to %d", settings.Seat, config.Philosophers-1)
+		}
+		if seen[settings.Seat] {
+			return fmt.Errorf("config: the settings of seat %d are given twice", settings.Seat)
+		}
+		seen[settings.Seat] = true
+		if settings.Meals < 0 || settings.Eat < 0 || settings.Think < 0 {
+			return fmt.Errorf("config: the meals, eat and think of seat %d cannot be negative", settings.Seat)
+		}
+		if settings.Meals > 0 && config.ArrivalRate > 0 {
+			return fmt.Errorf("config: the meals of seat %d cannot be set in the open mode, where each guest draws his own", settings.Seat)
+		}
+	}
+	return nil
+}
+
+// Seat returns the settings of the given seat, empty when none are given
+func (config Config) Seat(seat int) SeatSettings {
+	for _, settings := range config.Seats {
+		if settings.Seat == seat {
+			return settings
+		}
+	}
+	return SeatSettings{Seat: seat}
+}
+
+// MealsOf returns how many meals the philosopher of the given seat eats
+func (config Config) MealsOf(seat int) int {
+	if meals := config.Seat(seat).Meals; meals > 0 {
+		return meals
+	}
+	return config.Meals
+}
+
+// SeatMeals returns how many meals the philosopher of each seat eats
+func (config Config) SeatMeals() []int {
+	var meals = make([]int, config.Philosophers)
+	for seat := range meals {
+		meals[seat] = config.MealsOf(seat)
+	}
+	return meals
+}
+
+// TotalMeals returns how many meals the philosophers of a table eat
+func (config Config) TotalMeals() int {
+	var total = 0
+	for seat := 0; seat < config.Philosophers; seat++ {
+		total += config.MealsOf(seat)
+	}
+	return total
+}
@@ -0,0 +1,50 @@
+package main
+
+// SeatSet is a set of seats of a table, such as the seats of the philosophers eating. It is a sparse set :
+// adding, removing and testing a seat take constant time, and going over the members only visits the members,
+// where a bitset would scan every seat of the table. It keeps the Host fast with 100k philosophers.
+type SeatSet struct {
+	dense  []int // the members, in no particular order
+	sparse []int // sparse[seat] is the index of the seat in dense when it is a member
+}
+
+// NewSeatSet creates an empty set for a table of the given number of seats
+func NewSeatSet(seats int) *SeatSet {
+	return &SeatSet{dense: make([]int, 0, seats), sparse: make([]int, seats)}
+}
+
+// Has tells if the seat is a member of the set
+func (set *SeatSet) Has(seat int) bool {
+	var index = set.sparse[seat]
+	return index < len(set.dense) && set.dense[index] == seat
+}
+
+// Add makes the seat a member of the set
+func (set *SeatSet) Add(seat int) {
+	if set.Has(seat) {
+		return
+	}
+	set.sparse[seat] = len(set.dense)
+	set.dense = append(set.dense, seat)
+}
+
+// Remove takes the seat out of the set, the last member takes its place
+func (set *SeatSet) Remove(seat int) {
+	if !set.Has(seat) {
+		return
+	}
+	var index, last = set.sparse[seat], set.dense[len(set.dense)-1]
+	set.dense[index] = last
+	set.sparse[last] = index
+	set.dense = set.dense[:len(set.dense)-1]
+}
+
+// Len returns how many seats are members of the set
+func (set *SeatSet) Len() int {
+	return len(set.dense)
+}
+
+// Members returns the seats of the set in no particular order, the slice must not be modified
+func (set *SeatSet) Members() []int {
+	return set.dense
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Simulation is a whole dinner : the tables described by the configuration, their Hosts and philosophers,
+// the Kitchen they share, and the EventBus telling everything that happens. It allows the dinner to be
+// controlled and observed from the outside, by the command line as well as by the gRPC server.
+// A paused Simulation holds each event before it is delivered, which stops every philosopher and Host
+// as soon as they have something to tell, Step then lets the events through one at a time.
+// With the discrete engine, the DiscreteEngine simulates the philosophers of all the tables instead of their goroutines,
+// and the dinner can be saved in a Snapshot and restored.
+// When its configuration enables it, the CapacityController adjusts the capacity of the tables while the dinner takes place.
+type Simulation struct {
+	mutex   sync.Mutex
+	started bool
+	closing bool
+	stopped bool
+	gate    sync.Mutex // guards paused and resume, apart from mutex which is held while talking to the Hosts
+	paused  bool
+	resume  chan struct{}
+	steps   chan struct{}
+	config  Config
+	info    RunInfo
+	events  *EventBus
+	state   *StateTracker
+	tables  []*Table
+	kitchen *Kitchen
+	engine  *DiscreteEngine
+	control *CapacityController
+	slos    *SLOTracker
+	done    chan struct{}
+	result  Result
+}
+
+// Result is the outcome of a Simulation, it holds the Stats of each table, and why the dinner ended before all the
+// meals were eaten, empty when they were (see Completion)
+type Result struct {
+	Tables []*Table
+	Ended  string
+}
+
+// NewSimulation prepares a dinner according to a validated configuration, nothing happens until Start is called
+// so that handlers and subscribers can be added to the EventBus without missing any event
+func NewSimulation(config Config) *Simulation {
+	var events = NewEventBus()
+	var simulation = &Simulation{config: config, info: NewRunInfo(config), events: events, state: NewStateTracker(config.SeatMeals()), steps: make(chan struct{}, 1), done: make(chan struct{})}
+	events.SetRun(simulation.info.ID)
+	events.Handle(simulation.hold)
+	events.Handle(simulation.state.Track)
+
+	// The shared rice pot, only when the configuration asks for one
+	if config.PotCapacity > 0 {
+		simulation.kitchen = NewKitchen(config.PotCapacity, events)
+	}
+
+	simulation.tables = make([]*Table, config.Tables)
+	for table := range simulation.tables {
+		simulation.tables[table] = NewTable(table, config, simulation.kitchen, events)
+	}
+	if config.Engine == discreteEngine {
+		simulation.engine = NewDiscreteEngine(simulation.tables, events)
+	}
+	simulation.slos = NewSLOTracker(config)
+	if simulation.slos != nil {
+		events.Handle(simulation.slos.Handle)
+	}
+	simulation.control = NewCapacityController(config, simulation)
+	if simulation.control != nil {
+		simulation.Observe()
+		events.Handle(simulation.control.Handle)
+	}
+	return simulation
+}
+
+// Events returns the EventBus of the simulation
+func (simulation *Simulation) Events() *EventBus {
+	return simulation.events
+}
+
+// Info returns the RunInfo identifying the run of the simulation
+func (simulation *Simulation) Info() RunInfo {
+	return simulation.info
+}
+
+// Config returns the configuration of the simulation
+func (simulation *Simulation) Config() Config {
+	simulation.mutex.Lock()
+	defer simulation.mutex.Unlock()
+	return simulation.config
+}
+
+// Start starts the Kitchen and the tables, then closes everything in the background once all the philosophers have finished
+func (simulation *Simulation) Start() {
+	simulation.mutex.Lock()
+	simulation.started = true
+	simulation.mutex.Unlock()
+	if simulation.kitchen != nil {
+		go simulation.kitchen.Run()
+	}
+	var completion = NewCompletion(simulation.tables)
+	simulation.events.Handle(completion.Handle)
+	go completion.Run()
+	for _, table := range simulation.tables {
+		if simulation.engine != nil {
+			table.startHosts()
+		} else {
+			table.Start(completion)
+		}
+	}
+	if simulation.engine != nil {
+		simulation.engine.Start(completion)
+	}
+	if simulation.control != nil {
+		go simulation.control.Run(simulation.done)
+	}
+
+	go func() {
+		// Wait for all the philosophers to eat all their meals, or to leave once the dinner should end
+		select {
+		case <-completion.Over():
+			simulation.Stop()
+			<-completion.Done()
+		case <-completion.Done():
+		}
+
+		simulation.mutex.Lock()
+		simulation.closing = true
+		simulation.mutex.Unlock()
+
+		for _, table := range simulation.tables {
+			table.Close()
+		}
+		if simulation.kitchen != nil {
+			simulation.kitchen.Close()
+		}
+		completion.Close()
+		simulation.result = Result{Tables: simulation.tables, Ended: completion.Reason()}
+		simulation.state.Finish(simulation.result.Failed())
+		simulation.events.Close()
+		close(simulation.done)
+	}()
+}
+
+// Wait waits for the end of the dinner and returns its Result
+func (simulation *Simulation) Wait() Result {
+	<-simulation.done
+	return simulation.result
+}
+
+// Done is closed once the dinner is over
+func (simulation *Simulation) Done() <-chan struct{} {
+	return simulation.done
+}
+
+// Run starts the dinner and waits for its end
+func (simulation *Simulation) Run() Result {
+	simulation.Start()
+	return simulation.Wait()
+}
+
+// Observe asks the Hosts to publish their HostState after each message from now on, for State : copying the records of
+// a Host after each of its messages is only worth it for a dinner watched from the outside, such as by the gRPC server
+func (simulation *Simulation) Observe() {
+	for _, table := range simulation.tables {
+		table.observed.Store(true)
+	}
+}
+
+// State returns a copy of what the philosophers are doing, as told by the events emitted so far, along with the
+// HostState published by each Host between two messages when the dinner is observed (see Observe) : the seats eating,
+// the utensils they hold and the counters of the Host. The Hosts have the last word on who eats, so that the phases agree with the holders of the utensils :
+// a philosopher whose meal the Host granted is at least waiting, and one whose meal the Host saw end is thinking.
+// Nothing is read from the records of the Hosts while they change them, and a paused dinner does not block it.
+func (simulation *Simulation) State() State {
+	var state = simulation.state.State()
+	simulation.gate.Lock()
+	state.Paused = simulation.paused
+	simulation.gate.Unlock()
+	type seat struct{ table, seat int }
+	var eating = make(map[seat]bool)
+	var lockFree = make(map[int]bool)
+	for _, table := range simulation.tables {
+		lockFree[table.id] = table.admission != nil
+		for _, shard := range table.shards {
+			state.QueueDepth += len(shard.requestChan)
+			var host = shard.published.Load()
+			if host == nil {
+				continue
+			}
+			var published = *host
+			published.Mailbox = len(shard.requestChan)
+			state.Hosts = append(state.Hosts, published)
+			state.Accepted += published.Accepted
+			state.Rejected += published.Rejected
+			for _, eater := range published.Eating {
+				eating[seat{table.id, eater}] = true
+			}
+		}
+	}
+	for i := range state.Philosophers {
+		var philosopher = &state.Philosophers[i]
+		if lockFree[philosopher.Table] {
+			// the meals eaten without asking the Host are only known by their events
+			continue
+		}
+		var granted = eating[seat{philosopher.Table, philosopher.Philosopher}]
+		switch {
+		case granted && (philosopher.Phase == PhaseThinking || philosopher.Phase == PhaseHungry):
+			philosopher.Phase = PhaseWaiting
+		case !granted && (philosopher.Phase == PhaseWaiting || philosopher.Phase == PhaseEating):
+			philosopher.Phase = PhaseThinking
+		}
+	}
+	return state
+}
+
+// Pause holds the events from now on, until Resume or Stop is called, the Liveness of the tables being suspended meanwhile
+func (simulation *Simulation) Pause() {
+	simulation.gate.Lock()
+	defer simulation.gate.Unlock()
+	if !simulation.paused {
+		simulation.paused = true
+		simulation.resume = make(chan struct{})
+		for _, table := range simulation.tables {
+			table.liveness.Suspend(true)
+		}
+	}
+}
+
+// Resume lets the events of a paused Simulation go on
+func (simulation *Simulation) Resume() {
+	simulation.gate.Lock()
+	defer simulation.gate.Unlock()
+	if simulation.paused {
+		simulation.paused = false
+		close(simulation.resume)
+		for _, table := range simulation.tables {
+			table.liveness.Suspend(false)
+		}
+	}
+}
+
+// Paused tells if the events of the Simulation are held
+func (simulation *Simulation) Paused() bool {
+	simulation.gate.Lock()
+	defer simulation.gate.Unlock()
+	return simulation.paused
+}
+
+// Step lets the next event of a paused Simulation through, it does not wait for it to be emitted
+func (simulation *Simulation) Step() {
+	select {
+	case simulation.steps <- struct{}{}:
+	default:
+	}
+}
+
+// hold is the first handler of the EventBus, it blocks the event while the Simulation is paused
+func (simulation *Simulation) hold(Event) {
+	simulation.gate.Lock()
+	var paused, resume = simulation.paused, simulation.resume
+	simulation.gate.Unlock()
+	if paused {
+		select {
+		case <-simulation.steps:
+		case <-resume:
+		}
+	}
+}
+
+// Stop ends the dinner before all the meals are eaten : the Hosts revoke the meals being eaten, whose philosophers
+// release their utensils at once, and answer the requests to eat with a shutdown, so that the philosophers leave the
+// table. The meals of the worker pool and of the lock-free admission are left to end. It also resumes a paused Simulation.
+func (simulation *Simulation) Stop() {
+	simulation.Resume()
+	simulation.mutex.Lock()
+	defer simulation.mutex.Unlock()
+	if simulation.closing || simulation.stopped {
+		return
+	}
+	simulation.stopped = true
+	for _, table := range simulation.tables {
+		table.Stop()
+	}
+}
+
+// SetMaxEaters changes how many philosophers the Hosts allow to eat at the same time while the dinner takes place
+func (simulation *Simulation) SetMaxEaters(maxEaters int) error {
+	if maxEaters < 1 {
+		return fmt.Errorf("the Host must allow at least one philosopher to eat, got %d", maxEaters)
+	}
+	simulation.mutex.Lock()
+	defer simulation.mutex.Unlock()
+	if simulation.closing {
+		return ErrShutdown
+	}
+	for _, table := range simulation.tables {
+		for _, shard := range table.shards {
+			shard.host.Tell(SetMaxEaters{maxEaters: maxEaters})
+		}
+	}
+	simulation.config.MaxEaters = maxEaters
+	return nil
+}
+
+// Starved returns the names of the philosophers who starved, whatever their table
+func (result Result) Starved() []string {
+	var starved []string
+	for _, table := range result.Tables {
+		starved = append(starved, table.stats.starved...)
+	}
+	return starved
+}
+
+// Failed tells if the dinner failed, which happens when a philosopher starved or panicked
+func (result Result) Failed() bool {
+	return len(result.Starved()) > 0 || len(result.Failures()) > 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// dinner is the outcome of a dinner run by a test : its configuration and Result, the reports of the philosophers,
+// how long the dinner lasted, and the longest wait of each philosopher before one of his meals, from the moment he
+// got hungry, all in the time of the dinner
+type dinner struct {
+	config      Config
+	result      Result
+	reports     []PhilosopherReport
+	span        time.Duration
+	longestWait map[string]time.Duration
+}
+
+// report returns the report of the philosopher of a seat of the first table
+func (dinner dinner) report(seat int) PhilosopherReport {
+	for _, report := range dinner.reports {
+		if report.Table == 0 && report.Philosopher == seat {
+			return report
+		}
+	}
+	return PhilosopherReport{}
+}
+
+// meals sums the meals eaten by the philosophers
+func (dinner dinner) meals() int {
+	var meals = 0
+	for _, report := range dinner.reports {
+		meals += report.Meals
+	}
+	return meals
+}
+
+// runExample runs the dinner of a file of the examples directory with the seed, failing the test when it does not
+// end within a minute
+func runExample(t *testing.T, path string, seed int64) dinner {
+	t.Helper()
+	config, err := LoadConfig(path, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Seed = seed
+
+	var run = dinner{config: config, longestWait: make(map[string]time.Duration)}
+	var simulation = NewSimulation(config)
+	var recorder = NewRecorder(false)
+	var hungrySince = make(map[string]time.Duration)
+	simulation.Events().SetQuiet()
+	simulation.Events().Handle(recorder.Record)
+	simulation.Events().Handle(func(event Event) {
+		// the handlers are called one event at a time
+		switch event.Kind {
+		case eventRejected, eventThrottled, eventPaused:
+			if _, hungry := hungrySince[event.Name]; !hungry {
+				hungrySince[event.Name] = event.Elapsed
+			}
+		case eventStarted:
+			if since, hungry := hungrySince[event.Name]; hungry {
+				run.longestWait[event.Name] = max(run.longestWait[event.Name], event.Elapsed-since)
+				delete(hungrySince, event.Name)
+			}
+		}
+		run.span = event.Elapsed
+	})
+	var timer = time.AfterFunc(time.Minute, simulation.Stop)
+	run.result = simulation.Run()
+	if !timer.Stop() {
+		t.Fatalf("%s with seed %d : the dinner was stopped after a minute", path, seed)
+	}
+	run.reports = recorder.Reports()
+	return run
+}
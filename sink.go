@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Below are the kinds of Sinks the -sink flag creates
+const consoleSink = "console"       // the lines of the console on the standard output, through the -log Logger
+const fileSink = "file"             // the lines of the console in a file, through a Logger of the -log format
+const jsonlSink = "jsonl"           // the events in JSON, one per line, in a file or on the standard output with -
+const sqliteSink = "sqlite"         // the events saved along with the run in a Store
+const natsSink = "nats"             // the events published to a NATS server
+const prometheusSink = "prometheus" // the number of events of each kind, served to Prometheus on an address
+
+// Sink is where the events of a dinner end up. A Sink is written from a single goroutine, and is flushed once no
+// event is waiting for it, so that it can buffer its writes.
+type Sink interface {
+	// Write writes an event
+	Write(event Event) error
+	// Flush writes out the events written so far
+	Flush() error
+	// Close flushes the Sink and releases it
+	Close() error
+}
+
+// SinkSpec describes a Sink, as given to the -sink flag, kind:target;filter=expression;buffer=size :
+// - the kind of the Sink and its target, a file, a database or an address depending on the kind
+// - the Filter selecting the events written to it, all of them by default
+// - sample, to write only one of every sample events selected, 0 or 1 writing them all
+// - its buffer, how many events can wait for it before it misses some, 0 for a Sink written as the events are
+// emitted, which never misses one but slows the dinner down when it is slow
+// - for the files, rotate, the size beyond which the file rotates (see RotatingFile), and keep, how many older
+// files are kept, so that a Sink can stay on for as long as a dinner lasts
+type SinkSpec struct {
+	Kind   string
+	Target string
+	Filter *Filter
+	Sample int
+	Buffer int
+	Rotate int64
+	Keep   int
+}
+
+// ParseSinkSpec parses the description of a Sink, such as jsonl:events.jsonl;filter=event==starved;buffer=1024
+func ParseSinkSpec(text string) (SinkSpec, error) {
+	var parts = strings.Split(text, ";")
+	var spec = SinkSpec{Keep: defaultRotatedFiles}
+	spec.Kind, spec.Target, _ = strings.Cut(parts[0], ":")
+	switch spec.Kind {
+	case consoleSink:
+	case natsSink:
+		spec.Buffer = natsBufferSize
+	case fileSink, jsonlSink, sqliteSink, prometheusSink:
+		if spec.Target == "" {
+			return spec, fmt.Errorf("sink: %s needs a target, such as %s:%s", spec.Kind, spec.Kind, sinkExamples[spec.Kind])
+		}
+	default:
+		return spec, fmt.Errorf("sink: unknown kind %q, expected %s, %s, %s, %s, %s or %s", spec.Kind, consoleSink, fileSink,
+			jsonlSink, sqliteSink, natsSink, prometheusSink)
+	}
+	for _, option := range parts[1:] {
+		var name, value, _ = strings.Cut(option, "=")
+		name = strings.TrimSpace(name)
+		switch name {
+		case "filter":
+			filter, err := ParseFilter(value)
+			if err != nil {
+				return spec, fmt.Errorf("sink: %v", err)
+			}
+			spec.Filter = filter
+		case "buffer", "sample", "keep":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 0 {
+				return spec, fmt.Errorf("sink: invalid %s %q, expected a number", name, value)
+			}
+			switch name {
+			case "buffer":
+				spec.Buffer = count
+			case "sample":
+				spec.Sample = count
+			default:
+				spec.Keep = count
+			}
+		case "rotate":
+			size, err := ParseByteSize(value)
+			if err != nil {
+				return spec, fmt.Errorf("sink: %v", err)
+			}
+			spec.Rotate = size
+		default:
+			return spec, fmt.Errorf("sink: unknown option %q, expected filter, sample, buffer, rotate or keep", name)
+		}
+	}
+	if spec.Rotate > 0 && spec.Kind != fileSink && (spec.Kind != jsonlSink || spec.Target == "-") {
+		return spec, fmt.Errorf("sink: only the files of the file and jsonl sinks rotate")
+	}
+	return spec, nil
+}
+
+// sinkExamples are examples of the targets of each kind of Sink
+var sinkExamples = map[string]string{fileSink: "dinner.log", jsonlSink: "events.jsonl", sqliteSink: "runs.db",
+	natsSink: "nats://localhost:4222", prometheusSink: ":9100"}
+
+// SinkOptions are the settings of the program the Sinks follow : the Messages writing the lines of the console, the
+// Logger of the standard output and the format of the -log flag, and the subject and encoding of the NATS messages
+type SinkOptions struct {
+	Messages     *Messages
+	Logger       Logger
+	LogName      string
+	NATSSubject  string
+	NATSEncoding string
+}
+
+// NewSink creates the Sink of a SinkSpec
+func NewSink(spec SinkSpec, options SinkOptions) (Sink, error) {
+	switch spec.Kind {
+	case consoleSink:
+		return &LogSink{log: LogEvents(options.Logger, options.Messages)}, nil
+	case fileSink:
+		file, err := createSinkFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		var writer = bufio.NewWriter(file)
+		logger, err := NewLogger(options.LogName, writer)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &LogSink{log: LogEvents(logger, options.Messages), writer: writer, closer: file}, nil
+	case jsonlSink:
+		var output io.WriteCloser = os.Stdout
+		if spec.Target != "-" {
+			file, err := createSinkFile(spec)
+			if err != nil {
+				return nil, err
+			}
+			output = file
+		}
+		var writer = bufio.NewWriter(output)
+		return &JSONLSink{writer: writer, encoder: json.NewEncoder(writer), closer: output}, nil
+	case sqliteSink:
+		store, err := OpenStore(spec.Target)
+		if err != nil {
+			return nil, err
+		}
+		return &SQLiteSink{store: store, path: spec.Target}, nil
+	case natsSink:
+		var address = spec.Target
+		if address == "" {
+			address = "localhost"
+		}
+		return DialNATS(address, options.NATSSubject, options.NATSEncoding)
+	case prometheusSink:
+		return NewPrometheusSink(spec.Target), nil
+	}
+	return nil, fmt.Errorf("sink: unknown kind %q", spec.Kind)
+}
+
+// createSinkFile creates the file of a Sink, rotating when the SinkSpec asks for it
+func createSinkFile(spec SinkSpec) (io.WriteCloser, error) {
+	if spec.Rotate > 0 {
+		return NewRotatingFile(spec.Target, spec.Rotate, spec.Keep)
+	}
+	file, err := os.Create(spec.Target)
+	if err != nil {
+		return nil, fmt.Errorf("sink: %v", err)
+	}
+	return file, nil
+}
+
+// LogSink writes the lines of the console through a Logger, on the standard output or in a file
+type LogSink struct {
+	log    func(Event)
+	writer *bufio.Writer
+	closer io.Closer
+}
+
+// Write writes the line of the event
+func (sink *LogSink) Write(event Event) error {
+	sink.log(event)
+	return nil
+}
+
+// Flush writes out the lines written in the file
+func (sink *LogSink) Flush() error {
+	if sink.writer == nil {
+		return nil
+	}
+	return sink.writer.Flush()
+}
+
+// Close flushes the lines and closes the file
+func (sink *LogSink) Close() error {
+	if sink.closer == nil {
+		return nil
+	}
+	return errors.Join(sink.Flush(), sink.closer.Close())
+}
+
+// JSONLSink writes the events in JSON, one per line, as the query command reads them
+type JSONLSink struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// Write writes the event on a line
+func (sink *JSONLSink) Write(event Event) error {
+	return sink.encoder.Encode(event)
+}
+
+// Flush writes out the lines
+func (sink *JSONLSink) Flush() error {
+	return sink.writer.Flush()
+}
+
+// Close flushes the lines and closes the file, the standard output being left open
+func (sink *JSONLSink) Close() error {
+	var err = sink.Flush()
+	if sink.closer != os.Stdout {
+		err = errors.Join(err, sink.closer.Close())
+	}
+	return err
+}
+
+// SQLiteSink keeps the events of the dinner, to save them along with the run in a Store once it is over
+type SQLiteSink struct {
+	mutex  sync.Mutex
+	store  *Store
+	path   string
+	events []Event
+}
+
+// Write keeps the event
+func (sink *SQLiteSink) Write(event Event) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+// Flush does nothing, the events are saved with the run
+func (sink *SQLiteSink) Flush() error { return nil }
+
+// Close does nothing, the events are saved with the run
+func (sink *SQLiteSink) Close() error { return nil }
+
+// Save saves the run in the Store along with the events kept, and returns its id
+func (sink *SQLiteSink) Save(run Run) (int64, error) {
+	sink.mutex.Lock()
+	run.Events = append([]Event(nil), sink.events...)
+	sink.mutex.Unlock()
+	return sink.store.Save(run)
+}
+
+// PrometheusSink counts the events of each kind on each table, and serves the counts in the text format of
+// Prometheus on /metrics while the dinner takes place
+type PrometheusSink struct {
+	mutex  sync.Mutex
+	counts map[[2]string]int
+	server *http.Server
+	err    chan error
+}
+
+// NewPrometheusSink creates a PrometheusSink serving the counts on the address, such as :9100
+func NewPrometheusSink(address string) *PrometheusSink {
+	var sink = &PrometheusSink{counts: make(map[[2]string]int), err: make(chan error, 1)}
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		sink.WriteMetrics(w)
+	})
+	sink.server = &http.Server{Addr: address, Handler: mux}
+	go func() {
+		if err := sink.server.ListenAndServe(); err != http.ErrServerClosed {
+			sink.err <- fmt.Errorf("sink: %v", err)
+		}
+	}()
+	return sink
+}
+
+// Write counts the event
+func (sink *PrometheusSink) Write(event Event) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.counts[[2]string{strconv.Itoa(event.Table), string(event.Kind)}]++
+	return nil
+}
+
+// Flush tells the error of the server, if any
+func (sink *PrometheusSink) Flush() error {
+	select {
+	case err := <-sink.err:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Close stops serving the counts
+func (sink *PrometheusSink) Close() error {
+	return errors.Join(sink.Flush(), sink.server.Close())
+}
+
+// WriteMetrics writes the counts in the text format of Prometheus, labelled by table and kind
+func (sink *PrometheusSink) WriteMetrics(w io.Writer) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	var keys = make([][2]string, 0, len(sink.counts))
+	for key := range sink.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	fmt.Fprintf(w, "# HELP philosophers_sink_events_total Events of the dinner, by table and kind.\n")
+	fmt.Fprintf(w, "# TYPE philosophers_sink_events_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(w, "philosophers_sink_events_total{table=%q,kind=%q} %d\n", key[0], key[1], sink.counts[key])
+	}
+}
+
+// Sinks fans the events of a dinner out to several Sinks, each of them with its own Filter and its own buffer :
+// - a Sink without buffer is an EventBus handler, written as the events are emitted and flushed when it is closed
+// - a Sink with a buffer is an EventBus subscriber written by its own goroutine, flushed once no event is waiting
+// for it, so that a slow Sink never slows the dinner down nor the other Sinks, but misses the events emitted while
+// its buffer is full
+// The first error of each Sink is kept and reported when the Sinks are closed.
+type Sinks struct {
+	outputs []*sinkOutput
+}
+
+// sinkOutput is a Sink of the Sinks, along with what follows its writes
+type sinkOutput struct {
+	spec         SinkSpec
+	sink         Sink
+	selected     int
+	mutex        sync.Mutex
+	err          error
+	events       *EventBus
+	subscription chan Event
+	done         chan struct{}
+}
+
+// Add adds a Sink, it must be called before Attach
+func (sinks *Sinks) Add(spec SinkSpec, sink Sink) {
+	sinks.outputs = append(sinks.outputs, &sinkOutput{spec: spec, sink: sink})
+}
+
+// Attach writes the events emitted on the bus from now on to the Sinks
+func (sinks *Sinks) Attach(events *EventBus) {
+	for _, output := range sinks.outputs {
+		output.events = events
+		if output.spec.Buffer == 0 {
+			events.Handle(output.write)
+			continue
+		}
+		output.subscription = events.Subscribe(output.spec.Buffer)
+		output.done = make(chan struct{})
+		go func() {
+			defer close(output.done)
+			for event := range output.subscription {
+				output.write(event)
+				if len(output.subscription) == 0 {
+					output.fail(output.sink.Flush())
+				}
+			}
+		}()
+	}
+}
+
+// write writes the event to the Sink when its Filter selects it and it is sampled
+func (output *sinkOutput) write(event Event) {
+	if !output.spec.Filter.Match(event) {
+		return
+	}
+	output.selected++
+	if output.spec.Sample <= 1 || (output.selected-1)%output.spec.Sample == 0 {
+		output.fail(output.sink.Write(event))
+	}
+}
+
+// fail keeps the first error of the Sink
+func (output *sinkOutput) fail(err error) {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	if output.err == nil {
+		output.err = err
+	}
+}
+
+// SQLite returns the SQLiteSinks, which save the run once it is over
+func (sinks *Sinks) SQLite() []*SQLiteSink {
+	var stores []*SQLiteSink
+	for _, output := range sinks.outputs {
+		if store, ok := output.sink.(*SQLiteSink); ok {
+			stores = append(stores, store)
+		}
+	}
+	return stores
+}
+
+// Close writes the events still waiting for the Sinks, then closes them, it returns their first errors
+func (sinks *Sinks) Close() error {
+	if sinks == nil {
+		return nil
+	}
+	var errs []error
+	for _, output := range sinks.outputs {
+		if output.subscription != nil {
+			output.events.Unsubscribe(output.subscription)
+			<-output.done
+		}
+		output.fail(output.sink.Close())
+		if output.err != nil {
+			errs = append(errs, fmt.Errorf("%s sink: %v", output.spec.Kind, output.err))
+		}
+	}
+	return errors.Join(errs...)
+}
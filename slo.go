@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const defaultSLOWindow = 10 * time.Second // the window over which the burn rate of an SLO is measured, by default
+
+// SLO is a service level objective of the dinner : the share objective of the meals, such as 0.95, must start
+// within threshold from the moment the philosopher asked to eat, the burn rate being measured over the last window.
+// It is named after its terms, such as "95% within 500ms", unless given a name.
+type SLO struct {
+	Name      string   `json:"name"`
+	Objective float64  `json:"objective"`
+	Threshold Duration `json:"threshold"`
+	Window    Duration `json:"window"`
+}
+
+// SLOStatus is how the dinner does against an SLO so far :
+// - the meals started, the starvations counting as meals which never started, and how many of them started in time
+// - the compliance, the share of the meals started in time, 1 before the first meal
+// - the share of the error budget left, the meals allowed to start late by the objective, negative once exhausted
+// - the burn rate, how fast the meals of the last window use the error budget, 1 using it exactly by the end of the
+// dinner, and above 1 exhausting it sooner
+// - whether the SLO is met, and the compliance of each philosopher
+type SLOStatus struct {
+	Name         string           `json:"name"`
+	Objective    float64          `json:"objective"`
+	Threshold    time.Duration    `json:"threshold"`
+	Window       time.Duration    `json:"window"`
+	Meals        int              `json:"meals"`
+	Good         int              `json:"good"`
+	Compliance   float64          `json:"compliance"`
+	Budget       float64          `json:"budget"`
+	BurnRate     float64          `json:"burnRate"`
+	Met          bool             `json:"met"`
+	Philosophers []SLOPhilosopher `json:"philosophers"`
+}
+
+// SLOPhilosopher is how the meals of a philosopher do against an SLO
+type SLOPhilosopher struct {
+	Table       int     `json:"table"`
+	Philosopher int     `json:"philosopher"`
+	Name        string  `json:"name"`
+	Meals       int     `json:"meals"`
+	Good        int     `json:"good"`
+	Compliance  float64 `json:"compliance"`
+	Met         bool    `json:"met"`
+}
+
+// SLOTracker measures the waits of the meals against the SLOs of the configuration while the dinner takes place, it
+// is meant to be an EventBus handler. A philosopher asks to eat at his first rejection, or when his meal starts if he
+// is accepted at once, as for the SteadyState, and the events are timestamped by the Clock of the EventBus, so that
+// the waits and the windows are in simulated time with the discrete engine.
+// A nil SLOTracker means that the configuration declares no SLO.
+type SLOTracker struct {
+	mutex       sync.Mutex
+	slos        []SLO
+	thresholds  []time.Duration
+	windows     []time.Duration
+	last        time.Time
+	hungrySince map[sloSeat]time.Time
+	names       map[sloSeat]string
+	meals       []sloMeal
+}
+
+// sloSeat identifies a philosopher among the tables
+type sloSeat struct {
+	table       int
+	philosopher int
+}
+
+// sloMeal is a meal which started at the given time after the given wait, or a starvation
+type sloMeal struct {
+	seat    sloSeat
+	at      time.Time
+	wait    time.Duration
+	starved bool
+}
+
+// NewSLOTracker creates the SLOTracker of the SLOs of a validated configuration, nil when it declares none
+func NewSLOTracker(config Config) *SLOTracker {
+	if len(config.SLOs) == 0 {
+		return nil
+	}
+	var tracker = &SLOTracker{slos: config.SLOs, hungrySince: make(map[sloSeat]time.Time), names: make(map[sloSeat]string)}
+	for _, slo := range config.SLOs {
+		tracker.thresholds = append(tracker.thresholds, config.Scale(time.Duration(slo.Threshold)))
+		tracker.windows = append(tracker.windows, config.Scale(time.Duration(slo.Window)))
+	}
+	return tracker
+}
+
+// Handle records the waits of the meals started and the starvations
+func (tracker *SLOTracker) Handle(event Event) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.last = event.Time
+	var seat = sloSeat{table: event.Table, philosopher: event.Philosopher}
+	switch event.Kind {
+	case eventRejected, eventThrottled:
+		if _, hungry := tracker.hungrySince[seat]; !hungry {
+			tracker.hungrySince[seat] = event.Time
+		}
+	case eventStarted:
+		var wait = time.Duration(0)
+		if since, hungry := tracker.hungrySince[seat]; hungry {
+			wait = event.Time.Sub(since)
+			delete(tracker.hungrySince, seat)
+		}
+		tracker.names[seat] = event.Name
+		tracker.meals = append(tracker.meals, sloMeal{seat: seat, at: event.Time, wait: wait})
+	case eventStarved:
+		delete(tracker.hungrySince, seat)
+		tracker.names[seat] = event.Name
+		tracker.meals = append(tracker.meals, sloMeal{seat: seat, at: event.Time, starved: true})
+	}
+}
+
+// Statuses tells how the dinner does against each SLO so far, it can be called while the dinner takes place
+func (tracker *SLOTracker) Statuses() []SLOStatus {
+	if tracker == nil {
+		return nil
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	var statuses = make([]SLOStatus, 0, len(tracker.slos))
+	for i, slo := range tracker.slos {
+		var status = SLOStatus{Name: slo.Name, Objective: slo.Objective, Threshold: tracker.thresholds[i], Window: tracker.windows[i]}
+		var perSeat = make(map[sloSeat]*SLOPhilosopher)
+		var recent, recentGood = 0, 0
+		for _, meal := range tracker.meals {
+			var good = !meal.starved && meal.wait <= tracker.thresholds[i]
+			var philosopher = perSeat[meal.seat]
+			if philosopher == nil {
+				philosopher = &SLOPhilosopher{Table: meal.seat.table, Philosopher: meal.seat.philosopher, Name: tracker.names[meal.seat]}
+				perSeat[meal.seat] = philosopher
+			}
+			status.Meals++
+			philosopher.Meals++
+			if good {
+				status.Good++
+				philosopher.Good++
+			}
+			if meal.at.After(tracker.last.Add(-tracker.windows[i])) {
+				recent++
+				if good {
+					recentGood++
+				}
+			}
+		}
+		status.Compliance = compliance(status.Good, status.Meals)
+		status.Met = status.Compliance >= slo.Objective
+		var allowed = (1 - slo.Objective) * float64(status.Meals)
+		status.Budget = 1
+		if status.Meals > 0 {
+			status.Budget = 1 - float64(status.Meals-status.Good)/allowed
+		}
+		if recent > 0 {
+			status.BurnRate = (1 - compliance(recentGood, recent)) / (1 - slo.Objective)
+		}
+		for _, philosopher := range perSeat {
+			philosopher.Compliance = compliance(philosopher.Good, philosopher.Meals)
+			philosopher.Met = philosopher.Compliance >= slo.Objective
+			status.Philosophers = append(status.Philosophers, *philosopher)
+		}
+		sort.Slice(status.Philosophers, func(i, j int) bool {
+			var a, b = status.Philosophers[i], status.Philosophers[j]
+			return a.Table < b.Table || a.Table == b.Table && a.Philosopher < b.Philosopher
+		})
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// compliance returns the share of the meals started in time, 1 without meal
+func compliance(good, meals int) float64 {
+	if meals == 0 {
+		return 1
+	}
+	return float64(good) / float64(meals)
+}
+
+// verdict tells whether the SLO is met in a word
+func (status SLOStatus) verdict() string {
+	if status.Met {
+		return "met"
+	}
+	return "missed"
+}
+
+// String gives a one line summary of the status
+func (status SLOStatus) String() string {
+	return fmt.Sprintf("%s, %.1f%% of %d meals in time, %.0f%% of the error budget left, burn rate %.2f over the last %v, %s",
+		status.Name, 100*status.Compliance, status.Meals, 100*status.Budget, status.BurnRate, status.Window, status.verdict())
+}
+
+// SLOs tells how the dinner does against each SLO of its configuration so far, nil when it declares none
+func (simulation *Simulation) SLOs() []SLOStatus {
+	return simulation.slos.Statuses()
+}
+
+// WriteSLOs writes the verdict of each SLO, then the philosophers who missed it, nothing when there is no SLO
+func WriteSLOs(w io.Writer, statuses []SLOStatus) {
+	for _, status := range statuses {
+		fmt.Fprintf(w, "SLO : %s\n", status)
+		var missed []SLOPhilosopher
+		for _, philosopher := range status.Philosophers {
+			if !philosopher.Met {
+				missed = append(missed, philosopher)
+			}
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		var writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "  TABLE\tPHILOSOPHER\tMEALS\tIN TIME\tCOMPLIANCE")
+		for _, philosopher := range missed {
+			fmt.Fprintf(writer, "  %d\t%s\t%d\t%d\t%.1f%%\n", philosopher.Table, philosopher.Name, philosopher.Meals, philosopher.Good,
+				100*philosopher.Compliance)
+		}
+		writer.Flush()
+	}
+}
+
+// validateSLOs checks the SLOs, whose objective leaves some meals allowed to start late
+func (config Config) validateSLOs() error {
+	var names = make(map[string]bool)
+	for _, slo := range config.SLOs {
+		if slo.Objective <= 0 || slo.Objective >= 1 {
+			return fmt.Errorf("config: the objective of SLO %q must be above 0 and below 1, got %g", slo.Name, slo.Objective)
+		}
+		if slo.Threshold <= 0 || slo.Window < 0 {
+			return fmt.Errorf("config: SLO %q needs a positive threshold and a window which is not negative, got %v and %v", slo.Name,
+				time.Duration(slo.Threshold), time.Duration(slo.Window))
+		}
+		if names[slo.Name] {
+			return fmt.Errorf("config: SLO %q declared twice", slo.Name)
+		}
+		names[slo.Name] = true
+	}
+	return nil
+}
+
+// sloName names an SLO after its terms, such as "95% within 500ms"
+func sloName(slo SLO) string {
+	var objective = strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", 100*slo.Objective), "0"), ".")
+	return fmt.Sprintf("%s%% within %v", objective, time.Duration(slo.Threshold))
+}
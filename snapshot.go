@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const snapshotVersion = 1 // the version of the format of the snapshots, a snapshot of another version cannot be restored
+
+// Snapshot is the state of a dinner simulated by the discrete-event engine, so that a paused dinner can be saved
+// to a file and restored later or in another process. It is taken between two occurrences of the engine, when no
+// request is waiting for an answer of the Hosts, and it holds :
+// - the RunInfo of the run, which goes on with the same id once restored, and the configuration of the dinner
+// - the time of the dinner elapsed since its start
+// - the state of each seat, the times being relative to the start of the dinner
+// The Hosts rebuild their records from the philosophers eating, the Stats, the histories, the deadlines and the
+// rice pot of a restored dinner start afresh
+type Snapshot struct {
+	Version int            `json:"version"`
+	Run     RunInfo        `json:"run"`
+	Config  Config         `json:"config"`
+	Elapsed Duration       `json:"elapsed"`
+	Seats   []SeatSnapshot `json:"seats"`
+}
+
+// SeatSnapshot is the state of the philosopher of a seat, he has :
+// - what he is doing and how many meals he has eaten, as told by the State
+// - his current meal, which is his number of meals once he left the table
+// - whether he was admitted without the Host, when he is eating with the lock-free admission
+// - when he got hungry, when his meal started and how long his meal lasts or what is left of a paused meal
+// - how many times his current meal was turned down, and how long he last backed off
+// - the time and the order of his next occurrence, when he has one
+// - his energy and since when he is hungry, when the health model is enabled
+// - how many numbers he drew from his Random
+type SeatSnapshot struct {
+	PhilosopherState
+	Meal        int       `json:"meal"`
+	Admitted    bool      `json:"admitted,omitempty"`
+	HungrySince Duration  `json:"hungrySince"`
+	MealStart   Duration  `json:"mealStart,omitempty"`
+	MealLeft    Duration  `json:"mealLeft,omitempty"`
+	Retries     int       `json:"retries,omitempty"`
+	BackedOff   Duration  `json:"backedOff,omitempty"`
+	Next        *Duration `json:"next,omitempty"`
+	Order       uint64    `json:"order,omitempty"`
+	Energy      *float64  `json:"energy,omitempty"`
+	EnergySince Duration  `json:"energySince,omitempty"`
+	Draws       uint64    `json:"draws"`
+}
+
+// ParseSnapshot reads a snapshot and checks that its configuration is valid
+func ParseSnapshot(data []byte) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("snapshot: %v", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return snapshot, fmt.Errorf("snapshot: unknown version %d, expected %d", snapshot.Version, snapshotVersion)
+	}
+	return snapshot, snapshot.Config.Validate()
+}
+
+// Snapshot takes a snapshot of the dinner, it returns an error unless the dinner is simulated by the discrete engine
+// and is taking place. The engine takes it before its next occurrence, a paused dinner being stepped until then.
+func (simulation *Simulation) Snapshot() ([]byte, error) {
+	var engine = simulation.engine
+	if engine == nil {
+		return nil, fmt.Errorf("only a dinner simulated by the %s engine can be snapshotted", discreteEngine)
+	}
+	simulation.mutex.Lock()
+	var started = simulation.started
+	simulation.mutex.Unlock()
+	if !started {
+		return nil, fmt.Errorf("the dinner has not started")
+	}
+
+	var snapshots = make(chan Snapshot, 1)
+	var capture = func() { snapshots <- simulation.capture() }
+	var requests = engine.snapshots
+	var ticker = time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case requests <- capture:
+			requests = nil
+		case snapshot := <-snapshots:
+			return json.MarshalIndent(snapshot, "", "\t")
+		case <-engine.done:
+			select {
+			case snapshot := <-snapshots:
+				return json.MarshalIndent(snapshot, "", "\t")
+			default:
+				return nil, ErrShutdown
+			}
+		case <-ticker.C:
+			simulation.gate.Lock()
+			var paused = simulation.paused
+			simulation.gate.Unlock()
+			if paused {
+				simulation.Step()
+			}
+		}
+	}
+}
+
+// capture builds the snapshot of the dinner, it is called by the engine between two occurrences, when the events
+// it emitted have all been tracked
+func (simulation *Simulation) capture() Snapshot {
+	var snapshot = simulation.engine.capture()
+	snapshot.Run = simulation.info
+	snapshot.Config = simulation.Config()
+	var phases = make(map[[2]int]Phase)
+	for _, state := range simulation.state.State().Philosophers {
+		phases[[2]int{state.Table, state.Philosopher}] = state.Phase
+	}
+	for i := range snapshot.Seats {
+		var seat = &snapshot.Seats[i]
+		if seat.Phase == "" {
+			seat.Phase = phases[[2]int{seat.Table, seat.Philosopher}]
+		}
+		if seat.Phase == "" {
+			seat.Phase = PhaseThinking
+		}
+	}
+	return snapshot
+}
+
+// Restore makes the dinner start where the snapshot was taken, it must be called before Start on a Simulation
+// created with the configuration of the snapshot
+func (simulation *Simulation) Restore(data []byte) error {
+	if simulation.engine == nil {
+		return fmt.Errorf("only a dinner simulated by the %s engine can be restored", discreteEngine)
+	}
+	snapshot, err := ParseSnapshot(data)
+	if err != nil {
+		return err
+	}
+	if snapshot.Run.ConfigHash != simulation.info.ConfigHash || snapshot.Config.Seed != simulation.config.Seed {
+		return fmt.Errorf("snapshot: run %s is a dinner of configuration %s and seed %d, not %s and %d",
+			snapshot.Run.ID, snapshot.Run.ConfigHash, snapshot.Config.Seed, simulation.info.ConfigHash, simulation.config.Seed)
+	}
+	for _, seat := range snapshot.Seats {
+		if seat.Table < 0 || seat.Table >= len(simulation.tables) || seat.Philosopher < 0 || seat.Philosopher >= len(simulation.tables[seat.Table].philosophers) {
+			return fmt.Errorf("snapshot: %w, there is no seat %d at table %d", ErrUnknownPhilosopher, seat.Philosopher, seat.Table)
+		}
+	}
+
+	simulation.mutex.Lock()
+	defer simulation.mutex.Unlock()
+	if simulation.started {
+		return fmt.Errorf("the dinner has already started")
+	}
+	for _, seat := range snapshot.Seats {
+		simulation.tables[seat.Table].philosophers[seat.Philosopher].countEating = seat.Meal
+		simulation.state.Restore(seat.PhilosopherState)
+	}
+	simulation.info = snapshot.Run
+	simulation.events.SetRun(snapshot.Run.ID)
+	simulation.engine.restored = &snapshot
+	return nil
+}
+
+// capture returns the snapshot of the seats, it is called between two occurrences
+func (engine *DiscreteEngine) capture() Snapshot {
+	var next = make(map[[2]int]Occurrence)
+	for _, occurrence := range engine.agenda {
+		if occurrence.timer == engine.diners[occurrence.table][occurrence.seat].timer {
+			next[[2]int{occurrence.table, occurrence.seat}] = occurrence
+		}
+	}
+
+	var snapshot = Snapshot{Version: snapshotVersion, Elapsed: Duration(engine.clock.Now().Sub(engine.start))}
+	for index, table := range engine.tables {
+		for seat, philosopher := range table.philosophers {
+			var diner = &engine.diners[index][seat]
+			var state = SeatSnapshot{
+				PhilosopherState: PhilosopherState{Table: index, Philosopher: seat, Name: philosopher.name, MealsEaten: philosopher.countEating},
+				Meal:             philosopher.countEating,
+				HungrySince:      Duration(diner.hungrySince.Sub(engine.start)),
+				MealLeft:         Duration(diner.mealLeft),
+				Retries:          diner.retry.count,
+				BackedOff:        Duration(diner.retry.last),
+				Draws:            philosopher.random.Draws()}
+			if gone, found := engine.gone[philosopher]; found {
+				state.Phase, state.MealsEaten = gone.Phase, gone.MealsEaten
+			}
+			if diner.eating {
+				state.Phase = PhaseEating
+				state.Admitted = diner.admitted
+				state.MealStart = Duration(diner.mealStart.Sub(engine.start))
+			}
+			if occurrence, found := next[[2]int{index, seat}]; found {
+				var at = Duration(occurrence.at)
+				state.Next, state.Order = &at, occurrence.seq
+			}
+			if energy := philosopher.energy; energy != nil {
+				var level = energy.level
+				state.Energy, state.EnergySince = &level, Duration(energy.since.Sub(engine.start))
+			}
+			snapshot.Seats = append(snapshot.Seats, state)
+		}
+	}
+	return snapshot
+}
+
+// restore brings the seats back to the state of the snapshot, the philosophers who were eating get their utensils
+// back, then the occurrences are scheduled in the order they had
+func (engine *DiscreteEngine) restore(snapshot *Snapshot) {
+	engine.start = engine.clock.Now().Add(-time.Duration(snapshot.Elapsed))
+	var pending []SeatSnapshot
+	for _, state := range snapshot.Seats {
+		var table = engine.tables[state.Table]
+		var philosopher = table.philosophers[state.Philosopher]
+		var diner = &engine.diners[state.Table][state.Philosopher]
+		philosopher.random.Restore(state.Draws)
+		if energy := philosopher.energy; energy != nil && state.Energy != nil {
+			energy.level, energy.since = *state.Energy, engine.start.Add(time.Duration(state.EnergySince))
+		}
+		if state.Phase == PhaseStarved || state.Phase == PhaseFailed || state.Phase == PhaseLeft {
+			engine.gone[philosopher] = state.PhilosopherState
+		}
+		diner.hungrySince = engine.start.Add(time.Duration(state.HungrySince))
+		diner.mealLeft = time.Duration(state.MealLeft)
+		diner.retry = Retry{count: state.Retries, last: time.Duration(state.BackedOff)}
+		if state.Phase == PhaseEating {
+			var grant Grant
+			if state.Admitted {
+				grant, _ = philosopher.admission.claim(philosopher)
+			} else {
+				table.requests(state.Philosopher).Tell(ResumeGrant{philosopher: philosopher.id, meal: philosopher.countEating})
+				grant = <-philosopher.feedbackChannel
+			}
+			philosopher.takeUtensils(grant.chopSticks)
+			diner.chopSticks = grant.chopSticks
+			diner.admitted = state.Admitted
+			diner.eating = true
+			diner.mealStart = engine.start.Add(time.Duration(state.MealStart))
+			engine.eating[state.Table].Add(state.Philosopher)
+		}
+		if state.Next != nil {
+			pending = append(pending, state)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		if *pending[i].Next != *pending[j].Next {
+			return *pending[i].Next < *pending[j].Next
+		}
+		return pending[i].Order < pending[j].Order
+	})
+	for _, state := range pending {
+		engine.schedule(state.Table, state.Philosopher, time.Duration(*state.Next))
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PhilosopherState tells what a philosopher is doing and how many meals he has eaten
+type PhilosopherState struct {
+	Table       int    `json:"table"`
+	Philosopher int    `json:"philosopher"`
+	Name        string `json:"name"`
+	Phase       Phase  `json:"phase"`
+	MealsEaten  int    `json:"mealsEaten"`
+}
+
+// State tells what the philosophers of a Simulation are doing, along with how many requests are waiting for the Hosts,
+// how many backpressure events and liveness incidents were emitted and the illegal transitions seen in the events,
+// and, for a Simulation, the HostState of each Host and how many requests to eat they accepted and rejected in total
+type State struct {
+	Running      bool               `json:"running"`
+	Paused       bool               `json:"paused"`
+	Failed       bool               `json:"failed"`
+	Events       uint64             `json:"events"`
+	QueueDepth   int                `json:"queueDepth"`
+	Backpressure int                `json:"backpressure"`
+	Incidents    int                `json:"incidents"`
+	Accepted     int                `json:"accepted"`
+	Rejected     int                `json:"rejected"`
+	Violations   []string           `json:"violations,omitempty"`
+	Philosophers []PhilosopherState `json:"philosophers"`
+	Hosts        []HostState        `json:"hosts,omitempty"`
+}
+
+// StateTracker follows the events of a Simulation to know what the philosophers are doing, the PhaseMachine of each
+// philosopher telling his phase and rejecting the events which cannot happen to him
+type StateTracker struct {
+	mutex        sync.Mutex
+	running      bool
+	failed       bool
+	events       uint64
+	backpressure int
+	incidents    int
+	violations   []string
+	meals        []int
+	philosophers map[string]*PhilosopherState
+	machines     map[string]*PhaseMachine
+}
+
+// NewStateTracker creates a StateTracker for a dinner which is about to start, meals tells how many meals the
+// philosopher of each seat eats, nil when they are not known such as in a trace
+func NewStateTracker(meals []int) *StateTracker {
+	return &StateTracker{running: true, meals: meals, philosophers: make(map[string]*PhilosopherState), machines: make(map[string]*PhaseMachine)}
+}
+
+// machine returns the PhaseMachine of the philosopher of a seat, created once he is first seen
+func (tracker *StateTracker) machine(seat int, name string) *PhaseMachine {
+	var machine = tracker.machines[name]
+	if machine == nil {
+		var meals = 0
+		if seat >= 0 && seat < len(tracker.meals) {
+			meals = tracker.meals[seat]
+		}
+		machine = NewPhaseMachine(meals)
+		tracker.machines[name] = machine
+	}
+	return machine
+}
+
+// Track updates the state according to an event, it is meant to be an EventBus handler
+func (tracker *StateTracker) Track(event Event) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.events = event.Seq
+	if event.Kind == eventBackpressure {
+		tracker.backpressure++
+		return
+	}
+	if event.Kind == eventUnresponsive {
+		tracker.incidents++
+		return
+	}
+	if event.Name == "" {
+		return
+	}
+	var philosopher = tracker.philosophers[event.Name]
+	if philosopher == nil {
+		philosopher = &PhilosopherState{Table: event.Table, Philosopher: event.Philosopher, Name: event.Name, Phase: PhaseThinking}
+		tracker.philosophers[event.Name] = philosopher
+	}
+
+	var machine = tracker.machine(event.Philosopher, event.Name)
+	if err := machine.Apply(event); err != nil {
+		tracker.violations = append(tracker.violations, fmt.Sprintf("table %d, event %d, %v", event.Table, event.Seq, err))
+	}
+	philosopher.Phase, philosopher.MealsEaten = machine.Phase, machine.Eaten
+}
+
+// Phase returns the phase of the named philosopher, empty when he was not seen yet
+func (tracker *StateTracker) Phase(name string) Phase {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if philosopher := tracker.philosophers[name]; philosopher != nil {
+		return philosopher.Phase
+	}
+	return ""
+}
+
+// Restore sets the state of a philosopher, such as the one of a Snapshot
+func (tracker *StateTracker) Restore(state PhilosopherState) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.philosophers[state.Name] = &state
+	var machine = tracker.machine(state.Philosopher, state.Name)
+	machine.Phase, machine.Eaten = state.Phase, state.MealsEaten
+}
+
+// Finish records the end of the dinner
+func (tracker *StateTracker) Finish(failed bool) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.running = false
+	tracker.failed = failed
+}
+
+// State returns a copy of the current state, the philosophers being sorted by table and id
+func (tracker *StateTracker) State() State {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	var state = State{Running: tracker.running, Failed: tracker.failed, Events: tracker.events, Backpressure: tracker.backpressure, Incidents: tracker.incidents,
+		Violations: append([]string(nil), tracker.violations...)}
+	for _, philosopher := range tracker.philosophers {
+		state.Philosophers = append(state.Philosophers, *philosopher)
+	}
+	sort.Slice(state.Philosophers, func(i, j int) bool {
+		var a, b = state.Philosophers[i], state.Philosophers[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Philosopher != b.Philosopher {
+			return a.Philosopher < b.Philosopher
+		}
+		return a.Name < b.Name
+	})
+	return state
+}
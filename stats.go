@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Below are the causes of rejection counted in the Stats
+const causeAlreadyEating = "already eating"
+const causeMaxEaters = "max eaters"
+const causeUtensils = "utensils"
+const causeDish = "dish"
+const causeRicePot = "rice pot"
+const causeDeadline = "deadline"
+const causeStrategy = "strategy"
+const causeTicket = "ticket"
+const causeRateLimit = "rate limit"
+
+// Stats holds the metrics gathered by the Host of a table during the dinner :
+// - how many requests to eat were accepted, and how many philosophers started eating without asking the Host
+// - how many requests to eat were rejected, per cause of rejection
+// - how many philosophers served themselves at the same time from the central dish, at most
+// - the philosophers who starved
+// - how many times each philosopher was asked to pause, how many meals were actually paused, and who waited
+// the longest before eating when preemption is enabled
+// - the deadline misses, nil unless the deadline mode is enabled
+// - the priority inversions, nil when all the philosophers have the same priority
+// - how the two-phase grants were confirmed, nil unless they are enabled
+// - how many requests the Host received, the sum and the peak of the depth of its queue when it received them,
+// and how many requests waited longer than the backpressure threshold
+// - how many meals waited for all their utensils to be free with the atomic acquisition, out of how many
+// - the misuses of the utensils caught by the Ownership in the check mode
+// - the liveness incidents and how many of them each unresponsive philosopher caused, told by the Liveness of the table
+type Stats struct {
+	accepted      int
+	admitted      int
+	rejected      map[string]int
+	dishPeak      int
+	dishLimit     int
+	starved       []string
+	preempted     map[string]int
+	paused        int
+	longestWaiter string
+	longestWait   time.Duration
+	deadlines     *Deadlines
+	inversions    *Inversions
+	reservations  *Reservations
+	requests      int
+	queueCapacity int
+	queueDepth    int
+	queuePeak     int
+	backpressure  int
+	atomicWaits   int
+	atomicGrants  int
+	misuses       []string
+	failures      []PhilosopherFailure
+	incidents     int
+	unresponsive  map[string]int
+}
+
+// newStats creates empty Stats
+func newStats() Stats {
+	return Stats{rejected: make(map[string]int)}
+}
+
+// add adds the Stats of another shard of the table, which has no dish, no deadlines nor preemption, the priority
+// inversions being only detected within a shard
+func (stats *Stats) add(other Stats) {
+	stats.accepted += other.accepted
+	for cause, count := range other.rejected {
+		stats.rejected[cause] += count
+	}
+	stats.starved = append(stats.starved, other.starved...)
+	stats.paused += other.paused
+	stats.requests += other.requests
+	stats.queueCapacity += other.queueCapacity
+	stats.queueDepth += other.queueDepth
+	stats.queuePeak = max(stats.queuePeak, other.queuePeak)
+	stats.backpressure += other.backpressure
+	if stats.reservations != nil {
+		stats.reservations.add(other.reservations)
+	}
+}
+
+// String gives a one line summary of the Stats
+func (stats Stats) String() string {
+	var total = 0
+	var causes []string
+	for cause, count := range stats.rejected {
+		total += count
+		causes = append(causes, fmt.Sprintf("%s %d", cause, count))
+	}
+	sort.Strings(causes)
+
+	var summary = fmt.Sprintf("%d requests accepted, %d rejected", stats.accepted, total)
+	if stats.admitted > 0 {
+		summary = fmt.Sprintf("%d meals started without the Host, ", stats.admitted) + summary
+	}
+	if len(causes) > 0 {
+		summary += fmt.Sprintf(" (%s)", strings.Join(causes, ", "))
+	}
+	if stats.dishLimit > 0 {
+		summary += fmt.Sprintf(", dish peak %d/%d", stats.dishPeak, stats.dishLimit)
+	}
+	if stats.preempted != nil {
+		var preemptions = 0
+		var victims []string
+		for name, count := range stats.preempted {
+			preemptions += count
+			victims = append(victims, fmt.Sprintf("%s %d", name, count))
+		}
+		sort.Strings(victims)
+		summary += fmt.Sprintf(", %d preemptions", preemptions)
+		if len(victims) > 0 {
+			summary += fmt.Sprintf(" (%s)", strings.Join(victims, ", "))
+		}
+		summary += fmt.Sprintf(" and %d meals paused", stats.paused)
+		if stats.longestWaiter != "" {
+			summary += fmt.Sprintf(", longest wait %v (%s)", stats.longestWait.Round(time.Millisecond), stats.longestWaiter)
+		}
+	}
+	if stats.inversions != nil && stats.inversions.count > 0 {
+		summary += fmt.Sprintf(", %d priority inversions lasting %v, longest %v", stats.inversions.count,
+			stats.inversions.total.Round(time.Millisecond), stats.inversions.longest.Round(time.Millisecond))
+	}
+	if stats.reservations != nil {
+		summary += ", " + stats.reservations.String()
+	}
+	if stats.queueCapacity > 0 && stats.requests > 0 {
+		summary += fmt.Sprintf(", request queue depth mean %.2f peak %d/%d",
+			float64(stats.queueDepth)/float64(stats.requests), stats.queuePeak, stats.queueCapacity)
+	}
+	if stats.backpressure > 0 {
+		summary += fmt.Sprintf(", %d requests slowed by backpressure", stats.backpressure)
+	}
+	if stats.atomicGrants > 0 {
+		summary += fmt.Sprintf(", %d of %d atomic acquisitions waited", stats.atomicWaits, stats.atomicGrants)
+	}
+	if len(stats.misuses) > 0 {
+		summary += fmt.Sprintf(", %d misuses of the utensils", len(stats.misuses))
+	}
+	if stats.incidents > 0 {
+		summary += fmt.Sprintf(", %d liveness incidents of %d philosophers", stats.incidents, len(stats.unresponsive))
+	}
+	if len(stats.starved) > 0 {
+		summary += fmt.Sprintf(", %d starved", len(stats.starved))
+	}
+	return summary
+}
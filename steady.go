@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// waitBuckets are the upper bounds of the buckets counting how long the philosophers waited before their meals
+var waitBuckets = [...]time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second}
+
+// SteadyState sums up the dinner once it has settled, it is meant to be an EventBus handler : the events of the
+// warm-up, from the first event, and of the cool-down, until the last event, are recorded along with the others but
+// left out of its statistics, so that the transients of the start and of the end of the dinner do not skew them.
+// The window is only known once the dinner is over, so the SteadyState keeps the time of the events it counts :
+// - the meals finished, which give the throughput
+// - the requests to eat accepted and rejected, the throttled ones included
+// - how long each philosopher waited before a meal started, from his first rejection, 0 when he was accepted at once
+// The events are timestamped by the Clock of the EventBus, so that the windows are in simulated time with the discrete engine.
+type SteadyState struct {
+	mutex       sync.Mutex
+	warmup      time.Duration
+	cooldown    time.Duration
+	first       time.Time
+	last        time.Time
+	finished    []time.Time
+	accepted    []time.Time
+	rejected    []time.Time
+	waits       []steadyWait
+	hungrySince map[string]time.Time
+}
+
+// steadyWait is how long a philosopher waited before the meal which started at the given time
+type steadyWait struct {
+	at   time.Time
+	wait time.Duration
+}
+
+// SteadySummary is what the SteadyState tells once the dinner is over :
+// - the window it looks at, counted from the first event, and how long the whole dinner lasted
+// - the meals finished in the window and the throughput in meals per second
+// - the requests to eat accepted and rejected in the window
+// - the waits of the meals started in the window, sorted, and how many of them fall in each bucket of waitBuckets
+type SteadySummary struct {
+	From       time.Duration
+	To         time.Duration
+	Duration   time.Duration
+	Meals      int
+	Throughput float64
+	Accepted   int
+	Rejected   int
+	Waits      []time.Duration
+	Buckets    []int
+}
+
+// NewSteadyState creates a SteadyState leaving out the given warm-up and cool-down
+func NewSteadyState(warmup, cooldown time.Duration) *SteadyState {
+	return &SteadyState{warmup: warmup, cooldown: cooldown, hungrySince: make(map[string]time.Time)}
+}
+
+// Handle records the event
+func (steady *SteadyState) Handle(event Event) {
+	steady.mutex.Lock()
+	defer steady.mutex.Unlock()
+
+	if steady.first.IsZero() {
+		steady.first = event.Time
+	}
+	steady.last = event.Time
+	switch event.Kind {
+	case eventAccepted:
+		steady.accepted = append(steady.accepted, event.Time)
+	case eventRejected, eventThrottled:
+		steady.rejected = append(steady.rejected, event.Time)
+		if _, hungry := steady.hungrySince[event.Name]; !hungry {
+			steady.hungrySince[event.Name] = event.Time
+		}
+	case eventStarted:
+		var wait = time.Duration(0)
+		if since, hungry := steady.hungrySince[event.Name]; hungry {
+			wait = event.Time.Sub(since)
+			delete(steady.hungrySince, event.Name)
+		}
+		steady.waits = append(steady.waits, steadyWait{at: event.Time, wait: wait})
+	case eventFinished:
+		steady.finished = append(steady.finished, event.Time)
+	}
+}
+
+// Summary returns the statistics of the events in the window, once the dinner is over
+func (steady *SteadyState) Summary() SteadySummary {
+	steady.mutex.Lock()
+	defer steady.mutex.Unlock()
+
+	var from, to = steady.first.Add(steady.warmup), steady.last.Add(-steady.cooldown)
+	var summary = SteadySummary{From: steady.warmup, To: to.Sub(steady.first), Duration: steady.last.Sub(steady.first),
+		Buckets: make([]int, len(waitBuckets)+1)}
+	if !to.After(from) {
+		summary.To = summary.From
+		return summary
+	}
+	var inWindow = func(at time.Time) bool {
+		return !at.Before(from) && !at.After(to)
+	}
+	var count = func(times []time.Time) int {
+		var counted = 0
+		for _, at := range times {
+			if inWindow(at) {
+				counted++
+			}
+		}
+		return counted
+	}
+	summary.Meals = count(steady.finished)
+	summary.Throughput = float64(summary.Meals) / to.Sub(from).Seconds()
+	summary.Accepted = count(steady.accepted)
+	summary.Rejected = count(steady.rejected)
+	for _, wait := range steady.waits {
+		if !inWindow(wait.at) {
+			continue
+		}
+		summary.Waits = append(summary.Waits, wait.wait)
+		var bucket = 0
+		for bucket < len(waitBuckets) && wait.wait > waitBuckets[bucket] {
+			bucket++
+		}
+		summary.Buckets[bucket]++
+	}
+	sort.Slice(summary.Waits, func(i, j int) bool { return summary.Waits[i] < summary.Waits[j] })
+	return summary
+}
+
+// Percentile returns the wait below which the given percentage of the waits of the window fall
+func (summary SteadySummary) Percentile(percent int) time.Duration {
+	if len(summary.Waits) == 0 {
+		return 0
+	}
+	return summary.Waits[min(len(summary.Waits)-1, len(summary.Waits)*percent/100)]
+}
+
+// String gives a one line summary of the window
+func (summary SteadySummary) String() string {
+	var window = fmt.Sprintf("%v to %v of %v", summary.From.Round(time.Millisecond), summary.To.Round(time.Millisecond),
+		summary.Duration.Round(time.Millisecond))
+	if summary.To <= summary.From {
+		return window + ", the warm-up and the cool-down leave nothing"
+	}
+	var total time.Duration
+	for _, wait := range summary.Waits {
+		total += wait
+	}
+	var mean = meanDuration(total, int64(len(summary.Waits)))
+	return fmt.Sprintf("%s, %d meals (%.2f meals/s), %d requests accepted, %d rejected, wait mean %v p50 %v p99 %v", window,
+		summary.Meals, summary.Throughput, summary.Accepted, summary.Rejected, mean.Round(time.Millisecond),
+		summary.Percentile(50).Round(time.Millisecond), summary.Percentile(99).Round(time.Millisecond))
+}
+
+// Histogram tells how many meals of the window started after a wait in each bucket of waitBuckets
+func (summary SteadySummary) Histogram() string {
+	var buckets []string
+	for bucket, count := range summary.Buckets {
+		if bucket < len(waitBuckets) {
+			buckets = append(buckets, fmt.Sprintf("<=%v %d", waitBuckets[bucket], count))
+		} else {
+			buckets = append(buckets, fmt.Sprintf(">%v %d", waitBuckets[len(waitBuckets)-1], count))
+		}
+	}
+	return strings.Join(buckets, ", ")
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const sqliteShell = "sqlite3" // the SQLite command line shell, the Store needs it to be installed
+
+// storeSchema creates the tables of the Store :
+// - runs holds a row per dinner, with its configuration, its totals and its RunInfo
+// - philosophers holds the PhilosopherReport of each philosopher of each run
+// - events holds the trace of the runs saved along with their events
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+  id INTEGER PRIMARY KEY,
+  started TEXT NOT NULL,
+  duration_ms INTEGER NOT NULL,
+  config TEXT NOT NULL,
+  tables INTEGER NOT NULL,
+  philosophers INTEGER NOT NULL,
+  meals INTEGER NOT NULL,
+  accepted INTEGER NOT NULL,
+  rejected INTEGER NOT NULL,
+  starved INTEGER NOT NULL,
+  failed INTEGER NOT NULL,
+  summary TEXT NOT NULL,
+  run_id TEXT NOT NULL DEFAULT '',
+  config_hash TEXT NOT NULL DEFAULT '',
+  strategy TEXT NOT NULL DEFAULT '',
+  seed INTEGER NOT NULL DEFAULT 0,
+  go_version TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS philosophers (
+  run INTEGER NOT NULL REFERENCES runs(id),
+  table_id INTEGER NOT NULL,
+  philosopher INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  meals INTEGER NOT NULL,
+  accepted INTEGER NOT NULL,
+  rejected INTEGER NOT NULL,
+  preempted INTEGER NOT NULL,
+  paused INTEGER NOT NULL,
+  starved INTEGER NOT NULL,
+  eating_ms INTEGER NOT NULL,
+  waiting_ms INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS events (
+  run INTEGER NOT NULL REFERENCES runs(id),
+  seq INTEGER NOT NULL,
+  time TEXT NOT NULL,
+  table_id INTEGER NOT NULL,
+  philosopher INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  meal INTEGER NOT NULL,
+  detail TEXT NOT NULL
+);
+`
+
+// storeRunInfoColumns are the columns of the RunInfo, added to the runs table of the databases created before them
+var storeRunInfoColumns = []string{
+	"run_id TEXT NOT NULL DEFAULT ''",
+	"config_hash TEXT NOT NULL DEFAULT ''",
+	"strategy TEXT NOT NULL DEFAULT ''",
+	"seed INTEGER NOT NULL DEFAULT 0",
+	"go_version TEXT NOT NULL DEFAULT ''",
+}
+
+// Store saves the runs of the dinner in a SQLite database, so that experiments can be compared over time
+// It drives the SQLite command line shell rather than linking against SQLite, which keeps this program free
+// of dependencies : the scripts are run in a single transaction, and the queries are read in JSON.
+type Store struct {
+	path string
+}
+
+// Run is a dinner to save in the Store, Events is empty unless the trace of the events is saved too
+type Run struct {
+	Info     RunInfo
+	Started  time.Time
+	Finished time.Time
+	Config   Config
+	Result   Result
+	Reports  []PhilosopherReport
+	Events   []Event
+}
+
+// RunSummary is a run as listed by the Store
+type RunSummary struct {
+	ID           int64   `json:"id"`
+	Started      string  `json:"started"`
+	DurationMs   int64   `json:"duration_ms"`
+	Config       string  `json:"config"`
+	Tables       int     `json:"tables"`
+	Philosophers int     `json:"philosophers"`
+	Meals        int     `json:"meals"`
+	Accepted     int     `json:"accepted"`
+	Rejected     int     `json:"rejected"`
+	Starved      int     `json:"starved"`
+	Failed       int     `json:"failed"`
+	Summary      string  `json:"summary"`
+	RunID        string  `json:"run_id"`
+	ConfigHash   string  `json:"config_hash"`
+	Strategy     string  `json:"strategy"`
+	Seed         int64   `json:"seed"`
+	GoVersion    string  `json:"go_version"`
+	WaitingMs    float64 `json:"waiting_ms"`
+	Fairness     float64 `json:"fairness"`
+}
+
+// OpenStore opens the database at the given path, creating it and its tables when needed
+func OpenStore(path string) (*Store, error) {
+	if _, err := exec.LookPath(sqliteShell); err != nil {
+		return nil, fmt.Errorf("store: the %s command is needed to save the runs: %v", sqliteShell, err)
+	}
+	var store = &Store{path: path}
+	if _, err := store.exec(storeSchema); err != nil {
+		return nil, err
+	}
+	if err := store.migrate(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// migrate adds the columns of the RunInfo to the runs table of a database created before them
+func (store *Store) migrate() error {
+	output, err := store.exec("SELECT name FROM pragma_table_info('runs');")
+	if err != nil {
+		return err
+	}
+	var columns = make(map[string]bool)
+	for _, name := range strings.Fields(output) {
+		columns[name] = true
+	}
+	var script strings.Builder
+	for _, column := range storeRunInfoColumns {
+		if name := strings.Fields(column)[0]; !columns[name] {
+			fmt.Fprintf(&script, "ALTER TABLE runs ADD COLUMN %s;\n", column)
+		}
+	}
+	if script.Len() == 0 {
+		return nil
+	}
+	_, err = store.exec(script.String())
+	return err
+}
+
+// Save saves a run and returns its id
+func (store *Store) Save(run Run) (int64, error) {
+	var script strings.Builder
+	var accepted, rejected = 0, 0
+	for _, table := range run.Result.Tables {
+		accepted += table.stats.accepted
+		for _, count := range table.stats.rejected {
+			rejected += count
+		}
+	}
+	var summaries []string
+	for _, table := range run.Result.Tables {
+		summaries = append(summaries, table.stats.String())
+	}
+	var meals = 0
+	for _, report := range run.Reports {
+		meals += report.Meals
+	}
+	configJSON, _ := json.Marshal(run.Config)
+
+	script.WriteString("BEGIN IMMEDIATE;\n")
+	fmt.Fprintf(&script, "INSERT INTO runs (started, duration_ms, config, tables, philosophers, meals, accepted, rejected, starved, failed, summary, "+
+		"run_id, config_hash, strategy, seed, go_version) VALUES (%s, %d, %s, %d, %d, %d, %d, %d, %d, %d, %s, %s, %s, %s, %d, %s);\n",
+		sqlQuote(run.Started.Format(time.RFC3339Nano)), run.Finished.Sub(run.Started).Milliseconds(), sqlQuote(string(configJSON)),
+		len(run.Result.Tables), len(run.Reports), meals, accepted, rejected, len(run.Result.Starved()), sqlBool(run.Result.Failed()),
+		sqlQuote(strings.Join(summaries, "\n")), sqlQuote(run.Info.ID), sqlQuote(run.Info.ConfigHash), sqlQuote(run.Info.Strategy),
+		run.Info.Seed, sqlQuote(run.Info.GoVersion))
+	script.WriteString("CREATE TEMP TABLE saved AS SELECT last_insert_rowid() AS id;\n")
+	for _, report := range run.Reports {
+		fmt.Fprintf(&script, "INSERT INTO philosophers VALUES ((SELECT id FROM saved), %d, %d, %s, %d, %d, %d, %d, %d, %d, %d, %d);\n",
+			report.Table, report.Philosopher, sqlQuote(report.Name), report.Meals, report.Accepted, report.Rejected,
+			report.Preempted, report.Paused, sqlBool(report.Starved), report.Eating.Milliseconds(), report.Waiting.Milliseconds())
+	}
+	for _, event := range run.Events {
+		fmt.Fprintf(&script, "INSERT INTO events VALUES ((SELECT id FROM saved), %d, %s, %d, %d, %s, %s, %d, %s);\n",
+			event.Seq, sqlQuote(event.Time.Format(time.RFC3339Nano)), event.Table, event.Philosopher,
+			sqlQuote(event.Name), sqlQuote(string(event.Kind)), event.Meal, sqlQuote(event.Detail))
+	}
+	script.WriteString("SELECT id FROM saved;\nCOMMIT;\n")
+
+	output, err := store.exec(script.String())
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("store: unexpected answer of %s: %q", sqliteShell, output)
+	}
+	return id, nil
+}
+
+// Runs lists the saved runs, all of them when no id is given
+func (store *Store) Runs(ids ...int64) ([]RunSummary, error) {
+	// the fairness is Jain's index of the mean waits of the philosophers, 1 when none of them waited
+	var query = "SELECT runs.*, coalesce((SELECT avg(waiting_ms) FROM philosophers WHERE run = runs.id), 0) AS waiting_ms, " +
+		"coalesce((SELECT sum(wait) * sum(wait) / (count(*) * sum(wait * wait)) FROM " +
+		"(SELECT 1.0 * waiting_ms / max(meals, 1) AS wait FROM philosophers WHERE run = runs.id)), 1) AS fairness FROM runs"
+	if len(ids) > 0 {
+		var list []string
+		for _, id := range ids {
+			list = append(list, strconv.FormatInt(id, 10))
+		}
+		query += " WHERE id IN (" + strings.Join(list, ", ") + ")"
+	}
+	output, err := store.exec(query+" ORDER BY id;", "-json")
+	if err != nil {
+		return nil, err
+	}
+	var runs []RunSummary
+	if strings.TrimSpace(output) == "" {
+		return runs, nil
+	}
+	if err := json.Unmarshal([]byte(output), &runs); err != nil {
+		return nil, fmt.Errorf("store: unexpected answer of %s: %v", sqliteShell, err)
+	}
+	return runs, nil
+}
+
+// Events returns the trace of the events of a run saved along with its events, in the order they were emitted
+func (store *Store) Events(run int64) ([]Event, error) {
+	output, err := store.exec(fmt.Sprintf("SELECT events.*, runs.run_id FROM events JOIN runs ON runs.id = events.run "+
+		"WHERE events.run = %d ORDER BY events.seq;", run), "-json")
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Seq         uint64    `json:"seq"`
+		Time        time.Time `json:"time"`
+		Table       int       `json:"table_id"`
+		Philosopher int       `json:"philosopher"`
+		Name        string    `json:"name"`
+		Kind        EventKind `json:"kind"`
+		Meal        int       `json:"meal"`
+		Detail      string    `json:"detail"`
+		RunID       string    `json:"run_id"`
+	}
+	if strings.TrimSpace(output) != "" {
+		if err := json.Unmarshal([]byte(output), &rows); err != nil {
+			return nil, fmt.Errorf("store: unexpected answer of %s: %v", sqliteShell, err)
+		}
+	}
+	var events = make([]Event, len(rows))
+	for i, row := range rows {
+		// the store keeps the fields of the first revision of the events
+		events[i] = Event{Version: 1, Seq: row.Seq, Time: row.Time, Run: row.RunID, Table: row.Table, Philosopher: row.Philosopher,
+			Name: row.Name, Kind: row.Kind, Meal: row.Meal, Detail: row.Detail}
+	}
+	return events, nil
+}
+
+// exec runs a SQL script on the database and returns what the shell printed
+func (store *Store) exec(script string, options ...string) (string, error) {
+	var command = exec.Command(sqliteShell, append(append([]string{"-bail", "-batch"}, options...), store.path)...)
+	command.Stdin = strings.NewReader(script)
+	var stderr strings.Builder
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("store: %s: %v %s", store.path, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// sqlQuote writes a string as a SQL literal
+func sqlQuote(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+// sqlBool writes a boolean as SQLite does
+func sqlBool(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// history is the history command, it lists the saved runs or compares the runs whose ids are given
+func history(arguments []string) error {
+	var flags = flag.NewFlagSet("history", flag.ExitOnError)
+	var path = flags.String("store", "runs.db", "SQLite database where the runs were saved")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s history [-store runs.db] [run ids to compare]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	var ids []int64
+	for _, argument := range flags.Args() {
+		id, err := strconv.ParseInt(argument, 10, 64)
+		if err != nil {
+			return fmt.Errorf("history: %q is not a run id", argument)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := os.Stat(*path); err != nil {
+		return fmt.Errorf("history: %v", err)
+	}
+	store, err := OpenStore(*path)
+	if err != nil {
+		return err
+	}
+	runs, err := store.Runs(ids...)
+	if err != nil {
+		return err
+	}
+	if len(runs) < len(ids) {
+		return fmt.Errorf("history: only %d of the %d runs were found in %s", len(runs), len(ids), *path)
+	}
+
+	var writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(ids) == 0 {
+		fmt.Fprintln(writer, "RUN\tSTARTED\tCONFIG\tSEED\tDURATION\tPHILOSOPHERS\tMEALS\tACCEPTED\tREJECTED\tWAITING\tFAIRNESS\tSTARVED")
+		for _, run := range runs {
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%d\t%v\t%d\t%d\t%d\t%d\t%v\t%.3f\t%d\n", run.ID, formatStarted(run.Started), run.ConfigHash, run.Seed,
+				time.Duration(run.DurationMs)*time.Millisecond, run.Philosophers, run.Meals, run.Accepted, run.Rejected,
+				time.Duration(run.WaitingMs*float64(time.Millisecond)).Round(time.Millisecond), run.Fairness, run.Starved)
+		}
+		return writer.Flush()
+	}
+	if err := compareRuns(writer, runs); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// compareRuns prints the given runs side by side, along with the settings that differ between them
+func compareRuns(writer *tabwriter.Writer, runs []RunSummary) error {
+	var row = func(label string, value func(RunSummary) string) {
+		fmt.Fprint(writer, label)
+		for _, run := range runs {
+			fmt.Fprintf(writer, "\t%s", value(run))
+		}
+		fmt.Fprintln(writer)
+	}
+	row("run", func(run RunSummary) string { return strconv.FormatInt(run.ID, 10) })
+	row("run id", func(run RunSummary) string { return run.RunID })
+	row("config hash", func(run RunSummary) string { return run.ConfigHash })
+	row("strategy", func(run RunSummary) string { return run.Strategy })
+	row("seed", func(run RunSummary) string { return strconv.FormatInt(run.Seed, 10) })
+	row("go version", func(run RunSummary) string { return run.GoVersion })
+	row("started", func(run RunSummary) string { return formatStarted(run.Started) })
+	row("duration", func(run RunSummary) string { return (time.Duration(run.DurationMs) * time.Millisecond).String() })
+	row("philosophers", func(run RunSummary) string { return strconv.Itoa(run.Philosophers) })
+	row("meals", func(run RunSummary) string { return strconv.Itoa(run.Meals) })
+	row("accepted", func(run RunSummary) string { return strconv.Itoa(run.Accepted) })
+	row("rejected", func(run RunSummary) string { return strconv.Itoa(run.Rejected) })
+	row("mean waiting", func(run RunSummary) string {
+		return time.Duration(run.WaitingMs * float64(time.Millisecond)).Round(time.Millisecond).String()
+	})
+	row("fairness", func(run RunSummary) string { return strconv.FormatFloat(run.Fairness, 'f', 3, 64) })
+	row("starved", func(run RunSummary) string { return strconv.Itoa(run.Starved) })
+
+	var configs = make([]map[string]any, len(runs))
+	var settings = make(map[string]bool)
+	for i, run := range runs {
+		// UseNumber keeps the settings as written : a large seed would otherwise lose digits as a float64
+		var decoder = json.NewDecoder(strings.NewReader(run.Config))
+		decoder.UseNumber()
+		if err := decoder.Decode(&configs[i]); err != nil {
+			return fmt.Errorf("history: config of run %d: %v", run.ID, err)
+		}
+		for setting := range configs[i] {
+			settings[setting] = true
+		}
+	}
+	var names []string
+	for setting := range settings {
+		names = append(names, setting)
+	}
+	sort.Strings(names)
+	for _, setting := range names {
+		var same = true
+		for i := range configs {
+			same = same && reflect.DeepEqual(configs[i][setting], configs[0][setting])
+		}
+		if !same {
+			var index = 0
+			row("config."+setting, func(RunSummary) string {
+				value, _ := json.Marshal(configs[index][setting])
+				index++
+				return string(value)
+			})
+		}
+	}
+	return nil
+}
+
+// formatStarted writes when a run started in the local time, to the second
+func formatStarted(started string) string {
+	parsed, err := time.Parse(time.RFC3339Nano, started)
+	if err != nil {
+		return started
+	}
+	return parsed.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const greedyStrategy = "greedy" // the default strategy, the Host lets a philosopher eat as soon as he can
+
+// StrategyRequest is what a Strategy knows about a request to eat, the Host having checked that the philosopher
+// is not eating, that a place is left among the philosophers allowed to eat and that his utensils are free :
+// - the table, the seat, the name and the priority of the philosopher, and the meal he asks for
+// - when he got hungry, and the time of the dinner when the Host decides
+// - how many philosophers of the table are eating, and how many are allowed to
+type StrategyRequest struct {
+	Table       int
+	Seat        int
+	Name        string
+	Priority    int
+	Meal        int
+	HungrySince time.Time
+	Now         time.Time
+	Eaters      int
+	MaxEaters   int
+}
+
+// Strategy is an admission policy of the Host, which gets the last word on a request to eat once the rules of the
+// table are met, and is told when a meal it admitted starts and when the philosopher releases his utensils. Each Host
+// has its own Strategy and calls it from its goroutine only. The strategies are registered by name with RegisterStrategy,
+// and the strategy of the Config selects one of them. With the lock-free admission the philosophers who find their
+// chopsticks free eat without asking the Host, and therefore without asking the Strategy
+type Strategy interface {
+	// Admit tells if the philosopher may eat now, along with the reason of a refusal
+	Admit(request StrategyRequest) (bool, string)
+	// Served tells that the philosopher of the seat starts eating
+	Served(seat int, now time.Time)
+	// Released tells that the philosopher of the seat released his utensils, his meal being over or paused
+	Released(seat int, now time.Time)
+}
+
+// StrategyFactory creates the Strategy of the Host of a table from a validated configuration
+type StrategyFactory func(config Config, table int) Strategy
+
+// strategies are the registered strategies, by name
+var strategies = struct {
+	sync.Mutex
+	factories map[string]StrategyFactory
+}{factories: map[string]StrategyFactory{greedyStrategy: func(Config, int) Strategy { return GreedyStrategy{} }}}
+
+// RegisterStrategy makes a Strategy available under the given name, it is meant to be called from the init function
+// of the file compiling in the strategy, and panics when the name is already taken, as database/sql.Register does
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategies.Lock()
+	defer strategies.Unlock()
+	if name == "" || factory == nil {
+		panic("RegisterStrategy: the strategy needs a name and a factory")
+	}
+	if _, found := strategies.factories[name]; found {
+		panic(fmt.Sprintf("RegisterStrategy: strategy %q registered twice", name))
+	}
+	strategies.factories[name] = factory
+}
+
+// Strategies returns the names of the registered strategies, sorted
+func Strategies() []string {
+	strategies.Lock()
+	defer strategies.Unlock()
+	var names []string
+	for name := range strategies.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategy creates the Strategy of the Host of a table, the strategy of the configuration being registered
+func NewStrategy(config Config, table int) Strategy {
+	strategies.Lock()
+	var factory = strategies.factories[config.Strategy]
+	strategies.Unlock()
+	if factory == nil {
+		return GreedyStrategy{}
+	}
+	return factory(config, table)
+}
+
+// validStrategy checks that the strategy is registered
+func validStrategy(name string) error {
+	strategies.Lock()
+	var _, found = strategies.factories[name]
+	strategies.Unlock()
+	if !found {
+		return fmt.Errorf("config: unknown strategy %q, expected one of %v", name, Strategies())
+	}
+	return nil
+}
+
+// GreedyStrategy admits every request, the Host letting a philosopher eat as soon as the rules of the table allow it
+type GreedyStrategy struct{}
+
+// Admit admits the request
+func (GreedyStrategy) Admit(StrategyRequest) (bool, string) { return true, "" }
+
+// Served does nothing
+func (GreedyStrategy) Served(int, time.Time) {}
+
+// Released does nothing
+func (GreedyStrategy) Released(int, time.Time) {}
@@ -0,0 +1,40 @@
+// Package main is the strategy of a student, a starting point to copy and change, graded by the exercise command
+// once built as a Go plugin :
+//
+//	go build -buildmode=plugin -o student.so ./student && go run . exercise -plugin student.so -pass 60
+//
+// A plugin cannot share the types of the simulation, its strategy only takes standard types, the ones of the
+// methods of a PluginStrategy.
+package main
+
+import "time"
+
+// NewStrategy creates the strategy of the given table, the exercise command calls it for each table of a scenario
+func NewStrategy(table int) any {
+	return &Strategy{eating: make(map[int]time.Time)}
+}
+
+// Strategy lets every philosopher eat as soon as the rules of the table allow it, which is safe but lets the
+// greediest philosophers eat the most : make it fair
+type Strategy struct {
+	eating map[int]time.Time // when each philosopher eating now started his meal
+}
+
+// Admit tells if the philosopher of the seat may eat his meal now, along with the reason of a refusal, given when
+// he got hungry, the time of the dinner and how many philosophers are eating and allowed to
+func (strategy *Strategy) Admit(seat, meal int, hungrySince, now time.Time, eaters, maxEaters int) (bool, string) {
+	return true, ""
+}
+
+// Served tells that the philosopher of the seat starts eating
+func (strategy *Strategy) Served(seat int, now time.Time) {
+	strategy.eating[seat] = now
+}
+
+// Released tells that the philosopher of the seat released his utensils
+func (strategy *Strategy) Released(seat int, now time.Time) {
+	delete(strategy.eating, seat)
+}
+
+// main is required by go build but not called, the exercise command opens the plugin instead
+func main() {}
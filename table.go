@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Table gathers everything needed for a dinner around one table :
+// - the philosophers seated according to the configured topology
+// - the utensils placed between them, chopsticks or forks and spoons depending on the configured variant
+// - the shards of the table, each of them being a contiguous arc of seats managed by its own Host, a single shard by default
+// - the Seating computed once for the Hosts, the number of philosophers eating and the maximum allowed, which the Hosts share
+// - the claims on the chopsticks shared by seats of different shards, a claimed chopstick being held by a philosopher
+// - the Admission letting the philosophers eat without asking the Host, nil unless the lock-free admission is enabled
+// - the PairAllocator giving the philosophers their utensils together, nil unless the acquisition is atomic
+// - the Ownership registry of who holds each utensil, nil unless in the check mode
+// - the central dish of the table, nil when the table has none
+// - the Kitchen shared with the other tables, nil when there is no shared rice pot
+// - the Reception welcoming the guests in the open mode, nil when the philosophers stay seated
+// - the WorkerPool running the philosophers, nil when each of them has his own goroutine
+// - the Liveness watching the heartbeats of the philosophers, nil unless the heartbeats are enabled
+// - the Clock the Hosts read the time from, nil for the real time, which is the case unless the DiscreteEngine simulates the dinner
+// - whether the dinner is stopped, so that the DiscreteEngine looks for the meals revoked by the Hosts
+// - the EventBus where the Hosts tell the requests they refused
+// - the Stats left by the Hosts once the table is closed
+type Table struct {
+	id           int
+	config       Config
+	philosophers []*Philosopher
+	utensils     []*ChopStick
+	shards       []*Shard
+	seating      Seating
+	eaters       atomic.Int64
+	maxEaters    atomic.Int64
+	claims       []atomic.Bool
+	admission    *Admission
+	allocator    *PairAllocator
+	ownership    *Ownership
+	failures     Failures
+	utensilWaits *UtensilWaits
+	dish         *Dish
+	kitchen      *Kitchen
+	reception    *Reception
+	pool         *WorkerPool
+	liveness     *Liveness
+	clock        *Clock
+	stopped      atomic.Bool
+	events       *EventBus
+	tenants      *Tenants
+	middlewares  []Middleware
+	observed     atomic.Bool // the Hosts publish their HostState after each message, for the State of the Simulation
+	stats        Stats
+}
+
+// Shard is the part of a table managed by one Host, the seats from first to last (excluded), along with :
+// - the HostActor managing them, and its Mailbox in which the philosophers of these seats send their requests
+// - the HostMetrics measuring the Host while the dinner takes place
+// - the Stats left by the Host once the table is closed
+// - the last HostState published by the Host when the table is observed
+type Shard struct {
+	first       int
+	last        int
+	host        *HostActor
+	requestChan Mailbox
+	metrics     HostMetrics
+	overload    *Overload
+	stats       Stats
+	hostDone    chan struct{}
+	published   atomic.Pointer[HostState]
+}
+
+// NewTable seats the philosophers around a new table, the name of the philosophers, their seat number unless
+// the seats are named, is prefixed with the table id when there are several tables
+func NewTable(id int, config Config, kitchen *Kitchen, events *EventBus) *Table {
+	// Placing the utensils, the configuration has already been validated so this cannot fail
+	var utensils, needs, _ = layUtensils(config)
+
+	// Creating the Philosophers, on a ring of 5 with chopsticks :
+	// philosopher 0 will need chopstick 0-1 and 0-4
+	// philosopher 1 will need chopstick 0-1 and 1-2
+	// ...
+	// philosopher 4 will need chopstick 0-4 and 3-4
+	// In the worker pool execution, the philosophers share the Random and the feedback channel of their worker
+	var philosophers = make([]*Philosopher, config.Philosophers)
+	var backoff = NewBackoff(config)
+	for philosopher := 0; philosopher < config.Philosophers; philosopher++ {
+		var name = config.Names.Name(philosopher)
+		if config.Tables > 1 {
+			name = fmt.Sprintf("%d.%s", id, name)
+		}
+		var random *Random
+		var feedbackChannel chan Grant
+		if config.Execution == goroutinesExecution {
+			random = NewRandom(config.Seed, id, philosopher)
+			feedbackChannel = make(chan Grant, config.FeedbackChannelSize)
+		}
+		var limiter *RateLimiter
+		if config.RateLimiter == philosophersRateLimiter {
+			limiter = NewRateLimiter(config, 1)
+		}
+		philosophers[philosopher] = &Philosopher{
+			id:              philosopher,
+			table:           id,
+			name:            name,
+			priority:        config.Priority(philosopher),
+			countEating:     0,
+			meals:           config.MealsOf(philosopher),
+			thinking:        time.Duration(config.Seat(philosopher).Think),
+			eating:          time.Duration(config.Seat(philosopher).Eat),
+			speed:           config.Speed,
+			confirmTimeout:  config.Scale(time.Duration(config.ConfirmTimeout)),
+			needs:           needs[philosopher],
+			energy:          NewEnergy(config),
+			random:          random,
+			events:          events,
+			limiter:         limiter,
+			backoff:         backoff,
+			feedbackChannel: feedbackChannel}
+	}
+
+	var dish *Dish
+	if config.DishCapacity > 0 {
+		dish = NewDish(config.DishCapacity)
+	}
+
+	var shards = make([]*Shard, config.Shards)
+	for shard := range shards {
+		shards[shard] = &Shard{
+			first:       shard * config.Philosophers / config.Shards,
+			last:        (shard + 1) * config.Philosophers / config.Shards,
+			requestChan: make(Mailbox, config.RequestChannelSize),
+			hostDone:    make(chan struct{})}
+		shards[shard].overload = NewOverload(config, &shards[shard].metrics)
+	}
+
+	var table = &Table{
+		id:           id,
+		config:       config,
+		philosophers: philosophers,
+		utensils:     utensils,
+		shards:       shards,
+		claims:       make([]atomic.Bool, len(utensils)),
+		dish:         dish,
+		kitchen:      kitchen,
+		events:       events}
+	for _, shard := range shards {
+		shard.host = NewHostActor(table, shard)
+	}
+	if config.Admission == lockFreeAdmission {
+		table.admission = NewAdmission(table)
+	}
+	table.seating = NewSeating(philosophers, config.Utensils == chopSticksVariant && config.Napkins == 0, func(seat, neighbor int) bool {
+		return table.admission != nil || table.shardOf(seat) != table.shardOf(neighbor)
+	})
+	table.maxEaters.Store(int64(config.MaxEaters))
+	table.tenants = NewTenants(config)
+	table.allocator = NewPairAllocator(config, utensils)
+	table.ownership = NewOwnership(config, id, utensils)
+	table.utensilWaits = NewUtensilWaits(config)
+	for _, philosopher := range philosophers {
+		philosopher.admission = table.admission
+		philosopher.allocator = table.allocator
+		philosopher.ownership = table.ownership
+		philosopher.failures = &table.failures
+		philosopher.utensilWaits = table.utensilWaits
+		philosopher.overload = table.shardOf(philosopher.id).overload
+	}
+	if config.ArrivalRate > 0 {
+		table.reception = NewReception(table)
+	}
+	if config.Execution == workerPoolExecution {
+		table.pool = NewWorkerPool(table)
+	}
+	table.liveness = NewLiveness(table)
+	for _, philosopher := range philosophers {
+		philosopher.liveness = table.liveness
+	}
+	return table
+}
+
+// shardOf returns the shard managing the given seat, the one whose arc starts last before it
+func (table *Table) shardOf(seat int) *Shard {
+	return table.shards[((seat+1)*len(table.shards)-1)/len(table.philosophers)]
+}
+
+// requests returns the Mailbox in which the philosopher of the given seat sends his requests
+func (table *Table) requests(seat int) Mailbox {
+	return table.shardOf(seat).requestChan
+}
+
+// reserveEater takes one of the places of the philosophers allowed to eat, it returns false when none is left
+func (table *Table) reserveEater() bool {
+	for {
+		var eaters = table.eaters.Load()
+		if eaters >= table.maxEaters.Load() {
+			return false
+		}
+		if table.eaters.CompareAndSwap(eaters, eaters+1) {
+			return true
+		}
+	}
+}
+
+// startHosts starts the Host of each shard
+func (table *Table) startHosts() {
+	// The host will ensure that a max of maxEaters philosophers eat at the same time
+	// and that this philosophers are not neighbors otherwise we could
+	// end up with a deadlock
+	for _, shard := range table.shards {
+		go shard.host.Run()
+	}
+}
+
+// Start starts the Hosts of the table and the goroutines for the philosophers, or the workers running them, each meal eaten is told to the Completion
+// In the open mode, the Reception seats the guests as they arrive and tells the Completion about them
+func (table *Table) Start(completion *Completion) {
+	table.startHosts()
+	table.liveness.Start()
+
+	if table.reception != nil {
+		go table.reception.Run(completion)
+		return
+	}
+
+	if table.pool != nil {
+		table.pool.Start(completion)
+		return
+	}
+	for _, philosopher := range table.philosophers {
+		go philosopher.eat(table.requests(philosopher.id), completion)
+	}
+}
+
+// Stop asks the Hosts to send the philosophers away as they ask to eat, and the Reception to seat no more guests
+// It must only be called once, before Close
+func (table *Table) Stop() {
+	table.stopped.Store(true)
+	table.admission.Stop()
+	for _, shard := range table.shards {
+		shard.host.Tell(Shutdown{})
+	}
+	if table.reception != nil {
+		close(table.reception.stop)
+	}
+}
+
+// Close stops the Hosts of the table and waits for their Stats, it must only be called once all the philosophers have finished eating
+func (table *Table) Close() {
+	table.liveness.Stop()
+	for _, shard := range table.shards {
+		close(shard.requestChan)
+		<-shard.hostDone
+	}
+	table.stats = table.shards[0].stats
+	for _, shard := range table.shards[1:] {
+		table.stats.add(shard.stats)
+	}
+	table.stats.admitted = table.admission.Accepted()
+	table.stats.atomicWaits, table.stats.atomicGrants = table.allocator.Waits()
+	table.stats.misuses = table.ownership.Violations()
+	table.stats.failures = table.failures.List()
+	if table.liveness != nil {
+		table.stats.incidents, table.stats.unresponsive = table.liveness.incidents, table.liveness.unresponsive
+	}
+}
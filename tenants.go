@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+const causeTenant = "tenant quota" // the cause of the rejections of the philosophers whose tenant eats its quota
+
+// Tenants shares a table among independent groups of philosophers, the tenants given in the settings of their seats :
+// the Host turns down a philosopher while the philosophers of his tenant eating reach its quota, so that a tenant
+// cannot take the whole table from the others, and each tenant is measured on its own. The Host of the table is the
+// only one counting the philosophers eating, the metrics being read from other goroutines while the dinner takes place.
+type Tenants struct {
+	seats   []*tenantMeter // the tenant of each seat, nil for the seats without tenant
+	tenants []*tenantMeter // sorted by name
+}
+
+// tenantMeter counts what the philosophers of a tenant do : how many of them eat now and at most, how many meals
+// they started, how many of their requests the quota turned down, and how long they waited for their meals
+type tenantMeter struct {
+	name     string
+	quota    int64
+	eating   atomic.Int64
+	peak     atomic.Int64
+	meals    atomic.Int64
+	rejected atomic.Int64
+	waited   atomic.Int64
+}
+
+// TenantLoad is what the Tenants of a table tell about a tenant : its quota, 0 when it has none, how many of its
+// philosophers eat now and at most, how many meals they started, how many requests the quota turned down, and how
+// long they waited for their meals in total
+type TenantLoad struct {
+	Table    int           `json:"table"`
+	Tenant   string        `json:"tenant"`
+	Quota    int           `json:"quota"`
+	Eating   int           `json:"eating"`
+	Peak     int           `json:"peak"`
+	Meals    int64         `json:"meals"`
+	Rejected int64         `json:"rejected"`
+	Waited   time.Duration `json:"waited"`
+}
+
+// NewTenants creates the Tenants of a table of a validated configuration, nil when no seat has a tenant
+func NewTenants(config Config) *Tenants {
+	var tenants = &Tenants{seats: make([]*tenantMeter, config.Philosophers)}
+	var byName = make(map[string]*tenantMeter)
+	for seat := range tenants.seats {
+		var name = config.Seat(seat).Tenant
+		if name == "" {
+			continue
+		}
+		if byName[name] == nil {
+			byName[name] = &tenantMeter{name: name, quota: int64(config.TenantQuotas[name])}
+			tenants.tenants = append(tenants.tenants, byName[name])
+		}
+		tenants.seats[seat] = byName[name]
+	}
+	if len(tenants.tenants) == 0 {
+		return nil
+	}
+	sort.Slice(tenants.tenants, func(i, j int) bool { return tenants.tenants[i].name < tenants.tenants[j].name })
+	return tenants
+}
+
+// validateTenants checks the quotas of the tenants, which are counted by the Host of the table and therefore need
+// the philosophers to ask it and a single Host
+func (config Config) validateTenants() error {
+	var tenants = make(map[string]bool)
+	for _, settings := range config.Seats {
+		if settings.Tenant != "" {
+			tenants[settings.Tenant] = true
+		}
+	}
+	for name, quota := range config.TenantQuotas {
+		if !tenants[name] {
+			return fmt.Errorf("config: quota given for tenant %q, which no seat belongs to", name)
+		}
+		if quota < 1 {
+			return fmt.Errorf("config: the quota of tenant %q must allow at least one philosopher to eat, got %d", name, quota)
+		}
+	}
+	if len(config.TenantQuotas) > 0 && (config.Shards > 1 || config.Admission == lockFreeAdmission) {
+		return fmt.Errorf("config: the quotas of the tenants are counted by the single Host of a table, they do not work with shards nor the lock-free admission")
+	}
+	return nil
+}
+
+// Full tells if the tenant of the seat eats its quota, the seats without tenant never being held back
+func (tenants *Tenants) Full(seat int) bool {
+	if tenants == nil || tenants.seats[seat] == nil {
+		return false
+	}
+	var tenant = tenants.seats[seat]
+	return tenant.quota > 0 && tenant.eating.Load() >= tenant.quota
+}
+
+// Name returns the tenant of the seat, empty when it has none
+func (tenants *Tenants) Name(seat int) string {
+	if tenants == nil || tenants.seats[seat] == nil {
+		return ""
+	}
+	return tenants.seats[seat].name
+}
+
+// Served counts a meal of the philosopher of the seat starting after the given wait
+func (tenants *Tenants) Served(seat int, wait time.Duration) {
+	if tenants == nil || tenants.seats[seat] == nil {
+		return
+	}
+	var tenant = tenants.seats[seat]
+	storeMax(&tenant.peak, tenant.eating.Add(1))
+	tenant.meals.Add(1)
+	tenant.waited.Add(int64(wait))
+}
+
+// Rejected counts a request of the philosopher of the seat turned down by the quota of his tenant
+func (tenants *Tenants) Rejected(seat int) {
+	if tenants != nil && tenants.seats[seat] != nil {
+		tenants.seats[seat].rejected.Add(1)
+	}
+}
+
+// Released tells that the philosopher of the seat released his utensils, his meal being over or paused
+func (tenants *Tenants) Released(seat int) {
+	if tenants != nil && tenants.seats[seat] != nil {
+		tenants.seats[seat].eating.Add(-1)
+	}
+}
+
+// TenantLoads returns what the Tenants of the table tell now, sorted by tenant
+func (table *Table) TenantLoads() []TenantLoad {
+	if table.tenants == nil {
+		return nil
+	}
+	var loads = make([]TenantLoad, 0, len(table.tenants.tenants))
+	for _, tenant := range table.tenants.tenants {
+		loads = append(loads, TenantLoad{Table: table.id, Tenant: tenant.name, Quota: int(tenant.quota), Eating: int(tenant.eating.Load()),
+			Peak: int(tenant.peak.Load()), Meals: tenant.meals.Load(), Rejected: tenant.rejected.Load(), Waited: time.Duration(tenant.waited.Load())})
+	}
+	return loads
+}
+
+// TenantLoads returns what the Tenants of each table tell now
+func (simulation *Simulation) TenantLoads() []TenantLoad {
+	var loads []TenantLoad
+	for _, table := range simulation.tables {
+		loads = append(loads, table.TenantLoads()...)
+	}
+	return loads
+}
+
+// WriteTenantLoads writes the meals, the peak against the quota, the rejections and the mean wait of each tenant at
+// the end of the dinner, nothing when the seats have no tenant
+func WriteTenantLoads(w io.Writer, result Result) {
+	if len(result.Tables) == 0 || result.Tables[0].tenants == nil {
+		return
+	}
+	var writer = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tTENANT\tMEALS\tPEAK\tQUOTA\tREJECTED\tMEAN WAIT")
+	for _, table := range result.Tables {
+		for _, load := range table.TenantLoads() {
+			var quota = "-"
+			if load.Quota > 0 {
+				quota = fmt.Sprint(load.Quota)
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%d\t%d\t%s\t%d\t%v\n", load.Table, load.Tenant, load.Meals, load.Peak, quota, load.Rejected,
+				meanDuration(load.Waited, load.Meals).Round(time.Millisecond))
+		}
+	}
+	writer.Flush()
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Below are the types of the messages of the text protocol, sent by the philosopher
+const textJoin = "join"
+const textAsk = "ask"
+const textFinished = "finished"
+const textPaused = "paused"
+const textStarved = "starved"
+const textPing = "ping"
+
+// Below are the types of the messages of the text protocol, sent by the Host
+const textWelcome = "welcome"
+const textGranted = "granted"
+const textRejected = "rejected"
+const textPreempt = "preempt"
+const textShutdown = "shutdown"
+const textPong = "pong"
+const textError = "error"
+
+// TextMessage is a message of the text protocol, which lets philosophers written in any language join a served table :
+// each message is a JSON object on its own line, whose type tells what it is.
+// The philosopher sends :
+// - join with his seat, first
+// - ask when he would like to eat, the Host answering with granted, along with the number of his meal and the ids of
+// his utensils in locking order, or with rejected, along with when to ask again when the Host is overloaded
+// - finished when he has finished the meal he was granted, paused when he paused it after a preempt
+// - starved when he gives up his remaining meals
+// - ping when he has nothing else to say, the Host answering with pong, since a philosopher silent for 30 seconds is gone
+// The Host sends :
+// - welcome with his seat, his name, the meals he still has to eat, the configuration of the table and the revision
+// of the schema, or error with the reason why he cannot sit, after which the connection is closed
+// - granted, rejected, preempt when he is asked to pause his meal, shutdown when the dinner is stopped
+// - error when a message breaks the protocol, such as a meal finished before it was granted, the message being ignored
+// The Host keeps the state of each connection, the philosopher never tells the number of his meals nor when he got
+// hungry.
+type TextMessage struct {
+	Type       string          `json:"type"`
+	Seat       int             `json:"seat"`
+	Name       string          `json:"name,omitempty"`
+	Meal       int             `json:"meal"`
+	Meals      int             `json:"meals,omitempty"`
+	Utensils   []int           `json:"utensils,omitempty"`
+	RetryAfter Duration        `json:"retryAfter,omitempty"`
+	Config     json.RawMessage `json:"config,omitempty"`
+	Version    int             `json:"version,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// textConn is a connection talking the text protocol, either over TCP or over the standard input and output of a
+// command, which keeps what the philosopher of the connection is doing :
+// - seat is the seat he joined
+// - meal is the number of his current meal, counted from 0 since he joined
+// - hungrySince is when he first asked to eat his current meal
+// - asking tells that he waits for the answer of the Host, eating that the Host let him eat his current meal
+type textConn struct {
+	io.ReadWriteCloser
+	lines       *bufio.Scanner
+	writeMutex  sync.Mutex
+	mutex       sync.Mutex
+	seat        int
+	meal        int
+	hungrySince time.Time
+	asking      bool
+	eating      bool
+}
+
+// newTextConn starts the text protocol over the connection
+func newTextConn(conn io.ReadWriteCloser) *textConn {
+	var lines = bufio.NewScanner(conn)
+	lines.Buffer(make([]byte, 4096), maxFrameSize)
+	return &textConn{ReadWriteCloser: conn, lines: lines}
+}
+
+// read reads the next message, failing when the philosopher is silent for heartbeatTimeout on the connections which
+// have deadlines, and when the line is not a message
+func (conn *textConn) read() (TextMessage, error) {
+	var message TextMessage
+	if deadline, ok := conn.ReadWriteCloser.(interface{ SetReadDeadline(time.Time) error }); ok {
+		deadline.SetReadDeadline(time.Now().Add(heartbeatTimeout))
+	}
+	if !conn.lines.Scan() {
+		if conn.lines.Err() != nil {
+			return message, conn.lines.Err()
+		}
+		return message, io.EOF
+	}
+	if err := json.Unmarshal(conn.lines.Bytes(), &message); err != nil {
+		return message, fmt.Errorf("invalid message %q: %v", conn.lines.Text(), err)
+	}
+	return message, nil
+}
+
+// write sends a message on its own line
+func (conn *textConn) write(message TextMessage) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+	_, err = conn.Write(append(line, '\n'))
+	return err
+}
+
+// refuse tells the philosopher that the message breaks the protocol, the connection going on
+func (conn *textConn) refuse(seat int, reason string) error {
+	return conn.write(TextMessage{Type: textError, Seat: seat, Error: reason})
+}
+
+// readJoin reads the join message the connection starts with
+func (conn *textConn) readJoin() (JoinMessage, error) {
+	message, err := conn.read()
+	if err != nil {
+		return JoinMessage{}, err
+	}
+	if message.Type != textJoin {
+		return JoinMessage{}, fmt.Errorf("expected a %s message, got %q", textJoin, message.Type)
+	}
+	conn.seat = message.Seat
+	return JoinMessage{seat: message.Seat}, nil
+}
+
+func (conn *textConn) welcome(welcome WelcomeMessage) error {
+	if welcome.err != "" {
+		return conn.write(TextMessage{Type: textError, Seat: welcome.seat, Error: welcome.err})
+	}
+	return conn.write(TextMessage{Type: textWelcome, Seat: welcome.seat, Name: welcome.name, Meals: welcome.mealsLeft,
+		Config: welcome.config, Version: schemaVersion})
+}
+
+// request reads the messages of the philosopher until one of them is a request for the Host, answering the pings and
+// refusing the messages which break the protocol
+func (conn *textConn) request(seat int) (Request, error) {
+	for {
+		message, err := conn.read()
+		if err != nil {
+			if err == io.EOF || conn.lines.Err() != nil {
+				return Request{}, err
+			}
+			conn.refuse(seat, err.Error())
+			continue
+		}
+		conn.mutex.Lock()
+		var request = Request{philosopher: seat, meal: conn.meal, sent: time.Now()}
+		var reason string
+		switch message.Type {
+		case textAsk:
+			if conn.asking || conn.eating {
+				reason = "already waiting for an answer or eating"
+				break
+			}
+			if conn.hungrySince.IsZero() {
+				conn.hungrySince = request.sent
+			}
+			request.command, request.hungrySince, conn.asking = wantToEat, conn.hungrySince, true
+		case textFinished, textPaused:
+			if !conn.eating {
+				reason = fmt.Sprintf("no meal granted to be %s", message.Type)
+				break
+			}
+			request.command, conn.eating = finishedEating, false
+			if message.Type == textPaused {
+				request.command = pausedEating
+			} else {
+				conn.meal, conn.hungrySince = conn.meal+1, time.Time{}
+			}
+		case textStarved:
+			if conn.eating {
+				reason = "cannot starve while eating"
+				break
+			}
+			request.command, request.hungrySince = starved, conn.hungrySince
+		case textPing:
+			conn.mutex.Unlock()
+			conn.write(TextMessage{Type: textPong, Seat: seat})
+			continue
+		default:
+			reason = fmt.Sprintf("unknown message %q, expected %s, %s, %s, %s or %s", message.Type, textAsk, textFinished, textPaused, textStarved, textPing)
+		}
+		conn.mutex.Unlock()
+		if reason != "" {
+			conn.refuse(seat, reason)
+			continue
+		}
+		return request, nil
+	}
+}
+
+func (conn *textConn) answer(grant Grant) error {
+	conn.mutex.Lock()
+	var message = TextMessage{Type: textRejected, Seat: conn.seat, Meal: conn.meal}
+	switch {
+	case grant.shutdown:
+		message.Type, conn.asking, conn.eating = textShutdown, false, false
+	case grant.preempt:
+		message.Type = textPreempt
+	case grant.allowed:
+		message.Type, conn.asking, conn.eating = textGranted, false, true
+		for _, chopStick := range grant.chopSticks {
+			message.Utensils = append(message.Utensils, chopStick.id)
+		}
+	default:
+		message.RetryAfter, conn.asking = Duration(grant.retryAfter), false
+	}
+	conn.mutex.Unlock()
+	return conn.write(message)
+}
+
+// commandConn is the standard input and output of a command, seen as a connection
+type commandConn struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (conn commandConn) Close() error {
+	conn.WriteCloser.Close()
+	return conn.ReadCloser.Close()
+}
+
+// ServeTextTable runs the Host of the table described by the configuration for philosophers talking the text protocol
+// (see TextMessage), until they have all eaten all their meals : the ones joining on the given address when it is
+// not empty, and the ones run by the command when it is not empty, once per seat, the command telling its seat in
+// PHILOSOPHER_SEAT and being run again when it ends before eating all its meals
+func ServeTextTable(address string, command []string, config Config, events *EventBus) (Result, error) {
+	if err := checkServedConfig(config); err != nil {
+		return Result{}, err
+	}
+	var server = NewTableServer(config, events, nil)
+	if address != "" {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return Result{}, err
+		}
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					var text = newTextConn(conn)
+					join, err := text.readJoin()
+					if err != nil {
+						text.refuse(-1, err.Error())
+						conn.Close()
+						return
+					}
+					server.serve(text, join.seat)
+				}()
+			}
+		}()
+	}
+	if len(command) > 0 {
+		for seat := range config.Philosophers {
+			go server.runCommand(command, seat, events)
+		}
+	}
+	return server.Wait(), nil
+}
+
+// runCommand runs the command of the philosopher of the seat until he has eaten all his meals, giving up after
+// maxReconnects runs which ended before, the errors being told by error events
+func (server *TableServer) runCommand(command []string, seat int, events *EventBus) {
+	for run := 0; run < maxReconnects; run++ {
+		if run > 0 {
+			time.Sleep(reconnectDelay)
+		}
+		var err = server.runCommandOnce(command, seat)
+		server.mutex.Lock()
+		var done = server.seats[seat].eaten >= server.table.config.MealsOf(seat)
+		server.mutex.Unlock()
+		if done {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("exited before eating all his meals")
+		}
+		events.Emit(Event{Table: 0, Philosopher: seat, Name: server.table.philosophers[seat].name, Kind: eventError,
+			Detail: fmt.Sprintf("command %v of seat %d: %v", command, seat, err)})
+	}
+}
+
+// runCommandOnce runs the command of the philosopher of the seat once, serving him over its standard input and output
+func (server *TableServer) runCommandOnce(command []string, seat int) error {
+	var cmd = exec.Command(command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PHILOSOPHER_SEAT=%d", seat))
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	var text = newTextConn(commandConn{ReadCloser: stdout, WriteCloser: stdin})
+	if join, err := text.readJoin(); err != nil {
+		text.refuse(seat, err.Error())
+		text.Close()
+	} else if join.seat != seat {
+		text.refuse(seat, fmt.Sprintf("this command runs the philosopher of seat %d, not %d", seat, join.seat))
+		text.Close()
+	} else {
+		server.serve(text, seat)
+	}
+	return cmd.Wait()
+}
@@ -0,0 +1,79 @@
+package main
+
+// Tickets serves the philosophers of a table in the order they got hungry, as in the bakery algorithm of Lamport,
+// which is the ticket admission. It is owned by the Host :
+// - each philosopher draws a ticket with his first request of a meal, a number larger than all the tickets drawn before
+// at the table, and keeps it until he eats or leaves
+// - the Host only lets a philosopher eat when no philosopher holding a smaller ticket waits for one of his utensils,
+// or for the last place at the table, the requests compatible with the waiters before them being served at once
+// The waiter holding the smallest ticket is never turned down because of the tickets, so that every philosopher eats
+// after a bounded number of meals of the others, and the decisions of the Host tell the ticket of the philosopher
+// A nil Tickets means that the ticket admission is disabled.
+type Tickets struct {
+	drawn   uint64
+	waiting map[int]Ticket
+}
+
+// Ticket is the ticket a philosopher drew for a meal
+type Ticket struct {
+	number      uint64
+	philosopher *Philosopher
+	meal        int
+}
+
+// NewTickets creates the ticket dispenser of a Host, it returns nil unless the configuration asks for the ticket admission
+func NewTickets(config Config) *Tickets {
+	if config.Admission != ticketAdmission {
+		return nil
+	}
+	return &Tickets{waiting: make(map[int]Ticket)}
+}
+
+// Draw returns the ticket of the philosopher for his current meal, drawing a new one on his first request of the meal
+func (tickets *Tickets) Draw(philosopher *Philosopher) uint64 {
+	if tickets == nil {
+		return 0
+	}
+	var ticket, drawn = tickets.waiting[philosopher.id]
+	if !drawn || ticket.meal != philosopher.countEating {
+		tickets.drawn++
+		ticket = Ticket{number: tickets.drawn, philosopher: philosopher, meal: philosopher.countEating}
+		tickets.waiting[philosopher.id] = ticket
+	}
+	return ticket.number
+}
+
+// GiveWay tells if the philosopher has to be turned down in favor of the waiter holding the smallest ticket before his
+// which needs one of his utensils, or any waiter before him when lastPlace tells that only one more philosopher can eat
+func (tickets *Tickets) GiveWay(philosopher *Philosopher, lastPlace bool) (Ticket, bool) {
+	if tickets == nil {
+		return Ticket{}, false
+	}
+	var asking = tickets.waiting[philosopher.id]
+	var first Ticket
+	for id, waiter := range tickets.waiting {
+		if id == philosopher.id || waiter.number > asking.number || (first.philosopher != nil && waiter.number > first.number) {
+			continue
+		}
+		if lastPlace || shareUtensils(waiter.philosopher.needs, philosopher.needs) {
+			first = waiter
+		}
+	}
+	return first, first.philosopher != nil
+}
+
+// Served records that the philosopher starts eating, his ticket is used
+func (tickets *Tickets) Served(philosopher *Philosopher) {
+	if tickets == nil {
+		return
+	}
+	delete(tickets.waiting, philosopher.id)
+}
+
+// Left forgets the ticket of a philosopher who left the table without eating, or whose seat was given to a guest
+func (tickets *Tickets) Left(seat int) {
+	if tickets == nil {
+		return
+	}
+	delete(tickets.waiting, seat)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Topology describes how the philosophers are seated, it is an adjacency list where topology[i] holds
+// the philosophers sharing a chopstick with philosopher i. Each pair of neighbors shares exactly one chopstick,
+// so two neighbors can never eat at the same time.
+type Topology [][]int
+
+// RingTopology returns the classic round table where philosopher i shares a chopstick with
+// philosophers i-1 and i+1 (philosopher n-1 and philosopher 0 being neighbors)
+func RingTopology(n int) Topology {
+	var topology = make(Topology, n)
+	for philosopher := 0; philosopher < n; philosopher++ {
+		topology.link(philosopher, (philosopher+1)%n)
+	}
+	return topology
+}
+
+// GridTopology returns philosophers seated on a grid of width columns and height rows, philosopher
+// y*width+x sharing a chopstick with the philosophers above, below, on his left and on his right.
+// When wrap is true the grid is a torus : the borders are connected and every philosopher has four neighbors.
+func GridTopology(width, height int, wrap bool) Topology {
+	var topology = make(Topology, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var philosopher = y*width + x
+			if x+1 < width {
+				topology.link(philosopher, philosopher+1)
+			} else if wrap {
+				topology.link(philosopher, y*width)
+			}
+			if y+1 < height {
+				topology.link(philosopher, philosopher+width)
+			} else if wrap {
+				topology.link(philosopher, x)
+			}
+		}
+	}
+	return topology
+}
+
+// ParseTopology builds a topology from its description :
+// - "ring:N" for N philosophers around a round table
+// - "grid:WxH" for W*H philosophers on a grid, each of them having up to four neighbors
+// - "torus:WxH" for the same grid with its borders connected
+func ParseTopology(description string) (Topology, error) {
+	var kind, size, found = strings.Cut(description, ":")
+	if !found {
+		return nil, fmt.Errorf("topology: %q should look like ring:N, grid:WxH or torus:WxH", description)
+	}
+
+	switch kind {
+	case "ring":
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("topology: invalid ring size %q", size)
+		}
+		return RingTopology(n), nil
+	case "grid", "torus":
+		var width, height, found = strings.Cut(size, "x")
+		w, errWidth := strconv.Atoi(width)
+		h, errHeight := strconv.Atoi(height)
+		if !found || errWidth != nil || errHeight != nil || w < 1 || h < 1 {
+			return nil, fmt.Errorf("topology: invalid %s size %q, expected WxH", kind, size)
+		}
+		return GridTopology(w, h, kind == "torus"), nil
+	}
+	return nil, fmt.Errorf("topology: unknown kind %q, expected ring, grid or torus", kind)
+}
+
+// UnmarshalJSON accepts either an adjacency list or a description understood by ParseTopology
+func (topology *Topology) UnmarshalJSON(data []byte) error {
+	var description string
+	if err := json.Unmarshal(data, &description); err == nil {
+		parsed, err := ParseTopology(description)
+		if err != nil {
+			return err
+		}
+		*topology = parsed
+		return nil
+	}
+
+	var adjacency [][]int
+	if err := json.Unmarshal(data, &adjacency); err != nil {
+		return err
+	}
+	*topology = adjacency
+	return nil
+}
+
+// link makes the two philosophers neighbors, unless they are the same philosopher or already neighbors
+func (topology Topology) link(a, b int) {
+	if a == b || topology.AreNeighbors(a, b) {
+		return
+	}
+	topology[a] = append(topology[a], b)
+	topology[b] = append(topology[b], a)
+}
+
+// Validate checks that the topology is a proper undirected graph :
+// - every neighbor is a known philosopher
+// - no philosopher is his own neighbor, and no neighbor is listed twice
+// - if a is a neighbor of b then b is a neighbor of a
+func (topology Topology) Validate() error {
+	for philosopher, neighbors := range topology {
+		var seen = make(map[int]bool)
+		for _, neighbor := range neighbors {
+			if neighbor < 0 || neighbor >= len(topology) {
+				return fmt.Errorf("topology: philosopher %d has unknown neighbor %d", philosopher, neighbor)
+			}
+			if neighbor == philosopher {
+				return fmt.Errorf("topology: philosopher %d cannot be his own neighbor", philosopher)
+			}
+			if seen[neighbor] {
+				return fmt.Errorf("topology: philosopher %d lists neighbor %d twice", philosopher, neighbor)
+			}
+			seen[neighbor] = true
+			if !topology.AreNeighbors(neighbor, philosopher) {
+				return fmt.Errorf("topology: philosopher %d lists %d as neighbor but not the other way around", philosopher, neighbor)
+			}
+		}
+	}
+	return nil
+}
+
+// AreNeighbors tells if the two philosophers share a chopstick
+func (topology Topology) AreNeighbors(a, b int) bool {
+	for _, neighbor := range topology[a] {
+		if neighbor == b {
+			return true
+		}
+	}
+	return false
+}
+
+// ChopSticks returns one chopstick per pair of neighbors, each chopstick being described by the ids of
+// the two philosophers sharing it (lowest id first). Chopsticks are sorted so that their index can be used
+// as a global locking order.
+func (topology Topology) ChopSticks() [][2]int {
+	var chopSticks [][2]int
+	for philosopher, neighbors := range topology {
+		for _, neighbor := range neighbors {
+			if philosopher < neighbor {
+				chopSticks = append(chopSticks, [2]int{philosopher, neighbor})
+			}
+		}
+	}
+	sort.Slice(chopSticks, func(i, j int) bool {
+		if chopSticks[i][0] != chopSticks[j][0] {
+			return chopSticks[i][0] < chopSticks[j][0]
+		}
+		return chopSticks[i][1] < chopSticks[j][1]
+	})
+	return chopSticks
+}
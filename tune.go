@@ -0,0 +1,238 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Below are the objectives the tune command minimizes
+const p99Objective = "p99"           // the 99th percentile of the waits before the meals
+const meanObjective = "mean"         // the mean wait before the meals
+const makespanObjective = "makespan" // how long the dinner lasts
+
+// TuningParameter is a setting of the Config the tune command searches over :
+// - its name, as in the config file, and the bounds of its values
+// - whether its values are searched on a logarithmic scale, for the durations spanning several orders of magnitude
+// - how to read it from a configuration, write it into one and print one of its values
+type TuningParameter struct {
+	Name   string
+	Min    float64
+	Max    float64
+	Log    bool
+	read   func(config Config) float64
+	write  func(config *Config, value float64)
+	format func(value float64) string
+}
+
+// tuningParameters returns the parameters the tune command knows, for a dinner of the given configuration
+func tuningParameters(config Config) []TuningParameter {
+	var duration = func(value float64) string { return time.Duration(value).Round(time.Millisecond).String() }
+	return []TuningParameter{
+		{Name: "maxEaters", Min: 1, Max: float64(max(largestIndependentSet(config.Topology), 1)),
+			read:   func(config Config) float64 { return float64(config.MaxEaters) },
+			write:  func(config *Config, value float64) { config.MaxEaters = int(math.Round(value)) },
+			format: func(value float64) string { return fmt.Sprint(int(math.Round(value))) }},
+		{Name: "backoffBase", Min: float64(time.Millisecond), Max: float64(time.Second), Log: true,
+			read:   func(config Config) float64 { return float64(config.BackoffBase) },
+			write:  func(config *Config, value float64) { config.BackoffBase = Duration(value) },
+			format: duration},
+		{Name: "backoffMax", Min: float64(time.Millisecond), Max: float64(10 * time.Second), Log: true,
+			read:   func(config Config) float64 { return float64(config.BackoffMax) },
+			write:  func(config *Config, value float64) { config.BackoffMax = Duration(value) },
+			format: duration},
+		{Name: "agingRate", Min: 0, Max: 50,
+			read:   func(config Config) float64 { return config.AgingRate },
+			write:  func(config *Config, value float64) { config.AgingRate = value },
+			format: func(value float64) string { return fmt.Sprintf("%.2f", value) }},
+	}
+}
+
+// Tuner searches the parameters minimizing an objective by simulated annealing, each candidate being a dinner
+// simulated by the DiscreteEngine with the same seed, so that two candidates only differ by their parameters :
+// - the configuration the search starts from, and the parameters it changes
+// - the objective, and the Random drawing the moves of the search
+type Tuner struct {
+	config     Config
+	parameters []TuningParameter
+	objective  string
+	random     *Random
+}
+
+// NewTuner creates the Tuner of the dinner of a validated configuration, for the named parameters
+func NewTuner(config Config, names []string, objective string, seed int64) (*Tuner, error) {
+	if objective != p99Objective && objective != meanObjective && objective != makespanObjective {
+		return nil, fmt.Errorf("tune: unknown objective %q, expected %q, %q or %q", objective, p99Objective, meanObjective, makespanObjective)
+	}
+	config.Engine = discreteEngine
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("tune: %v", err)
+	}
+	var tuner = &Tuner{config: config, objective: objective, random: NewRandom(seed, -4)}
+	var known = tuningParameters(config)
+	for _, name := range names {
+		var found = false
+		for _, parameter := range known {
+			if parameter.Name == strings.TrimSpace(name) {
+				tuner.parameters = append(tuner.parameters, parameter)
+				found = true
+			}
+		}
+		if !found {
+			var names []string
+			for _, parameter := range known {
+				names = append(names, parameter.Name)
+			}
+			return nil, fmt.Errorf("tune: unknown parameter %q, expected some of %s", name, strings.Join(names, ", "))
+		}
+	}
+	if len(tuner.parameters) == 0 {
+		return nil, fmt.Errorf("tune: no parameter to tune")
+	}
+	return tuner, nil
+}
+
+// Score runs the dinner of the configuration and returns its objective, lower being better, +Inf when a
+// philosopher starved
+func (tuner *Tuner) Score(config Config) float64 {
+	var simulation = NewSimulation(config)
+	var recorder = NewRecorder(false)
+	var whole = NewSteadyState(0, 0)
+	simulation.Events().SetQuiet()
+	simulation.Events().Handle(recorder.Record)
+	simulation.Events().Handle(whole.Handle)
+	if simulation.Run().Failed() {
+		return math.Inf(1)
+	}
+	var summary = whole.Summary()
+	switch tuner.objective {
+	case meanObjective:
+		var total time.Duration
+		for _, wait := range summary.Waits {
+			total += wait
+		}
+		return float64(meanDuration(total, int64(len(summary.Waits))))
+	case makespanObjective:
+		return float64(recorder.Span())
+	}
+	return float64(summary.Percentile(99))
+}
+
+// Run anneals for the given number of iterations, the temperature falling from 1 to 0.01, and returns the best
+// configuration found along with its score. A worse candidate is accepted with the probability exp(-Δ/T), Δ being
+// how much worse it is relatively to the current one. Each improvement of the best configuration is told to report.
+func (tuner *Tuner) Run(iterations int, report func(iteration int, config Config, score float64)) (Config, float64) {
+	var current = tuner.config
+	var currentScore = tuner.Score(current)
+	var best, bestScore = current, currentScore
+	report(0, current, currentScore)
+	for iteration := 1; iteration <= iterations; iteration++ {
+		var temperature = math.Pow(0.01, float64(iteration)/float64(iterations))
+		var candidate, valid = tuner.move(current, temperature)
+		if !valid {
+			continue
+		}
+		var score = tuner.Score(candidate)
+		var delta = (score - currentScore) / math.Max(currentScore, 1)
+		if score <= currentScore || (!math.IsInf(score, 1) && tuner.random.Float64() < math.Exp(-delta/temperature)) {
+			current, currentScore = candidate, score
+		}
+		if score < bestScore {
+			best, bestScore = candidate, score
+			report(iteration, candidate, score)
+		}
+	}
+	return best, bestScore
+}
+
+// move changes one of the parameters of the configuration at random, by a step shrinking with the temperature,
+// it returns false when the resulting configuration is not valid
+func (tuner *Tuner) move(config Config, temperature float64) (Config, bool) {
+	var parameter = tuner.parameters[tuner.random.Intn(len(tuner.parameters))]
+	var value, low, high = parameter.read(config), parameter.Min, parameter.Max
+	if parameter.Log {
+		value, low, high = math.Log(math.Max(value, low)), math.Log(low), math.Log(high)
+	}
+	var step = (high - low) * 0.2 * math.Max(temperature, 0.05) * tuner.random.ExpFloat64()
+	if tuner.random.Intn(2) == 0 {
+		step = -step
+	}
+	value = math.Min(high, math.Max(low, value+step))
+	if parameter.Log {
+		value = math.Exp(value)
+	}
+	parameter.write(&config, value)
+	return config, config.Validate() == nil
+}
+
+// Describe tells the values of the tuned parameters of the configuration
+func (tuner *Tuner) Describe(config Config) string {
+	var values []string
+	for _, parameter := range tuner.parameters {
+		values = append(values, fmt.Sprintf("%s %s", parameter.Name, parameter.format(parameter.read(config))))
+	}
+	return strings.Join(values, ", ")
+}
+
+// tune is the tune command, it searches the parameters of the Host and of the philosophers minimizing an objective
+// of the dinner of the config file, simulating each candidate with the discrete engine, and writes the best
+// configuration found
+func tune(arguments []string) error {
+	var flags = flag.NewFlagSet("tune", flag.ExitOnError)
+	var configFile = flags.String("config", "", "JSON file describing the dinner, see the -config flag of the program")
+	var objective = flags.String("objective", p99Objective, "what to minimize : p99 or mean wait before the meals, or makespan of the dinner")
+	var parameters = flags.String("parameters", "maxEaters,backoffBase,backoffMax,agingRate", "parameters to tune, separated by commas")
+	var iterations = flags.Int("iterations", 200, "how many candidates are simulated")
+	var seed = flags.Int64("seed", 0, "seed of the dinners and of the search, the one of the config file or a random one by default")
+	var out = flags.String("out", "", "write the best configuration in this file (such as tuned.json) instead of the standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s tune [-config dinner.json] [-objective p99] [-parameters maxEaters,agingRate] [-iterations 200] [-out tuned.json]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	config, err := LoadConfig(*configFile, Config{})
+	if err != nil {
+		return err
+	}
+	if *seed != 0 {
+		config.Seed = *seed
+	}
+	tuner, err := NewTuner(config, strings.Split(*parameters, ","), *objective, config.Seed)
+	if err != nil {
+		return err
+	}
+	if config.Backoff == thinkBackoff || config.Backoff == immediateBackoff {
+		fmt.Printf("The %s backoff has no bounds, backoffBase and backoffMax change nothing\n", config.Backoff)
+	}
+
+	var format = func(score float64) string {
+		if math.IsInf(score, 1) {
+			return "starvation"
+		}
+		return time.Duration(score).Round(time.Millisecond).String()
+	}
+	fmt.Printf("Tuning %s for the %s with the seed %d\n", tuner.Describe(config), *objective, config.Seed)
+	var best, score = tuner.Run(*iterations, func(iteration int, config Config, score float64) {
+		fmt.Printf("%4d : %s %s with %s\n", iteration, *objective, format(score), tuner.Describe(config))
+	})
+	fmt.Printf("Best %s %s with %s\n", *objective, format(score), tuner.Describe(best))
+
+	// the best configuration runs with the engine of the config file
+	best.Engine = config.Engine
+	data, err := json.MarshalIndent(best, "", "\t")
+	if err != nil {
+		return fmt.Errorf("tune: %v", err)
+	}
+	if *out == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	return os.WriteFile(*out, append(data, '\n'), 0644)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// confirmPoll is how long a philosopher confirming a two-phase grant waits before trying again to take a utensil still held
+const confirmPoll = 50 * time.Microsecond
+
+// Reservations follows the two-phase grants of a Host : a grant only reserves the utensils of the meal, the Host
+// marking them as held by the philosopher at once, and the philosopher then confirms that he took them, or gives the
+// grant back when one of them is still held by the previous eater at the end of the confirm timeout. This closes the
+// window where a philosopher is allowed to eat while the mutexes of his utensils are not free yet : the Host knows
+// which seats actually eat, and which ones only hold a reservation.
+// A meal which ends before its grant is confirmed, such as the one of a remote philosopher whose connection is lost,
+// is counted as unconfirmed.
+// A nil Reservations means that the grants are final, the philosopher being trusted to take his utensils.
+type Reservations struct {
+	since       map[int]time.Time // the seats whose grant is not confirmed yet, by the time it was granted
+	confirmed   int
+	aborted     int
+	unconfirmed int
+	confirming  time.Duration
+	longest     time.Duration
+}
+
+// NewReservations creates the Reservations of a Host, nil unless the configuration sets confirmTimeout
+func NewReservations(config Config) *Reservations {
+	if config.ConfirmTimeout == 0 {
+		return nil
+	}
+	return &Reservations{since: make(map[int]time.Time)}
+}
+
+// Reserved records that the seat was granted a meal, its utensils being reserved until he confirms
+func (reservations *Reservations) Reserved(seat int, now time.Time) {
+	if reservations != nil {
+		reservations.since[seat] = now
+	}
+}
+
+// Pending tells if the grant of the seat waits for its confirmation
+func (reservations *Reservations) Pending(seat int) bool {
+	if reservations == nil {
+		return false
+	}
+	var _, pending = reservations.since[seat]
+	return pending
+}
+
+// Confirmed records that the philosopher of the seat took his utensils, or gave them back when aborted
+func (reservations *Reservations) Confirmed(seat int, aborted bool, now time.Time) {
+	var confirming = now.Sub(reservations.since[seat])
+	delete(reservations.since, seat)
+	if aborted {
+		reservations.aborted++
+		return
+	}
+	reservations.confirmed++
+	reservations.confirming += confirming
+	reservations.longest = max(reservations.longest, confirming)
+}
+
+// Released records that the meal of the seat ended, before its grant was confirmed when it is still pending
+func (reservations *Reservations) Released(seat int) {
+	if reservations.Pending(seat) {
+		delete(reservations.since, seat)
+		reservations.unconfirmed++
+	}
+}
+
+// Seats returns the seats whose grant waits for its confirmation, sorted
+func (reservations *Reservations) Seats() []int {
+	if reservations == nil || len(reservations.since) == 0 {
+		return nil
+	}
+	var seats = make([]int, 0, len(reservations.since))
+	for seat := range reservations.since {
+		seats = append(seats, seat)
+	}
+	sort.Ints(seats)
+	return seats
+}
+
+// add adds the Reservations of another shard of the table
+func (reservations *Reservations) add(other *Reservations) {
+	reservations.confirmed += other.confirmed
+	reservations.aborted += other.aborted
+	reservations.unconfirmed += other.unconfirmed
+	reservations.confirming += other.confirming
+	reservations.longest = max(reservations.longest, other.longest)
+}
+
+// String tells how the grants were confirmed
+func (reservations *Reservations) String() string {
+	var mean = time.Duration(0)
+	if reservations.confirmed > 0 {
+		mean = reservations.confirming / time.Duration(reservations.confirmed)
+	}
+	var summary = fmt.Sprintf("%d grants confirmed in %v on average, longest %v, %d aborted", reservations.confirmed,
+		mean.Round(time.Microsecond), reservations.longest.Round(time.Microsecond), reservations.aborted)
+	if reservations.unconfirmed > 0 {
+		summary += fmt.Sprintf(", %d unconfirmed", reservations.unconfirmed)
+	}
+	return summary
+}
+
+// confirmGrant makes the philosopher take the utensils of a two-phase grant within the confirm timeout, checked by
+// the Ownership and measured by the UtensilWaits as takeUtensils does, and tells the Host whether he took them.
+// When one of them is still held at the timeout he takes none of them and gives the grant back, which he tells with
+// an aborted event, and returns false.
+func (philosopher *Philosopher) confirmGrant(mailbox Mailbox, chopSticks []*ChopStick) bool {
+	var waited, blocking, took = philosopher.allocator.AcquireBefore(chopSticks, time.Now().Add(philosopher.confirmTimeout))
+	if !took {
+		var detail string
+		if !philosopher.events.Quiet() {
+			detail = fmt.Sprintf("the %s %d was still held after %v", blocking.kind, blocking.id, waited.Round(time.Microsecond))
+		}
+		philosopher.emit(eventAborted, detail)
+		mailbox.Tell(Confirm{philosopher: philosopher.id, meal: philosopher.countEating, aborted: true, sent: time.Now()})
+		return false
+	}
+	philosopher.ownership.Acquired(philosopher, chopSticks)
+	philosopher.utensilWaits.Acquired(philosopher, waited, blocking)
+	mailbox.Tell(Confirm{philosopher: philosopher.id, meal: philosopher.countEating, sent: time.Now()})
+	return true
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UtensilKind tells what a utensil is, philosophers need utensils of given kinds to eat
+type UtensilKind string
+
+// Below are the kinds of utensils found on the tables
+const chopStickKind UtensilKind = "chopstick"
+const forkKind UtensilKind = "fork"
+const spoonKind UtensilKind = "spoon"
+const napkinKind UtensilKind = "napkin"
+
+// Below are the allowed variants for the utensils setting of the Config
+const chopSticksVariant = "chopsticks"
+const forksAndSpoonsVariant = "forksAndSpoons"
+
+// ChopStick represents a utensil on the table along with a meachnisme to lock it, it is a chopstick in the
+// classic dinner, and a fork or a spoon in the forks and spoons variant. The id gives the locking order.
+// A ChopStick records how contended it is, the durations being read from the Clock of the dinner :
+// - the requests to eat the Host denied because a neighbor held it
+// - how many times it was locked, how many of them had to wait for it, and for how long
+// - how long it was held
+type ChopStick struct {
+	mutex    sync.Mutex
+	id       int
+	kind     UtensilKind
+	clock    *Clock
+	lockedAt time.Time // guarded by mutex
+	denied   atomic.Int64
+	locks    atomic.Int64
+	waits    atomic.Int64
+	waiting  atomic.Int64
+	maxWait  atomic.Int64
+	holding  atomic.Int64
+	maxHold  atomic.Int64
+}
+
+// Lock takes the utensil, waiting for it when it is held
+func (chopStick *ChopStick) Lock() {
+	chopStick.lock()
+}
+
+// lock takes the utensil, and returns how long it waited for it
+func (chopStick *ChopStick) lock() time.Duration {
+	var start = chopStick.clock.Now()
+	var waited = time.Duration(0)
+	if !chopStick.mutex.TryLock() {
+		chopStick.mutex.Lock()
+		waited = chopStick.clock.Now().Sub(start)
+		chopStick.waits.Add(1)
+		chopStick.waiting.Add(int64(waited))
+		storeMax(&chopStick.maxWait, int64(waited))
+		start = chopStick.clock.Now()
+	}
+	chopStick.locks.Add(1)
+	chopStick.lockedAt = start
+	return waited
+}
+
+// lockBefore takes the utensil unless it is still held at the deadline, trying again every confirmPoll, and returns
+// how long it waited for it along with whether it took it
+func (chopStick *ChopStick) lockBefore(deadline time.Time) (time.Duration, bool) {
+	var start = chopStick.clock.Now()
+	if chopStick.mutex.TryLock() {
+		chopStick.locks.Add(1)
+		chopStick.lockedAt = start
+		return 0, true
+	}
+	var locked = false
+	for !locked && time.Now().Before(deadline) {
+		time.Sleep(confirmPoll)
+		locked = chopStick.mutex.TryLock()
+	}
+	var waited = chopStick.clock.Now().Sub(start)
+	chopStick.waits.Add(1)
+	chopStick.waiting.Add(int64(waited))
+	storeMax(&chopStick.maxWait, int64(waited))
+	if locked {
+		chopStick.locks.Add(1)
+		chopStick.lockedAt = chopStick.clock.Now()
+	}
+	return waited, locked
+}
+
+// Unlock puts the utensil back on the table
+func (chopStick *ChopStick) Unlock() {
+	var held = int64(chopStick.clock.Now().Sub(chopStick.lockedAt))
+	chopStick.holding.Add(held)
+	storeMax(&chopStick.maxHold, held)
+	chopStick.mutex.Unlock()
+}
+
+// Denied counts a request to eat denied because a neighbor held the utensil
+func (chopStick *ChopStick) Denied() {
+	chopStick.denied.Add(1)
+}
+
+// storeMax stores the value if it is larger than the current one
+func storeMax(current *atomic.Int64, value int64) {
+	for {
+		var old = current.Load()
+		if value <= old || current.CompareAndSwap(old, value) {
+			return
+		}
+	}
+}
+
+// Need is one utensil a philosopher requires to eat, any of the candidates within his reach will do
+type Need struct {
+	kind       UtensilKind
+	candidates []*ChopStick
+}
+
+// layUtensils places the utensils on the table according to the configured variant, it returns
+// all the utensils along with the needs of each philosopher :
+// - with chopsticks, there is one chopstick per pair of neighbors and a philosopher needs all the chopsticks he shares
+// - with forks and spoons, the forks and the spoons are two separate pools spread over the pairs of neighbors and a philosopher
+// needs one fork and one spoon among the ones he shares with his neighbors
+// When the table has napkins, they are a third pool spread over the pairs of neighbors, each napkin within reach of
+// the philosophers of an arc of pairs so that every philosopher reaches one, and a philosopher also needs one of them
+func layUtensils(config Config) ([]*ChopStick, [][]Need, error) {
+	var pairs = config.Topology.ChopSticks()
+	var needs = make([][]Need, config.Philosophers)
+	var utensils []*ChopStick
+
+	switch config.Utensils {
+	case chopSticksVariant:
+		for _, owners := range pairs {
+			var chopStick = &ChopStick{id: len(utensils), kind: chopStickKind}
+			utensils = append(utensils, chopStick)
+			for _, owner := range owners {
+				needs[owner] = append(needs[owner], Need{kind: chopStickKind, candidates: []*ChopStick{chopStick}})
+			}
+		}
+	case forksAndSpoonsVariant:
+		var reachable = make([]map[UtensilKind][]*ChopStick, config.Philosophers)
+		for philosopher := range reachable {
+			reachable[philosopher] = make(map[UtensilKind][]*ChopStick)
+		}
+		var pools = []struct {
+			kind  UtensilKind
+			count int
+		}{{forkKind, config.Forks}, {spoonKind, config.Spoons}}
+		for _, pool := range pools {
+			for i := 0; i < pool.count && len(pairs) > 0; i++ {
+				var utensil = &ChopStick{id: len(utensils), kind: pool.kind}
+				utensils = append(utensils, utensil)
+				for _, owner := range pairs[i*len(pairs)/pool.count] {
+					reachable[owner][pool.kind] = append(reachable[owner][pool.kind], utensil)
+				}
+			}
+		}
+		for philosopher := range needs {
+			for _, pool := range pools {
+				if len(reachable[philosopher][pool.kind]) == 0 {
+					return nil, nil, fmt.Errorf("config: philosopher %d cannot reach any %s", philosopher, pool.kind)
+				}
+				needs[philosopher] = append(needs[philosopher], Need{kind: pool.kind, candidates: reachable[philosopher][pool.kind]})
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("config: unknown utensils %q, expected %q or %q", config.Utensils, chopSticksVariant, forksAndSpoonsVariant)
+	}
+
+	if config.Napkins > 0 {
+		var reachable = make([][]*ChopStick, config.Philosophers)
+		for i := 0; i < config.Napkins && len(pairs) > 0; i++ {
+			var napkin = &ChopStick{id: len(utensils), kind: napkinKind}
+			utensils = append(utensils, napkin)
+			var first = i * len(pairs) / config.Napkins
+			var last = max((i+1)*len(pairs)/config.Napkins, first+1)
+			for _, owners := range pairs[first:last] {
+				for _, owner := range owners {
+					if !containsChopStick(reachable[owner], napkin) {
+						reachable[owner] = append(reachable[owner], napkin)
+					}
+				}
+			}
+		}
+		for philosopher := range needs {
+			if len(reachable[philosopher]) == 0 {
+				return nil, nil, fmt.Errorf("config: philosopher %d cannot reach any %s", philosopher, napkinKind)
+			}
+			needs[philosopher] = append(needs[philosopher], Need{kind: napkinKind, candidates: reachable[philosopher]})
+		}
+	}
+
+	return utensils, needs, nil
+}
+
+// Seating is what the Hosts compute once about the seats of a table, so that deciding on a request only looks
+// at the seats around the philosopher :
+// - neighbors holds, for each seat, the seats which may need one of his utensils and are watched by the same Host
+// - utensils holds, for each seat, the utensils he needs sorted in locking order, only with chopsticks where a
+// philosopher always needs the same utensils, nil in the forks and spoons variant and with napkins where the Host picks them
+// - crossings holds, for each seat, the chopsticks he shares with the seats of other shards, which the Hosts claim
+// before letting him eat since they do not know who eats in the other shards, and all his chopsticks with the lock-free
+// admission where the philosophers claim them without asking the Host
+type Seating struct {
+	neighbors [][]int
+	utensils  [][]*ChopStick
+	crossings [][]Crossing
+}
+
+// Crossing is a chopstick shared with a neighbor, which is claimed rather than watched by the Host
+type Crossing struct {
+	chopStick *ChopStick
+	neighbor  int
+}
+
+// NewSeating computes the Seating of the given philosophers, fixed telling if each of them always needs the same
+// utensils, claimed tells if the chopsticks shared by two seats are claimed rather than watched by the Host
+func NewSeating(philosophers []*Philosopher, fixed bool, claimed func(seat, neighbor int) bool) Seating {
+	var owners = make(map[*ChopStick][]int)
+	for _, philosopher := range philosophers {
+		for _, need := range philosopher.needs {
+			for _, candidate := range need.candidates {
+				owners[candidate] = append(owners[candidate], philosopher.id)
+			}
+		}
+	}
+
+	var seating = Seating{
+		neighbors: make([][]int, len(philosophers)),
+		utensils:  make([][]*ChopStick, len(philosophers)),
+		crossings: make([][]Crossing, len(philosophers))}
+	for seat, philosopher := range philosophers {
+		for _, need := range philosopher.needs {
+			for _, candidate := range need.candidates {
+				for _, owner := range owners[candidate] {
+					if owner == seat || contains(seating.neighbors[seat], owner) {
+						continue
+					}
+					if claimed(seat, owner) {
+						seating.crossings[seat] = append(seating.crossings[seat], Crossing{chopStick: candidate, neighbor: owner})
+					} else {
+						seating.neighbors[seat] = append(seating.neighbors[seat], owner)
+					}
+				}
+			}
+		}
+		if fixed {
+			for _, need := range philosopher.needs {
+				seating.utensils[seat] = append(seating.utensils[seat], need.candidates[0])
+			}
+			sort.Slice(seating.utensils[seat], func(i, j int) bool { return seating.utensils[seat][i].id < seating.utensils[seat][j].id })
+		}
+	}
+	return seating
+}
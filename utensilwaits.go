@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// UtensilWaits measures how long the philosophers of a table take to lock the utensils of their meals once they are
+// allowed to eat, the time to acquire : a philosopher quickly allowed to eat by the Host can still wait for a utensil
+// whose previous holder has not put it back yet, or for the PairAllocator with the atomic acquisition, and the
+// philosophers eating without the Host with the lock-free admission may find their utensils taken.
+// Each acquisition which waited for threshold or longer is told by a chopstickWait event.
+// A nil UtensilWaits means that the acquisitions are not measured.
+type UtensilWaits struct {
+	threshold    time.Duration
+	acquisitions atomic.Int64
+	slow         atomic.Int64
+	waited       atomic.Int64
+	longest      atomic.Int64
+}
+
+// NewUtensilWaits creates the UtensilWaits of a table, nil unless the configuration sets chopstickWait
+func NewUtensilWaits(config Config) *UtensilWaits {
+	if config.ChopstickWait == 0 {
+		return nil
+	}
+	return &UtensilWaits{threshold: config.Scale(time.Duration(config.ChopstickWait))}
+}
+
+// Acquired records that the philosopher locked the utensils of his meal after waiting for the blocking one, nil when
+// he did not wait
+func (waits *UtensilWaits) Acquired(philosopher *Philosopher, waited time.Duration, blocking *ChopStick) {
+	if waits == nil {
+		return
+	}
+	waits.acquisitions.Add(1)
+	waits.waited.Add(int64(waited))
+	storeMax(&waits.longest, int64(waited))
+	if waited < waits.threshold || blocking == nil {
+		return
+	}
+	waits.slow.Add(1)
+	var detail string
+	if !philosopher.events.Quiet() {
+		detail = fmt.Sprintf("%v for the %s %d", waited.Round(time.Microsecond), blocking.kind, blocking.id)
+	}
+	philosopher.emit(eventChopstickWait, detail)
+}
+
+// String reports how long the acquisitions waited
+func (waits *UtensilWaits) String() string {
+	var acquisitions = waits.acquisitions.Load()
+	return fmt.Sprintf("%d of %d acquisitions waited %v or longer for a utensil, %v in total, longest %v", waits.slow.Load(), acquisitions,
+		waits.threshold, time.Duration(waits.waited.Load()).Round(time.Microsecond), time.Duration(waits.longest.Load()).Round(time.Microsecond))
+}
@@ -0,0 +1,254 @@
+//go:build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// EngineRun is a dinner run by one of the engines for the verify command :
+// - its Result, and the reports of the philosophers
+// - the answers of the Hosts to each philosopher, in the order he received them
+// - the illegal transitions of the phases of the philosophers, which none of the engines should allow
+// - how long it took
+type EngineRun struct {
+	engine     string
+	result     Result
+	reports    []PhilosopherReport
+	answers    map[string][]EventKind
+	violations []string
+	elapsed    time.Duration
+}
+
+// verify is the verify command, it runs the same dinner with the same seed through the concurrent engine and through
+// the discrete engine, then compares the answers of the Hosts to each philosopher and the statistics of both dinners.
+// The answers depend on when the requests reach the Host, which the concurrent engine does not control, so they
+// may diverge after a while : the verification fails when a philosopher eats a different number of meals or moves
+// from a phase to another one his PhaseMachine rejects, which none of the engines should allow, or when a statistic
+// differs by more than the tolerance.
+// Given a bundle, it runs the dinner of the bundle again instead, which must give the outcome it expects (see Bundle).
+func verify(arguments []string) error {
+	var flags = flag.NewFlagSet("verify", flag.ExitOnError)
+	var configFile = flags.String("config", "", "JSON file describing the dinner, see the -config flag of the program")
+	var topology = flags.String("topology", "", "seating of the philosophers, ring:N, grid:WxH or torus:WxH (overrides the config file)")
+	var seed = flags.Int64("seed", 0, "seed of both dinners, the one of the config file or a random one by default")
+	var tolerance = flags.Float64("tolerance", 0.25, "largest relative difference allowed between the statistics of both engines")
+	var bundlePath = flags.String("bundle", "", "archive written by the bundle command, whose dinner is run again instead of comparing the engines")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s verify [-config dinner.json] [-seed 42] [-tolerance 0.25]\n       %s verify -bundle puzzle.tar.gz\n",
+			os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+	if *bundlePath != "" {
+		if *configFile != "" || *topology != "" || *seed != 0 {
+			return fmt.Errorf("verify: a bundle holds its dinner, -bundle cannot be combined with -config, -topology nor -seed")
+		}
+		return verifyBundle(*bundlePath)
+	}
+
+	var overrides Config
+	if *topology != "" {
+		parsed, err := ParseTopology(*topology)
+		if err != nil {
+			return err
+		}
+		overrides.Topology = parsed
+	}
+	config, err := LoadConfig(*configFile, overrides)
+	if err != nil {
+		return err
+	}
+	if *seed != 0 {
+		config.Seed = *seed
+	}
+
+	fmt.Printf("Verifying %d philosophers with the seed %d\n", config.Philosophers*config.Tables, config.Seed)
+	var runs []EngineRun
+	for _, engine := range []string{concurrentEngine, discreteEngine} {
+		config.Engine = engine
+		if err := config.Validate(); err != nil {
+			return fmt.Errorf("verify: %v", err)
+		}
+		runs = append(runs, runEngine(engine, config))
+	}
+
+	var divergences = compareAnswers(runs[0], runs[1])
+	var failures = compareStatistics(runs[0], runs[1], *tolerance)
+	for _, run := range runs {
+		for _, violation := range run.violations {
+			failures = append(failures, fmt.Sprintf("%s with the %s engine", violation, run.engine))
+		}
+	}
+	for _, divergence := range divergences {
+		fmt.Println(divergence)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("verify: the engines diverge :\n  %s", strings.Join(failures, "\n  "))
+	}
+	fmt.Println("Both engines agree")
+	return nil
+}
+
+// runEngine runs the dinner with the given engine, quietly, recording the answers of the Hosts
+func runEngine(engine string, config Config) EngineRun {
+	var run = EngineRun{engine: engine, answers: make(map[string][]EventKind)}
+	var simulation = NewSimulation(config)
+	var recorder = NewRecorder(false)
+	simulation.Events().SetQuiet()
+	simulation.Events().Handle(recorder.Record)
+	simulation.Events().Handle(func(event Event) {
+		// the handlers are called one event at a time
+		if event.Kind == eventAccepted || event.Kind == eventRejected || event.Kind == eventThrottled {
+			run.answers[event.Name] = append(run.answers[event.Name], event.Kind)
+		}
+	})
+
+	var start = time.Now()
+	run.result = simulation.Run()
+	run.elapsed = time.Since(start)
+	run.reports = recorder.Reports()
+	run.violations = simulation.State().Violations
+	return run
+}
+
+// compareAnswers tells how many philosophers received the same answers from the Hosts in both runs,
+// and where the answers of the first philosophers whose answers differ diverge
+func compareAnswers(a, b EngineRun) []string {
+	var names []string
+	for _, report := range a.reports {
+		names = append(names, report.Name)
+	}
+
+	var same = 0
+	var divergences []string
+	for _, name := range names {
+		var answersA, answersB = a.answers[name], b.answers[name]
+		var index = 0
+		for index < len(answersA) && index < len(answersB) && answersA[index] == answersB[index] {
+			index++
+		}
+		if index == len(answersA) && index == len(answersB) {
+			same++
+			continue
+		}
+		if len(divergences) < 5 {
+			divergences = append(divergences, fmt.Sprintf("  philosopher %s diverges at answer %d : %s with the %s engine, %s with the %s engine",
+				name, index, answerAt(answersA, index), a.engine, answerAt(answersB, index), b.engine))
+		}
+	}
+	return append([]string{fmt.Sprintf("Answers of the Hosts : %d of %d philosophers got the same answers", same, len(names))}, divergences...)
+}
+
+// answerAt returns the answer of the given index, if any
+func answerAt(answers []EventKind, index int) string {
+	if index < len(answers) {
+		return string(answers[index])
+	}
+	return "no more answer"
+}
+
+// compareStatistics prints the statistics of both runs side by side, and returns the differences which are not allowed :
+// the meals eaten by each philosopher who did not starve, and the statistics differing by more than the tolerance
+func compareStatistics(a, b EngineRun, tolerance float64) []string {
+	var failures []string
+	var writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "\t%s\t%s\tdifference\n", a.engine, b.engine)
+	fmt.Fprintf(writer, "duration\t%v\t%v\tx%.0f faster\n", a.elapsed.Round(time.Millisecond), b.elapsed.Round(time.Microsecond),
+		a.elapsed.Seconds()/math.Max(b.elapsed.Seconds(), 1e-9))
+	var formattedRow = func(label string, format string, valueA, valueB float64, checked bool) {
+		var difference = math.Abs(valueA-valueB) / math.Max(math.Max(math.Abs(valueA), math.Abs(valueB)), 1)
+		fmt.Fprintf(writer, "%s\t"+format+"\t"+format+"\t%.0f%%\n", label, valueA, valueB, difference*100)
+		if checked && difference > tolerance {
+			failures = append(failures, fmt.Sprintf("%s differs by %.0f%%, more than %.0f%%", label, difference*100, tolerance*100))
+		}
+	}
+	var row = func(label string, valueA, valueB float64, checked bool) {
+		formattedRow(label, "%g", valueA, valueB, checked)
+	}
+
+	var statsA, statsB = statsOf(a.result), statsOf(b.result)
+	row("meals", float64(mealsOf(a.reports)), float64(mealsOf(b.reports)), false)
+	row("requests accepted", float64(statsA.accepted+statsA.admitted), float64(statsB.accepted+statsB.admitted), false)
+	row("requests rejected", float64(rejectedOf(statsA)), float64(rejectedOf(statsB)), true)
+	var causes = make(map[string]bool)
+	for cause := range statsA.rejected {
+		causes[cause] = true
+	}
+	for cause := range statsB.rejected {
+		causes[cause] = true
+	}
+	var sorted []string
+	for cause := range causes {
+		sorted = append(sorted, cause)
+	}
+	sort.Strings(sorted)
+	for _, cause := range sorted {
+		row("  rejected for "+cause, float64(statsA.rejected[cause]), float64(statsB.rejected[cause]), false)
+	}
+	row("meals paused", float64(statsA.paused), float64(statsB.paused), false)
+	row("mean waiting (ms)", meanWaiting(a.reports), meanWaiting(b.reports), true)
+	var fairnessA, fairnessB = NewFairness(a.reports), NewFairness(b.reports)
+	formattedRow("fairness (Jain index)", "%.3f", fairnessA.Index, fairnessB.Index, false)
+	formattedRow("latency max/min", "%.3f", fairnessA.LatencyRatio, fairnessB.LatencyRatio, false)
+	row("starved", float64(len(statsA.starved)), float64(len(statsB.starved)), true)
+	writer.Flush()
+
+	for i := range a.reports {
+		var reportA, reportB = a.reports[i], b.reports[i]
+		if !reportA.Starved && !reportB.Starved && reportA.Meals != reportB.Meals {
+			failures = append(failures, fmt.Sprintf("philosopher %s ate %d meals with the %s engine and %d with the %s engine",
+				reportA.Name, reportA.Meals, a.engine, reportB.Meals, b.engine))
+		}
+	}
+	return failures
+}
+
+// statsOf sums the Stats of the tables of a dinner
+func statsOf(result Result) Stats {
+	var stats = newStats()
+	for _, table := range result.Tables {
+		stats.add(table.stats)
+		stats.admitted += table.stats.admitted
+	}
+	return stats
+}
+
+// rejectedOf sums the requests rejected whatever their cause
+func rejectedOf(stats Stats) int {
+	var rejected = 0
+	for _, count := range stats.rejected {
+		rejected += count
+	}
+	return rejected
+}
+
+// mealsOf sums the meals eaten by the philosophers
+func mealsOf(reports []PhilosopherReport) int {
+	var meals = 0
+	for _, report := range reports {
+		meals += report.Meals
+	}
+	return meals
+}
+
+// meanWaiting is the mean time a philosopher waited for a meal, in milliseconds
+func meanWaiting(reports []PhilosopherReport) float64 {
+	var waiting time.Duration
+	var meals = 0
+	for _, report := range reports {
+		waiting += report.Waiting
+		meals += report.Meals
+	}
+	if meals == 0 {
+		return 0
+	}
+	return math.Round(waiting.Seconds() * 1000 / float64(meals))
+}
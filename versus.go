@@ -0,0 +1,184 @@
+//go:build !js
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const versusWidth = 44                      // columns of each side of the versus view
+const versusRedraw = 100 * time.Millisecond // how often the versus view is redrawn on a terminal
+
+// VersusSide is one of the two dinners of the versus command, the same dinner served with another strategy : its
+// events, simulated beforehand by the discrete engine, are replayed up to the virtual time shown, a StateTracker
+// telling what each philosopher is doing then, along with the meals eaten and the requests turned down so far
+type VersusSide struct {
+	Strategy string
+	events   []Event
+	next     int
+	tracker  *StateTracker
+	recorder *Recorder
+	eaten    int
+	rejected int
+	span     time.Duration
+	result   Result
+}
+
+// NewVersusSide simulates the dinner of the configuration served with the strategy by the discrete engine, whose
+// Clock gives the virtual time of its events, and keeps its events to replay them
+func NewVersusSide(config Config, strategy string) (*VersusSide, error) {
+	config.Strategy, config.Engine = strategy, discreteEngine
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("versus: %s: %v", strategy, err)
+	}
+	var side = &VersusSide{Strategy: strategy, tracker: NewStateTracker(config.SeatMeals()), recorder: NewRecorder(false)}
+	var simulation = NewSimulation(config)
+	simulation.Events().SetQuiet()
+	simulation.Events().Handle(side.recorder.Record)
+	simulation.Events().Handle(func(event Event) {
+		// the handlers are called one event at a time
+		side.events = append(side.events, event)
+	})
+	side.result = simulation.Run()
+	if len(side.events) > 0 {
+		side.span = side.events[len(side.events)-1].Elapsed
+	}
+	return side, nil
+}
+
+// Advance replays the events which happened up to the virtual time, from the start of the dinner
+func (side *VersusSide) Advance(now time.Duration) {
+	for side.next < len(side.events) && side.events[side.next].Elapsed <= now {
+		var event = side.events[side.next]
+		side.tracker.Track(event)
+		switch event.Kind {
+		case eventFinished:
+			side.eaten++
+		case eventRejected, eventThrottled:
+			side.rejected++
+		}
+		side.next++
+	}
+}
+
+// Lines renders the side at the virtual time reached : its strategy, the meals eaten and the requests turned down so
+// far, then a line per philosopher telling his phase and a # per meal he ate
+func (side *VersusSide) Lines() []string {
+	var state = side.tracker.State()
+	var lines = []string{side.Strategy, fmt.Sprintf("%d meals eaten, %d requests turned down", side.eaten, side.rejected), ""}
+	for _, philosopher := range state.Philosophers {
+		var name = philosopher.Name
+		if len(name) > 12 {
+			name = name[:12]
+		}
+		lines = append(lines, fmt.Sprintf("%-12s %-8s %s", name, philosopher.Phase, strings.Repeat("#", min(philosopher.MealsEaten, versusWidth-22))))
+	}
+	return lines
+}
+
+// Summary tells how the dinner went with the strategy : when it ended in virtual time, the meals eaten, the requests
+// turned down, the fairness of the waits and the starved or failed philosophers
+func (side *VersusSide) Summary() string {
+	var summary = fmt.Sprintf("%s : %d meals in %v, %d requests turned down, fairness %.3f", side.Strategy, side.eaten,
+		side.span.Round(time.Millisecond), side.rejected, NewFairness(side.recorder.Reports()).Index)
+	if starved := side.result.Starved(); len(starved) > 0 {
+		summary += fmt.Sprintf(", starved %s", strings.Join(starved, ", "))
+	}
+	if failures := side.result.Failures(); len(failures) > 0 {
+		summary += fmt.Sprintf(", failed %s", failuresSummary(failures))
+	}
+	return summary
+}
+
+// writeVersus writes both sides next to each other at the virtual time now, out of the span of the longest dinner
+func writeVersus(w io.Writer, a, b *VersusSide, now, span time.Duration) {
+	fmt.Fprintf(w, "Virtual time %v / %v\n\n", now.Round(time.Millisecond), span.Round(time.Millisecond))
+	var left, right = a.Lines(), b.Lines()
+	for i := 0; i < max(len(left), len(right)); i++ {
+		var line string
+		if i < len(left) {
+			line = left[i]
+		}
+		if i < len(right) {
+			line = fmt.Sprintf("%-*s| %s", versusWidth, line, right[i])
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// versus is the versus command, it serves the dinner of the config file with two strategies, with the same seed,
+// and shows both tables side by side, their virtual times moving together, so that the strategies can be told
+// apart at a glance. Both dinners are simulated at once by the discrete engine, then played at the given rate of
+// their virtual time, redrawn on a terminal, only their end being written otherwise.
+func versus(arguments []string) error {
+	var flags = flag.NewFlagSet("versus", flag.ExitOnError)
+	var configFile = flags.String("config", "", "JSON file describing the dinner, see the -config flag of the program")
+	var strategies = flags.String("strategies", greedyStrategy+","+planStrategy, "the two strategies to compare, separated by a comma")
+	var seed = flags.Int64("seed", 0, "seed of both dinners, the one of the config file or a random one by default")
+	var rate = flags.String("rate", "1x", "how fast the virtual time is played, such as 0.5x to watch the dinners twice slower")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s versus [-config dinner.json] [-strategies greedy,plan] [-rate 1x]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	var names = strings.Split(*strategies, ",")
+	if len(names) != 2 {
+		return fmt.Errorf("versus: two strategies are compared, got %q", *strategies)
+	}
+	playback, err := ParseSpeed(*rate)
+	if err != nil {
+		return err
+	}
+	config, err := LoadConfig(*configFile, Config{})
+	if err != nil {
+		return err
+	}
+	if *seed != 0 {
+		config.Seed = *seed
+	}
+
+	var sides [2]*VersusSide
+	var errs [2]error
+	var wait sync.WaitGroup
+	for i, name := range names {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			sides[i], errs[i] = NewVersusSide(config, name)
+		}()
+	}
+	wait.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	var a, b = sides[0], sides[1]
+	var span = max(a.span, b.span)
+
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		var ticker = time.NewTicker(versusRedraw)
+		defer ticker.Stop()
+		var start = time.Now()
+		for now := time.Duration(0); now < span; <-ticker.C {
+			now = min(time.Duration(float64(time.Since(start))*playback), span)
+			a.Advance(now)
+			b.Advance(now)
+			fmt.Print("\033[H\033[2J")
+			writeVersus(os.Stdout, a, b, now, span)
+		}
+	} else {
+		a.Advance(span)
+		b.Advance(span)
+		writeVersus(os.Stdout, a, b, span, span)
+	}
+	fmt.Printf("\nSeed %d\n%s\n%s\n", config.Seed, a.Summary(), b.Summary())
+	return nil
+}
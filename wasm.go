@@ -0,0 +1,117 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+)
+
+// browserSimulation is the dinner taking place in the web page, nil until start is called
+var browserSimulation *Simulation
+
+// Start of the program when built for the browser (GOOS=js GOARCH=wasm), see web/index.html
+// It exposes to JavaScript a global philosophers object with :
+// - start(config, onEvent, onDone) starting a dinner described by a JSON configuration, in the format of the -config file,
+// onEvent is called with each event and the phase of the philosopher it names once it happened, and onDone with the summary of each table once the dinner is over,
+// start returns the configuration completed with its defaults, or an object with an error when it is not valid
+// - pause(), resume(), step() and stop() controlling the dinner
+// - state() returning what the philosophers are doing
+func main() {
+	var bridge = map[string]any{
+		"start": js.FuncOf(startInBrowser),
+		"pause": js.FuncOf(func(js.Value, []js.Value) any {
+			if browserSimulation != nil {
+				browserSimulation.Pause()
+			}
+			return nil
+		}),
+		"resume": js.FuncOf(func(js.Value, []js.Value) any {
+			if browserSimulation != nil {
+				browserSimulation.Resume()
+			}
+			return nil
+		}),
+		"step": js.FuncOf(func(js.Value, []js.Value) any {
+			if browserSimulation != nil {
+				browserSimulation.Step()
+			}
+			return nil
+		}),
+		"stop": js.FuncOf(func(js.Value, []js.Value) any {
+			if browserSimulation != nil {
+				// the Hosts may be busy, and a function called by JavaScript must not block
+				go browserSimulation.Stop()
+			}
+			return nil
+		}),
+		"state": js.FuncOf(func(js.Value, []js.Value) any {
+			if browserSimulation == nil {
+				return nil
+			}
+			return toJavaScript(browserSimulation.State())
+		}),
+	}
+	js.Global().Set("philosophers", js.ValueOf(bridge))
+
+	// the functions above are called by the page until it is closed
+	select {}
+}
+
+// startInBrowser is the start function of the philosophers object, the previous dinner is stopped
+func startInBrowser(this js.Value, arguments []js.Value) any {
+	if len(arguments) < 1 {
+		return failure("start needs a configuration")
+	}
+	config, err := ParseConfig([]byte(arguments[0].String()), Config{})
+	if err != nil {
+		return failure(err.Error())
+	}
+
+	if browserSimulation != nil {
+		go browserSimulation.Stop()
+	}
+	var simulation = NewSimulation(config)
+	browserSimulation = simulation
+	if len(arguments) > 1 && arguments[1].Type() == js.TypeFunction {
+		var onEvent = arguments[1]
+		simulation.Events().Handle(func(event Event) {
+			// the StateTracker of the simulation handles the event first
+			onEvent.Invoke(toJavaScript(event), string(simulation.state.Phase(event.Name)))
+		})
+	}
+	var onDone js.Value
+	if len(arguments) > 2 && arguments[2].Type() == js.TypeFunction {
+		onDone = arguments[2]
+	}
+
+	simulation.Observe()
+	simulation.Start()
+	go func() {
+		var result = simulation.Wait()
+		if onDone.IsUndefined() {
+			return
+		}
+		var summary []any
+		for _, table := range result.Tables {
+			summary = append(summary, fmt.Sprintf("Table %d : %s", table.id, table.stats))
+		}
+		onDone.Invoke(js.ValueOf(summary))
+	}()
+	return toJavaScript(config)
+}
+
+// toJavaScript converts a value to a JavaScript object through JSON
+func toJavaScript(value any) js.Value {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return js.Null()
+	}
+	return js.Global().Get("JSON").Call("parse", string(data))
+}
+
+// failure is what a function of the philosophers object returns when it fails, a Go panic would end the program
+func failure(message string) any {
+	return js.ValueOf(map[string]any{"error": message})
+}
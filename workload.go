@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Workload replaces the meals of the philosophers : instead of eating for a while, a philosopher allowed to eat calls
+// it, holding his utensils until it returns, so that the Hosts, their strategies and their metrics drive a real load,
+// such as requests to an endpoint or a lock of an application, with the contention pattern of the dinner.
+// The context is cancelled when the meal is interrupted, by a preemption or by the end of the dinner, and the
+// philosopher only gives back his utensils once the Workload has returned. A paused meal calls it again from the start.
+type Workload func(ctx context.Context, meal WorkloadMeal) error
+
+// WorkloadMeal tells a Workload who is eating : the table, the seat and the name of the philosopher, the meal he eats
+// and the utensils he holds meanwhile
+type WorkloadMeal struct {
+	Table       int
+	Philosopher int
+	Name        string
+	Meal        int
+	Utensils    []string
+}
+
+// errWorkloadDiscrete tells that the discrete engine, which simulates the time, cannot wait for a Workload
+var errWorkloadDiscrete = errors.New("workload: the discrete engine simulates the meals, a workload needs the concurrent engine")
+
+// SetWorkload makes the philosophers of all the tables call the Workload instead of eating, it must be called before
+// Start. The workload needs the goroutines of the philosophers, it cannot run in the discrete engine, the worker
+// pool nor the open mode.
+func (simulation *Simulation) SetWorkload(workload Workload) error {
+	simulation.mutex.Lock()
+	defer simulation.mutex.Unlock()
+	switch {
+	case simulation.started:
+		return errors.New("workload: the dinner has already started")
+	case simulation.engine != nil:
+		return errWorkloadDiscrete
+	case simulation.config.Execution != goroutinesExecution:
+		return fmt.Errorf("workload: the %s execution runs the philosophers in turns, a workload needs the %s execution",
+			simulation.config.Execution, goroutinesExecution)
+	case simulation.config.ArrivalRate > 0:
+		return errors.New("workload: the guests of the open mode cannot run a workload")
+	}
+	for _, table := range simulation.tables {
+		for _, philosopher := range table.philosophers {
+			philosopher.workload = workload
+		}
+	}
+	return nil
+}
+
+// work calls the Workload of the philosopher for his current meal in its own goroutine, the returned channel tells
+// its error once it has returned, a workloadPanic when it panicked, it is nil without Workload so that the meal only
+// ends with its timer
+func (philosopher Philosopher) work(ctx context.Context, chopSticks []*ChopStick) <-chan error {
+	if philosopher.workload == nil {
+		return nil
+	}
+	var meal = WorkloadMeal{Table: philosopher.table, Philosopher: philosopher.id, Name: philosopher.name, Meal: philosopher.countEating}
+	for _, chopStick := range chopSticks {
+		meal.Utensils = append(meal.Utensils, fmt.Sprintf("%s %d", chopStick.kind, chopStick.id))
+	}
+	var done = make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- workloadPanic{value: recovered, stack: goroutineStack()}
+			}
+		}()
+		done <- philosopher.workload(ctx, meal)
+	}()
+	return done
+}
+
+// workloadPanic is the panic of a Workload, caught in its goroutine to be raised again by the philosopher calling it,
+// whose recovery fails him (see recoverFailure) along with the stack of the Workload
+type workloadPanic struct {
+	value any
+	stack []byte
+}
+
+// Error tells the value of the panic
+func (failure workloadPanic) Error() string {
+	return fmt.Sprintf("workload panicked: %v", failure.value)
+}
+
+// LoadGenerator is the Simulation used as a load generator : the philosophers call its Workload instead of eating,
+// and it measures each call along with the metrics of the dinner. Embedding programs create it from a Config and
+// their Workload, observe its EventBus and its State as those of any Simulation, and Run it.
+type LoadGenerator struct {
+	*Simulation
+	mutex     sync.Mutex
+	latencies []time.Duration
+	failures  map[string]int
+	started   time.Time
+}
+
+// LoadReport tells how the calls of a LoadGenerator went :
+// - how many calls returned, how many of them failed, and how many failed with each error
+// - the mean and the percentiles of the latency of the calls
+// - how long the load lasted, and the calls returned per second
+// - the Result of the dinner, which tells the rejections, the waits and the starvations as for any dinner
+type LoadReport struct {
+	Calls      int
+	Failures   int
+	Errors     map[string]int
+	Mean       time.Duration
+	P50        time.Duration
+	P99        time.Duration
+	Duration   time.Duration
+	Throughput float64
+	Result     Result
+}
+
+// NewLoadGenerator prepares the dinner of a validated configuration, whose philosophers call the Workload instead of
+// eating, nothing happens until Start or Run is called
+func NewLoadGenerator(config Config, workload Workload) (*LoadGenerator, error) {
+	if workload == nil {
+		return nil, errors.New("workload: missing workload")
+	}
+	var generator = &LoadGenerator{Simulation: NewSimulation(config), failures: make(map[string]int)}
+	var measured = func(ctx context.Context, meal WorkloadMeal) error {
+		var start = time.Now()
+		var err = workload(ctx, meal)
+		generator.record(time.Since(start), err)
+		return err
+	}
+	if err := generator.SetWorkload(measured); err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+// record keeps the latency of a call and its error
+func (generator *LoadGenerator) record(latency time.Duration, err error) {
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+	generator.latencies = append(generator.latencies, latency)
+	if err != nil {
+		generator.failures[err.Error()]++
+	}
+}
+
+// Start starts the dinner, and with it the load
+func (generator *LoadGenerator) Start() {
+	generator.mutex.Lock()
+	generator.started = time.Now()
+	generator.mutex.Unlock()
+	generator.Simulation.Start()
+}
+
+// Run starts the load and waits for all the meals to be eaten, or the dinner to be stopped, then reports it
+func (generator *LoadGenerator) Run() LoadReport {
+	generator.Start()
+	return generator.Report(generator.Wait())
+}
+
+// Report tells how the calls went so far, along with the Result of the dinner once it is over
+func (generator *LoadGenerator) Report(result Result) LoadReport {
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+	var latencies = append([]time.Duration(nil), generator.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var report = LoadReport{Calls: len(latencies), Errors: make(map[string]int), Duration: time.Since(generator.started), Result: result}
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	for message, count := range generator.failures {
+		report.Errors[message] = count
+		report.Failures += count
+	}
+	if len(latencies) > 0 {
+		report.Mean = meanDuration(total, int64(len(latencies)))
+		report.P50 = latencies[min(len(latencies)-1, len(latencies)*50/100)]
+		report.P99 = latencies[min(len(latencies)-1, len(latencies)*99/100)]
+	}
+	if report.Duration > 0 {
+		report.Throughput = float64(report.Calls) / report.Duration.Seconds()
+	}
+	return report
+}
+
+// String gives a one line summary of the calls
+func (report LoadReport) String() string {
+	return fmt.Sprintf("%d calls in %v (%.2f calls/s), %d failed, latency mean %v p50 %v p99 %v", report.Calls,
+		report.Duration.Round(time.Millisecond), report.Throughput, report.Failures, report.Mean.Round(time.Microsecond),
+		report.P50.Round(time.Microsecond), report.P99.Round(time.Microsecond))
+}